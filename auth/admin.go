@@ -0,0 +1,188 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultAdminScope is the scope admin API tokens must carry unless admin.scope says otherwise
+// Grant it by adding it to an operator client's allowed_scopes
+const DefaultAdminScope = "auth-server:admin"
+
+// DefaultAdminAddress keeps the admin listener on loopback unless admin.address says otherwise
+const DefaultAdminAddress = "127.0.0.1:8081"
+
+// minBootstrapTokenLength keeps admin.bootstrap_token out of guessing range
+const minBootstrapTokenLength = 32
+
+// bootstrapActor is the audit subject of requests made with admin.bootstrap_token
+const bootstrapActor = "admin:bootstrap"
+
+// adminActorKey is the gin context key holding the authenticated admin's Actor
+const adminActorKey = "admin_actor"
+
+// requireAdmin admits requests whose bearer token carries the admin scope, or is admin.bootstrap_token
+func (as *authServer) requireAdmin(c *gin.Context) {
+	logger := GetRequestLogger(c)
+
+	if isBootstrapToken(c.Request.Header.Get("Authorization")) {
+		actor := Actor{Subject: bootstrapActor, RequestID: GetRequestID(c), IP: clientIP(c)}
+		logger.Warn().Str("path", c.Request.URL.Path).Msg("Admin API called with the bootstrap token")
+		as.auditActor(actor, AuditEntry{Action: AuditAdminAccess, Detail: c.Request.Method + " " + c.Request.URL.Path + ": bootstrap token"})
+		c.Set(adminActorKey, actor)
+		c.Next()
+		return
+	}
+
+	claims, ok := as.authenticateBearer(c)
+	if !ok {
+		as.auditRequest(c, AuditEntry{Action: AuditAdminAccess, Outcome: AuditOutcomeFailure, Detail: c.Request.Method + " " + c.Request.URL.Path + ": invalid bearer token"})
+		c.Abort()
+		return
+	}
+
+	if !slices.Contains(claims.Scope, AppConfig.Admin.Scope) {
+		logger.Warn().Str("client_id", claims.ClientID).Msg("Token lacks admin scope")
+		as.auditRequest(c, AuditEntry{Action: AuditAdminAccess, Outcome: AuditOutcomeFailure, Actor: claims.ClientID,
+			ClientID: claims.ClientID, TokenID: claims.TokenID, Detail: c.Request.Method + " " + c.Request.URL.Path + ": missing admin scope"})
+		RespondWithError(c, ErrForbiddenError("Token lacks the "+AppConfig.Admin.Scope+" scope"))
+		c.Abort()
+		return
+	}
+
+	c.Set(adminActorKey, Actor{
+		Subject:   claims.ClientID,
+		RequestID: GetRequestID(c),
+		IP:        clientIP(c),
+	})
+	c.Next()
+}
+
+// isBootstrapToken reports whether an Authorization header carries the configured bootstrap token
+func isBootstrapToken(authHeader string) bool {
+	bootstrap := AppConfig.Admin.BootstrapToken
+	token, found := strings.CutPrefix(authHeader, "Bearer ")
+	return bootstrap != "" && found && subtle.ConstantTimeCompare([]byte(token), []byte(bootstrap)) == 1
+}
+
+// adminActor returns the admin that requireAdmin authenticated
+func adminActor(c *gin.Context) Actor {
+	if actor, exists := c.Get(adminActorKey); exists {
+		return actor.(Actor)
+	}
+	return Actor{Subject: "unknown", RequestID: GetRequestID(c), IP: clientIP(c)}
+}
+
+// rotateKeyHandler retires the active signing key and creates a new one
+func (as *authServer) rotateKeyHandler(c *gin.Context) {
+	logger := GetRequestLogger(c)
+
+	if as.keyRing == nil {
+		RespondWithError(c, ErrConflictError("Key rotation requires jwt.signing_algorithm "+SigningAlgorithmES256))
+		return
+	}
+
+	actor := adminActor(c)
+	info, err := as.store.RotateSigningKey(c.Request.Context(), actor)
+	if err != nil {
+		RespondWithError(c, HandleDatabaseError(err, logger))
+		return
+	}
+
+	logger.Info().Str("key_id", info.KeyID).Str("actor", actor.Subject).Msg("Signing key rotated by admin")
+	c.JSON(http.StatusOK, info)
+}
+
+// listLockoutsHandler lists the client IDs and source IPs with recent failed secret checks
+// Failure counts are this instance's; lockouts include those made by other instances
+func (as *authServer) listLockoutsHandler(c *gin.Context) {
+	lockouts := []LockoutInfo{}
+	if as.lockouts != nil {
+		lockouts = as.lockouts.Lockouts(time.Now())
+	}
+	c.JSON(http.StatusOK, gin.H{"lockouts": lockouts})
+}
+
+// unlockClientHandler lifts a client ID's lockout and forgets its failures on every instance
+func (as *authServer) unlockClientHandler(c *gin.Context) {
+	as.clearLockout(c, ClientLockoutSubject(c.Param("client_id")))
+}
+
+// unlockIPHandler lifts a source IP's lockout and forgets its failures on every instance
+func (as *authServer) unlockIPHandler(c *gin.Context) {
+	ip := net.ParseIP(c.Param("ip"))
+	if ip == nil {
+		RespondWithError(c, ErrBadRequest("Invalid IP address"))
+		return
+	}
+	as.clearLockout(c, IPLockoutSubject(ip.String()))
+}
+
+// clearLockout announces the end of a lockout and answers 204
+func (as *authServer) clearLockout(c *gin.Context, subject string) {
+	logger := GetRequestLogger(c)
+
+	as.store.ClearLockout(subject, adminActor(c))
+	logger.Info().Str("subject", subject).Msg("Lockout cleared via admin API")
+	c.Status(http.StatusNoContent)
+}
+
+// startAdmin serves the admin API, pprof and runtime inspection on admin.address, apart from the public port
+func (s *authServer) startAdmin() {
+	logger := GetLogger()
+	addr := AppConfig.Admin.Address
+
+	router := gin.New()
+	router.Use(
+		TracingMiddleware(),
+		LoggingMiddleware(),
+		RecoveryMiddleware(),
+	)
+	if s.metrics != nil {
+		router.Use(s.metrics.Middleware())
+	}
+	adminRoutes(router, s)
+
+	s.adminSrv = &http.Server{
+		Addr:        addr,
+		Handler:     router,
+		ReadTimeout: 15 * time.Second,
+		// CPU profiles and traces take 30 seconds by default
+		WriteTimeout:   2 * time.Minute,
+		MaxHeaderBytes: 1 << 20, // 1MB
+	}
+
+	go func() {
+		logger.Info().
+			Str("address", addr).
+			Msg("Starting admin server")
+
+		if err := s.adminSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.Error().
+				Err(err).
+				Str("address", addr).
+				Msg("Admin server error")
+		}
+	}()
+}
+
+// pprofRoutes serves net/http/pprof behind requireAdmin
+func pprofRoutes(r *gin.Engine, s *authServer) {
+	debug := r.Group("/debug/pprof", s.requireAdmin)
+	debug.GET("/", gin.WrapF(pprof.Index))
+	debug.GET("/cmdline", gin.WrapF(pprof.Cmdline))
+	debug.GET("/profile", gin.WrapF(pprof.Profile))
+	debug.GET("/symbol", gin.WrapF(pprof.Symbol))
+	debug.POST("/symbol", gin.WrapF(pprof.Symbol))
+	debug.GET("/trace", gin.WrapF(pprof.Trace))
+	debug.GET("/:profile", func(c *gin.Context) {
+		pprof.Handler(c.Param("profile")).ServeHTTP(c.Writer, c.Request)
+	})
+}
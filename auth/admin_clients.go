@@ -0,0 +1,407 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"auth-server/scope"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Client field limits enforced by the admin API
+const (
+	DefaultAccessTokenTTL = 3600
+	MaxAccessTokenTTL     = 86400
+	maxClientNameLength   = 255
+	maxSecretLabelLength  = 100
+)
+
+// clientIDPattern matches the client IDs the admin API accepts
+var clientIDPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._:-]{0,99}$`)
+
+// createClientRequest is the body of POST /admin/clients
+type createClientRequest struct {
+	ClientID       string     `json:"client_id"`
+	Name           string     `json:"name"`
+	AccessTokenTTL *int32     `json:"access_token_ttl"`
+	AllowedScopes  []string   `json:"allowed_scopes"`
+	ValidFrom      *time.Time `json:"valid_from"`
+	ValidUntil     *time.Time `json:"valid_until"`
+}
+
+// createClientResponse reveals the generated secret; it is never returned again
+type createClientResponse struct {
+	ClientRecord
+	ClientSecret string `json:"client_secret"`
+}
+
+// createClientHandler registers a client with a server-generated secret
+func (as *authServer) createClientHandler(c *gin.Context) {
+	logger := GetRequestLogger(c)
+
+	var req createClientRequest
+	if apiErr := decodeAdminBody(c, &req); apiErr != nil {
+		RespondWithError(c, apiErr)
+		return
+	}
+	if req.AccessTokenTTL == nil {
+		ttl := int32(DefaultAccessTokenTTL)
+		req.AccessTokenTTL = &ttl
+	}
+	if apiErr := ValidateRequest(c, func() error {
+		if !clientIDPattern.MatchString(req.ClientID) {
+			return errors.New("client_id must be 1-100 letters, digits, '.', '_', ':' or '-', starting with a letter or digit")
+		}
+		return validateClientFields(&req.Name, req.AccessTokenTTL, &req.AllowedScopes)
+	}); apiErr != nil {
+		RespondWithError(c, apiErr)
+		return
+	}
+
+	secret, err := GenerateClientSecret()
+	if err != nil {
+		RespondWithError(c, ErrInternalServerError("Failed to generate client secret").WithOriginalError(err))
+		return
+	}
+
+	client, err := as.store.CreateClient(c.Request.Context(), ClientRecord{
+		ClientID:       req.ClientID,
+		Name:           req.Name,
+		AccessTokenTTL: *req.AccessTokenTTL,
+		AllowedScopes:  req.AllowedScopes,
+		ValidFrom:      req.ValidFrom,
+		ValidUntil:     req.ValidUntil,
+	}, secret, adminActor(c))
+	if err != nil {
+		respondStoreError(c, err)
+		return
+	}
+
+	logger.Info().Str("client_id", client.ClientID).Msg("Client created via admin API")
+	c.Header("Cache-Control", "no-store")
+	c.Header("Location", c.Request.URL.Path+"/"+client.ClientID)
+	c.JSON(http.StatusCreated, createClientResponse{ClientRecord: *client, ClientSecret: secret})
+}
+
+// listClientsHandler lists clients, filtered by ?active, ?q and ?include_deleted and paged by ?limit and ?offset
+func (as *authServer) listClientsHandler(c *gin.Context) {
+	var filter ClientFilter
+	if apiErr := ValidateRequest(c, func() error {
+		var err error
+		if value := c.Query("active"); value != "" {
+			active, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("active must be true or false")
+			}
+			filter.Active = &active
+		}
+		if value := c.Query("include_deleted"); value != "" {
+			if filter.IncludeDeleted, err = strconv.ParseBool(value); err != nil {
+				return fmt.Errorf("include_deleted must be true or false")
+			}
+		}
+		if filter.Limit, err = queryInt(c, "limit"); err != nil {
+			return err
+		}
+		if filter.Offset, err = queryInt(c, "offset"); err != nil {
+			return err
+		}
+		filter.Search = c.Query("q")
+		return nil
+	}); apiErr != nil {
+		RespondWithError(c, apiErr)
+		return
+	}
+
+	page, err := as.store.ListClients(c.Request.Context(), filter)
+	if err != nil {
+		respondStoreError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+// getClientHandler returns one client, including soft-deleted ones
+func (as *authServer) getClientHandler(c *gin.Context) {
+	client, err := as.store.GetClient(c.Request.Context(), c.Param("client_id"))
+	if err != nil {
+		respondStoreError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, client)
+}
+
+// updateClientHandler changes a client's name, token TTL, scopes, active flag, validity window or rate limits
+// With revoke_tokens set, every token issued to the client so far is revoked as well
+func (as *authServer) updateClientHandler(c *gin.Context) {
+	logger := GetRequestLogger(c)
+
+	var update ClientUpdate
+	if apiErr := decodeAdminBody(c, &update); apiErr != nil {
+		RespondWithError(c, apiErr)
+		return
+	}
+	if apiErr := ValidateRequest(c, func() error {
+		return validateClientFields(update.Name, update.AccessTokenTTL, update.AllowedScopes)
+	}); apiErr != nil {
+		RespondWithError(c, apiErr)
+		return
+	}
+
+	client, err := as.store.UpdateClient(c.Request.Context(), c.Param("client_id"), update, adminActor(c))
+	if err != nil {
+		respondStoreError(c, err)
+		return
+	}
+
+	logger.Info().Str("client_id", client.ClientID).Bool("revoke_tokens", update.RevokeTokens).Msg("Client updated via admin API")
+	c.JSON(http.StatusOK, client)
+}
+
+// deleteClientHandler soft-deletes a client, cutting it off from new tokens
+func (as *authServer) deleteClientHandler(c *gin.Context) {
+	logger := GetRequestLogger(c)
+
+	client, err := as.store.DeleteClient(c.Request.Context(), c.Param("client_id"), adminActor(c))
+	if err != nil {
+		respondStoreError(c, err)
+		return
+	}
+
+	logger.Info().Str("client_id", client.ClientID).Msg("Client deleted via admin API")
+	c.JSON(http.StatusOK, client)
+}
+
+// restoreClientHandler undoes a soft delete
+func (as *authServer) restoreClientHandler(c *gin.Context) {
+	logger := GetRequestLogger(c)
+
+	client, err := as.store.RestoreClient(c.Request.Context(), c.Param("client_id"), adminActor(c))
+	if err != nil {
+		respondStoreError(c, err)
+		return
+	}
+
+	logger.Info().Str("client_id", client.ClientID).Msg("Client restored via admin API")
+	c.JSON(http.StatusOK, client)
+}
+
+// clientAuditHandler lists a client's recorded changes, newest first, up to ?limit
+func (as *authServer) clientAuditHandler(c *gin.Context) {
+	limit, err := queryInt(c, "limit")
+	if err != nil {
+		RespondWithError(c, ErrBadRequest("allowed_cidrs: "+err.Error()))
+		return
+	}
+
+	entries, err := as.store.ClientAudit(c.Request.Context(), c.Param("client_id"), limit)
+	if err != nil {
+		respondStoreError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"entries": entries})
+}
+
+// addSecretRequest is the body of POST /admin/clients/{id}/secrets
+type addSecretRequest struct {
+	Label     string     `json:"label"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// addSecretResponse reveals the generated secret; it is never returned again
+type addSecretResponse struct {
+	ClientSecretInfo
+	ClientSecret string `json:"client_secret"`
+}
+
+// expireSecretRequest is the optional body of POST /admin/clients/{id}/secrets/{secret_id}/expire
+type expireSecretRequest struct {
+	GraceSeconds int64 `json:"grace_seconds"`
+}
+
+// listClientSecretsHandler lists a client's secrets without revealing them
+func (as *authServer) listClientSecretsHandler(c *gin.Context) {
+	secrets, err := as.store.ListClientSecrets(c.Request.Context(), c.Param("client_id"))
+	if err != nil {
+		respondStoreError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"secrets": secrets})
+}
+
+// addClientSecretHandler generates a secondary secret that works alongside the primary until promoted
+func (as *authServer) addClientSecretHandler(c *gin.Context) {
+	logger := GetRequestLogger(c)
+
+	var req addSecretRequest
+	if apiErr := decodeAdminBody(c, &req); apiErr != nil {
+		RespondWithError(c, apiErr)
+		return
+	}
+	if apiErr := ValidateRequest(c, func() error {
+		if len(req.Label) > maxSecretLabelLength {
+			return fmt.Errorf("label must be at most %d characters", maxSecretLabelLength)
+		}
+		if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+			return errors.New("expires_at must be in the future")
+		}
+		return nil
+	}); apiErr != nil {
+		RespondWithError(c, apiErr)
+		return
+	}
+
+	secret, err := GenerateClientSecret()
+	if err != nil {
+		RespondWithError(c, ErrInternalServerError("Failed to generate client secret").WithOriginalError(err))
+		return
+	}
+
+	info, err := as.store.AddClientSecret(c.Request.Context(), c.Param("client_id"), req.Label, req.ExpiresAt, secret, adminActor(c))
+	if err != nil {
+		respondStoreError(c, err)
+		return
+	}
+
+	logger.Info().Str("client_id", info.ClientID).Int64("secret_id", info.ID).Msg("Client secret added via admin API")
+	c.Header("Cache-Control", "no-store")
+	c.Header("Location", c.Request.URL.Path+"/"+strconv.FormatInt(info.ID, 10))
+	c.JSON(http.StatusCreated, addSecretResponse{ClientSecretInfo: *info, ClientSecret: secret})
+}
+
+// promoteClientSecretHandler makes a secret the client's primary
+func (as *authServer) promoteClientSecretHandler(c *gin.Context) {
+	logger := GetRequestLogger(c)
+
+	secretID, apiErr := secretIDParam(c)
+	if apiErr != nil {
+		RespondWithError(c, apiErr)
+		return
+	}
+
+	info, err := as.store.PromoteClientSecret(c.Request.Context(), c.Param("client_id"), secretID, adminActor(c))
+	if err != nil {
+		respondStoreError(c, err)
+		return
+	}
+
+	logger.Info().Str("client_id", info.ClientID).Int64("secret_id", info.ID).Msg("Client secret promoted via admin API")
+	c.JSON(http.StatusOK, info)
+}
+
+// expireClientSecretHandler ends a non-primary secret's validity, immediately or after grace_seconds
+func (as *authServer) expireClientSecretHandler(c *gin.Context) {
+	logger := GetRequestLogger(c)
+
+	secretID, apiErr := secretIDParam(c)
+	if apiErr != nil {
+		RespondWithError(c, apiErr)
+		return
+	}
+
+	var req expireSecretRequest
+	if c.Request.ContentLength != 0 {
+		if apiErr := decodeAdminBody(c, &req); apiErr != nil {
+			RespondWithError(c, apiErr)
+			return
+		}
+	}
+	if req.GraceSeconds < 0 {
+		RespondWithError(c, ErrBadRequest("grace_seconds must not be negative"))
+		return
+	}
+
+	grace := time.Duration(req.GraceSeconds) * time.Second
+	info, err := as.store.ExpireClientSecret(c.Request.Context(), c.Param("client_id"), secretID, grace, adminActor(c))
+	if err != nil {
+		respondStoreError(c, err)
+		return
+	}
+
+	logger.Info().Str("client_id", info.ClientID).Int64("secret_id", info.ID).Time("expires_at", *info.ExpiresAt).Msg("Client secret expiry set via admin API")
+	c.JSON(http.StatusOK, info)
+}
+
+// secretIDParam parses the :secret_id path parameter
+func secretIDParam(c *gin.Context) (int64, *APIError) {
+	id, err := strconv.ParseInt(c.Param("secret_id"), 10, 64)
+	if err != nil || id <= 0 {
+		return 0, ErrBadRequest("secret_id must be a positive integer")
+	}
+	return id, nil
+}
+
+// validateClientFields checks the client attributes that are present
+func validateClientFields(name *string, ttl *int32, scopes *[]string) error {
+	if name != nil && len(*name) > maxClientNameLength {
+		return fmt.Errorf("name must be at most %d characters", maxClientNameLength)
+	}
+	if ttl != nil && (*ttl <= 0 || *ttl > MaxAccessTokenTTL) {
+		return fmt.Errorf("access_token_ttl must be between 1 and %d seconds", MaxAccessTokenTTL)
+	}
+	if scopes != nil {
+		if _, err := scope.Compile(*scopes, scope.Options{}); err != nil {
+			return fmt.Errorf("allowed_scopes: %w", err)
+		}
+	}
+	return nil
+}
+
+// decodeAdminBody decodes a JSON body strictly, so misspelled fields are reported rather than ignored
+func decodeAdminBody(c *gin.Context, v any) *APIError {
+	decoder := json.NewDecoder(c.Request.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return ErrBadRequest("Invalid JSON body").WithOriginalError(err).WithDetails(err.Error())
+	}
+	return nil
+}
+
+// queryInt parses an optional non-negative integer query parameter
+func queryInt(c *gin.Context, name string) (int, error) {
+	value := c.Query(name)
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%s must be a non-negative integer", name)
+	}
+	return n, nil
+}
+
+// respondStoreError maps Store errors to API errors
+func respondStoreError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrClientNotFound):
+		RespondWithError(c, ErrNotFoundError("Client not found"))
+	case errors.Is(err, ErrClientExists):
+		RespondWithError(c, ErrConflictError("Client already exists"))
+	case errors.Is(err, ErrClientDeleted):
+		RespondWithError(c, ErrConflictError("Client is deleted; restore it first"))
+	case errors.Is(err, ErrInvalidWindow):
+		RespondWithError(c, ErrBadRequest("valid_from must be before valid_until"))
+	case errors.Is(err, ErrInvalidLimit):
+		RespondWithError(c, ErrBadRequest("Rate limits need a positive rate for their burst, and neither may be negative"))
+	case errors.Is(err, ErrInvalidCIDR):
+		RespondWithError(c, ErrBadRequest("allowed_cidrs: "+err.Error()))
+	case errors.Is(err, ErrSecretNotFound):
+		RespondWithError(c, ErrNotFoundError("Client secret not found"))
+	case errors.Is(err, ErrSecretIsPrimary):
+		RespondWithError(c, ErrConflictError("Client secret is primary; promote another secret first"))
+	case errors.Is(err, ErrSecretExpired):
+		RespondWithError(c, ErrConflictError("Client secret has expired"))
+	case errors.Is(err, ErrWebhookNotFound):
+		RespondWithError(c, ErrNotFoundError("Webhook subscription not found"))
+	case errors.Is(err, ErrInvalidWebhook):
+		RespondWithError(c, ErrBadRequest("Webhook url must be an absolute http or https URL"))
+	default:
+		RespondWithError(c, HandleDatabaseError(err, GetRequestLogger(c)))
+	}
+}
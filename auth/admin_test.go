@@ -0,0 +1,175 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequireAdmin_MissingToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	server := &authServer{jwtSecret: []byte("test-secret")}
+	reached := false
+	router := gin.New()
+	router.GET("/admin/clients", server.requireAdmin, func(c *gin.Context) { reached = true })
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", "/admin/clients", nil))
+
+	if recorder.Code != http.StatusUnauthorized || reached {
+		t.Errorf("Expected 401 without reaching the handler, got %d (reached=%v)", recorder.Code, reached)
+	}
+}
+
+func TestCreateClientHandler_Validation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	server := &authServer{jwtSecret: []byte("test-secret")}
+	router := gin.New()
+	router.POST("/admin/clients", server.createClientHandler)
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"malformed JSON", `{`},
+		{"unknown field", `{"client_id": "billing", "scopes": ["a"]}`},
+		{"invalid client ID", `{"client_id": "bad id"}`},
+		{"TTL too long", `{"client_id": "billing", "access_token_ttl": 999999}`},
+		{"invalid scope", `{"client_id": "billing", "allowed_scopes": ["http://x/a*b"]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest("POST", "/admin/clients", strings.NewReader(tt.body)))
+			if recorder.Code != http.StatusBadRequest {
+				t.Errorf("Expected 400, got %d: %s", recorder.Code, recorder.Body.String())
+			}
+		})
+	}
+}
+
+func TestRespondStoreError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{ErrClientNotFound, http.StatusNotFound},
+		{ErrClientExists, http.StatusConflict},
+		{ErrClientDeleted, http.StatusConflict},
+		{ErrSecretNotFound, http.StatusNotFound},
+		{ErrSecretIsPrimary, http.StatusConflict},
+		{errors.New("ORA-03113"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		recorder := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(recorder)
+		respondStoreError(c, tt.err)
+		if recorder.Code != tt.want {
+			t.Errorf("respondStoreError(%v) = %d, want %d", tt.err, recorder.Code, tt.want)
+		}
+	}
+}
+
+func TestClientSecretHandlers_Validation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	server := &authServer{jwtSecret: []byte("test-secret")}
+	router := gin.New()
+	router.POST("/admin/clients/:client_id/secrets", server.addClientSecretHandler)
+	router.POST("/admin/clients/:client_id/secrets/:secret_id/promote", server.promoteClientSecretHandler)
+	router.POST("/admin/clients/:client_id/secrets/:secret_id/expire", server.expireClientSecretHandler)
+
+	tests := []struct {
+		name string
+		path string
+		body string
+	}{
+		{"unknown field", "/admin/clients/billing/secrets", `{"name": "next"}`},
+		{"label too long", "/admin/clients/billing/secrets", `{"label": "` + strings.Repeat("x", 101) + `"}`},
+		{"expiry in the past", "/admin/clients/billing/secrets", `{"expires_at": "2000-01-01T00:00:00Z"}`},
+		{"invalid secret ID", "/admin/clients/billing/secrets/abc/promote", ``},
+		{"negative grace", "/admin/clients/billing/secrets/7/expire", `{"grace_seconds": -1}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest("POST", tt.path, strings.NewReader(tt.body)))
+			if recorder.Code != http.StatusBadRequest {
+				t.Errorf("Expected 400, got %d: %s", recorder.Code, recorder.Body.String())
+			}
+		})
+	}
+}
+
+func TestAdminRoutes_NotOnPublicRouter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	server := &authServer{jwtSecret: []byte("test-secret")}
+	router := gin.New()
+	routes(router, server)
+
+	for _, path := range []string{"/auth-server/v1/admin/clients", "/auth-server/v1/admin/cache/stats", "/debug/pprof/"} {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest("GET", path, nil))
+		if recorder.Code != http.StatusNotFound {
+			t.Errorf("Expected %s to be absent from the public router, got %d", path, recorder.Code)
+		}
+	}
+}
+
+func TestRequireAdmin_BootstrapToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	saved := AppConfig
+	defer func() { AppConfig = saved }()
+	AppConfig.Admin.Pprof = true
+	AppConfig.Admin.BootstrapToken = strings.Repeat("b", minBootstrapTokenLength)
+
+	server := &authServer{jwtSecret: []byte("test-secret")}
+	router := gin.New()
+	adminRoutes(router, server)
+	var actor Actor
+	router.GET("/whoami", server.requireAdmin, func(c *gin.Context) { actor = adminActor(c) })
+
+	tests := []struct {
+		name   string
+		path   string
+		header string
+		want   int
+	}{
+		{"bootstrap token", "/whoami", "Bearer " + AppConfig.Admin.BootstrapToken, http.StatusOK},
+		{"wrong token", "/whoami", "Bearer " + strings.Repeat("c", minBootstrapTokenLength), http.StatusUnauthorized},
+		{"token without Bearer", "/whoami", AppConfig.Admin.BootstrapToken, http.StatusUnauthorized},
+		{"pprof with bootstrap token", "/debug/pprof/cmdline", "Bearer " + AppConfig.Admin.BootstrapToken, http.StatusOK},
+		{"pprof without token", "/debug/pprof/heap", "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest("GET", tt.path, nil)
+			if tt.header != "" {
+				request.Header.Set("Authorization", tt.header)
+			}
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, request)
+			if recorder.Code != tt.want {
+				t.Errorf("Expected %d, got %d", tt.want, recorder.Code)
+			}
+		})
+	}
+	if actor.Subject != bootstrapActor {
+		t.Errorf("Expected the bootstrap actor, got %q", actor.Subject)
+	}
+
+	// An empty bootstrap token must never match an empty bearer token
+	AppConfig.Admin.BootstrapToken = ""
+	if isBootstrapToken("Bearer ") {
+		t.Error("Expected no bootstrap token to be accepted when none is configured")
+	}
+}
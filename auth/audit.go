@@ -0,0 +1,709 @@
+package auth
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+// Audit actions; admin changes to clients and their secrets use the client audit actions
+const (
+	AuditTokenIssue         = "token.issue"
+	AuditTokenRevoke        = "token.revoke"
+	AuditClientRevokeTokens = "client.revoke_tokens"
+	AuditLockout            = "lockout.trigger"
+	AuditLockoutClear       = "lockout.clear"
+	AuditKeyRotate          = "key.rotate"
+	AuditAdminAccess        = "admin.access"
+	AuditWebhookCreated     = "webhook.created"
+	AuditWebhookDeleted     = "webhook.deleted"
+	AuditLogLevelChange     = "log.level_change"
+	AuditCacheInvalidate    = "cache.invalidate"
+	AuditCacheClear         = "cache.clear"
+)
+
+// Audit outcomes
+const (
+	AuditOutcomeSuccess = "success"
+	AuditOutcomeFailure = "failure"
+)
+
+// auditDetailMax is the most detail an entry keeps, the size of audit_log.detail
+const auditDetailMax = 4000
+
+// Audit sinks
+const (
+	AuditSinkFile = "file"
+	AuditSinkDB   = "db"
+)
+
+// auditGenesisHash is the previous hash of the first entry in a chain
+var auditGenesisHash = strings.Repeat("0", sha256.Size*2)
+
+// AuditEntry is one security-relevant event
+// Each sink numbers its entries and chains them by hash, so any edit, insertion or removal shows up in verification
+type AuditEntry struct {
+	Seq       int64     `json:"seq"`
+	Time      time.Time `json:"time"`
+	Action    string    `json:"action"`
+	Outcome   string    `json:"outcome"`
+	Actor     string    `json:"actor"` // Client ID for token requests, admin subject for changes
+	ClientID  string    `json:"client_id,omitempty"`
+	TokenID   string    `json:"token_id,omitempty"`
+	IP        string    `json:"ip,omitempty"`
+	RequestID string    `json:"request_id,omitempty"`
+	Detail    string    `json:"detail,omitempty"`
+	PrevHash  string    `json:"prev_hash"`
+	Hash      string    `json:"hash"`
+}
+
+// computeHash hashes every field but Hash itself, each length-prefixed so values can't run together
+func (e *AuditEntry) computeHash() string {
+	h := sha256.New()
+	for _, field := range []string{
+		strconv.FormatInt(e.Seq, 10),
+		e.Time.UTC().Format(time.RFC3339Nano),
+		e.Action, e.Outcome, e.Actor, e.ClientID, e.TokenID, e.IP, e.RequestID, e.Detail,
+		e.PrevHash,
+	} {
+		fmt.Fprintf(h, "%d:%s", len(field), field)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// chain links entries onto a chain ending at seq and head, returning the new end
+func chainAuditEntries(entries []AuditEntry, seq int64, head string) (int64, string) {
+	for i := range entries {
+		seq++
+		entries[i].Seq = seq
+		entries[i].PrevHash = head
+		entries[i].Hash = entries[i].computeHash()
+		head = entries[i].Hash
+	}
+	return seq, head
+}
+
+// AuditVerification is the result of walking an audit chain
+type AuditVerification struct {
+	Entries   int64  `json:"entries"`
+	LastSeq   int64  `json:"last_seq"`
+	LastHash  string `json:"last_hash"`
+	Valid     bool   `json:"valid"`
+	BrokenSeq int64  `json:"broken_seq,omitempty"` // First entry that fails verification
+	Problem   string `json:"problem,omitempty"`
+}
+
+// auditVerifier checks entries one at a time, in sequence order, from the start of the chain
+type auditVerifier struct {
+	result AuditVerification
+}
+
+func newAuditVerifier() *auditVerifier {
+	return &auditVerifier{result: AuditVerification{Valid: true, LastHash: auditGenesisHash}}
+}
+
+// check verifies the next entry, returning false once the chain is broken
+func (v *auditVerifier) check(entry *AuditEntry) bool {
+	switch {
+	case entry.Seq != v.result.LastSeq+1:
+		return v.fail(entry.Seq, fmt.Sprintf("expected entry %d", v.result.LastSeq+1))
+	case entry.PrevHash != v.result.LastHash:
+		return v.fail(entry.Seq, "previous hash does not match the preceding entry")
+	case entry.Hash != entry.computeHash():
+		return v.fail(entry.Seq, "entry does not match its hash")
+	}
+	v.result.Entries++
+	v.result.LastSeq = entry.Seq
+	v.result.LastHash = entry.Hash
+	return true
+}
+
+func (v *auditVerifier) fail(seq int64, problem string) bool {
+	v.result.Valid = false
+	v.result.BrokenSeq = seq
+	v.result.Problem = problem
+	return false
+}
+
+// auditSink stores chained entries
+// append must leave the sink unchanged when it fails, so the same entries can be retried
+type auditSink interface {
+	name() string
+	append(ctx context.Context, entries []AuditEntry) error
+	close() error
+}
+
+// fileAuditSink appends entries to a file as JSON lines
+// The file must not be rotated: verification starts from its first line
+type fileAuditSink struct {
+	mu   sync.Mutex
+	file *os.File
+	seq  int64
+	head string
+}
+
+// openFileAuditSink opens or creates an audit file and resumes its chain from the last entry
+func openFileAuditSink(path string) (*fileAuditSink, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit file: %w", err)
+	}
+	last, err := lastAuditLine(file)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to read audit file: %w", err)
+	}
+
+	sink := &fileAuditSink{file: file, head: auditGenesisHash}
+	if last != nil {
+		var entry AuditEntry
+		if err := json.Unmarshal(last, &entry); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("unreadable last entry in audit file %s: %w", path, err)
+		}
+		sink.seq, sink.head = entry.Seq, entry.Hash
+	}
+	return sink, nil
+}
+
+// lastAuditLine returns the last complete line of an audit file, or nil if it has none
+func lastAuditLine(file *os.File) ([]byte, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	// Entries are well under this, so the last line is always within the tail read
+	const tail = 64 << 10
+	offset := max(0, info.Size()-tail)
+	buf := make([]byte, info.Size()-offset)
+	if _, err := file.ReadAt(buf, offset); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	buf = bytes.TrimRight(buf, "\n")
+	if len(buf) == 0 {
+		return nil, nil
+	}
+	if i := bytes.LastIndexByte(buf, '\n'); i >= 0 {
+		buf = buf[i+1:]
+	}
+	return buf, nil
+}
+
+func (s *fileAuditSink) name() string { return AuditSinkFile }
+
+func (s *fileAuditSink) append(ctx context.Context, entries []AuditEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	chained := make([]AuditEntry, len(entries))
+	copy(chained, entries)
+	seq, head := chainAuditEntries(chained, s.seq, s.head)
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for i := range chained {
+		if err := encoder.Encode(&chained[i]); err != nil {
+			return err
+		}
+	}
+
+	// A partial write is cut off again so the retry doesn't leave half an entry in the chain
+	info, err := s.file.Stat()
+	if err != nil {
+		return err
+	}
+	if _, err := s.file.Write(buf.Bytes()); err != nil {
+		if truncErr := s.file.Truncate(info.Size()); truncErr != nil {
+			log.Error().Err(truncErr).Msg("Failed to cut partial write from audit file")
+		}
+		return err
+	}
+	// The entries are in the file now, so a failed sync must not cause them to be written twice
+	s.seq, s.head = seq, head
+	if err := s.file.Sync(); err != nil {
+		log.Warn().Err(err).Msg("Failed to sync audit file")
+	}
+	return nil
+}
+
+func (s *fileAuditSink) close() error {
+	return s.file.Close()
+}
+
+// VerifyAuditFile walks the hash chain of an audit file written by the file sink
+func VerifyAuditFile(path string) (*AuditVerification, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	verifier := newAuditVerifier()
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for scanner.Scan() {
+		var entry AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			verifier.fail(verifier.result.LastSeq+1, "unreadable entry: "+err.Error())
+			break
+		}
+		if !verifier.check(&entry) {
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return &verifier.result, nil
+}
+
+// dbAuditSink writes entries to the audit_log table
+// Every instance shares one chain; its end is kept in audit_head, which is locked while a batch is added
+type dbAuditSink struct {
+	db *sql.DB
+}
+
+func (s *dbAuditSink) name() string { return AuditSinkDB }
+
+func (s *dbAuditSink) append(ctx context.Context, entries []AuditEntry) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var (
+		seq  int64
+		head string
+	)
+	if err := tx.QueryRowContext(ctx, "SELECT seq, hash FROM audit_head WHERE id = 1 FOR UPDATE").Scan(&seq, &head); err != nil {
+		return fmt.Errorf("failed to lock audit chain: %w", err)
+	}
+
+	chained := make([]AuditEntry, len(entries))
+	copy(chained, entries)
+	seq, head = chainAuditEntries(chained, seq, head)
+
+	stmt, err := tx.PrepareContext(ctx, `INSERT INTO audit_log
+		(seq, created_at, action, outcome, actor, client_id, token_id, remote_addr, request_id, detail, prev_hash, hash)
+		VALUES (:seq, :created_at, :action, :outcome, :actor, :client_id, :token_id, :remote_addr, :request_id, :detail, :prev_hash, :hash)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, entry := range chained {
+		if _, err := stmt.ExecContext(ctx,
+			sql.Named("seq", entry.Seq),
+			sql.Named("created_at", entry.Time),
+			sql.Named("action", entry.Action),
+			sql.Named("outcome", entry.Outcome),
+			sql.Named("actor", entry.Actor),
+			sql.Named("client_id", entry.ClientID),
+			sql.Named("token_id", entry.TokenID),
+			sql.Named("remote_addr", entry.IP),
+			sql.Named("request_id", entry.RequestID),
+			sql.Named("detail", entry.Detail),
+			sql.Named("prev_hash", entry.PrevHash),
+			sql.Named("hash", entry.Hash)); err != nil {
+			return fmt.Errorf("failed to insert audit entry %d: %w", entry.Seq, err)
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE audit_head SET seq = :seq, hash = :hash WHERE id = 1",
+		sql.Named("seq", seq), sql.Named("hash", head)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *dbAuditSink) close() error { return nil }
+
+// auditColumns are selected in scanAuditEntry order
+const auditColumns = "seq, created_at, action, outcome, actor, client_id, token_id, remote_addr, request_id, detail, prev_hash, hash"
+
+// scanAuditEntry reads an audit_log row; Oracle stores empty strings as NULL
+func scanAuditEntry(row interface{ Scan(...any) error }) (*AuditEntry, error) {
+	var (
+		entry                                    AuditEntry
+		clientID, tokenID, ip, requestID, detail sql.NullString
+	)
+	if err := row.Scan(&entry.Seq, &entry.Time, &entry.Action, &entry.Outcome, &entry.Actor,
+		&clientID, &tokenID, &ip, &requestID, &detail, &entry.PrevHash, &entry.Hash); err != nil {
+		return nil, err
+	}
+	entry.Time = entry.Time.UTC()
+	entry.ClientID = clientID.String
+	entry.TokenID = tokenID.String
+	entry.IP = ip.String
+	entry.RequestID = requestID.String
+	entry.Detail = detail.String
+	return &entry, nil
+}
+
+// auditQueue holds a sink's entries until they are written
+type auditQueue struct {
+	sink    auditSink
+	pending []AuditEntry
+}
+
+// AuditLog queues audit entries and writes them to every configured sink in the background
+// Entries a sink fails to take are retried on the next flush, up to maxPending per sink
+// A nil *AuditLog records nothing
+type AuditLog struct {
+	mu         sync.Mutex
+	queues     []*auditQueue
+	maxPending int
+	flushTick  *time.Ticker
+	done       chan struct{}
+	stopped    chan struct{}
+	stopOnce   sync.Once
+}
+
+// NewAuditLog opens the configured sinks and starts the background writer
+func NewAuditLog(cfg audit, db *sql.DB) (*AuditLog, error) {
+	al := &AuditLog{
+		maxPending: cfg.MaxPending,
+		flushTick:  time.NewTicker(time.Duration(cfg.FlushInterval) * time.Millisecond),
+		done:       make(chan struct{}),
+		stopped:    make(chan struct{}),
+	}
+	for _, name := range cfg.Sinks {
+		var sink auditSink
+		switch name {
+		case AuditSinkFile:
+			fileSink, err := openFileAuditSink(cfg.Path)
+			if err != nil {
+				al.closeSinks()
+				return nil, err
+			}
+			sink = fileSink
+		case AuditSinkDB:
+			sink = &dbAuditSink{db: db}
+		default:
+			al.closeSinks()
+			return nil, fmt.Errorf("unknown audit sink %q", name)
+		}
+		al.queues = append(al.queues, &auditQueue{sink: sink})
+	}
+
+	go al.backgroundFlush()
+
+	log.Info().
+		Strs("sinks", cfg.Sinks).
+		Int("flush_interval_ms", cfg.FlushInterval).
+		Msg("Audit log initialized")
+	return al, nil
+}
+
+// Record queues an entry for every sink, stamping its time if unset
+func (al *AuditLog) Record(entry AuditEntry) {
+	if al == nil {
+		return
+	}
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	// Oracle keeps microseconds, so the hash covers no more than the database can store
+	entry.Time = entry.Time.UTC().Truncate(time.Microsecond)
+	if entry.Outcome == "" {
+		entry.Outcome = AuditOutcomeSuccess
+	}
+	entry.Detail = truncate(entry.Detail, auditDetailMax)
+
+	al.mu.Lock()
+	defer al.mu.Unlock()
+	for _, queue := range al.queues {
+		if len(queue.pending) >= al.maxPending {
+			log.Error().Str("sink", queue.sink.name()).Str("action", entry.Action).Msg("Audit queue full, entry dropped")
+			continue
+		}
+		queue.pending = append(queue.pending, entry)
+	}
+}
+
+// Flush writes every queued entry now
+func (al *AuditLog) Flush(ctx context.Context) {
+	if al == nil {
+		return
+	}
+	for _, queue := range al.queues {
+		al.mu.Lock()
+		batch := queue.pending
+		queue.pending = nil
+		al.mu.Unlock()
+		if len(batch) == 0 {
+			continue
+		}
+
+		if err := queue.sink.append(ctx, batch); err != nil {
+			log.Error().Err(err).Str("sink", queue.sink.name()).Int("entries", len(batch)).Msg("Failed to write audit entries, will retry")
+			// Put the batch back ahead of anything recorded since, keeping the order
+			al.mu.Lock()
+			queue.pending = append(batch, queue.pending...)
+			al.mu.Unlock()
+		}
+	}
+}
+
+// backgroundFlush writes queued entries every flush interval until stopped
+func (al *AuditLog) backgroundFlush() {
+	defer close(al.stopped)
+	for {
+		select {
+		case <-al.done:
+			al.flushTick.Stop()
+			al.Flush(context.Background())
+			return
+		case <-al.flushTick.C:
+			al.Flush(context.Background())
+		}
+	}
+}
+
+// Stop writes what is still queued and closes the sinks
+func (al *AuditLog) Stop() {
+	if al == nil {
+		return
+	}
+	al.stopOnce.Do(func() {
+		close(al.done)
+		<-al.stopped
+		al.closeSinks()
+		log.Info().Msg("Audit log stopped")
+	})
+}
+
+func (al *AuditLog) closeSinks() {
+	for _, queue := range al.queues {
+		if err := queue.sink.close(); err != nil {
+			log.Warn().Err(err).Str("sink", queue.sink.name()).Msg("Error closing audit sink")
+		}
+	}
+}
+
+// auditRequest records an entry about an HTTP request, taking its IP and request ID
+func (as *authServer) auditRequest(c *gin.Context, entry AuditEntry) {
+	entry.IP = clientIP(c)
+	entry.RequestID = GetRequestID(c)
+	as.auditLog.Record(entry)
+}
+
+// auditTokenRequest records the outcome of a token request; anything but an issued token is a failure
+func (as *authServer) auditTokenRequest(c *gin.Context, clientID, tokenID, outcome string) {
+	entry := AuditEntry{Action: AuditTokenIssue, Actor: clientID, ClientID: clientID, TokenID: tokenID}
+	if outcome != tokenOutcomeIssued {
+		entry.Outcome = AuditOutcomeFailure
+		entry.Detail = outcome
+	}
+	as.auditRequest(c, entry)
+}
+
+// auditActor records an entry about a change made by actor
+func (as *authServer) auditActor(actor Actor, entry AuditEntry) {
+	entry.Actor = actor.Subject
+	entry.IP = actor.IP
+	entry.RequestID = actor.RequestID
+	as.auditLog.Record(entry)
+}
+
+// AuditFilter narrows an audit log query
+type AuditFilter struct {
+	ClientID string
+	Action   string
+	Since    *time.Time
+	Until    *time.Time
+	Limit    int
+	Offset   int
+}
+
+// where builds the WHERE clause and its arguments
+func (f AuditFilter) where() (string, []any) {
+	var (
+		conditions []string
+		args       []any
+	)
+	if f.ClientID != "" {
+		conditions = append(conditions, "client_id = :client_id")
+		args = append(args, sql.Named("client_id", f.ClientID))
+	}
+	if f.Action != "" {
+		conditions = append(conditions, "action = :action")
+		args = append(args, sql.Named("action", f.Action))
+	}
+	if f.Since != nil {
+		conditions = append(conditions, "created_at >= :since")
+		args = append(args, sql.Named("since", *f.Since))
+	}
+	if f.Until != nil {
+		conditions = append(conditions, "created_at < :until")
+		args = append(args, sql.Named("until", *f.Until))
+	}
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// AuditEntries returns entries from the audit_log table, newest first
+func (s *Store) AuditEntries(ctx context.Context, filter AuditFilter) ([]AuditEntry, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	filter.Limit, filter.Offset = clampPage(filter.Limit, filter.Offset)
+	where, args := filter.where()
+	query := "SELECT " + auditColumns + " FROM audit_log" + where +
+		" ORDER BY seq DESC OFFSET :offset ROWS FETCH NEXT :limit ROWS ONLY"
+	args = append(args, sql.Named("offset", filter.Offset), sql.Named("limit", filter.Limit))
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("auditEntries: %v", err)
+	}
+	defer rows.Close()
+
+	entries := []AuditEntry{}
+	for rows.Next() {
+		entry, err := scanAuditEntry(rows)
+		if err != nil {
+			return nil, fmt.Errorf("auditEntries: %v", err)
+		}
+		entries = append(entries, *entry)
+	}
+	return entries, rows.Err()
+}
+
+// VerifyAuditLog walks the whole audit_log chain and checks that it ends where audit_head says
+// The head catches entries removed from the end, which the chain alone cannot
+func (s *Store) VerifyAuditLog(ctx context.Context) (*AuditVerification, error) {
+	var headSeq int64
+	var headHash string
+	if err := s.db.QueryRowContext(ctx, "SELECT seq, hash FROM audit_head WHERE id = 1").Scan(&headSeq, &headHash); err != nil {
+		return nil, fmt.Errorf("verifyAuditLog: %v", err)
+	}
+
+	rows, err := s.db.QueryContext(ctx, "SELECT "+auditColumns+" FROM audit_log WHERE seq <= :seq ORDER BY seq", sql.Named("seq", headSeq))
+	if err != nil {
+		return nil, fmt.Errorf("verifyAuditLog: %v", err)
+	}
+	defer rows.Close()
+
+	verifier := newAuditVerifier()
+	for rows.Next() {
+		entry, err := scanAuditEntry(rows)
+		if err != nil {
+			return nil, fmt.Errorf("verifyAuditLog: %v", err)
+		}
+		if !verifier.check(entry) {
+			return &verifier.result, nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("verifyAuditLog: %v", err)
+	}
+
+	if verifier.result.LastSeq != headSeq || verifier.result.LastHash != headHash {
+		verifier.fail(verifier.result.LastSeq+1, fmt.Sprintf("chain ends before the recorded head at entry %d", headSeq))
+	}
+	return &verifier.result, nil
+}
+
+// queryTime reads an optional RFC 3339 time query parameter
+func queryTime(c *gin.Context, name string) (*time.Time, error) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be an RFC 3339 time", name)
+	}
+	return &t, nil
+}
+
+// auditEntriesHandler lists audit_log entries, newest first, filtered by ?client_id, ?action, ?since and ?until
+func (as *authServer) auditEntriesHandler(c *gin.Context) {
+	if !slices.Contains(AppConfig.Audit.Sinks, AuditSinkDB) {
+		RespondWithError(c, ErrConflictError("Audit queries require the "+AuditSinkDB+" audit sink"))
+		return
+	}
+
+	filter := AuditFilter{ClientID: c.Query("client_id"), Action: c.Query("action")}
+	if apiErr := ValidateRequest(c, func() error {
+		var err error
+		if filter.Since, err = queryTime(c, "since"); err != nil {
+			return err
+		}
+		if filter.Until, err = queryTime(c, "until"); err != nil {
+			return err
+		}
+		if filter.Limit, err = queryInt(c, "limit"); err != nil {
+			return err
+		}
+		filter.Offset, err = queryInt(c, "offset")
+		return err
+	}); apiErr != nil {
+		RespondWithError(c, apiErr)
+		return
+	}
+
+	entries, err := as.store.AuditEntries(c.Request.Context(), filter)
+	if err != nil {
+		respondStoreError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"entries": entries})
+}
+
+// auditVerifyHandler walks the audit chains: the shared db sink and this instance's file
+func (as *authServer) auditVerifyHandler(c *gin.Context) {
+	logger := GetRequestLogger(c)
+
+	results := map[string]*AuditVerification{}
+	for _, sink := range AppConfig.Audit.Sinks {
+		var (
+			result *AuditVerification
+			err    error
+		)
+		switch sink {
+		case AuditSinkDB:
+			result, err = as.store.VerifyAuditLog(c.Request.Context())
+		case AuditSinkFile:
+			result, err = VerifyAuditFile(AppConfig.Audit.Path)
+		}
+		if err != nil {
+			RespondWithError(c, ErrInternalServerError("Failed to verify the "+sink+" audit log").WithOriginalError(err))
+			return
+		}
+		if !result.Valid {
+			logger.Error().Str("sink", sink).Int64("broken_seq", result.BrokenSeq).Str("problem", result.Problem).Msg("Audit chain broken")
+		}
+		results[sink] = result
+	}
+
+	valid := true
+	for _, result := range results {
+		valid = valid && result.Valid
+	}
+	c.JSON(http.StatusOK, gin.H{"valid": valid, "sinks": results})
+}
@@ -0,0 +1,121 @@
+package auth
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newFileAuditLog opens an audit log writing only to path
+func newFileAuditLog(t *testing.T, path string) *AuditLog {
+	al, err := NewAuditLog(audit{Sinks: []string{AuditSinkFile}, Path: path, FlushInterval: 1000, MaxPending: 100}, nil)
+	if err != nil {
+		t.Fatalf("NewAuditLog failed: %v", err)
+	}
+	return al
+}
+
+func TestAuditLog_FileChainResumes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	al := newFileAuditLog(t, path)
+	al.Record(AuditEntry{Action: AuditTokenIssue, Actor: "billing", ClientID: "billing", TokenID: "t1"})
+	al.Record(AuditEntry{Action: AuditTokenIssue, Outcome: AuditOutcomeFailure, Actor: "billing", ClientID: "billing", Detail: tokenOutcomeInvalidCredentials})
+	al.Stop()
+
+	// A restarted server continues the chain rather than starting a new one
+	al = newFileAuditLog(t, path)
+	al.Record(AuditEntry{Action: AuditKeyRotate, Actor: "ops"})
+	al.Stop()
+
+	result, err := VerifyAuditFile(path)
+	if err != nil {
+		t.Fatalf("VerifyAuditFile failed: %v", err)
+	}
+	if !result.Valid || result.Entries != 3 || result.LastSeq != 3 {
+		t.Errorf("expected a valid chain of 3 entries, got %+v", result)
+	}
+}
+
+func TestVerifyAuditFile_DetectsTampering(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	al := newFileAuditLog(t, path)
+	for _, clientID := range []string{"a", "b", "c"} {
+		al.Record(AuditEntry{Action: AuditTokenRevoke, Actor: clientID, ClientID: clientID})
+	}
+	al.Stop()
+
+	original, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read audit file: %v", err)
+	}
+	lines := bytes.SplitAfter(original, []byte("\n"))
+
+	tests := []struct {
+		name      string
+		tamper    func() []byte
+		brokenSeq int64
+	}{
+		{"edited entry", func() []byte {
+			return bytes.Replace(original, []byte(`"client_id":"b"`), []byte(`"client_id":"x"`), 1)
+		}, 2},
+		{"removed entry", func() []byte {
+			return bytes.Join([][]byte{lines[0], lines[2]}, nil)
+		}, 3},
+		{"reordered entries", func() []byte {
+			return bytes.Join([][]byte{lines[1], lines[0], lines[2]}, nil)
+		}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := os.WriteFile(path, tt.tamper(), 0o600); err != nil {
+				t.Fatalf("failed to write audit file: %v", err)
+			}
+			result, err := VerifyAuditFile(path)
+			if err != nil {
+				t.Fatalf("VerifyAuditFile failed: %v", err)
+			}
+			if result.Valid || result.BrokenSeq != tt.brokenSeq {
+				t.Errorf("expected the chain to break at entry %d, got %+v", tt.brokenSeq, result)
+			}
+		})
+	}
+}
+
+func TestAuditLog_NilRecordsNothing(t *testing.T) {
+	var al *AuditLog
+	al.Record(AuditEntry{Action: AuditTokenIssue})
+	al.Flush(t.Context())
+	al.Stop()
+}
+
+func TestRequireAdmin_AuditsDenial(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	path := filepath.Join(t.TempDir(), "audit.log")
+	server := &authServer{jwtSecret: []byte("test-secret"), auditLog: newFileAuditLog(t, path)}
+
+	router := gin.New()
+	adminRoutes(router, server)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/auth-server/v1/admin/keys/rotate", nil))
+	server.auditLog.Stop()
+
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", w.Code)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read audit file: %v", err)
+	}
+	entry := string(data)
+	if !strings.Contains(entry, `"action":"admin.access"`) || !strings.Contains(entry, `"outcome":"failure"`) ||
+		!strings.Contains(entry, "/admin/keys/rotate") {
+		t.Errorf("expected a failed admin access entry, got %s", entry)
+	}
+}
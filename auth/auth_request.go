@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Response headers describing the authorized token, for the proxy to forward upstream
+const (
+	HeaderAuthClientID = "X-Auth-Client-Id"
+	HeaderAuthScopes   = "X-Auth-Scopes"
+	HeaderAuthTokenID  = "X-Auth-Token-Id"
+)
+
+// nginx auth_request handler
+// nginx sends a GET subrequest carrying the client's Authorization header plus the original request in
+// X-Original-URI and X-Original-Method, and only looks at the status code: 2xx allows, 401/403 deny.
+//
+//	location = /_auth {
+//	    internal;
+//	    proxy_pass              http://auth-server:8080/auth-server/v1/oauth/auth-request;
+//	    proxy_pass_request_body off;
+//	    proxy_set_header        Content-Length "";
+//	    proxy_set_header        X-Original-URI $request_uri;
+//	    proxy_set_header        X-Original-Method $request_method;
+//	    proxy_set_header        X-Original-Host $host;
+//	    proxy_set_header        X-Forwarded-Proto $scheme;
+//	}
+func (as *authServer) authRequestHandler(c *gin.Context) {
+	profile, _ := lookupProxyProfile(ProxyProfileNginx)
+	as.forwardAuth(c, ProxyProfileNginx, profile)
+}
+
+// authRequestStatus maps a denial to a status auth_request understands
+// nginx turns anything other than 2xx, 401 and 403 into a 500 for the client, so every other denial is a 403
+func authRequestStatus(apiErr *APIError) int {
+	if apiErr.StatusCode == http.StatusUnauthorized {
+		return http.StatusUnauthorized
+	}
+	return http.StatusForbidden
+}
+
+// setAuthResponseHeaders exposes the authorized token's identity to the proxy
+func setAuthResponseHeaders(c *gin.Context, claims *Claims) {
+	c.Header(HeaderAuthClientID, claims.ClientID)
+	c.Header(HeaderAuthScopes, strings.Join(claims.Scope, " "))
+	c.Header(HeaderAuthTokenID, claims.TokenID)
+}
+
+// firstHeader returns the first non-empty value among the named headers
+func firstHeader(r *http.Request, names ...string) string {
+	for _, name := range names {
+		if value := strings.TrimSpace(r.Header.Get(name)); value != "" {
+			return value
+		}
+	}
+	return ""
+}
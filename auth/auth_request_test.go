@@ -0,0 +1,121 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestAuthRequestHandler_MissingOriginalURI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	ctx, cancel := createTestContextFunc()
+	defer cancel()
+
+	server := &authServer{
+		jwtSecret: []byte("test-secret"),
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+
+	router := gin.New()
+	router.GET("/auth-request", server.authRequestHandler)
+
+	req, _ := http.NewRequest("GET", "/auth-request", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	// nginx turns a 400 into a 500, so a malformed subrequest is denied with 403
+	if recorder.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 for missing X-Original-URI, got %d", recorder.Code)
+	}
+}
+
+func TestAuthRequestHandler_BareUnauthorized(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	ctx, cancel := createTestContextFunc()
+	defer cancel()
+
+	server := &authServer{
+		jwtSecret: []byte("test-secret"),
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+
+	router := gin.New()
+	router.GET("/auth-request", server.authRequestHandler)
+
+	for _, authHeader := range []string{"", "InvalidToken"} {
+		req, _ := http.NewRequest("GET", "/auth-request", nil)
+		req.Header.Set("X-Original-URI", "/api/users")
+		req.Header.Set("X-Original-Method", "GET")
+		if authHeader != "" {
+			req.Header.Set("Authorization", authHeader)
+		}
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+
+		if recorder.Code != http.StatusUnauthorized {
+			t.Errorf("Expected status 401, got %d", recorder.Code)
+		}
+		// nginx ignores the body, so none is sent
+		if recorder.Body.Len() != 0 {
+			t.Errorf("Expected empty body, got %q", recorder.Body.String())
+		}
+	}
+}
+
+func TestAuthRequestStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		apiErr     *APIError
+		wantStatus int
+	}{
+		{"bad request", ErrBadRequest("Missing X-Original-URI header"), http.StatusForbidden},
+		{"unauthorized", ErrUnauthorizedError("Missing token"), http.StatusUnauthorized},
+		{"forbidden", ErrForbiddenError("Insufficient scope"), http.StatusForbidden},
+		{"too many requests", ErrTooManyRequestsError("Rate limit exceeded"), http.StatusForbidden},
+		{"internal error", ErrInternalServerError("Failed to check revocation"), http.StatusForbidden},
+		{"unavailable", ErrServiceUnavailableError("Draining"), http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(recorder)
+
+			profile, _ := lookupProxyProfile(ProxyProfileNginx)
+			denyForwardAuth(c, profile, tt.apiErr)
+
+			if c.Writer.Status() != tt.wantStatus {
+				t.Errorf("Expected status %d for %d, got %d", tt.wantStatus, tt.apiErr.StatusCode, c.Writer.Status())
+			}
+			if recorder.Body.Len() != 0 {
+				t.Errorf("Expected empty body, got %q", recorder.Body.String())
+			}
+		})
+	}
+}
+
+func TestSetAuthResponseHeaders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+
+	setAuthResponseHeaders(c, &Claims{ClientID: "client-1", TokenID: "jti-1", Scope: []string{"a", "b"}})
+
+	if got := recorder.Header().Get(HeaderAuthClientID); got != "client-1" {
+		t.Errorf("Expected client ID header 'client-1', got %q", got)
+	}
+	if got := recorder.Header().Get(HeaderAuthScopes); got != "a b" {
+		t.Errorf("Expected scopes header 'a b', got %q", got)
+	}
+	if got := recorder.Header().Get(HeaderAuthTokenID); got != "jti-1" {
+		t.Errorf("Expected token ID header 'jti-1', got %q", got)
+	}
+}
@@ -0,0 +1,137 @@
+package auth
+
+import (
+	"context"
+	"net/netip"
+	"slices"
+	"strings"
+
+	"auth-server/scope"
+
+	"github.com/rs/zerolog/log"
+)
+
+// EndpointMethodAny in an endpoint rule matches every HTTP method
+const EndpointMethodAny = "*"
+
+// lookupClient returns a client from cache, falling back to the database on a miss
+func (as *authServer) lookupClient(ctx context.Context, clientID string) (*Clients, error) {
+	if client, found := as.clientCache.Get(ctx, clientID); found {
+		return client, nil
+	}
+
+	client, err := as.clientByID(ctx, clientID)
+	if err != nil {
+		return nil, err
+	}
+	as.clientCache.Set(clientID, client)
+	return client, nil
+}
+
+// authorizeBearer validates an Authorization header value and authorizes the original request from source with it
+// Returns the token's claims when they could be extracted, even if authorization failed
+func (as *authServer) authorizeBearer(ctx context.Context, authHeader, method, resourceURL string, source netip.Addr) (*Claims, *APIError) {
+	if authHeader == "" {
+		return nil, ErrUnauthorizedError("Missing Authorization header")
+	}
+
+	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+	if tokenString == authHeader {
+		return nil, ErrUnauthorizedError("Bearer token required")
+	}
+
+	claims, err := as.validateJWT(ctx, tokenString)
+	if err != nil {
+		return nil, ErrUnauthorizedError("Invalid or expired token").WithOriginalError(err)
+	}
+
+	if apiErr := as.authorizeSource(ctx, claims, source); apiErr != nil {
+		return claims, apiErr
+	}
+	return claims, as.authorizeRequest(ctx, claims, method, resourceURL)
+}
+
+// authorizeRequest decides whether a validated token may call method on resourceURL
+// Clients with endpoint rules are authorized per method against those rules; others by token scope alone
+// Patterns come from the client's current configuration, and only those also granted to the token count
+func (as *authServer) authorizeRequest(ctx context.Context, claims *Claims, method, resourceURL string) *APIError {
+	client, err := as.lookupClient(ctx, claims.ClientID)
+	if err != nil {
+		log.Warn().Err(err).Str("client_id", claims.ClientID).Msg("Client lookup failed during authorization")
+		return ErrForbiddenError("Client is not authorized").WithOriginalError(err)
+	}
+
+	matcher, err := client.scopeMatcher()
+	if err != nil {
+		log.Error().Err(err).Str("client_id", claims.ClientID).Msg("Client has invalid scope patterns")
+		return ErrInternalServerError("Client scope configuration is invalid").WithOriginalError(err)
+	}
+	matched := matcher.Match(resourceURL)
+
+	if len(client.Endpoints) == 0 {
+		for _, pattern := range matched {
+			if slices.Contains(claims.Scope, pattern) {
+				return nil
+			}
+		}
+		log.Warn().
+			Str("client_id", claims.ClientID).
+			Str("resource", resourceURL).
+			Strs("allowed_scopes", claims.Scope).
+			Msg("Resource not in token scopes - access denied")
+		return ErrForbiddenError("Resource not in token scopes")
+	}
+
+	if method == "" {
+		log.Warn().Str("client_id", claims.ClientID).Str("resource", resourceURL).Msg("Missing request method for endpoint authorization")
+		return ErrBadRequest("Missing X-Forwarded-Method header (request method)")
+	}
+	method = strings.ToUpper(method)
+
+	resourceAllowed := false
+	for _, endpoint := range client.Endpoints {
+		if !slices.Contains(matched, endpoint.Url) || !slices.Contains(claims.Scope, endpoint.Scope) {
+			continue
+		}
+		resourceAllowed = true
+		if endpoint.Method == method || endpoint.Method == EndpointMethodAny {
+			return nil
+		}
+	}
+
+	if resourceAllowed {
+		log.Warn().
+			Str("client_id", claims.ClientID).
+			Str("resource", resourceURL).
+			Str("method", method).
+			Msg("Method not allowed for resource - access denied")
+		return ErrForbiddenError("Method not allowed for resource")
+	}
+
+	log.Warn().
+		Str("client_id", claims.ClientID).
+		Str("resource", resourceURL).
+		Str("method", method).
+		Msg("No endpoint rule matches resource - access denied")
+	return ErrForbiddenError("Resource not in token scopes")
+}
+
+// scopeMatcher returns the client's compiled scope and endpoint patterns, compiling them on first use
+// Concurrent first calls may compile twice; the result is identical either way
+func (c *Clients) scopeMatcher() (*scope.Matcher, error) {
+	if m := c.matcher.Load(); m != nil {
+		return m, nil
+	}
+
+	patterns := slices.Clone(c.AllowedScopes)
+	for _, endpoint := range c.Endpoints {
+		patterns = append(patterns, endpoint.Url)
+	}
+
+	m, err := scope.Compile(patterns, scope.Options{IgnoreQuery: AppConfig.Authorization.IgnoreQuery})
+	if err != nil {
+		return nil, err
+	}
+	c.matcher.Store(m)
+	return m, nil
+}
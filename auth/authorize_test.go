@@ -0,0 +1,139 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func newAuthorizeTestServer(t *testing.T, client *Clients) *authServer {
+	ctx, cancel := createTestContextFunc()
+	t.Cleanup(cancel)
+
+	cache := NewClientCache(time.Minute, 10)
+	t.Cleanup(cache.Stop)
+	cache.Set(client.ClientID, client)
+
+	return &authServer{
+		jwtSecret:   []byte("test-secret"),
+		ctx:         ctx,
+		cancel:      cancel,
+		clientCache: cache,
+	}
+}
+
+func TestAuthorizeRequest_ScopeOnly(t *testing.T) {
+	server := newAuthorizeTestServer(t, &Clients{
+		ClientID:      "client-1",
+		AllowedScopes: []string{"http://localhost:3000/api/users"},
+	})
+	claims := &Claims{ClientID: "client-1", Scope: []string{"http://localhost:3000/api/users"}}
+
+	// Without endpoint rules the method is not needed
+	if apiErr := server.authorizeRequest(context.Background(), claims, "", "http://localhost:3000/api/users"); apiErr != nil {
+		t.Errorf("Expected access granted, got %v", apiErr)
+	}
+
+	apiErr := server.authorizeRequest(context.Background(), claims, "GET", "http://localhost:3000/api/posts")
+	if apiErr == nil || apiErr.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected 403 for resource outside scopes, got %v", apiErr)
+	}
+}
+
+func TestAuthorizeRequest_EndpointRules(t *testing.T) {
+	users := "http://localhost:3000/api/users"
+	posts := "http://localhost:3000/api/posts"
+	server := newAuthorizeTestServer(t, &Clients{
+		ClientID:      "client-1",
+		AllowedScopes: []string{users, posts},
+		Endpoints: []Endpoints{
+			{ClientID: "client-1", Scope: users, Method: "GET", Url: users, Active: true},
+			{ClientID: "client-1", Scope: users, Method: "POST", Url: users, Active: true},
+			{ClientID: "client-1", Scope: posts, Method: EndpointMethodAny, Url: posts, Active: true},
+		},
+	})
+	claims := &Claims{ClientID: "client-1", Scope: []string{users, posts}}
+
+	tests := []struct {
+		name       string
+		method     string
+		resource   string
+		statusCode int
+	}{
+		{"allowed method", "GET", users, 0},
+		{"lowercase method", "post", users, 0},
+		{"denied method", "DELETE", users, http.StatusForbidden},
+		{"wildcard method", "DELETE", posts, 0},
+		{"unknown resource", "GET", "http://localhost:3000/api/admin", http.StatusForbidden},
+		{"missing method", "", users, http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			apiErr := server.authorizeRequest(context.Background(), claims, test.method, test.resource)
+			if test.statusCode == 0 {
+				if apiErr != nil {
+					t.Errorf("Expected access granted, got %v", apiErr)
+				}
+				return
+			}
+			if apiErr == nil || apiErr.StatusCode != test.statusCode {
+				t.Errorf("Expected status %d, got %v", test.statusCode, apiErr)
+			}
+		})
+	}
+}
+
+func TestAuthorizeRequest_EndpointScopeNotInToken(t *testing.T) {
+	users := "http://localhost:3000/api/users"
+	server := newAuthorizeTestServer(t, &Clients{
+		ClientID: "client-1",
+		Endpoints: []Endpoints{
+			{ClientID: "client-1", Scope: users, Method: "GET", Url: users, Active: true},
+		},
+	})
+
+	// The rule exists, but this token was not granted the rule's scope
+	claims := &Claims{ClientID: "client-1", Scope: []string{"http://localhost:3000/api/posts"}}
+	apiErr := server.authorizeRequest(context.Background(), claims, "GET", users)
+	if apiErr == nil || apiErr.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected 403, got %v", apiErr)
+	}
+}
+
+func TestAuthorizeRequest_PathTemplates(t *testing.T) {
+	server := newAuthorizeTestServer(t, &Clients{
+		ClientID:      "client-1",
+		AllowedScopes: []string{"http://localhost:3000/api/users/{id}", "http://localhost:3000/api/reports/**"},
+	})
+	claims := &Claims{ClientID: "client-1", Scope: []string{"http://localhost:3000/api/users/{id}"}}
+
+	if apiErr := server.authorizeRequest(context.Background(), claims, "GET", "http://LOCALHOST:3000/api/users/42"); apiErr != nil {
+		t.Errorf("Expected template scope to grant access, got %v", apiErr)
+	}
+
+	// The client may access reports, but this token was not granted that scope
+	if apiErr := server.authorizeRequest(context.Background(), claims, "GET", "http://localhost:3000/api/reports/2024"); apiErr == nil {
+		t.Errorf("Expected access denied for scope not in token")
+	}
+}
+
+func TestAuthorizeRequest_EndpointTemplates(t *testing.T) {
+	users := "http://localhost:3000/api/users/{id}"
+	server := newAuthorizeTestServer(t, &Clients{
+		ClientID:      "client-1",
+		AllowedScopes: []string{"users"},
+		Endpoints: []Endpoints{
+			{ClientID: "client-1", Scope: "users", Method: "GET", Url: users, Active: true},
+		},
+	})
+	claims := &Claims{ClientID: "client-1", Scope: []string{"users"}}
+
+	if apiErr := server.authorizeRequest(context.Background(), claims, "GET", "http://localhost:3000/api/users/7"); apiErr != nil {
+		t.Errorf("Expected access granted, got %v", apiErr)
+	}
+	if apiErr := server.authorizeRequest(context.Background(), claims, "DELETE", "http://localhost:3000/api/users/7"); apiErr == nil || apiErr.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected 403 for denied method, got %v", apiErr)
+	}
+}
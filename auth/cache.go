@@ -1,20 +1,26 @@
 package auth
 
 import (
+	"context"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // ClientCache provides in-memory caching for client credentials with thread-safe operations
 type ClientCache struct {
 	mu            sync.RWMutex
 	cache         map[string]*CachedClient
+	missing       map[string]time.Time // Client IDs with no client, to when that answer expires
 	ttl           time.Duration
 	maxSize       int
 	stats         CacheStatsAtomic
+	warm          atomic.Bool // Set once the startup warm-up has finished
 	cleanupTicker *time.Ticker
 	done          chan struct{}
 }
@@ -40,6 +46,16 @@ type CacheStats struct {
 	Evicted int64
 }
 
+// CacheEntryInfo describes a cached client without its secrets
+type CacheEntryInfo struct {
+	ClientID  string    `json:"client_id"`
+	Name      string    `json:"name"`
+	Active    bool      `json:"active"`
+	Secrets   int       `json:"secrets"` // Unexpired secrets cached for the client
+	CachedAt  time.Time `json:"cached_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
 // NewClientCache creates a new client cache instance with validation
 // Parameters: ttl - time-to-live for cached entries, maxSize - maximum number of clients to cache
 func NewClientCache(ttl time.Duration, maxSize int) *ClientCache {
@@ -55,6 +71,7 @@ func NewClientCache(ttl time.Duration, maxSize int) *ClientCache {
 
 	cc := &ClientCache{
 		cache:   make(map[string]*CachedClient),
+		missing: make(map[string]time.Time),
 		ttl:     ttl,
 		maxSize: maxSize,
 		done:    make(chan struct{}),
@@ -81,23 +98,23 @@ func NewClientCache(ttl time.Duration, maxSize int) *ClientCache {
 
 // Get retrieves a client from cache if it exists and hasn't expired
 // Returns the cached client and true if found and not expired, nil and false otherwise
-func (cc *ClientCache) Get(clientID string) (*Clients, bool) {
+func (cc *ClientCache) Get(ctx context.Context, clientID string) (*Clients, bool) {
+	_, span := tracer().Start(ctx, "ClientCache.Get", trace.WithAttributes(attrClientID.String(clientID)))
+	defer span.End()
+
 	cc.mu.RLock()
 	cached, exists := cc.cache[clientID]
 	cc.mu.RUnlock()
 
-	if !exists {
-		cc.stats.Misses.Add(1)
-		return nil, false
-	}
-
 	// Check if expired (do this outside lock to minimize lock contention)
-	if time.Now().After(cached.ExpiresAt) {
+	if !exists || time.Now().After(cached.ExpiresAt) {
 		cc.stats.Misses.Add(1)
+		span.SetAttributes(attribute.Bool("auth.cache_hit", false))
 		return nil, false
 	}
 
 	cc.stats.Hits.Add(1)
+	span.SetAttributes(attribute.Bool("auth.cache_hit", true))
 	return cached.Client, true
 }
 
@@ -123,11 +140,33 @@ func (cc *ClientCache) Set(clientID string, client *Clients) {
 	}
 }
 
+// SetMissing records that no client exists for clientID, for the same TTL as cached clients
+// Unknown IDs are then answered from memory too, so lookup timing does not reveal which IDs exist
+func (cc *ClientCache) SetMissing(clientID string) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	if _, exists := cc.missing[clientID]; !exists && len(cc.missing) >= cc.maxSize {
+		cc.evictOldestMissingLocked()
+	}
+	cc.missing[clientID] = time.Now().Add(cc.ttl)
+}
+
+// IsMissing reports whether clientID was recently looked up and found not to exist
+func (cc *ClientCache) IsMissing(clientID string) bool {
+	cc.mu.RLock()
+	expiresAt, exists := cc.missing[clientID]
+	cc.mu.RUnlock()
+
+	return exists && time.Now().Before(expiresAt)
+}
+
 // Invalidate removes a specific client from cache (useful for forced updates)
 func (cc *ClientCache) Invalidate(clientID string) {
 	cc.mu.Lock()
 	defer cc.mu.Unlock()
 
+	delete(cc.missing, clientID)
 	if _, exists := cc.cache[clientID]; exists {
 		delete(cc.cache, clientID)
 		log.Debug().Str("client_id", clientID).Msg("Client cache entry invalidated")
@@ -141,6 +180,7 @@ func (cc *ClientCache) Clear() {
 
 	oldSize := len(cc.cache)
 	cc.cache = make(map[string]*CachedClient)
+	cc.missing = make(map[string]time.Time)
 	log.Info().Int("cleared_entries", oldSize).Msg("Client cache cleared")
 }
 
@@ -153,6 +193,26 @@ func (cc *ClientCache) GetStats() CacheStats {
 	}
 }
 
+// Entries lists the cached clients by client ID, leaving out secrets
+func (cc *ClientCache) Entries() []CacheEntryInfo {
+	cc.mu.RLock()
+	entries := make([]CacheEntryInfo, 0, len(cc.cache))
+	for clientID, cached := range cc.cache {
+		entries = append(entries, CacheEntryInfo{
+			ClientID:  clientID,
+			Name:      cached.Client.Name,
+			Active:    cached.Client.Active,
+			Secrets:   len(cached.Client.Secrets),
+			CachedAt:  cached.CreatedAt,
+			ExpiresAt: cached.ExpiresAt,
+		})
+	}
+	cc.mu.RUnlock()
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ClientID < entries[j].ClientID })
+	return entries
+}
+
 // GetSize returns current number of entries in cache
 func (cc *ClientCache) GetSize() int {
 	cc.mu.RLock()
@@ -160,6 +220,16 @@ func (cc *ClientCache) GetSize() int {
 	return len(cc.cache)
 }
 
+// SetWarm records that the startup warm-up has finished
+func (cc *ClientCache) SetWarm() {
+	cc.warm.Store(true)
+}
+
+// IsWarm reports whether the startup warm-up has finished
+func (cc *ClientCache) IsWarm() bool {
+	return cc.warm.Load()
+}
+
 // GetHitRate returns cache hit rate percentage (0-100)
 func (cc *ClientCache) GetHitRate() float64 {
 	hits := cc.stats.Hits.Load()
@@ -201,6 +271,12 @@ func (cc *ClientCache) removeExpired() {
 			removed++
 		}
 	}
+	for clientID, expiresAt := range cc.missing {
+		if now.After(expiresAt) {
+			delete(cc.missing, clientID)
+			removed++
+		}
+	}
 
 	if removed > 0 {
 		log.Debug().
@@ -239,6 +315,18 @@ func (cc *ClientCache) evictOldestLocked() {
 	}
 }
 
+// evictOldestMissingLocked drops the unknown client ID that expires first (assumes lock is held)
+func (cc *ClientCache) evictOldestMissingLocked() {
+	var oldestID string
+	var oldestExpiry time.Time
+	for clientID, expiresAt := range cc.missing {
+		if oldestID == "" || expiresAt.Before(oldestExpiry) {
+			oldestID, oldestExpiry = clientID, expiresAt
+		}
+	}
+	delete(cc.missing, oldestID)
+}
+
 // Stop gracefully stops the cache cleanup goroutine
 func (cc *ClientCache) Stop() {
 	close(cc.done)
@@ -247,12 +335,35 @@ func (cc *ClientCache) Stop() {
 
 // TokenBatchWriter handles asynchronous batch insertion of tokens to reduce DB load
 type TokenBatchWriter struct {
-	mu         sync.Mutex
-	tokens     []Token
-	maxBatch   int
-	flushTick  *time.Ticker
-	done       chan struct{}
-	authServer *authServer
+	mu            sync.Mutex
+	tokens        []Token
+	maxBatch      int
+	flushInterval time.Duration
+	flushTick     *time.Ticker
+	done          chan struct{}
+	authServer    *authServer
+	stats         batchStatsAtomic
+}
+
+// batchStatsAtomic tracks batch writes with atomic operations (thread-safe)
+type batchStatsAtomic struct {
+	inFlight      atomic.Int64 // Batches being written
+	written       atomic.Int64 // Tokens written since startup
+	failed        atomic.Int64 // Tokens whose batch failed to write
+	lastFlushedAt atomic.Int64 // Unix nanoseconds of the last finished write; 0 before the first
+	lastError     atomic.Pointer[string]
+}
+
+// BatchWriterState is a snapshot of the batch writer for reporting
+type BatchWriterState struct {
+	Pending         int        `json:"pending"`
+	InFlight        int64      `json:"in_flight"`
+	MaxBatch        int        `json:"max_batch"`
+	FlushIntervalMS int64      `json:"flush_interval_ms"`
+	Written         int64      `json:"written"`
+	Failed          int64      `json:"failed"`
+	LastFlushedAt   *time.Time `json:"last_flushed_at,omitempty"`
+	LastError       string     `json:"last_error,omitempty"` // Error of the last write, empty if it succeeded
 }
 
 // NewTokenBatchWriter creates a new token batch writer with specified parameters
@@ -268,11 +379,12 @@ func NewTokenBatchWriter(as *authServer, maxBatch int, flushInterval time.Durati
 	}
 
 	tbw := &TokenBatchWriter{
-		tokens:     make([]Token, 0, maxBatch),
-		maxBatch:   maxBatch,
-		done:       make(chan struct{}),
-		authServer: as,
-		flushTick:  time.NewTicker(flushInterval),
+		tokens:        make([]Token, 0, maxBatch),
+		maxBatch:      maxBatch,
+		flushInterval: flushInterval,
+		done:          make(chan struct{}),
+		authServer:    as,
+		flushTick:     time.NewTicker(flushInterval),
 	}
 
 	// Start background flush goroutine
@@ -304,14 +416,16 @@ func (tbw *TokenBatchWriter) Add(token Token) {
 	}
 }
 
-// Flush immediately writes pending tokens to database (blocking)
-func (tbw *TokenBatchWriter) Flush() {
+// Flush immediately hands pending tokens to a database write, returning how many it handed over
+func (tbw *TokenBatchWriter) Flush() int {
 	tbw.mu.Lock()
 	defer tbw.mu.Unlock()
 
-	if len(tbw.tokens) > 0 {
+	pending := len(tbw.tokens)
+	if pending > 0 {
 		tbw.flushLockedAsync()
 	}
+	return pending
 }
 
 // flushLockedAsync flushes tokens asynchronously without acquiring lock (assumes lock is held)
@@ -326,8 +440,18 @@ func (tbw *TokenBatchWriter) flushLockedAsync() {
 	tbw.tokens = tbw.tokens[:0]
 
 	// Write to database asynchronously in separate goroutine
+	// The flush serves many requests, so it starts a trace of its own
+	tbw.stats.inFlight.Add(1)
 	go func() {
-		if err := tbw.authServer.insertTokenBatch(batch); err != nil {
+		defer tbw.stats.inFlight.Add(-1)
+		_, span := tracer().Start(context.Background(), "TokenBatchWriter.flush",
+			trace.WithAttributes(attribute.Int("auth.batch_size", len(batch))))
+		start := time.Now()
+		err := tbw.authServer.insertTokenBatch(batch)
+		tbw.authServer.metrics.batchFlushed(len(batch), time.Since(start), err)
+		endSpan(span, &err)
+		tbw.recordWrite(len(batch), err)
+		if err != nil {
 			log.Error().
 				Err(err).
 				Int("batch_size", len(batch)).
@@ -362,6 +486,39 @@ func (tbw *TokenBatchWriter) Stop() {
 	log.Info().Msg("Token batch writer stopped")
 }
 
+// recordWrite updates the batch statistics after a database write
+func (tbw *TokenBatchWriter) recordWrite(size int, err error) {
+	if err != nil {
+		tbw.stats.failed.Add(int64(size))
+		message := err.Error()
+		tbw.stats.lastError.Store(&message)
+	} else {
+		tbw.stats.written.Add(int64(size))
+		tbw.stats.lastError.Store(nil)
+	}
+	tbw.stats.lastFlushedAt.Store(time.Now().UnixNano())
+}
+
+// State returns a snapshot of the batch writer's queue and write history
+func (tbw *TokenBatchWriter) State() BatchWriterState {
+	state := BatchWriterState{
+		Pending:         tbw.GetPendingCount(),
+		InFlight:        tbw.stats.inFlight.Load(),
+		MaxBatch:        tbw.maxBatch,
+		FlushIntervalMS: tbw.flushInterval.Milliseconds(),
+		Written:         tbw.stats.written.Load(),
+		Failed:          tbw.stats.failed.Load(),
+	}
+	if at := tbw.stats.lastFlushedAt.Load(); at != 0 {
+		flushedAt := time.Unix(0, at)
+		state.LastFlushedAt = &flushedAt
+	}
+	if message := tbw.stats.lastError.Load(); message != nil {
+		state.LastError = *message
+	}
+	return state
+}
+
 // GetPendingCount returns number of tokens currently waiting for flush
 func (tbw *TokenBatchWriter) GetPendingCount() int {
 	tbw.mu.Lock()
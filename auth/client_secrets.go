@@ -0,0 +1,452 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Secret rotation errors
+var (
+	ErrSecretNotFound  = errors.New("client secret not found")
+	ErrSecretIsPrimary = errors.New("client secret is primary")
+	ErrSecretExpired   = errors.New("client secret has expired")
+)
+
+// Client secret audit actions
+const (
+	ClientAuditSecretAdded    = "client.secret_added"
+	ClientAuditSecretPromoted = "client.secret_promoted"
+	ClientAuditSecretExpired  = "client.secret_expired"
+)
+
+// secretUseInterval limits how often one instance writes a secret's last_used_at
+const secretUseInterval = time.Minute
+
+// initialSecretLabel labels the secret a client is created with
+const initialSecretLabel = "initial"
+
+// ClientSecret is one of a client's unexpired secrets, as loaded for verification
+type ClientSecret struct {
+	ID        int64
+	Hash      string // PHC-format hash, or a legacy plaintext secret
+	Primary   bool
+	ExpiresAt *time.Time
+
+	verified     atomic.Pointer[[sha256.Size]byte] // Keyed digest of the secret once it has verified
+	lastUseSaved atomic.Int64                      // Unix time this instance last wrote last_used_at
+}
+
+// expired reports whether the secret may no longer be used at now
+func (s *ClientSecret) expired(now time.Time) bool {
+	return s.ExpiresAt != nil && !now.Before(*s.ExpiresAt)
+}
+
+// ClientSecretInfo describes a client secret without revealing it
+type ClientSecretInfo struct {
+	ID         int64      `json:"id"`
+	ClientID   string     `json:"client_id"`
+	Label      string     `json:"label"`
+	Primary    bool       `json:"primary"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// clientSecretColumns are selected in scanClientSecretInfo order
+const clientSecretColumns = "id, client_id, label, is_primary, created_at, expires_at, last_used_at"
+
+// clientSecrets loads a client's unexpired secrets, primary first
+func (as *authServer) clientSecrets(clientID string) ([]*ClientSecret, error) {
+	ctx, cancel := context.WithTimeout(as.ctx, 5*time.Second)
+	defer cancel()
+
+	query := `SELECT id, secret_hash, is_primary, expires_at FROM client_secrets
+		WHERE client_id = :client_id AND (expires_at IS NULL OR expires_at > SYSTIMESTAMP)
+		ORDER BY is_primary DESC, created_at DESC`
+	rows, err := as.db.QueryContext(ctx, query, sql.Named("client_id", clientID))
+	if err != nil {
+		log.Error().Err(err).Str("client_id", clientID).Msg("Failed to query client secrets")
+		return nil, fmt.Errorf("clientSecrets %s: %v", clientID, err)
+	}
+	defer rows.Close()
+
+	var secrets []*ClientSecret
+	for rows.Next() {
+		var (
+			secret    ClientSecret
+			primary   int
+			expiresAt sql.NullTime
+		)
+		if err := rows.Scan(&secret.ID, &secret.Hash, &primary, &expiresAt); err != nil {
+			return nil, fmt.Errorf("clientSecrets %s: %v", clientID, err)
+		}
+		secret.Primary = primary == 1
+		if expiresAt.Valid {
+			secret.ExpiresAt = &expiresAt.Time
+		}
+		secrets = append(secrets, &secret)
+	}
+	return secrets, rows.Err()
+}
+
+// recordSecretUse writes a secret's last_used_at, at most once per secretUseInterval per instance
+func (as *authServer) recordSecretUse(clientID string, secret *ClientSecret, now time.Time) {
+	last := secret.lastUseSaved.Load()
+	if now.Sub(time.Unix(last, 0)) < secretUseInterval || !secret.lastUseSaved.CompareAndSwap(last, now.Unix()) {
+		return
+	}
+	if as.db == nil {
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(as.ctx, 5*time.Second)
+		defer cancel()
+
+		_, err := as.db.ExecContext(ctx, "UPDATE client_secrets SET last_used_at = :used_at WHERE id = :id",
+			sql.Named("used_at", now),
+			sql.Named("id", secret.ID))
+		if err != nil {
+			log.Warn().Err(err).Str("client_id", clientID).Int64("secret_id", secret.ID).Msg("Failed to record client secret use")
+		}
+	}()
+}
+
+// ListClientSecrets lists a client's secrets, including expired ones, primary first
+func (s *Store) ListClientSecrets(ctx context.Context, clientID string) ([]ClientSecretInfo, error) {
+	if _, err := s.GetClient(ctx, clientID); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := "SELECT " + clientSecretColumns + " FROM client_secrets WHERE client_id = :client_id ORDER BY is_primary DESC, created_at DESC"
+	rows, err := s.db.QueryContext(ctx, query, sql.Named("client_id", clientID))
+	if err != nil {
+		return nil, fmt.Errorf("listClientSecrets %s: %v", clientID, err)
+	}
+	defer rows.Close()
+
+	secrets := []ClientSecretInfo{}
+	for rows.Next() {
+		secret, err := scanClientSecretInfo(rows)
+		if err != nil {
+			return nil, fmt.Errorf("listClientSecrets %s: %v", clientID, err)
+		}
+		secrets = append(secrets, *secret)
+	}
+	return secrets, rows.Err()
+}
+
+// AddClientSecret stores a hash of an additional, non-primary secret; expiresAt may be nil
+func (s *Store) AddClientSecret(ctx context.Context, clientID, label string, expiresAt *time.Time, secret string, actor Actor) (*ClientSecretInfo, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	hash, err := HashClientSecret(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	current, err := s.lockClient(ctx, tx, clientID)
+	if err != nil {
+		return nil, err
+	}
+	if current.DeletedAt != nil {
+		return nil, ErrClientDeleted
+	}
+
+	id, err := insertClientSecret(ctx, tx, clientID, hash, label, false, expiresAt)
+	if err != nil {
+		return nil, err
+	}
+	added, err := lockClientSecret(ctx, tx, clientID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := map[string]FieldChange{"secret": {New: added}}
+	if err := s.commitClientChange(ctx, tx, clientID, ClientAuditSecretAdded, changes, actor); err != nil {
+		return nil, err
+	}
+	return added, nil
+}
+
+// PromoteClientSecret makes an unexpired secret the client's primary; the previous primary stays valid
+func (s *Store) PromoteClientSecret(ctx context.Context, clientID string, secretID int64, actor Actor) (*ClientSecretInfo, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	current, err := s.lockClient(ctx, tx, clientID)
+	if err != nil {
+		return nil, err
+	}
+	if current.DeletedAt != nil {
+		return nil, ErrClientDeleted
+	}
+	secret, err := lockClientSecret(ctx, tx, clientID, secretID)
+	if err != nil {
+		return nil, err
+	}
+	if secret.Primary {
+		return secret, nil
+	}
+	if secret.ExpiresAt != nil && !time.Now().Before(*secret.ExpiresAt) {
+		return nil, ErrSecretExpired
+	}
+
+	var previous sql.NullInt64
+	if err := tx.QueryRowContext(ctx, "SELECT MAX(id) FROM client_secrets WHERE client_id = :client_id AND is_primary = 1",
+		sql.Named("client_id", clientID)).Scan(&previous); err != nil {
+		return nil, fmt.Errorf("promoteClientSecret %s: %v", clientID, err)
+	}
+
+	query := `UPDATE client_secrets SET is_primary = CASE WHEN id = :id THEN 1 ELSE 0 END
+		WHERE client_id = :client_id AND (id = :id OR is_primary = 1)`
+	if _, err := tx.ExecContext(ctx, query, sql.Named("id", secretID), sql.Named("client_id", clientID)); err != nil {
+		log.Error().Err(err).Str("client_id", clientID).Int64("secret_id", secretID).Msg("Failed to promote client secret")
+		return nil, fmt.Errorf("promoteClientSecret %s: %v", clientID, err)
+	}
+
+	promoted, err := lockClientSecret(ctx, tx, clientID, secretID)
+	if err != nil {
+		return nil, err
+	}
+	changes := map[string]FieldChange{"primary_secret": {New: secretID}}
+	if previous.Valid {
+		changes["primary_secret"] = FieldChange{Old: previous.Int64, New: secretID}
+	}
+	if err := s.commitClientChange(ctx, tx, clientID, ClientAuditSecretPromoted, changes, actor); err != nil {
+		return nil, err
+	}
+	return promoted, nil
+}
+
+// ExpireClientSecret ends a non-primary secret's validity after grace; it never extends an earlier expiry
+func (s *Store) ExpireClientSecret(ctx context.Context, clientID string, secretID int64, grace time.Duration, actor Actor) (*ClientSecretInfo, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := s.lockClient(ctx, tx, clientID); err != nil {
+		return nil, err
+	}
+	secret, err := lockClientSecret(ctx, tx, clientID, secretID)
+	if err != nil {
+		return nil, err
+	}
+	if secret.Primary {
+		return nil, ErrSecretIsPrimary
+	}
+
+	expiresAt := time.Now().Add(max(grace, 0))
+	if secret.ExpiresAt != nil && !secret.ExpiresAt.After(expiresAt) {
+		return secret, nil
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE client_secrets SET expires_at = :expires_at WHERE id = :id",
+		sql.Named("expires_at", expiresAt),
+		sql.Named("id", secretID)); err != nil {
+		log.Error().Err(err).Str("client_id", clientID).Int64("secret_id", secretID).Msg("Failed to expire client secret")
+		return nil, fmt.Errorf("expireClientSecret %s: %v", clientID, err)
+	}
+
+	expired, err := lockClientSecret(ctx, tx, clientID, secretID)
+	if err != nil {
+		return nil, err
+	}
+	changes := map[string]FieldChange{fmt.Sprintf("secret.%d.expires_at", secretID): {Old: secret.ExpiresAt, New: expired.ExpiresAt}}
+	if err := s.commitClientChange(ctx, tx, clientID, ClientAuditSecretExpired, changes, actor); err != nil {
+		return nil, err
+	}
+	return expired, nil
+}
+
+// insertClientSecret adds a hashed secret inside tx and returns its ID
+func insertClientSecret(ctx context.Context, tx *sql.Tx, clientID, hash, label string, primary bool, expiresAt *time.Time) (int64, error) {
+	var id int64
+	query := `INSERT INTO client_secrets (client_id, secret_hash, label, is_primary, expires_at)
+		VALUES (:client_id, :secret_hash, :label, :is_primary, :expires_at)
+		RETURNING id INTO :id`
+	_, err := tx.ExecContext(ctx, query,
+		sql.Named("client_id", clientID),
+		sql.Named("secret_hash", hash),
+		sql.Named("label", label),
+		sql.Named("is_primary", boolToInt(primary)),
+		sql.Named("expires_at", optionalTime(expiresAt)),
+		sql.Named("id", sql.Out{Dest: &id}))
+	if err != nil {
+		log.Error().Err(err).Str("client_id", clientID).Msg("Failed to store client secret")
+		return 0, fmt.Errorf("insertClientSecret %s: %v", clientID, err)
+	}
+	return id, nil
+}
+
+// lockClientSecret reads one of a client's secrets inside tx, locking the row
+func lockClientSecret(ctx context.Context, tx *sql.Tx, clientID string, secretID int64) (*ClientSecretInfo, error) {
+	query := "SELECT " + clientSecretColumns + " FROM client_secrets WHERE id = :id AND client_id = :client_id FOR UPDATE"
+	secret, err := scanClientSecretInfo(tx.QueryRowContext(ctx, query, sql.Named("id", secretID), sql.Named("client_id", clientID)))
+	if err == sql.ErrNoRows {
+		return nil, ErrSecretNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("lockClientSecret %s/%d: %v", clientID, secretID, err)
+	}
+	return secret, nil
+}
+
+// scanClientSecretInfo reads a secret row selected with clientSecretColumns
+func scanClientSecretInfo(row interface{ Scan(...any) error }) (*ClientSecretInfo, error) {
+	var (
+		secret     ClientSecretInfo
+		label      sql.NullString
+		primary    int
+		createdAt  sql.NullTime
+		expiresAt  sql.NullTime
+		lastUsedAt sql.NullTime
+	)
+	if err := row.Scan(&secret.ID, &secret.ClientID, &label, &primary, &createdAt, &expiresAt, &lastUsedAt); err != nil {
+		return nil, err
+	}
+	secret.Label = label.String
+	secret.Primary = primary == 1
+	secret.CreatedAt = createdAt.Time
+	if expiresAt.Valid {
+		secret.ExpiresAt = &expiresAt.Time
+	}
+	if lastUsedAt.Valid {
+		secret.LastUsedAt = &lastUsedAt.Time
+	}
+	return &secret, nil
+}
+
+// expiringSecrets lists unexpired secrets of live clients that expire before horizon
+func (as *authServer) expiringSecrets(horizon time.Time) ([]ClientSecretInfo, error) {
+	ctx, cancel := context.WithTimeout(as.ctx, 10*time.Second)
+	defer cancel()
+
+	query := `SELECT cs.id, cs.client_id, cs.label, cs.is_primary, cs.created_at, cs.expires_at, cs.last_used_at
+		FROM client_secrets cs JOIN clients c ON c.client_id = cs.client_id
+		WHERE c.deleted_at IS NULL AND cs.expires_at > :now AND cs.expires_at <= :horizon
+		ORDER BY cs.expires_at`
+	rows, err := as.db.QueryContext(ctx, query, sql.Named("now", time.Now()), sql.Named("horizon", horizon))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var secrets []ClientSecretInfo
+	for rows.Next() {
+		secret, err := scanClientSecretInfo(rows)
+		if err != nil {
+			return nil, err
+		}
+		secrets = append(secrets, *secret)
+	}
+	return secrets, rows.Err()
+}
+
+// SecretExpiryMonitor periodically warns about client secrets that are about to expire
+type SecretExpiryMonitor struct {
+	checkTick  *time.Ticker
+	window     time.Duration
+	done       chan struct{}
+	authServer *authServer
+}
+
+// NewSecretExpiryMonitor creates a monitor and starts its background goroutine
+// Parameters: interval - time between checks, window - how far ahead expiring secrets are reported
+func NewSecretExpiryMonitor(as *authServer, interval, window time.Duration) *SecretExpiryMonitor {
+	if interval <= 0 {
+		log.Warn().Dur("interval", interval).Msg("Invalid secret expiry check interval, using default 1 hour")
+		interval = time.Hour
+	}
+
+	sm := &SecretExpiryMonitor{
+		checkTick:  time.NewTicker(interval),
+		window:     window,
+		done:       make(chan struct{}),
+		authServer: as,
+	}
+
+	go sm.backgroundCheck()
+
+	log.Info().
+		Str("check_interval", interval.String()).
+		Str("warning_window", window.String()).
+		Msg("Client secret expiry monitor initialized")
+
+	return sm
+}
+
+// backgroundCheck checks once at start and then on every tick until stopped
+func (sm *SecretExpiryMonitor) backgroundCheck() {
+	sm.check()
+	for {
+		select {
+		case <-sm.done:
+			sm.checkTick.Stop()
+			log.Debug().Msg("Client secret expiry monitor stopped")
+			return
+		case <-sm.checkTick.C:
+			sm.check()
+		}
+	}
+}
+
+// check logs a warning for every secret expiring within the window
+func (sm *SecretExpiryMonitor) check() {
+	now := time.Now()
+	secrets, err := sm.authServer.expiringSecrets(now.Add(sm.window))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to check client secret expiry")
+		return
+	}
+
+	for _, secret := range secrets {
+		event := log.Warn()
+		if secret.Primary {
+			// The client loses access entirely unless another secret is promoted in time
+			event = log.Error()
+		}
+		event.
+			Str("client_id", secret.ClientID).
+			Int64("secret_id", secret.ID).
+			Str("label", secret.Label).
+			Bool("primary", secret.Primary).
+			Time("expires_at", *secret.ExpiresAt).
+			Str("expires_in", secret.ExpiresAt.Sub(now).Round(time.Minute).String()).
+			Msg("Client secret expires soon")
+	}
+}
+
+// Stop stops the background check goroutine
+func (sm *SecretExpiryMonitor) Stop() {
+	close(sm.done)
+	log.Info().Msg("Client secret expiry monitor stopped")
+}
@@ -3,10 +3,12 @@ package auth
 import (
 	"errors"
 	"fmt"
+	"net"
 	"path/filepath"
 
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/viper"
+	"golang.org/x/crypto/bcrypt"
 )
 
 type (
@@ -32,20 +34,220 @@ type (
 		SecretKey       string `mapstructure:"secret_key,omitempty"`
 		AccessDuration  int    `mapstructure:"access_duration_minutes,omitempty"`
 		RefreshDuration int    `mapstructure:"refresh_duration_hours,omitempty"`
+		// TokenMode selects how issued tokens are tracked: "stateful" persists every
+		// token, "stateless" only records revoked JTIs in a denylist
+		TokenMode string `mapstructure:"token_mode,omitempty"`
+		// DenylistPurgeInterval is how often expired denylist entries are removed
+		DenylistPurgeInterval int `mapstructure:"denylist_purge_interval_seconds,omitempty"`
+		// SigningAlgorithm is "HS256" (shared secret) or "ES256" (key ring published at /jwks)
+		SigningAlgorithm string `mapstructure:"signing_algorithm,omitempty"`
+		// KeyGrace is how long a retired signing key keeps verifying tokens
+		KeyGrace int `mapstructure:"key_grace_minutes,omitempty"`
+		// Audience is set as the aud claim of issued tokens and required on validation when non-empty
+		Audience string `mapstructure:"audience,omitempty"`
+		// HS256Migration keeps accepting HS256 tokens for this long after an ES256 server starts,
+		// so tokens issued before the switch survive it; at most the access token TTL, zero rejects them
+		HS256Migration int `mapstructure:"hs256_migration_minutes,omitempty"`
+	}
+
+	// Event propagation configuration
+	events struct {
+		Transport    string `mapstructure:"transport,omitempty"`
+		PollInterval int    `mapstructure:"poll_interval_ms,omitempty"`
+		Retention    int    `mapstructure:"retention_minutes,omitempty"`
+		// Lookback is how long polls keep re-reading recent rows that may have committed late
+		Lookback int `mapstructure:"lookback_seconds,omitempty"`
+	}
+
+	// Authorization configuration
+	authorization struct {
+		// IgnoreQuery drops query strings before matching resource URLs against scope patterns
+		IgnoreQuery bool `mapstructure:"ignore_query"`
+	}
+
+	// Envoy ext_authz gRPC server configuration
+	extAuthz struct {
+		Enabled bool `mapstructure:"enabled"`
+		Port    int  `mapstructure:"port,omitempty"`
+	}
+
+	// Forward-auth configuration
+	forwardAuth struct {
+		DefaultProfile string                  `mapstructure:"default_profile,omitempty"`
+		Profiles       map[string]proxyProfile `mapstructure:"profiles"`
+	}
+
+	// proxyProfile names the headers a reverse proxy uses to describe the original request
+	// Header lists are tried in order; the first non-empty value wins
+	proxyProfile struct {
+		// URLHeaders carry the full original URL; when set, URI/host/proto headers are ignored
+		URLHeaders    []string `mapstructure:"url_headers"`
+		URIHeaders    []string `mapstructure:"uri_headers"`
+		MethodHeaders []string `mapstructure:"method_headers"`
+		HostHeaders   []string `mapstructure:"host_headers"`
+		ProtoHeaders  []string `mapstructure:"proto_headers"`
+		DefaultProto  string   `mapstructure:"default_proto,omitempty"`
+		// StatusOnly answers denials with a bare 401 or 403 instead of a JSON error body
+		StatusOnly bool `mapstructure:"status_only"`
+	}
+
+	// Admin API configuration
+	admin struct {
+		// Enabled serves the admin API, pprof and runtime inspection on Address
+		Enabled bool `mapstructure:"enabled"`
+		// Address is the admin listener's host:port; it must differ from the public server_port
+		Address string `mapstructure:"address,omitempty"`
+		// Scope must be granted to bearer tokens calling the admin API
+		Scope string `mapstructure:"scope,omitempty"`
+		// BootstrapToken is accepted in place of an admin token, to create the first admin client; empty disables it
+		BootstrapToken string `mapstructure:"bootstrap_token,omitempty"`
+		// Pprof serves Go profiles under /debug/pprof on the admin listener
+		Pprof bool `mapstructure:"pprof"`
+	}
+
+	// Client secret hashing configuration
+	clientSecrets struct {
+		// Algorithm hashes new and rehashed secrets: "argon2id" or "bcrypt"
+		Algorithm         string `mapstructure:"algorithm,omitempty"`
+		Argon2Memory      int    `mapstructure:"argon2_memory_kib,omitempty"`
+		Argon2Iterations  int    `mapstructure:"argon2_iterations,omitempty"`
+		Argon2Parallelism int    `mapstructure:"argon2_parallelism,omitempty"`
+		BcryptCost        int    `mapstructure:"bcrypt_cost,omitempty"`
+		// ExpiryWarning is how many days ahead expiring secrets are logged
+		ExpiryWarning int `mapstructure:"expiry_warning_days,omitempty"`
+		// ExpiryCheckInterval is how often secret expiry is checked
+		ExpiryCheckInterval int `mapstructure:"expiry_check_interval_minutes,omitempty"`
+	}
+
+	// Rate limiting configuration
+	rateLimit struct {
+		Enabled bool `mapstructure:"enabled"`
+		// Mode is "local" (each instance limits on its own) or "db" (hits are shared through the database)
+		Mode string `mapstructure:"mode,omitempty"`
+		// SyncInterval is how often db mode exchanges hits with other instances
+		SyncInterval int               `mapstructure:"sync_interval_ms,omitempty"`
+		Token        rateLimitEndpoint `mapstructure:"token"`
+		Validate     rateLimitEndpoint `mapstructure:"validate"`
+	}
+
+	// Token bucket limits for one endpoint; a zero rate disables that limit
+	rateLimitEndpoint struct {
+		// Client is the default per client ID; clients may override it
+		Client RateLimit `mapstructure:"client"`
+		IP     RateLimit `mapstructure:"ip"`
+	}
+
+	// Credential brute-force protection configuration
+	lockout struct {
+		Enabled bool `mapstructure:"enabled"`
+		// Failures within Window lock a client ID or source IP out for Duration
+		ClientThreshold int `mapstructure:"client_threshold,omitempty"`
+		IPThreshold     int `mapstructure:"ip_threshold,omitempty"`
+		Window          int `mapstructure:"window_minutes,omitempty"`
+		Duration        int `mapstructure:"duration_minutes,omitempty"`
+		// Each failure doubles the delay before the error is returned, starting at BaseDelay up to MaxDelay
+		BaseDelay int `mapstructure:"base_delay_ms,omitempty"`
+		MaxDelay  int `mapstructure:"max_delay_ms,omitempty"`
+	}
+
+	// Client address resolution
+	network struct {
+		// TrustedProxies are addresses or CIDRs whose client IP headers are believed
+		TrustedProxies []string `mapstructure:"trusted_proxies"`
+		// ClientIPHeaders name the headers trusted proxies put the client IP in, checked in order
+		ClientIPHeaders []string `mapstructure:"client_ip_headers"`
+	}
+
+	// Health and readiness checks
+	health struct {
+		// MaxDBLatency is the slowest database ping that still counts as ready
+		MaxDBLatency int `mapstructure:"max_db_latency_ms,omitempty"`
+		// MaxBatchBacklog is how many issued tokens may wait to be written before the instance is not ready
+		MaxBatchBacklog int `mapstructure:"max_batch_backlog,omitempty"`
+		// WarmClients is how many active clients are cached at startup before the instance is ready
+		WarmClients int `mapstructure:"warm_clients,omitempty"`
+		// DrainDelay is how long shutdown keeps serving while reporting not ready
+		DrainDelay int `mapstructure:"drain_delay_seconds,omitempty"`
+	}
+
+	// Security audit log
+	audit struct {
+		Enabled bool `mapstructure:"enabled"`
+		// Sinks are where entries are written: "file", "db" or both; each keeps its own hash chain
+		// Only the server writes the file, so every instance needs its own path; authctl writes to db alone
+		Sinks []string `mapstructure:"sinks"`
+		Path  string   `mapstructure:"path,omitempty"`
+		// FlushInterval is how often queued entries are written
+		FlushInterval int `mapstructure:"flush_interval_ms,omitempty"`
+		// MaxPending is how many entries a sink may have queued, e.g. while the database is down, before new ones are dropped
+		MaxPending int `mapstructure:"max_pending,omitempty"`
+	}
+
+	// Webhook event notifications
+	webhooks struct {
+		Enabled bool `mapstructure:"enabled"`
+		// Source is the CloudEvents source attribute of every event
+		Source       string `mapstructure:"source,omitempty"`
+		PollInterval int    `mapstructure:"poll_interval_ms,omitempty"`
+		Timeout      int    `mapstructure:"timeout_ms,omitempty"`
+		// A failed delivery is retried after BaseBackoff, doubling up to MaxBackoff, until MaxAttempts
+		MaxAttempts int `mapstructure:"max_attempts,omitempty"`
+		BaseBackoff int `mapstructure:"base_backoff_seconds,omitempty"`
+		MaxBackoff  int `mapstructure:"max_backoff_seconds,omitempty"`
+		// Retention is how long finished deliveries and their events are kept
+		Retention int `mapstructure:"retention_hours,omitempty"`
+	}
+
+	// OpenTelemetry tracing configuration
+	tracing struct {
+		Enabled bool `mapstructure:"enabled"`
+		// Exporter is "otlp" (gRPC to Endpoint) or "file" (spans appended to Path as JSON, for testing)
+		Exporter string `mapstructure:"exporter,omitempty"`
+		Endpoint string `mapstructure:"endpoint,omitempty"`
+		// Insecure sends OTLP without TLS, as to a collector on the same host
+		Insecure bool   `mapstructure:"insecure"`
+		Path     string `mapstructure:"path,omitempty"`
+		// SampleRatio is the share of new traces recorded; requests with a traceparent follow the caller's decision
+		SampleRatio float64 `mapstructure:"sample_ratio,omitempty"`
 	}
 
 	// Server configuration
 	configuration struct {
-		Version     string    `mapstructure:"version,omitempty"`
-		Logging     logging   `mapstructure:"logging"`
-		ServerPort  string    `mapstructure:"server_port"`
-		MetricPort  int       `mapstructure:"metric_port"`
-		Database    database  `mapstructure:"database"`
-		JWT         jwtConfig `mapstructure:"jwt"`
-		Environment string    `mapstructure:"environment,omitempty"`
+		Version       string        `mapstructure:"version,omitempty"`
+		Logging       logging       `mapstructure:"logging"`
+		ServerPort    string        `mapstructure:"server_port"`
+		MetricPort    int           `mapstructure:"metric_port"`
+		Database      database      `mapstructure:"database"`
+		JWT           jwtConfig     `mapstructure:"jwt"`
+		Events        events        `mapstructure:"events"`
+		Authorization authorization `mapstructure:"authorization"`
+		ExtAuthz      extAuthz      `mapstructure:"ext_authz"`
+		ForwardAuth   forwardAuth   `mapstructure:"forward_auth"`
+		Admin         admin         `mapstructure:"admin"`
+		ClientSecrets clientSecrets `mapstructure:"client_secrets"`
+		RateLimit     rateLimit     `mapstructure:"rate_limit"`
+		Lockout       lockout       `mapstructure:"lockout"`
+		Network       network       `mapstructure:"network"`
+		Health        health        `mapstructure:"health"`
+		Audit         audit         `mapstructure:"audit"`
+		Webhooks      webhooks      `mapstructure:"webhooks"`
+		Tracing       tracing       `mapstructure:"tracing"`
+		Environment   string        `mapstructure:"environment,omitempty"`
 	}
 )
 
+// Token tracking modes
+const (
+	TokenModeStateful  = "stateful"
+	TokenModeStateless = "stateless"
+)
+
+// Event transports
+const (
+	EventTransportDB    = "db"
+	EventTransportLocal = "local"
+)
+
 var (
 	AppConfig configuration
 )
@@ -108,6 +310,69 @@ func setDefaults() {
 	viper.SetDefault("database.timeout_seconds", 30)
 	viper.SetDefault("jwt.access_duration_minutes", 15)
 	viper.SetDefault("jwt.refresh_duration_hours", 24)
+	viper.SetDefault("jwt.token_mode", TokenModeStateful)
+	viper.SetDefault("jwt.denylist_purge_interval_seconds", 60)
+	viper.SetDefault("jwt.signing_algorithm", SigningAlgorithmHS256)
+	viper.SetDefault("jwt.key_grace_minutes", 60)
+	viper.SetDefault("events.transport", EventTransportDB)
+	viper.SetDefault("events.poll_interval_ms", 1000)
+	viper.SetDefault("events.retention_minutes", 60)
+	viper.SetDefault("events.lookback_seconds", 30)
+	viper.SetDefault("authorization.ignore_query", false)
+	viper.SetDefault("ext_authz.enabled", false)
+	viper.SetDefault("ext_authz.port", 9001)
+	viper.SetDefault("forward_auth.default_profile", ProxyProfileTraefik)
+	viper.SetDefault("admin.enabled", true)
+	viper.SetDefault("admin.address", DefaultAdminAddress)
+	viper.SetDefault("admin.scope", DefaultAdminScope)
+	viper.SetDefault("admin.pprof", true)
+	viper.SetDefault("client_secrets.algorithm", SecretAlgorithmArgon2id)
+	viper.SetDefault("client_secrets.argon2_memory_kib", DefaultArgon2MemoryKiB)
+	viper.SetDefault("client_secrets.argon2_iterations", DefaultArgon2Iterations)
+	viper.SetDefault("client_secrets.argon2_parallelism", DefaultArgon2Parallelism)
+	viper.SetDefault("client_secrets.bcrypt_cost", DefaultBcryptCost)
+	viper.SetDefault("client_secrets.expiry_warning_days", 14)
+	viper.SetDefault("client_secrets.expiry_check_interval_minutes", 60)
+	viper.SetDefault("rate_limit.enabled", true)
+	viper.SetDefault("rate_limit.mode", RateLimitModeLocal)
+	viper.SetDefault("rate_limit.sync_interval_ms", 1000)
+	viper.SetDefault("rate_limit.token.client.rate", 10)
+	viper.SetDefault("rate_limit.token.client.burst", 20)
+	viper.SetDefault("rate_limit.token.ip.rate", 50)
+	viper.SetDefault("rate_limit.token.ip.burst", 100)
+	viper.SetDefault("rate_limit.validate.client.rate", 500)
+	viper.SetDefault("rate_limit.validate.client.burst", 1000)
+	viper.SetDefault("lockout.enabled", true)
+	viper.SetDefault("lockout.client_threshold", 10)
+	viper.SetDefault("lockout.ip_threshold", 50)
+	viper.SetDefault("lockout.window_minutes", 15)
+	viper.SetDefault("lockout.duration_minutes", 15)
+	viper.SetDefault("lockout.base_delay_ms", 250)
+	viper.SetDefault("lockout.max_delay_ms", 4000)
+	viper.SetDefault("network.client_ip_headers", []string{"X-Real-IP"})
+	viper.SetDefault("health.max_db_latency_ms", 500)
+	viper.SetDefault("health.max_batch_backlog", 10000)
+	viper.SetDefault("health.warm_clients", 1000)
+	viper.SetDefault("health.drain_delay_seconds", 5)
+	viper.SetDefault("audit.enabled", true)
+	viper.SetDefault("audit.sinks", []string{AuditSinkFile})
+	viper.SetDefault("audit.path", "./logs/audit.log")
+	viper.SetDefault("audit.flush_interval_ms", 200)
+	viper.SetDefault("audit.max_pending", 10000)
+	viper.SetDefault("webhooks.enabled", false)
+	viper.SetDefault("webhooks.source", "auth-server")
+	viper.SetDefault("webhooks.poll_interval_ms", 1000)
+	viper.SetDefault("webhooks.timeout_ms", 5000)
+	viper.SetDefault("webhooks.max_attempts", 10)
+	viper.SetDefault("webhooks.base_backoff_seconds", 10)
+	viper.SetDefault("webhooks.max_backoff_seconds", 3600)
+	viper.SetDefault("webhooks.retention_hours", 168)
+	viper.SetDefault("tracing.enabled", false)
+	viper.SetDefault("tracing.exporter", TracingExporterOTLP)
+	viper.SetDefault("tracing.endpoint", "localhost:4317")
+	viper.SetDefault("tracing.insecure", true)
+	viper.SetDefault("tracing.path", "./logs/traces.json")
+	viper.SetDefault("tracing.sample_ratio", 1.0)
 }
 
 func validateConfiguration() error {
@@ -123,6 +388,119 @@ func validateConfiguration() error {
 		return errors.New("logging.max_size_mb must be greater than 0")
 	}
 
+	switch AppConfig.JWT.TokenMode {
+	case "", TokenModeStateful, TokenModeStateless:
+	default:
+		return fmt.Errorf("jwt.token_mode must be %q or %q", TokenModeStateful, TokenModeStateless)
+	}
+
+	switch AppConfig.JWT.SigningAlgorithm {
+	case "", SigningAlgorithmHS256, SigningAlgorithmES256:
+	default:
+		return fmt.Errorf("jwt.signing_algorithm must be %q or %q", SigningAlgorithmHS256, SigningAlgorithmES256)
+	}
+	if m := AppConfig.JWT.HS256Migration; m < 0 || (AppConfig.JWT.AccessDuration > 0 && m > AppConfig.JWT.AccessDuration) {
+		return errors.New("jwt.hs256_migration_minutes must be between 0 and jwt.access_duration_minutes")
+	}
+
+	switch AppConfig.Events.Transport {
+	case "", EventTransportDB, EventTransportLocal:
+	default:
+		return fmt.Errorf("events.transport must be %q or %q", EventTransportDB, EventTransportLocal)
+	}
+	if AppConfig.Events.Lookback < 0 {
+		return errors.New("events.lookback_seconds must not be negative")
+	}
+
+	switch AppConfig.ClientSecrets.Algorithm {
+	case "", SecretAlgorithmArgon2id, SecretAlgorithmBcrypt:
+	default:
+		return fmt.Errorf("client_secrets.algorithm must be %q or %q", SecretAlgorithmArgon2id, SecretAlgorithmBcrypt)
+	}
+	if cost := AppConfig.ClientSecrets.BcryptCost; cost != 0 && (cost < bcrypt.MinCost || cost > bcrypt.MaxCost) {
+		return fmt.Errorf("client_secrets.bcrypt_cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+	}
+	if p := AppConfig.ClientSecrets.Argon2Parallelism; p < 0 || p > 255 {
+		return errors.New("client_secrets.argon2_parallelism must be between 1 and 255")
+	}
+	if AppConfig.ClientSecrets.Argon2Memory < 0 || AppConfig.ClientSecrets.Argon2Iterations < 0 {
+		return errors.New("client_secrets argon2 parameters must be positive")
+	}
+
+	switch AppConfig.RateLimit.Mode {
+	case "", RateLimitModeLocal, RateLimitModeDB:
+	default:
+		return fmt.Errorf("rate_limit.mode must be %q or %q", RateLimitModeLocal, RateLimitModeDB)
+	}
+	for name, limit := range map[string]RateLimit{
+		"token.client":    AppConfig.RateLimit.Token.Client,
+		"token.ip":        AppConfig.RateLimit.Token.IP,
+		"validate.client": AppConfig.RateLimit.Validate.Client,
+		"validate.ip":     AppConfig.RateLimit.Validate.IP,
+	} {
+		if !limit.valid() {
+			return fmt.Errorf("rate_limit.%s needs a positive rate for its burst, and neither may be negative", name)
+		}
+	}
+
+	if l := AppConfig.Lockout; l.ClientThreshold < 0 || l.IPThreshold < 0 || l.Window < 0 || l.Duration < 0 || l.BaseDelay < 0 || l.MaxDelay < 0 {
+		return errors.New("lockout thresholds, durations and delays must not be negative")
+	}
+
+	if _, err := parseCIDRs(AppConfig.Network.TrustedProxies); err != nil {
+		return fmt.Errorf("network.trusted_proxies: %w", err)
+	}
+
+	if h := AppConfig.Health; h.MaxDBLatency < 0 || h.MaxBatchBacklog < 0 || h.WarmClients < 0 || h.DrainDelay < 0 {
+		return errors.New("health limits and delays must not be negative")
+	}
+
+	for _, sink := range AppConfig.Audit.Sinks {
+		if sink != AuditSinkFile && sink != AuditSinkDB {
+			return fmt.Errorf("audit.sinks may only contain %q and %q", AuditSinkFile, AuditSinkDB)
+		}
+	}
+	if a := AppConfig.Audit; a.FlushInterval < 0 || a.MaxPending < 0 {
+		return errors.New("audit.flush_interval_ms and audit.max_pending must not be negative")
+	}
+
+	if w := AppConfig.Webhooks; w.PollInterval < 0 || w.Timeout < 0 || w.MaxAttempts < 0 || w.BaseBackoff < 0 || w.MaxBackoff < 0 || w.Retention < 0 {
+		return errors.New("webhooks intervals, attempts, backoffs and retention must not be negative")
+	}
+
+	if addr := AppConfig.Admin.Address; addr != "" {
+		_, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return fmt.Errorf("admin.address must be host:port: %w", err)
+		}
+		if port == AppConfig.ServerPort || (AppConfig.ServerPort == "" && port == "8080") {
+			return errors.New("admin.address must not use the public server_port")
+		}
+	}
+	if token := AppConfig.Admin.BootstrapToken; token != "" && len(token) < minBootstrapTokenLength {
+		return fmt.Errorf("admin.bootstrap_token must be at least %d characters", minBootstrapTokenLength)
+	}
+
+	switch AppConfig.Tracing.Exporter {
+	case "", TracingExporterOTLP, TracingExporterFile:
+	default:
+		return fmt.Errorf("tracing.exporter must be %q or %q", TracingExporterOTLP, TracingExporterFile)
+	}
+	if r := AppConfig.Tracing.SampleRatio; r < 0 || r > 1 {
+		return errors.New("tracing.sample_ratio must be between 0 and 1")
+	}
+
+	for name, profile := range AppConfig.ForwardAuth.Profiles {
+		if len(profile.URLHeaders) == 0 && len(profile.URIHeaders) == 0 {
+			return fmt.Errorf("forward_auth.profiles.%s needs url_headers or uri_headers", name)
+		}
+	}
+	if name := AppConfig.ForwardAuth.DefaultProfile; name != "" {
+		if _, exists := lookupProxyProfile(name); !exists {
+			return fmt.Errorf("forward_auth.default_profile %q is not a known proxy profile", name)
+		}
+	}
+
 	return nil
 }
 
@@ -163,6 +541,176 @@ func applyDefaults() error {
 	if AppConfig.JWT.RefreshDuration == 0 {
 		AppConfig.JWT.RefreshDuration = 24
 	}
+	if AppConfig.JWT.TokenMode == "" {
+		AppConfig.JWT.TokenMode = TokenModeStateful
+	}
+	if AppConfig.JWT.DenylistPurgeInterval == 0 {
+		AppConfig.JWT.DenylistPurgeInterval = 60
+	}
+	if AppConfig.JWT.SigningAlgorithm == "" {
+		AppConfig.JWT.SigningAlgorithm = SigningAlgorithmHS256
+	}
+	if AppConfig.JWT.KeyGrace == 0 {
+		AppConfig.JWT.KeyGrace = 60
+	}
+
+	// Apply event propagation defaults
+	if AppConfig.Events.Transport == "" {
+		AppConfig.Events.Transport = EventTransportDB
+	}
+	if AppConfig.Events.PollInterval == 0 {
+		AppConfig.Events.PollInterval = 1000
+	}
+	if AppConfig.Events.Retention == 0 {
+		AppConfig.Events.Retention = 60
+	}
+	if AppConfig.Events.Lookback == 0 {
+		AppConfig.Events.Lookback = 30
+	}
+
+	// Apply forward-auth defaults
+	if AppConfig.ForwardAuth.DefaultProfile == "" {
+		AppConfig.ForwardAuth.DefaultProfile = ProxyProfileTraefik
+	}
+
+	// Apply ext_authz defaults
+	if AppConfig.ExtAuthz.Port == 0 {
+		AppConfig.ExtAuthz.Port = 9001
+	}
+
+	// Apply admin API defaults
+	if AppConfig.Admin.Address == "" {
+		AppConfig.Admin.Address = DefaultAdminAddress
+	}
+	if AppConfig.Admin.Scope == "" {
+		AppConfig.Admin.Scope = DefaultAdminScope
+	}
+
+	// Apply client secret hashing defaults
+	if AppConfig.ClientSecrets.Algorithm == "" {
+		AppConfig.ClientSecrets.Algorithm = SecretAlgorithmArgon2id
+	}
+	if AppConfig.ClientSecrets.Argon2Memory == 0 {
+		AppConfig.ClientSecrets.Argon2Memory = DefaultArgon2MemoryKiB
+	}
+	if AppConfig.ClientSecrets.Argon2Iterations == 0 {
+		AppConfig.ClientSecrets.Argon2Iterations = DefaultArgon2Iterations
+	}
+	if AppConfig.ClientSecrets.Argon2Parallelism == 0 {
+		AppConfig.ClientSecrets.Argon2Parallelism = DefaultArgon2Parallelism
+	}
+	if AppConfig.ClientSecrets.BcryptCost == 0 {
+		AppConfig.ClientSecrets.BcryptCost = DefaultBcryptCost
+	}
+	if AppConfig.ClientSecrets.ExpiryWarning == 0 {
+		AppConfig.ClientSecrets.ExpiryWarning = 14
+	}
+	if AppConfig.ClientSecrets.ExpiryCheckInterval == 0 {
+		AppConfig.ClientSecrets.ExpiryCheckInterval = 60
+	}
+
+	// Apply rate limiting defaults
+	if AppConfig.RateLimit.Mode == "" {
+		AppConfig.RateLimit.Mode = RateLimitModeLocal
+	}
+	if AppConfig.RateLimit.SyncInterval == 0 {
+		AppConfig.RateLimit.SyncInterval = 1000
+	}
+
+	// Apply lockout defaults; an explicit zero threshold turns that lockout off
+	if AppConfig.Lockout.ClientThreshold == 0 && !viper.IsSet("lockout.client_threshold") {
+		AppConfig.Lockout.ClientThreshold = 10
+	}
+	if AppConfig.Lockout.IPThreshold == 0 && !viper.IsSet("lockout.ip_threshold") {
+		AppConfig.Lockout.IPThreshold = 50
+	}
+	if AppConfig.Lockout.Window == 0 {
+		AppConfig.Lockout.Window = 15
+	}
+	if AppConfig.Lockout.Duration == 0 {
+		AppConfig.Lockout.Duration = 15
+	}
+	if AppConfig.Lockout.BaseDelay == 0 {
+		AppConfig.Lockout.BaseDelay = 250
+	}
+	if AppConfig.Lockout.MaxDelay == 0 {
+		AppConfig.Lockout.MaxDelay = 4000
+	}
+
+	// Apply client address defaults
+	if AppConfig.Network.ClientIPHeaders == nil {
+		AppConfig.Network.ClientIPHeaders = []string{"X-Real-IP"}
+	}
+	resolver, err := newClientIPResolver(AppConfig.Network)
+	if err != nil {
+		return err
+	}
+	clientIPs = resolver
+
+	// Apply health check defaults
+	if AppConfig.Health.MaxDBLatency == 0 {
+		AppConfig.Health.MaxDBLatency = 500
+	}
+	if AppConfig.Health.MaxBatchBacklog == 0 {
+		AppConfig.Health.MaxBatchBacklog = 10000
+	}
+	if AppConfig.Health.WarmClients == 0 {
+		AppConfig.Health.WarmClients = 1000
+	}
+	if AppConfig.Health.DrainDelay == 0 {
+		AppConfig.Health.DrainDelay = 5
+	}
+
+	// Apply audit defaults
+	if AppConfig.Audit.Sinks == nil {
+		AppConfig.Audit.Sinks = []string{AuditSinkFile}
+	}
+	if AppConfig.Audit.Path == "" {
+		AppConfig.Audit.Path = "./logs/audit.log"
+	}
+	if AppConfig.Audit.FlushInterval == 0 {
+		AppConfig.Audit.FlushInterval = 200
+	}
+	if AppConfig.Audit.MaxPending == 0 {
+		AppConfig.Audit.MaxPending = 10000
+	}
+
+	// Apply webhook defaults
+	if AppConfig.Webhooks.Source == "" {
+		AppConfig.Webhooks.Source = "auth-server"
+	}
+	if AppConfig.Webhooks.PollInterval == 0 {
+		AppConfig.Webhooks.PollInterval = 1000
+	}
+	if AppConfig.Webhooks.Timeout == 0 {
+		AppConfig.Webhooks.Timeout = 5000
+	}
+	if AppConfig.Webhooks.MaxAttempts == 0 {
+		AppConfig.Webhooks.MaxAttempts = 10
+	}
+	if AppConfig.Webhooks.BaseBackoff == 0 {
+		AppConfig.Webhooks.BaseBackoff = 10
+	}
+	if AppConfig.Webhooks.MaxBackoff == 0 {
+		AppConfig.Webhooks.MaxBackoff = 3600
+	}
+	if AppConfig.Webhooks.Retention == 0 {
+		AppConfig.Webhooks.Retention = 168
+	}
+
+	// Apply tracing defaults
+	if AppConfig.Tracing.Exporter == "" {
+		AppConfig.Tracing.Exporter = TracingExporterOTLP
+	}
+	if AppConfig.Tracing.Endpoint == "" {
+		AppConfig.Tracing.Endpoint = "localhost:4317"
+	}
+	if AppConfig.Tracing.Path == "" {
+		AppConfig.Tracing.Path = "./logs/traces.json"
+	}
+	if AppConfig.Tracing.SampleRatio == 0 {
+		AppConfig.Tracing.SampleRatio = 1
+	}
 
 	return nil
 }
@@ -198,3 +198,76 @@ func TestConfigurationDefaults(t *testing.T) {
 		t.Errorf("Database host default not applied")
 	}
 }
+
+func TestReadConfiguration_InvalidTokenMode(t *testing.T) {
+	tmpDir := t.TempDir()
+	configDir := filepath.Join(tmpDir, "config")
+	os.MkdirAll(configDir, 0755)
+
+	configPath := filepath.Join(configDir, "auth-server-config.json")
+	configData := map[string]interface{}{
+		"version":     "1.0.0",
+		"server_port": "8080",
+		"logging": map[string]interface{}{
+			"level":       -1,
+			"path":        "./logs/test.log",
+			"max_size_mb": 100,
+		},
+		"jwt": map[string]interface{}{
+			"token_mode": "sometimes",
+		},
+	}
+
+	data, _ := json.Marshal(configData)
+	os.WriteFile(configPath, data, 0644)
+
+	oldWd, _ := os.Getwd()
+	os.Chdir(tmpDir)
+	defer os.Chdir(oldWd)
+
+	oldConfig := AppConfig
+	defer func() { AppConfig = oldConfig }()
+
+	err := ReadConfiguration()
+	if err == nil {
+		t.Errorf("Expected error for invalid jwt.token_mode, got nil")
+	}
+}
+
+func TestReadConfiguration_ZeroLockoutThreshold(t *testing.T) {
+	tmpDir := t.TempDir()
+	configDir := filepath.Join(tmpDir, "config")
+	os.MkdirAll(configDir, 0755)
+
+	configPath := filepath.Join(configDir, "auth-server-config.json")
+	configData := map[string]interface{}{
+		"version":     "1.0.0",
+		"server_port": "8080",
+		"logging": map[string]interface{}{
+			"level":       -1,
+			"path":        "./logs/test.log",
+			"max_size_mb": 100,
+		},
+		"lockout": map[string]interface{}{
+			"client_threshold": 0,
+		},
+	}
+
+	data, _ := json.Marshal(configData)
+	os.WriteFile(configPath, data, 0644)
+
+	oldWd, _ := os.Getwd()
+	os.Chdir(tmpDir)
+	defer os.Chdir(oldWd)
+
+	oldConfig := AppConfig
+	defer func() { AppConfig = oldConfig }()
+
+	if err := ReadConfiguration(); err != nil {
+		t.Fatalf("ReadConfiguration failed: %v", err)
+	}
+	// An explicit zero turns client lockout off; the unset IP threshold gets its default
+	if AppConfig.Lockout.ClientThreshold != 0 || AppConfig.Lockout.IPThreshold != 50 {
+		t.Errorf("Expected thresholds 0 and 50, got %d and %d", AppConfig.Lockout.ClientThreshold, AppConfig.Lockout.IPThreshold)
+	}
+}
@@ -4,14 +4,19 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
 
 	_ "github.com/godror/godror"
 	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// errNoSuchClient is returned by clientByID for unknown and deleted clients
+var errNoSuchClient = errors.New("no such client")
+
 // newDbClient creates a new Oracle database connection
 // Connection string format: user/password@hostname:port/sid
 // Example: sys/Oracle123@localhost:1521/XE (with as sysdba)
@@ -43,6 +48,10 @@ func newDbClient(url string) (*sql.DB, error) {
 }
 
 func (as *authServer) revokeToken(revokedToken RevokedToken) error {
+	if as.stateless {
+		return as.denylistToken(revokedToken)
+	}
+
 	ctx, cancel := context.WithTimeout(as.ctx, 5*time.Second)
 	defer cancel()
 
@@ -64,6 +73,9 @@ func (as *authServer) revokeToken(revokedToken RevokedToken) error {
 		log.Error().Err(err).Str("token_id", revokedToken.TokenID).Msg("Failed to revoke token")
 		return err
 	}
+	if err := enqueueWebhook(ctx, tx, WebhookTokenRevoked, revokedToken.ClientID, revokedToken); err != nil {
+		return err
+	}
 
 	// Commit the transaction.
 	if err = tx.Commit(); err != nil {
@@ -76,7 +88,10 @@ func (as *authServer) revokeToken(revokedToken RevokedToken) error {
 	return nil
 }
 
-func (as *authServer) isTokenRevoked(tokenID string) (bool, error) {
+func (as *authServer) isTokenRevoked(ctx context.Context, tokenID string) (_ bool, err error) {
+	_, span := tracer().Start(ctx, "authServer.isTokenRevoked", trace.WithAttributes(attrTokenID.String(tokenID)))
+	defer endSpan(span, &err)
+
 	var revoked int
 	ctx, cancel := context.WithTimeout(as.ctx, 5*time.Second)
 	defer cancel()
@@ -129,21 +144,38 @@ func (as *authServer) insertToken(tokenInfo Token) error {
 	return nil
 }
 
-// func (as *authServer) getEndpoint(clientID, scope string) (*Endpoints, error) {
-// 	ctx, cancel := context.WithTimeout(as.ctx, 5*time.Second)
-// 	defer cancel()
-// 	var endpoint Endpoints
-// 	query := "select client_id, scope, method, endpoint_url, active from endpoints where client_id=:1 and scope=:2"
-// 	row := as.db.QueryRowContext(ctx, query, strings.TrimSpace(clientID), strings.TrimSpace(scope))
-// 	if err := row.Scan(&endpoint.ClientID, &endpoint.Scope, &endpoint.Method, &endpoint.Url, &endpoint.Active); err != nil {
-// 		if err == sql.ErrNoRows {
-// 			log.Error().Err(err)
-// 			return &endpoint, fmt.Errorf("clientByID %s: no such client", clientID)
-// 		}
-// 		return &endpoint, fmt.Errorf("clientByID %s: %v", clientID, err)
-// 	}
-// 	return &endpoint, nil
-// }
+// clientEndpoints loads the active endpoint rules for a client
+func (as *authServer) clientEndpoints(clientID string) ([]Endpoints, error) {
+	ctx, cancel := context.WithTimeout(as.ctx, 5*time.Second)
+	defer cancel()
+
+	query := "SELECT client_id, scope, method, endpoint_url, description FROM endpoints WHERE client_id = :client_id AND active = 1"
+	rows, err := as.db.QueryContext(ctx, query, sql.Named("client_id", strings.TrimSpace(clientID)))
+	if err != nil {
+		log.Error().Err(err).Str("client_id", clientID).Msg("Endpoint query failed")
+		return nil, fmt.Errorf("clientEndpoints %s: %v", clientID, err)
+	}
+	defer rows.Close()
+
+	var endpoints []Endpoints
+	for rows.Next() {
+		var endpoint Endpoints
+		var description sql.NullString
+		if err := rows.Scan(&endpoint.ClientID, &endpoint.Scope, &endpoint.Method, &endpoint.Url, &description); err != nil {
+			log.Error().Err(err).Str("client_id", clientID).Msg("Failed to scan endpoint row")
+			return nil, fmt.Errorf("clientEndpoints %s: %v", clientID, err)
+		}
+		endpoint.Description = description.String
+		endpoint.Method = strings.ToUpper(strings.TrimSpace(endpoint.Method))
+		endpoint.Active = true
+		endpoints = append(endpoints, endpoint)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("clientEndpoints %s: %v", clientID, err)
+	}
+
+	return endpoints, nil
+}
 
 func (as *authServer) getClientScopes(clientID string) ([]string, error) {
 	log.Debug().Str("client_id", clientID).Msg("Fetching client scopes from database")
@@ -174,21 +206,33 @@ func (as *authServer) getClientScopes(clientID string) ([]string, error) {
 	return scope, nil
 }
 
-func (as *authServer) clientByID(clientID string) (*Clients, error) {
+func (as *authServer) clientByID(ctx context.Context, clientID string) (_ *Clients, err error) {
+	_, span := tracer().Start(ctx, "authServer.clientByID", trace.WithAttributes(attrClientID.String(clientID)))
+	defer endSpan(span, &err)
+
 	ctx, cancel := context.WithTimeout(as.ctx, 5*time.Second)
 	defer cancel()
 	log.Debug().Str("client_id", clientID).Msg("Looking up client in database")
 
 	var client Clients
 	var scope string
-	var err error
-	query := "SELECT client_id, client_secret, access_token_ttl, allowed_scopes FROM clients WHERE client_id = :client_id"
+	var active int
+	var validFrom, validUntil sql.NullTime
+	var tokenRate, validateRate sql.NullFloat64
+	var tokenBurst, validateBurst sql.NullInt64
+	var cidrs sql.NullString
+	var cidrsOnUse, networkClaim int
+	query := `SELECT client_id, access_token_ttl, allowed_scopes, NVL(active, 1), valid_from, valid_until,
+			token_rate, token_burst, validate_rate, validate_burst,
+			allowed_cidrs, NVL(cidrs_on_use, 0), NVL(network_claim, 0)
+		FROM clients WHERE client_id = :client_id AND deleted_at IS NULL`
 	row := as.db.QueryRowContext(ctx, query, sql.Named("client_id", clientID))
 
-	if err := row.Scan(&client.ClientID, &client.ClientSecret, &client.AccessTokenTTL, &scope); err != nil {
+	if err := row.Scan(&client.ClientID, &client.AccessTokenTTL, &scope, &active, &validFrom, &validUntil,
+		&tokenRate, &tokenBurst, &validateRate, &validateBurst, &cidrs, &cidrsOnUse, &networkClaim); err != nil {
 		if err == sql.ErrNoRows {
 			log.Warn().Str("client_id", clientID).Msg("Client not found in database")
-			return &client, fmt.Errorf("clientByID %s: no such client", clientID)
+			return &client, fmt.Errorf("clientByID %s: %w", clientID, errNoSuchClient)
 		}
 		log.Error().Err(err).Str("client_id", clientID).Msg("Database query failed")
 		return &client, fmt.Errorf("clientByID %s: %v", clientID, err)
@@ -199,6 +243,42 @@ func (as *authServer) clientByID(clientID string) (*Clients, error) {
 		log.Error().Err(err).Str("client_id", clientID).Msg("Failed to parse allowed scopes")
 		return nil, err
 	}
+	client.Active = active == 1
+	if validFrom.Valid {
+		client.ValidFrom = &validFrom.Time
+	}
+	if validUntil.Valid {
+		client.ValidUntil = &validUntil.Time
+	}
+	client.TokenRateLimit = scanRateLimit(tokenRate, tokenBurst)
+	client.ValidateRateLimit = scanRateLimit(validateRate, validateBurst)
+	client.EnforceCIDRsOnUse = cidrsOnUse == 1
+	client.NetworkClaim = networkClaim == 1
+
+	allowedCIDRs, err := parseStringArray(cidrs.String)
+	if err != nil {
+		log.Error().Err(err).Str("client_id", clientID).Msg("Failed to parse allowed CIDRs")
+		return nil, err
+	}
+	if client.AllowedCIDRs, err = parseCIDRs(allowedCIDRs); err != nil {
+		log.Error().Err(err).Str("client_id", clientID).Msg("Client has invalid allowed CIDRs")
+		return nil, err
+	}
+
+	client.Endpoints, err = as.clientEndpoints(clientID)
+	if err != nil {
+		return nil, err
+	}
+
+	client.Secrets, err = as.clientSecrets(clientID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Compile patterns now so cached lookups never pay for it
+	if _, err := client.scopeMatcher(); err != nil {
+		log.Warn().Err(err).Str("client_id", clientID).Msg("Client has invalid scope patterns")
+	}
 
 	log.Debug().Str("client_id", clientID).Strs("allowed_scopes", client.AllowedScopes).Msg("Client found and scopes parsed")
 	return &client, nil
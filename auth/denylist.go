@@ -0,0 +1,146 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// denylistToken records a revoked JTI until its natural expiry (stateless mode)
+// The MERGE keeps repeated revocations of the same token idempotent
+func (as *authServer) denylistToken(revokedToken RevokedToken) error {
+	ctx, cancel := context.WithTimeout(as.ctx, 5*time.Second)
+	defer cancel()
+
+	tx, err := as.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to begin transaction for token denylisting")
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `MERGE INTO token_denylist d
+		USING (SELECT :token_id AS token_id FROM dual) s
+		ON (d.token_id = s.token_id)
+		WHEN NOT MATCHED THEN
+			INSERT (token_id, client_id, expires_at, revoked_at)
+			VALUES (:token_id, :client_id, :expires_at, :revoked_at)`
+	result, err := tx.ExecContext(ctx, query,
+		sql.Named("token_id", revokedToken.TokenID),
+		sql.Named("client_id", revokedToken.ClientID),
+		sql.Named("expires_at", revokedToken.ExpiresAt),
+		sql.Named("revoked_at", revokedToken.RevokedAt))
+	if err != nil {
+		log.Error().Err(err).Str("token_id", revokedToken.TokenID).Msg("Failed to add token to denylist")
+		return err
+	}
+	// A token already on the denylist was announced when it was first revoked
+	if added, _ := result.RowsAffected(); added > 0 {
+		if err := enqueueWebhook(ctx, tx, WebhookTokenRevoked, revokedToken.ClientID, revokedToken); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		log.Error().Err(err).Msg("Failed to commit token denylisting")
+		return err
+	}
+
+	log.Info().Str("token_id", revokedToken.TokenID).Time("expires_at", revokedToken.ExpiresAt).Msg("Token added to denylist")
+	return nil
+}
+
+// isTokenDenylisted reports whether a JTI has been revoked (stateless mode)
+// Unlike isTokenRevoked, a missing row is the normal case and means the token is valid
+func (as *authServer) isTokenDenylisted(ctx context.Context, tokenID string) (_ bool, err error) {
+	_, span := tracer().Start(ctx, "authServer.isTokenDenylisted", trace.WithAttributes(attrTokenID.String(tokenID)))
+	defer endSpan(span, &err)
+
+	var count int
+	ctx, cancel := context.WithTimeout(as.ctx, 5*time.Second)
+	defer cancel()
+
+	query := "SELECT COUNT(*) FROM token_denylist WHERE token_id = :token_id"
+	row := as.db.QueryRowContext(ctx, query, sql.Named("token_id", tokenID))
+
+	if err := row.Scan(&count); err != nil {
+		log.Error().Err(err).Str("token_id", tokenID).Msg("Denylist query failed")
+		return false, fmt.Errorf("tokenID %s: %v", tokenID, err)
+	}
+
+	return count > 0, nil
+}
+
+// purgeExpiredDenylist removes denylist entries whose tokens have expired anyway
+func (as *authServer) purgeExpiredDenylist() (int64, error) {
+	ctx, cancel := context.WithTimeout(as.ctx, 10*time.Second)
+	defer cancel()
+
+	result, err := as.db.ExecContext(ctx, "DELETE FROM token_denylist WHERE expires_at < :now", sql.Named("now", time.Now()))
+	if err != nil {
+		return 0, err
+	}
+
+	removed, _ := result.RowsAffected()
+	return removed, nil
+}
+
+// DenylistPurger periodically deletes expired entries from the JTI denylist
+type DenylistPurger struct {
+	purgeTick  *time.Ticker
+	done       chan struct{}
+	authServer *authServer
+}
+
+// NewDenylistPurger creates a purger and starts its background goroutine
+// Parameters: authServer - server instance for DB access, interval - time between purges
+func NewDenylistPurger(as *authServer, interval time.Duration) *DenylistPurger {
+	if interval <= 0 {
+		log.Warn().Dur("interval", interval).Msg("Invalid purge interval, using default 1 minute")
+		interval = time.Minute
+	}
+
+	dp := &DenylistPurger{
+		purgeTick:  time.NewTicker(interval),
+		done:       make(chan struct{}),
+		authServer: as,
+	}
+
+	go dp.backgroundPurge()
+
+	log.Info().
+		Str("purge_interval", interval.String()).
+		Msg("Token denylist purger initialized")
+
+	return dp
+}
+
+// backgroundPurge runs purges on every tick until stopped
+func (dp *DenylistPurger) backgroundPurge() {
+	for {
+		select {
+		case <-dp.done:
+			dp.purgeTick.Stop()
+			log.Debug().Msg("Token denylist background purge stopped")
+			return
+		case <-dp.purgeTick.C:
+			removed, err := dp.authServer.purgeExpiredDenylist()
+			if err != nil {
+				log.Error().Err(err).Msg("Failed to purge expired denylist entries")
+				continue
+			}
+			if removed > 0 {
+				log.Debug().Int64("removed", removed).Msg("Expired denylist entries purged")
+			}
+		}
+	}
+}
+
+// Stop stops the background purge goroutine
+func (dp *DenylistPurger) Stop() {
+	close(dp.done)
+	log.Info().Msg("Token denylist purger stopped")
+}
@@ -22,6 +22,7 @@ const (
 	ErrNotFound         ErrorCode = "not_found"
 	ErrConflict         ErrorCode = "conflict"
 	ErrValidationFailed ErrorCode = "validation_failed"
+	ErrTooManyRequests  ErrorCode = "too_many_requests"
 
 	// Server errors
 	ErrInternalServer     ErrorCode = "internal_server_error"
@@ -144,6 +145,11 @@ func ErrUnauthorizedError(message string) *APIError {
 	return NewAPIError(ErrUnauthorized, message, http.StatusUnauthorized)
 }
 
+// ErrInvalidClientError creates a 401 error for a client that may not obtain tokens
+func ErrInvalidClientError(message string) *APIError {
+	return NewAPIError(ErrInvalidClient, message, http.StatusUnauthorized)
+}
+
 // ErrForbiddenError creates a 403 Forbidden error
 func ErrForbiddenError(message string) *APIError {
 	return NewAPIError(ErrForbidden, message, http.StatusForbidden)
@@ -159,6 +165,11 @@ func ErrConflictError(message string) *APIError {
 	return NewAPIError(ErrConflict, message, http.StatusConflict)
 }
 
+// ErrTooManyRequestsError creates a 429 Too Many Requests error
+func ErrTooManyRequestsError(message string) *APIError {
+	return NewAPIError(ErrTooManyRequests, message, http.StatusTooManyRequests)
+}
+
 // ErrInternalServerError creates a 500 Internal Server Error
 func ErrInternalServerError(message string) *APIError {
 	return NewAPIError(ErrInternalServer, message, http.StatusInternalServerError)
@@ -0,0 +1,635 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// EventType identifies the kind of change propagated between auth-server instances
+type EventType string
+
+const (
+	EventTokenRevoked  EventType = "token.revoked"
+	EventClientRevoked EventType = "client.revoked"
+	EventClientChanged EventType = "client.changed"
+	EventKeyChanged    EventType = "key.changed"
+	EventLockout       EventType = "lockout.started"
+	EventLockoutEnded  EventType = "lockout.cleared"
+)
+
+// Event is a change notification shared between auth-server instances
+type Event struct {
+	ID        int64     `json:"id"`
+	Type      EventType `json:"type"`
+	ClientID  string    `json:"client_id,omitempty"`
+	TokenID   string    `json:"token_id,omitempty"`
+	KeyID     string    `json:"key_id,omitempty"`
+	ExpiresAt time.Time `json:"expires_at,omitempty"`
+	// RevokedBefore is the client-wide watermark carried by client.revoked events
+	RevokedBefore time.Time `json:"revoked_before,omitempty"`
+	// Subject is the client ID or source IP a lockout event applies to, see ClientLockoutSubject
+	Subject   string    `json:"subject,omitempty"`
+	Origin    string    `json:"origin"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// EventHandler is invoked for every event of a subscribed type
+type EventHandler func(Event)
+
+// EventTransport carries events between instances
+// The default is a DB-polled change feed; a message broker can be plugged in by implementing this interface
+type EventTransport interface {
+	// Publish sends an event to the other instances and assigns its ID
+	Publish(ctx context.Context, event *Event) error
+	// Start begins delivering events received from other instances
+	Start(deliver func(Event)) error
+	// Stop stops delivery and releases transport resources
+	Stop()
+}
+
+// EventLog is implemented by transports that retain history for catch-up reads
+type EventLog interface {
+	// EventsSince returns up to limit events with an ID greater than since, oldest first
+	// Transports whose IDs can commit out of order also return recent events at or below since,
+	// ahead of the newer ones and outside the limit; readers skip IDs they have already handled
+	// If types are given, only events of those types are returned
+	EventsSince(ctx context.Context, since int64, limit int, types ...EventType) ([]Event, error)
+}
+
+// eventListener receives every event of its types, including this instance's own, in transport order
+type eventListener struct {
+	ch    chan Event
+	types map[EventType]bool
+}
+
+// EventBus dispatches events to in-process subscribers and forwards them through a transport
+type EventBus struct {
+	mu             sync.RWMutex
+	handlers       map[EventType][]EventHandler
+	listeners      map[int]*eventListener
+	nextListenerID int
+	transport      EventTransport
+	instanceID     string
+}
+
+// NewEventBus creates an event bus for this instance using the given transport
+func NewEventBus(instanceID string, transport EventTransport) *EventBus {
+	return &EventBus{
+		handlers:   make(map[EventType][]EventHandler),
+		listeners:  make(map[int]*eventListener),
+		transport:  transport,
+		instanceID: instanceID,
+	}
+}
+
+// Subscribe registers a handler for the given event types
+func (b *EventBus) Subscribe(handler EventHandler, types ...EventType) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, t := range types {
+		b.handlers[t] = append(b.handlers[t], handler)
+	}
+}
+
+// Listen returns a channel receiving events of the given types from every instance, in transport order
+// The channel is closed if the listener falls more than buffer events behind; call the returned func to stop
+func (b *EventBus) Listen(buffer int, types ...EventType) (<-chan Event, func()) {
+	listener := &eventListener{
+		ch:    make(chan Event, buffer),
+		types: make(map[EventType]bool, len(types)),
+	}
+	for _, t := range types {
+		listener.types[t] = true
+	}
+
+	b.mu.Lock()
+	id := b.nextListenerID
+	b.nextListenerID++
+	b.listeners[id] = listener
+	b.mu.Unlock()
+
+	return listener.ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, exists := b.listeners[id]; exists {
+			delete(b.listeners, id)
+			close(listener.ch)
+		}
+	}
+}
+
+// Publish sends an event to other instances and dispatches it to local subscribers
+// Local subscribers are notified even if the transport fails, so this instance stays consistent
+func (b *EventBus) Publish(ctx context.Context, event Event) error {
+	event.Origin = b.instanceID
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+
+	if b.transport == nil {
+		b.notifyListeners(event)
+		b.dispatch(event)
+		return nil
+	}
+
+	err := b.transport.Publish(ctx, &event)
+	b.dispatch(event)
+	if err != nil {
+		return fmt.Errorf("failed to publish %s event: %w", event.Type, err)
+	}
+	return nil
+}
+
+// EventsSince reads retained events from the transport for catch-up
+func (b *EventBus) EventsSince(ctx context.Context, since int64, limit int, types ...EventType) ([]Event, error) {
+	eventLog, ok := b.transport.(EventLog)
+	if !ok {
+		return nil, fmt.Errorf("event transport does not retain history")
+	}
+	return eventLog.EventsSince(ctx, since, limit, types...)
+}
+
+// Start begins receiving events from other instances
+func (b *EventBus) Start() error {
+	if b.transport == nil {
+		return nil
+	}
+	return b.transport.Start(b.receive)
+}
+
+// Stop stops receiving events from other instances
+func (b *EventBus) Stop() {
+	if b.transport != nil {
+		b.transport.Stop()
+	}
+
+	b.closeListeners()
+	log.Info().Msg("Event bus stopped")
+}
+
+// closeListeners closes every listener channel so streaming handlers return
+func (b *EventBus) closeListeners() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for id, listener := range b.listeners {
+		delete(b.listeners, id)
+		close(listener.ch)
+	}
+}
+
+// receive handles an event delivered by the transport
+// Listeners see every event; handlers skip events this instance published since those were dispatched on Publish
+func (b *EventBus) receive(event Event) {
+	b.notifyListeners(event)
+	if event.Origin == b.instanceID {
+		return
+	}
+	b.dispatch(event)
+}
+
+// notifyListeners hands the event to interested listeners, dropping any that have fallen behind
+func (b *EventBus) notifyListeners(event Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for id, listener := range b.listeners {
+		if !listener.types[event.Type] {
+			continue
+		}
+		select {
+		case listener.ch <- event:
+		default:
+			log.Warn().Int("listener", id).Msg("Event listener fell behind, disconnecting")
+			delete(b.listeners, id)
+			close(listener.ch)
+		}
+	}
+}
+
+// dispatch runs every handler subscribed to the event's type
+func (b *EventBus) dispatch(event Event) {
+	b.mu.RLock()
+	handlers := b.handlers[event.Type]
+	b.mu.RUnlock()
+
+	for _, handler := range handlers {
+		handler(event)
+	}
+}
+
+// newInstanceID builds an identifier that is unique per running process
+func newInstanceID() string {
+	hostname, _ := os.Hostname()
+	return fmt.Sprintf("%s-%d-%s", hostname, os.Getpid(), generateRandomString(4))
+}
+
+// LocalTransport fans events out to every bus started on it within the same process
+// Useful for single-instance deployments and for tests that simulate several replicas
+// The most recent events are kept in memory for catch-up reads
+type LocalTransport struct {
+	mu         sync.Mutex
+	deliverers []func(Event)
+	history    []Event
+	nextID     int64
+}
+
+// localHistorySize bounds the number of events LocalTransport retains
+const localHistorySize = 1000
+
+// NewLocalTransport creates an in-process transport
+func NewLocalTransport() *LocalTransport {
+	return &LocalTransport{}
+}
+
+// Publish assigns the next ID and delivers the event to every started bus
+// Delivery happens under the lock so every bus sees events in ID order; handlers must not publish
+func (lt *LocalTransport) Publish(ctx context.Context, event *Event) error {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+
+	lt.nextID++
+	event.ID = lt.nextID
+
+	lt.history = append(lt.history, *event)
+	if len(lt.history) > localHistorySize {
+		lt.history = lt.history[len(lt.history)-localHistorySize:]
+	}
+
+	for _, deliver := range lt.deliverers {
+		deliver(*event)
+	}
+	return nil
+}
+
+// EventsSince returns retained events newer than since
+func (lt *LocalTransport) EventsSince(ctx context.Context, since int64, limit int, types ...EventType) ([]Event, error) {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+
+	var events []Event
+	for _, event := range lt.history {
+		if event.ID <= since || (len(types) > 0 && !slices.Contains(types, event.Type)) {
+			continue
+		}
+		events = append(events, event)
+		if len(events) == limit {
+			break
+		}
+	}
+	return events, nil
+}
+
+// Start registers a bus to receive events
+func (lt *LocalTransport) Start(deliver func(Event)) error {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+	lt.deliverers = append(lt.deliverers, deliver)
+	return nil
+}
+
+// Stop is a no-op for the in-process transport
+func (lt *LocalTransport) Stop() {}
+
+// DBChangeFeed propagates events through the auth_events table
+// Each instance inserts the events it publishes and polls for rows newer than the last one it has seen
+// IDs are allocated before commit, so a slow insert can become visible after a higher ID was already read;
+// every poll also re-reads rows created within the lookback window and skips the IDs already delivered
+type DBChangeFeed struct {
+	db        *sql.DB
+	interval  time.Duration
+	retention time.Duration
+	lookback  time.Duration
+	window    *eventWindow
+	pollTick  *time.Ticker
+	done      chan struct{}
+	stopOnce  sync.Once
+}
+
+// NewDBChangeFeed creates a DB-polled transport
+// Parameters: interval - poll frequency (bounds propagation delay), retention - how long rows are kept,
+// lookback - how long after creation a row may still commit (insert timeout plus clock skew between instances)
+func NewDBChangeFeed(db *sql.DB, interval, retention, lookback time.Duration) *DBChangeFeed {
+	if interval <= 0 {
+		log.Warn().Dur("interval", interval).Msg("Invalid poll interval, using default 1 second")
+		interval = time.Second
+	}
+	if retention <= 0 {
+		log.Warn().Dur("retention", retention).Msg("Invalid event retention, using default 1 hour")
+		retention = time.Hour
+	}
+	if lookback <= 0 {
+		log.Warn().Dur("lookback", lookback).Msg("Invalid event lookback, using default 30 seconds")
+		lookback = 30 * time.Second
+	}
+
+	return &DBChangeFeed{
+		db:        db,
+		interval:  interval,
+		retention: retention,
+		lookback:  lookback,
+		done:      make(chan struct{}),
+	}
+}
+
+// Publish inserts the event into the change feed table
+func (f *DBChangeFeed) Publish(ctx context.Context, event *Event) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `INSERT INTO auth_events(event_type, origin, client_id, token_id, key_id, expires_at, revoked_before, subject, created_at)
+		VALUES (:event_type, :origin, :client_id, :token_id, :key_id, :expires_at, :revoked_before, :subject, :created_at)
+		RETURNING id INTO :id`
+	_, err := f.db.ExecContext(ctx, query,
+		sql.Named("event_type", string(event.Type)),
+		sql.Named("origin", event.Origin),
+		sql.Named("client_id", event.ClientID),
+		sql.Named("token_id", event.TokenID),
+		sql.Named("key_id", event.KeyID),
+		sql.Named("expires_at", nullTime(event.ExpiresAt)),
+		sql.Named("revoked_before", nullTime(event.RevokedBefore)),
+		sql.Named("subject", event.Subject),
+		sql.Named("created_at", event.CreatedAt),
+		sql.Named("id", sql.Out{Dest: &event.ID}))
+	if err != nil {
+		log.Error().Err(err).Str("event_type", string(event.Type)).Msg("Failed to insert event into change feed")
+		return err
+	}
+	return nil
+}
+
+// Start positions the feed at the newest row and begins polling
+// Events published before startup are not replayed; caches start cold anyway
+func (f *DBChangeFeed) Start(deliver func(Event)) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var lastID int64
+	if err := f.db.QueryRowContext(ctx, "SELECT NVL(MAX(id), 0) FROM auth_events").Scan(&lastID); err != nil {
+		log.Error().Err(err).Msg("Failed to read change feed position")
+		return fmt.Errorf("failed to read change feed position: %w", err)
+	}
+
+	// Mark rows already inside the lookback window as delivered so the first poll does not replay them
+	f.window = newEventWindow(lastID)
+	recent, err := f.EventsSince(ctx, lastID, 0)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to read recent change feed events")
+		return fmt.Errorf("failed to read recent change feed events: %w", err)
+	}
+	for _, event := range recent {
+		f.window.add(event)
+	}
+
+	f.pollTick = time.NewTicker(f.interval)
+	go f.poll(deliver)
+
+	log.Info().
+		Int64("last_event_id", lastID).
+		Str("poll_interval", f.interval.String()).
+		Str("lookback", f.lookback.String()).
+		Msg("DB change feed started")
+	return nil
+}
+
+// poll reads new events on every tick and purges rows past retention
+func (f *DBChangeFeed) poll(deliver func(Event)) {
+	purgeEvery := int(f.retention / f.interval / 10)
+	if purgeEvery < 1 {
+		purgeEvery = 1
+	}
+	ticks := 0
+
+	for {
+		select {
+		case <-f.done:
+			f.pollTick.Stop()
+			log.Debug().Msg("DB change feed polling stopped")
+			return
+		case <-f.pollTick.C:
+			events, err := f.EventsSince(context.Background(), f.window.highest, 500)
+			if err != nil {
+				log.Error().Err(err).Int64("last_event_id", f.window.highest).Msg("Failed to poll change feed")
+				continue
+			}
+			for _, event := range events {
+				if f.window.add(event) {
+					deliver(event)
+				}
+			}
+			f.window.forget(time.Now().Add(-2 * f.lookback))
+
+			ticks++
+			if ticks%purgeEvery == 0 {
+				f.purge()
+			}
+		}
+	}
+}
+
+// EventsSince returns up to limit events with an ID greater than since, oldest first
+// Events at or below since created within the lookback window come first and do not count toward limit;
+// they cover rows that committed after a higher ID had been read. A zero limit returns only those
+func (f *DBChangeFeed) EventsSince(ctx context.Context, since int64, limit int, types ...EventType) ([]Event, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var typeArgs []any
+	typeFilter := ""
+	if len(types) > 0 {
+		placeholders := make([]string, len(types))
+		for i, t := range types {
+			name := fmt.Sprintf("type%d", i)
+			placeholders[i] = ":" + name
+			typeArgs = append(typeArgs, sql.Named(name, string(t)))
+		}
+		typeFilter = " AND event_type IN (" + strings.Join(placeholders, ", ") + ")"
+	}
+
+	recent, err := f.queryEvents(ctx, `WHERE id <= :since AND created_at >= :cutoff`+typeFilter+` ORDER BY id`,
+		append([]any{sql.Named("since", since), sql.Named("cutoff", time.Now().Add(-f.lookback))}, typeArgs...)...)
+	if err != nil || limit <= 0 {
+		return recent, err
+	}
+
+	newer, err := f.queryEvents(ctx, `WHERE id > :since`+typeFilter+` ORDER BY id FETCH FIRST :limit ROWS ONLY`,
+		append([]any{sql.Named("since", since), sql.Named("limit", limit)}, typeArgs...)...)
+	if err != nil {
+		return nil, err
+	}
+	return append(recent, newer...), nil
+}
+
+// queryEvents reads auth_events rows matching the given WHERE clause
+func (f *DBChangeFeed) queryEvents(ctx context.Context, where string, args ...any) ([]Event, error) {
+	query := `SELECT id, event_type, origin, client_id, token_id, key_id, expires_at, revoked_before, subject, created_at
+		FROM auth_events ` + where
+	rows, err := f.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []Event
+	for rows.Next() {
+		var event Event
+		var eventType string
+		var clientID, tokenID, keyID, subject sql.NullString
+		var expiresAt, revokedBefore sql.NullTime
+		if err := rows.Scan(&event.ID, &eventType, &event.Origin, &clientID, &tokenID, &keyID, &expiresAt, &revokedBefore, &subject, &event.CreatedAt); err != nil {
+			return nil, err
+		}
+		event.Type = EventType(eventType)
+		event.ClientID = clientID.String
+		event.TokenID = tokenID.String
+		event.KeyID = keyID.String
+		event.ExpiresAt = expiresAt.Time
+		event.RevokedBefore = revokedBefore.Time
+		event.Subject = subject.String
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+// purge removes rows older than the retention window
+func (f *DBChangeFeed) purge() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := f.db.ExecContext(ctx, "DELETE FROM auth_events WHERE created_at < :cutoff",
+		sql.Named("cutoff", time.Now().Add(-f.retention)))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to purge old change feed events")
+		return
+	}
+	if removed, _ := result.RowsAffected(); removed > 0 {
+		log.Debug().Int64("removed", removed).Msg("Old change feed events purged")
+	}
+}
+
+// Stop stops polling
+func (f *DBChangeFeed) Stop() {
+	f.stopOnce.Do(func() {
+		close(f.done)
+	})
+	log.Info().Msg("DB change feed stopped")
+}
+
+// eventWindow tracks the events a reader has handled so redelivered ones can be skipped
+type eventWindow struct {
+	highest int64
+	seen    map[int64]time.Time // Event ID to its creation time
+}
+
+// newEventWindow creates a window positioned after the given ID
+func newEventWindow(highest int64) *eventWindow {
+	return &eventWindow{highest: highest, seen: make(map[int64]time.Time)}
+}
+
+// add records the event and reports whether it is new to the reader
+func (w *eventWindow) add(event Event) bool {
+	if _, ok := w.seen[event.ID]; ok {
+		return false
+	}
+	w.seen[event.ID] = event.CreatedAt
+	w.highest = max(w.highest, event.ID)
+	return true
+}
+
+// forget drops events created before cutoff, which are too old to be redelivered
+func (w *eventWindow) forget(cutoff time.Time) {
+	for id, createdAt := range w.seen {
+		if createdAt.Before(cutoff) {
+			delete(w.seen, id)
+		}
+	}
+}
+
+// nullTime maps the zero time to NULL for optional timestamp columns
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
+
+// newEventTransport builds the transport selected in configuration
+func newEventTransport(db *sql.DB) EventTransport {
+	switch AppConfig.Events.Transport {
+	case EventTransportLocal:
+		return NewLocalTransport()
+	default:
+		return NewDBChangeFeed(db,
+			time.Duration(AppConfig.Events.PollInterval)*time.Millisecond,
+			time.Duration(AppConfig.Events.Retention)*time.Minute,
+			time.Duration(AppConfig.Events.Lookback)*time.Second)
+	}
+}
+
+// registerEventHandlers subscribes this instance's caches to change events
+func (as *authServer) registerEventHandlers() {
+	as.events.Subscribe(func(event Event) {
+		if as.clientCache != nil {
+			as.clientCache.Invalidate(event.ClientID)
+		}
+	}, EventClientChanged)
+
+	as.events.Subscribe(func(event Event) {
+		log.Debug().
+			Str("client_id", event.ClientID).
+			Str("token_id", event.TokenID).
+			Str("origin", event.Origin).
+			Msg("Token revocation event received")
+	}, EventTokenRevoked)
+
+	as.events.Subscribe(func(event Event) {
+		if as.watermarks != nil {
+			as.watermarks.Set(event.ClientID, event.RevokedBefore)
+		}
+	}, EventClientRevoked)
+
+	as.events.Subscribe(func(event Event) {
+		log.Info().
+			Str("key_id", event.KeyID).
+			Str("origin", event.Origin).
+			Msg("Signing key change event received")
+		if as.keyRing == nil {
+			return
+		}
+		if err := as.reloadKeyRing(); err != nil {
+			log.Error().Err(err).Str("key_id", event.KeyID).Msg("Failed to reload signing keys")
+		}
+	}, EventKeyChanged)
+
+	as.events.Subscribe(func(event Event) {
+		if as.lockouts == nil {
+			return
+		}
+		if event.Type == EventLockout {
+			as.lockouts.Lock(event.Subject, event.ExpiresAt)
+			return
+		}
+		as.lockouts.Clear(event.Subject)
+		log.Info().Str("subject", event.Subject).Str("origin", event.Origin).Msg("Lockout cleared")
+	}, EventLockout, EventLockoutEnded)
+}
+
+// publishEvent publishes an event if propagation is configured, logging rather than failing on errors
+// The change itself has already been committed, so a lost notification only delays cache convergence
+func (as *authServer) publishEvent(event Event) {
+	if as.events == nil {
+		return
+	}
+	if err := as.events.Publish(as.ctx, event); err != nil {
+		log.Error().
+			Err(err).
+			Str("event_type", string(event.Type)).
+			Str("client_id", event.ClientID).
+			Msg("Failed to propagate event")
+	}
+}
@@ -0,0 +1,166 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestEventBus_LocalSubscribers(t *testing.T) {
+	bus := NewEventBus("instance-a", nil)
+
+	var received []Event
+	bus.Subscribe(func(e Event) {
+		received = append(received, e)
+	}, EventClientChanged)
+
+	if err := bus.Publish(context.Background(), Event{Type: EventClientChanged, ClientID: "client-1"}); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	// Not subscribed to this type
+	bus.Publish(context.Background(), Event{Type: EventKeyChanged, KeyID: "k1"})
+
+	if len(received) != 1 {
+		t.Fatalf("Expected 1 event, got %d", len(received))
+	}
+	if received[0].Origin != "instance-a" {
+		t.Errorf("Expected origin 'instance-a', got %s", received[0].Origin)
+	}
+	if received[0].CreatedAt.IsZero() {
+		t.Errorf("Expected CreatedAt to be set")
+	}
+}
+
+func TestEventBus_PropagatesAcrossInstances(t *testing.T) {
+	transport := NewLocalTransport()
+	busA := NewEventBus("instance-a", transport)
+	busB := NewEventBus("instance-b", transport)
+	busA.Start()
+	busB.Start()
+
+	countA, countB := 0, 0
+	busA.Subscribe(func(Event) { countA++ }, EventTokenRevoked)
+	busB.Subscribe(func(Event) { countB++ }, EventTokenRevoked)
+
+	busA.Publish(context.Background(), Event{Type: EventTokenRevoked, TokenID: "jti-1"})
+
+	// The publisher handles its own event once, not again when it comes back through the transport
+	if countA != 1 {
+		t.Errorf("Expected publisher to handle event once, got %d", countA)
+	}
+	if countB != 1 {
+		t.Errorf("Expected other instance to handle event once, got %d", countB)
+	}
+}
+
+func TestRegisterEventHandlers_InvalidatesClientCache(t *testing.T) {
+	ctx, cancel := createTestContextFunc()
+	defer cancel()
+
+	transport := NewLocalTransport()
+
+	cache := NewClientCache(time.Minute, 10)
+	defer cache.Stop()
+	cache.Set("client-1", &Clients{ClientID: "client-1"})
+
+	replica := &authServer{ctx: ctx, clientCache: cache, events: NewEventBus("replica", transport)}
+	replica.registerEventHandlers()
+	replica.events.Start()
+
+	origin := &authServer{ctx: ctx, events: NewEventBus("origin", transport)}
+	origin.publishEvent(Event{Type: EventClientChanged, ClientID: "client-1"})
+
+	if _, found := cache.Get(context.Background(), "client-1"); found {
+		t.Errorf("Expected client to be invalidated on the other replica")
+	}
+}
+
+func TestPublishEvent_NoBus(t *testing.T) {
+	server := &authServer{}
+
+	// Should not panic when propagation is not configured
+	server.publishEvent(Event{Type: EventClientChanged, ClientID: "client-1"})
+}
+
+func TestEventBus_ListenReceivesOwnEventsInOrder(t *testing.T) {
+	transport := NewLocalTransport()
+	bus := NewEventBus("instance-a", transport)
+	bus.Start()
+
+	events, stop := bus.Listen(10, EventTokenRevoked)
+	defer stop()
+
+	bus.Publish(context.Background(), Event{Type: EventTokenRevoked, TokenID: "jti-1"})
+	bus.Publish(context.Background(), Event{Type: EventClientChanged, ClientID: "client-1"})
+	bus.Publish(context.Background(), Event{Type: EventTokenRevoked, TokenID: "jti-2"})
+
+	first, second := <-events, <-events
+	if first.TokenID != "jti-1" || second.TokenID != "jti-2" {
+		t.Errorf("Expected jti-1 then jti-2, got %s then %s", first.TokenID, second.TokenID)
+	}
+	if first.ID >= second.ID {
+		t.Errorf("Expected increasing IDs, got %d then %d", first.ID, second.ID)
+	}
+}
+
+func TestEventBus_SlowListenerIsDisconnected(t *testing.T) {
+	transport := NewLocalTransport()
+	bus := NewEventBus("instance-a", transport)
+	bus.Start()
+
+	events, stop := bus.Listen(1, EventTokenRevoked)
+	defer stop()
+
+	bus.Publish(context.Background(), Event{Type: EventTokenRevoked, TokenID: "jti-1"})
+	bus.Publish(context.Background(), Event{Type: EventTokenRevoked, TokenID: "jti-2"})
+
+	<-events
+	if _, open := <-events; open {
+		t.Errorf("Expected channel to be closed after overflow")
+	}
+}
+
+func TestLocalTransport_EventsSince(t *testing.T) {
+	transport := NewLocalTransport()
+	bus := NewEventBus("instance-a", transport)
+
+	for i := 0; i < 5; i++ {
+		bus.Publish(context.Background(), Event{Type: EventTokenRevoked})
+		bus.Publish(context.Background(), Event{Type: EventClientChanged})
+	}
+
+	events, err := bus.EventsSince(context.Background(), 2, 3, EventTokenRevoked)
+	if err != nil {
+		t.Fatalf("EventsSince failed: %v", err)
+	}
+	if len(events) != 3 {
+		t.Fatalf("Expected 3 events, got %d", len(events))
+	}
+	for _, event := range events {
+		if event.ID <= 2 || event.Type != EventTokenRevoked {
+			t.Errorf("Unexpected event %+v", event)
+		}
+	}
+}
+
+func TestEventWindow_SkipsRedeliveredEvents(t *testing.T) {
+	now := time.Now()
+	window := newEventWindow(10)
+
+	// A late commit below the starting position is still new
+	if !window.add(Event{ID: 8, CreatedAt: now}) {
+		t.Error("Expected a late event below the position to be delivered")
+	}
+	if !window.add(Event{ID: 12, CreatedAt: now}) || window.highest != 12 {
+		t.Errorf("Expected event 12 to be delivered and advance the position, at %d", window.highest)
+	}
+	if window.add(Event{ID: 8, CreatedAt: now}) || window.add(Event{ID: 12, CreatedAt: now}) {
+		t.Error("Expected redelivered events to be skipped")
+	}
+
+	window.add(Event{ID: 11, CreatedAt: now.Add(-time.Hour)})
+	window.forget(now.Add(-time.Minute))
+	if len(window.seen) != 2 || window.highest != 12 {
+		t.Errorf("Expected only the old event to be forgotten, got %v at %d", window.seen, window.highest)
+	}
+}
@@ -0,0 +1,121 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	authv3 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+	typev3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/codes"
+)
+
+// extAuthzServer implements Envoy's envoy.service.auth.v3.Authorization API
+// It runs the same token validation and scope/endpoint authorization as validateHandler
+type extAuthzServer struct {
+	authv3.UnimplementedAuthorizationServer
+	authServer *authServer
+}
+
+// newExtAuthzServer creates the ext_authz service backed by the given auth server
+func newExtAuthzServer(as *authServer) *extAuthzServer {
+	return &extAuthzServer{authServer: as}
+}
+
+// Check authorizes a single request forwarded by Envoy's ext_authz filter
+func (s *extAuthzServer) Check(ctx context.Context, req *authv3.CheckRequest) (*authv3.CheckResponse, error) {
+	requestID := uuid.New().String()
+	httpReq := req.GetAttributes().GetRequest().GetHttp()
+
+	// Envoy passes on the downstream request's headers, traceparent included
+	ctx = traceContext.Extract(ctx, propagation.MapCarrier(httpReq.GetHeaders()))
+	ctx, span := tracer().Start(ctx, "ext_authz.Check", trace.WithSpanKind(trace.SpanKindServer))
+	defer span.End()
+	logger := withTraceIDs(log.With().Str("request_id", requestID).Str("transport", "ext_authz"), ctx).Logger()
+
+	if httpReq == nil {
+		logger.Warn().Msg("ext_authz check without HTTP request attributes")
+		s.authServer.metrics.tokenValidation(validationEndpointExtAuthz, validationOutcomeInvalidRequest)
+		return deniedCheckResponse(ErrBadRequest("Missing HTTP request attributes"), requestID), nil
+	}
+
+	scheme := httpReq.GetScheme()
+	if scheme == "" {
+		scheme = "http"
+	}
+	resourceURL := scheme + "://" + httpReq.GetHost() + httpReq.GetPath()
+	method := httpReq.GetMethod()
+	// Envoy reports the downstream address after applying its own trusted hops
+	source := parseAddr(req.GetAttributes().GetSource().GetAddress().GetSocketAddress().GetAddress())
+
+	// Envoy lowercases header names
+	claims, apiErr := s.authServer.authorizeBearer(ctx, httpReq.GetHeaders()["authorization"], method, resourceURL, source)
+	s.authServer.metrics.tokenValidation(validationEndpointExtAuthz, validationOutcome(apiErr))
+	if apiErr != nil {
+		event := logger.Warn().
+			Str("resource", resourceURL).
+			Str("method", method).
+			Str("error_code", string(apiErr.Code)).
+			Str("error_message", apiErr.Message)
+		if claims != nil {
+			event = event.Str("client_id", claims.ClientID)
+		}
+		event.Msg("ext_authz check denied")
+
+		return deniedCheckResponse(apiErr, requestID), nil
+	}
+
+	logger.Info().
+		Str("client_id", claims.ClientID).
+		Str("method", method).
+		Str("resource", resourceURL).
+		Msg("ext_authz check granted")
+
+	return &authv3.CheckResponse{
+		Status: &status.Status{Code: int32(codes.OK)},
+		HttpResponse: &authv3.CheckResponse_OkResponse{
+			OkResponse: &authv3.OkHttpResponse{
+				Headers: []*corev3.HeaderValueOption{
+					headerOption(HeaderAuthClientID, claims.ClientID),
+					headerOption(HeaderAuthScopes, strings.Join(claims.Scope, " ")),
+					headerOption(HeaderAuthTokenID, claims.TokenID),
+				},
+			},
+		},
+	}, nil
+}
+
+// deniedCheckResponse builds a denial carrying the same APIError JSON body as the HTTP endpoints
+func deniedCheckResponse(apiErr *APIError, requestID string) *authv3.CheckResponse {
+	apiErr.RequestID = requestID
+	body, _ := json.Marshal(apiErr)
+
+	code := codes.PermissionDenied
+	if apiErr.StatusCode == http.StatusUnauthorized {
+		code = codes.Unauthenticated
+	}
+
+	return &authv3.CheckResponse{
+		Status: &status.Status{Code: int32(code), Message: apiErr.Message},
+		HttpResponse: &authv3.CheckResponse_DeniedResponse{
+			DeniedResponse: &authv3.DeniedHttpResponse{
+				Status:  &typev3.HttpStatus{Code: typev3.StatusCode(apiErr.StatusCode)},
+				Headers: []*corev3.HeaderValueOption{headerOption("Content-Type", "application/json")},
+				Body:    string(body),
+			},
+		},
+	}
+}
+
+func headerOption(key, value string) *corev3.HeaderValueOption {
+	return &corev3.HeaderValueOption{
+		Header: &corev3.HeaderValue{Key: key, Value: value},
+	}
+}
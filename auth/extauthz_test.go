@@ -0,0 +1,129 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"testing"
+
+	authv3 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// newExtAuthzTestClient serves the ext_authz API over an in-memory listener
+func newExtAuthzTestClient(t *testing.T, server *authServer) authv3.AuthorizationClient {
+	t.Helper()
+
+	listener := bufconn.Listen(1 << 20)
+	grpcServer := grpc.NewServer()
+	authv3.RegisterAuthorizationServer(grpcServer, newExtAuthzServer(server))
+	go grpcServer.Serve(listener)
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("Failed to dial ext_authz server: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return authv3.NewAuthorizationClient(conn)
+}
+
+func newCheckRequest(headers map[string]string) *authv3.CheckRequest {
+	return &authv3.CheckRequest{
+		Attributes: &authv3.AttributeContext{
+			Request: &authv3.AttributeContext_Request{
+				Http: &authv3.AttributeContext_HttpRequest{
+					Method:  "GET",
+					Scheme:  "http",
+					Host:    "localhost:3000",
+					Path:    "/api/users",
+					Headers: headers,
+				},
+			},
+		},
+	}
+}
+
+func TestExtAuthzCheck_Denied(t *testing.T) {
+	ctx, cancel := createTestContextFunc()
+	defer cancel()
+
+	server := &authServer{
+		jwtSecret: []byte("test-secret"),
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+	client := newExtAuthzTestClient(t, server)
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		message string
+	}{
+		{"missing authorization", map[string]string{}, "Missing Authorization header"},
+		{"not a bearer token", map[string]string{"authorization": "Basic abc"}, "Bearer token required"},
+		{"invalid token", map[string]string{"authorization": "Bearer not-a-jwt"}, "Invalid or expired token"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := client.Check(context.Background(), newCheckRequest(tt.headers))
+			if err != nil {
+				t.Fatalf("Check returned error: %v", err)
+			}
+
+			if codes.Code(resp.GetStatus().GetCode()) != codes.Unauthenticated {
+				t.Errorf("Expected UNAUTHENTICATED status, got %v", codes.Code(resp.GetStatus().GetCode()))
+			}
+
+			denied := resp.GetDeniedResponse()
+			if denied == nil {
+				t.Fatal("Expected a denied response")
+			}
+			if denied.GetStatus().GetCode() != http.StatusUnauthorized {
+				t.Errorf("Expected HTTP status 401, got %d", denied.GetStatus().GetCode())
+			}
+
+			var apiErr APIError
+			if err := json.Unmarshal([]byte(denied.GetBody()), &apiErr); err != nil {
+				t.Fatalf("Denied body is not an APIError: %v", err)
+			}
+			if apiErr.Message != tt.message {
+				t.Errorf("Expected message %q, got %q", tt.message, apiErr.Message)
+			}
+			if apiErr.RequestID == "" {
+				t.Error("Expected request_id in denied body")
+			}
+		})
+	}
+}
+
+func TestExtAuthzCheck_MissingHTTPAttributes(t *testing.T) {
+	ctx, cancel := createTestContextFunc()
+	defer cancel()
+
+	server := &authServer{
+		jwtSecret: []byte("test-secret"),
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+	client := newExtAuthzTestClient(t, server)
+
+	resp, err := client.Check(context.Background(), &authv3.CheckRequest{})
+	if err != nil {
+		t.Fatalf("Check returned error: %v", err)
+	}
+	if resp.GetDeniedResponse().GetStatus().GetCode() != http.StatusBadRequest {
+		t.Errorf("Expected HTTP status 400, got %d", resp.GetDeniedResponse().GetStatus().GetCode())
+	}
+}
@@ -0,0 +1,173 @@
+package auth
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Built-in proxy profile names
+const (
+	ProxyProfileValidate = "validate"
+	ProxyProfileNginx    = "nginx"
+	ProxyProfileTraefik  = "traefik"
+	ProxyProfileCaddy    = "caddy"
+)
+
+// builtinProxyProfiles describe how each supported proxy passes the original request
+// Profiles under forward_auth.profiles in the configuration replace or extend these
+var builtinProxyProfiles = map[string]proxyProfile{
+	// POST /validate: the full resource URL travels in X-Forwarded-For
+	ProxyProfileValidate: {
+		URLHeaders:    []string{"X-Forwarded-For"},
+		MethodHeaders: []string{"X-Forwarded-Method"},
+	},
+	// nginx auth_request only looks at the status code and accepts nothing but 2xx, 401 and 403
+	ProxyProfileNginx: {
+		URIHeaders:    []string{"X-Original-URI"},
+		MethodHeaders: []string{"X-Original-Method"},
+		HostHeaders:   []string{"X-Original-Host", "X-Forwarded-Host"},
+		ProtoHeaders:  []string{"X-Forwarded-Proto"},
+		StatusOnly:    true,
+	},
+	// Traefik forwardAuth returns non-2xx responses, body included, to the caller
+	ProxyProfileTraefik: {
+		URIHeaders:    []string{"X-Forwarded-Uri"},
+		MethodHeaders: []string{"X-Forwarded-Method"},
+		HostHeaders:   []string{"X-Forwarded-Host"},
+		ProtoHeaders:  []string{"X-Forwarded-Proto"},
+	},
+	// Caddy forward_auth sends the same headers as Traefik
+	ProxyProfileCaddy: {
+		URIHeaders:    []string{"X-Forwarded-Uri"},
+		MethodHeaders: []string{"X-Forwarded-Method"},
+		HostHeaders:   []string{"X-Forwarded-Host"},
+		ProtoHeaders:  []string{"X-Forwarded-Proto"},
+	},
+}
+
+// forwardedRequest is the original request a proxy asks us to authorize
+type forwardedRequest struct {
+	Method string
+	URL    string
+}
+
+// lookupProxyProfile returns the named profile, preferring configured profiles over built-in ones
+func lookupProxyProfile(name string) (proxyProfile, bool) {
+	name = strings.ToLower(name)
+	if profile, exists := AppConfig.ForwardAuth.Profiles[name]; exists {
+		return profile, true
+	}
+	profile, exists := builtinProxyProfiles[name]
+	return profile, exists
+}
+
+// originalRequest rebuilds the proxied request from the profile's headers
+// A full URL header wins; otherwise the URL is assembled from proto, host and URI
+func (p proxyProfile) originalRequest(r *http.Request) (forwardedRequest, *APIError) {
+	req := forwardedRequest{Method: firstHeader(r, p.MethodHeaders...)}
+
+	if len(p.URLHeaders) > 0 {
+		if req.URL = firstHeader(r, p.URLHeaders...); req.URL == "" {
+			return req, ErrBadRequest("Missing " + p.URLHeaders[0] + " header (resource endpoint)")
+		}
+		return req, nil
+	}
+
+	uri := firstHeader(r, p.URIHeaders...)
+	if uri == "" {
+		return req, ErrBadRequest("Missing " + strings.Join(p.URIHeaders, " or ") + " header (original URI)")
+	}
+
+	host := firstHeader(r, p.HostHeaders...)
+	if host == "" {
+		host = r.Host
+	}
+	proto := firstHeader(r, p.ProtoHeaders...)
+	if proto == "" {
+		proto = p.DefaultProto
+	}
+	if proto == "" {
+		proto = "http"
+	}
+
+	req.URL = proto + "://" + host + uri
+	return req, nil
+}
+
+// Forward-auth handler for Traefik, Caddy and other proxies that describe the original request in headers
+// The profile comes from the :profile path parameter, or forward_auth.default_profile.
+//
+//	http:
+//	  middlewares:
+//	    auth:
+//	      forwardAuth:
+//	        address: http://auth-server:8080/auth-server/v1/oauth/forward-auth/traefik
+//	        authResponseHeaders: [X-Auth-Client-Id, X-Auth-Scopes, X-Auth-Token-Id]
+func (as *authServer) forwardAuthHandler(c *gin.Context) {
+	name := c.Param("profile")
+	if name == "" {
+		name = AppConfig.ForwardAuth.DefaultProfile
+	}
+
+	profile, exists := lookupProxyProfile(name)
+	if !exists {
+		logger := GetRequestLogger(c)
+		logger.Warn().Str("profile", name).Msg("Unknown forward-auth proxy profile")
+		RespondWithError(c, ErrNotFoundError("Unknown proxy profile"))
+		return
+	}
+
+	as.forwardAuth(c, name, profile)
+}
+
+// forwardAuth authorizes the request described by profile, answering with headers on success
+func (as *authServer) forwardAuth(c *gin.Context, name string, profile proxyProfile) {
+	logger := GetRequestLogger(c)
+
+	original, apiErr := profile.originalRequest(c.Request)
+	if apiErr != nil {
+		logger.Warn().Str("profile", name).Str("error_message", apiErr.Message).Msg("Incomplete forward-auth request")
+		as.metrics.tokenValidation(validationEndpointForwardAuth, validationOutcomeInvalidRequest)
+		denyForwardAuth(c, profile, apiErr)
+		return
+	}
+
+	claims, apiErr := as.authorizeBearer(c.Request.Context(), c.Request.Header.Get("Authorization"), original.Method, original.URL, clientAddr(c))
+	as.metrics.tokenValidation(validationEndpointForwardAuth, validationOutcome(apiErr))
+	if apiErr != nil {
+		event := logger.Warn().
+			Str("profile", name).
+			Str("resource", original.URL).
+			Str("method", original.Method).
+			Str("error_code", string(apiErr.Code)).
+			Str("error_message", apiErr.Message)
+		if claims != nil {
+			event = event.Str("client_id", claims.ClientID)
+		}
+		event.Msg("Forward-auth denied")
+
+		denyForwardAuth(c, profile, apiErr)
+		return
+	}
+
+	logger.Info().
+		Str("profile", name).
+		Str("client_id", claims.ClientID).
+		Str("method", original.Method).
+		Str("resource", original.URL).
+		Msg("Forward-auth granted")
+
+	setAuthResponseHeaders(c, claims)
+	c.Status(http.StatusOK)
+}
+
+// denyForwardAuth answers a denied forward-auth request in the form the proxy expects
+func denyForwardAuth(c *gin.Context, profile proxyProfile, apiErr *APIError) {
+	if profile.StatusOnly {
+		c.Status(authRequestStatus(apiErr))
+		return
+	}
+	RespondWithError(c, apiErr)
+}
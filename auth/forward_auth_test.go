@@ -0,0 +1,141 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestProxyProfile_OriginalRequest(t *testing.T) {
+	tests := []struct {
+		profile    string
+		headers    map[string]string
+		wantURL    string
+		wantMethod string
+	}{
+		{
+			profile:    ProxyProfileValidate,
+			headers:    map[string]string{"X-Forwarded-For": "http://localhost:3000/api/users", "X-Forwarded-Method": "POST"},
+			wantURL:    "http://localhost:3000/api/users",
+			wantMethod: "POST",
+		},
+		{
+			profile:    ProxyProfileNginx,
+			headers:    map[string]string{"X-Original-URI": "/api/users?page=2", "X-Original-Method": "GET", "X-Original-Host": "api.example.com", "X-Forwarded-Proto": "https"},
+			wantURL:    "https://api.example.com/api/users?page=2",
+			wantMethod: "GET",
+		},
+		{
+			profile:    ProxyProfileTraefik,
+			headers:    map[string]string{"X-Forwarded-Uri": "/api/posts/1", "X-Forwarded-Method": "DELETE", "X-Forwarded-Host": "api.example.com", "X-Forwarded-Proto": "https"},
+			wantURL:    "https://api.example.com/api/posts/1",
+			wantMethod: "DELETE",
+		},
+		{
+			// Without host or proto headers the request's own host and http are used
+			profile:    ProxyProfileCaddy,
+			headers:    map[string]string{"X-Forwarded-Uri": "/api/posts", "X-Forwarded-Method": "GET"},
+			wantURL:    "http://auth.internal/api/posts",
+			wantMethod: "GET",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.profile, func(t *testing.T) {
+			profile, exists := lookupProxyProfile(tt.profile)
+			if !exists {
+				t.Fatalf("Profile %q not found", tt.profile)
+			}
+
+			req, _ := http.NewRequest("GET", "http://auth.internal/forward-auth", nil)
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+
+			original, apiErr := profile.originalRequest(req)
+			if apiErr != nil {
+				t.Fatalf("Unexpected error: %v", apiErr)
+			}
+			if original.URL != tt.wantURL {
+				t.Errorf("Expected URL %q, got %q", tt.wantURL, original.URL)
+			}
+			if original.Method != tt.wantMethod {
+				t.Errorf("Expected method %q, got %q", tt.wantMethod, original.Method)
+			}
+		})
+	}
+}
+
+func TestLookupProxyProfile_ConfiguredOverridesBuiltin(t *testing.T) {
+	saved := AppConfig
+	defer func() { AppConfig = saved }()
+
+	AppConfig.ForwardAuth.Profiles = map[string]proxyProfile{
+		"traefik": {URIHeaders: []string{"X-Custom-Uri"}, DefaultProto: "https"},
+	}
+
+	profile, exists := lookupProxyProfile("Traefik")
+	if !exists {
+		t.Fatal("Expected configured traefik profile")
+	}
+
+	req, _ := http.NewRequest("GET", "http://gateway/forward-auth", nil)
+	req.Header.Set("X-Custom-Uri", "/api/users")
+	original, apiErr := profile.originalRequest(req)
+	if apiErr != nil {
+		t.Fatalf("Unexpected error: %v", apiErr)
+	}
+	if original.URL != "https://gateway/api/users" {
+		t.Errorf("Expected configured headers to be used, got %q", original.URL)
+	}
+}
+
+func TestForwardAuthHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	ctx, cancel := createTestContextFunc()
+	defer cancel()
+
+	server := &authServer{
+		jwtSecret: []byte("test-secret"),
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+
+	router := gin.New()
+	router.GET("/forward-auth/:profile", server.forwardAuthHandler)
+
+	tests := []struct {
+		name       string
+		path       string
+		headers    map[string]string
+		wantStatus int
+	}{
+		{"unknown profile", "/forward-auth/haproxy", nil, http.StatusNotFound},
+		{"missing original URI", "/forward-auth/traefik", map[string]string{"Authorization": "Bearer token"}, http.StatusBadRequest},
+		{"missing token", "/forward-auth/traefik", map[string]string{"X-Forwarded-Uri": "/api/users"}, http.StatusUnauthorized},
+		{"invalid token", "/forward-auth/caddy", map[string]string{"X-Forwarded-Uri": "/api/users", "Authorization": "Bearer not-a-jwt"}, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", tt.path, nil)
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+
+			if recorder.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, recorder.Code)
+			}
+			// Traefik and Caddy relay denials to the caller, so the JSON error body is kept
+			if !strings.Contains(recorder.Body.String(), `"error"`) {
+				t.Errorf("Expected JSON error body, got %q", recorder.Body.String())
+			}
+		})
+	}
+}
@@ -2,8 +2,10 @@ package auth
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
-	"slices"
+	"net/netip"
+	"strconv"
 	"strings"
 	"time"
 
@@ -16,6 +18,7 @@ func (as *authServer) tokenHandler(c *gin.Context) {
 
 	if c.Request.Method != http.MethodPost {
 		logger.Warn().Str("method", c.Request.Method).Msg("Invalid HTTP method for token endpoint")
+		as.metrics.tokenRequest(tokenOutcomeInvalidRequest)
 		RespondWithError(c, ErrBadRequest("Only POST method is allowed"))
 		return
 	}
@@ -23,22 +26,41 @@ func (as *authServer) tokenHandler(c *gin.Context) {
 	var tokenReq TokenRequest
 	if err := json.NewDecoder(c.Request.Body).Decode(&tokenReq); err != nil {
 		logger.Warn().Err(err).Msg("Failed to decode token request JSON")
+		as.metrics.tokenRequest(tokenOutcomeInvalidRequest)
 		RespondWithError(c, ErrBadRequest("Invalid JSON format").WithOriginalError(err))
 		return
 	}
 
 	logger.Debug().Str("client_id", tokenReq.ClientID).Str("grant_type", tokenReq.GrantType).Msg("Processing token request")
 
+	// Locked out client IDs and source IPs are refused before their secret is looked at
+	if as.lockouts != nil {
+		if until, locked := as.lockouts.Locked(time.Now(), lockoutSubjects(tokenReq.ClientID, clientIP(c))...); locked {
+			logger.Warn().Str("client_id", tokenReq.ClientID).Time("locked_until", until).Msg("Token request while locked out")
+			c.Header("Retry-After", strconv.Itoa(ceilSeconds(time.Until(until))))
+			as.metrics.tokenRequest(tokenOutcomeLockedOut)
+			as.auditTokenRequest(c, tokenReq.ClientID, "", tokenOutcomeLockedOut)
+			RespondWithError(c, ErrTooManyRequestsError("Too many failed authentication attempts"))
+			return
+		}
+	}
+
 	// ✅ Try cache first (in-memory lookup is <1µs on hit)
 	var client *Clients
 	var err error
-	if cachedClient, found := as.clientCache.Get(tokenReq.ClientID); found {
+	if cachedClient, found := as.clientCache.Get(c.Request.Context(), tokenReq.ClientID); found {
 		client = cachedClient
-	} else {
+	} else if !as.clientCache.IsMissing(tokenReq.ClientID) {
 		// Cache miss - query database with timeout
-		client, err = as.clientByID(tokenReq.ClientID)
-		if err != nil {
+		client, err = as.clientByID(c.Request.Context(), tokenReq.ClientID)
+		if errors.Is(err, errNoSuchClient) {
+			// Remember unknown IDs as well, so they are not told apart by a database round trip
+			client = nil
+			as.clientCache.SetMissing(tokenReq.ClientID)
+		} else if err != nil {
 			logger.Warn().Err(err).Str("client_id", tokenReq.ClientID).Msg("Client lookup failed")
+			as.metrics.tokenRequest(tokenOutcomeError)
+			as.auditTokenRequest(c, tokenReq.ClientID, "", tokenOutcomeError)
 			RespondWithError(c, ErrInternalServerError("Failed to lookup client").WithOriginalError(err))
 			return
 		}
@@ -48,25 +70,72 @@ func (as *authServer) tokenHandler(c *gin.Context) {
 		}
 	}
 
-	if client == nil || client.ClientSecret != tokenReq.ClientSecret {
-		logger.Warn().Str("client_id", tokenReq.ClientID).Msg("Invalid client credentials")
+	// Unknown clients are checked against a dummy hash and answered exactly like a wrong secret
+	verified := false
+	if client == nil {
+		verifyDummySecret(tokenReq.ClientSecret, secretCheckSlots)
+	} else {
+		verified = as.verifyClientSecret(client, tokenReq.ClientSecret)
+	}
+	if !verified {
+		delay := as.recordSecretFailure(tokenReq.ClientID, clientIP(c))
+		logger.Warn().Str("client_id", tokenReq.ClientID).Dur("delay", delay).Msg("Invalid client credentials")
+		holdResponse(c.Request.Context(), delay)
+		as.metrics.tokenRequest(tokenOutcomeInvalidCredentials)
+		as.auditTokenRequest(c, tokenReq.ClientID, "", tokenOutcomeInvalidCredentials)
 		RespondWithError(c, ErrUnauthorizedError("Invalid client credentials"))
 		return
 	}
+	if as.lockouts != nil {
+		as.lockouts.Success(ClientLockoutSubject(client.ClientID))
+	}
+
+	// Disabled clients and clients outside their validity window keep their credentials but get no tokens
+	if err := client.checkAvailable(time.Now()); err != nil {
+		logger.Warn().Err(err).Str("client_id", tokenReq.ClientID).Msg("Client may not obtain tokens")
+		as.metrics.tokenRequest(tokenOutcomeClientUnavailable)
+		as.auditTokenRequest(c, tokenReq.ClientID, "", tokenOutcomeClientUnavailable)
+		RespondWithError(c, ErrInvalidClientError("Client is not allowed to obtain tokens").WithDetails(err.Error()))
+		return
+	}
 
 	logger.Debug().Str("client_id", tokenReq.ClientID).Msg("Client credentials validated")
 
+	// Clients with an allowlist only get tokens on those networks; bound tokens carry the network they were issued to
+	network, err := client.issuanceNetwork(clientAddr(c))
+	if err != nil {
+		logger.Warn().Str("client_id", tokenReq.ClientID).Str("client_ip", clientIP(c)).Msg("Token request from outside the client's allowed networks")
+		as.metrics.tokenRequest(tokenOutcomeNetworkDenied)
+		as.auditTokenRequest(c, tokenReq.ClientID, "", tokenOutcomeNetworkDenied)
+		RespondWithError(c, ErrForbiddenError("Client may not obtain tokens from this network"))
+		return
+	}
+	if !client.NetworkClaim {
+		network = netip.Prefix{}
+	}
+
+	// Each issued token costs a write, so clients are limited before one is generated
+	if !as.limitClient(c, rateLimitToken, client) {
+		as.metrics.tokenRequest(tokenOutcomeRateLimited)
+		as.auditTokenRequest(c, tokenReq.ClientID, "", tokenOutcomeRateLimited)
+		return
+	}
+
 	// Handle client credentials grant
 	// Scopes are automatically fetched from the client's configuration
 	if tokenReq.GrantType == "client_credentials" {
-		token, tokenID, err := as.generateJWT(tokenReq.ClientID)
+		token, tokenID, err := as.generateJWT(c.Request.Context(), tokenReq.ClientID, network)
 		if err != nil {
 			logger.Error().Err(err).Str("client_id", tokenReq.ClientID).Msg("Failed to generate JWT token")
+			as.metrics.tokenRequest(tokenOutcomeError)
+			as.auditTokenRequest(c, tokenReq.ClientID, "", tokenOutcomeError)
 			RespondWithError(c, ErrInternalServerError("Failed to generate token").WithOriginalError(err))
 			return
 		}
 
 		logger.Info().Str("client_id", tokenReq.ClientID).Str("token_id", tokenID).Msg("JWT token generated successfully")
+		as.metrics.tokenRequest(tokenOutcomeIssued)
+		as.auditTokenRequest(c, tokenReq.ClientID, tokenID, tokenOutcomeIssued)
 
 		c.Header("Content-Type", "application/json")
 		encoder := json.NewEncoder(c.Writer)
@@ -82,34 +151,43 @@ func (as *authServer) tokenHandler(c *gin.Context) {
 	}
 
 	logger.Warn().Str("grant_type", tokenReq.GrantType).Msg("Unsupported grant type")
+	as.metrics.tokenRequest(tokenOutcomeUnsupportedGrant)
+	as.auditTokenRequest(c, tokenReq.ClientID, "", tokenOutcomeUnsupportedGrant)
 	RespondWithError(c, ErrBadRequest("Unsupported grant type"))
 }
 
 // Validate token handler
 // This endpoint is called by nginx API gateway before forwarding requests to protected resources.
-// Nginx includes the X-Forwarded-For header with the requested resource endpoint URL.
+// Nginx includes the X-Forwarded-For header with the requested resource endpoint URL
+// and X-Forwarded-Method with the original HTTP method (the "validate" proxy profile).
+// Other proxies use forwardAuthHandler with their own profile.
 func (as *authServer) validateHandler(c *gin.Context) {
 	logger := GetRequestLogger(c)
 	logger.Debug().Msg("Processing validate request")
 
 	if c.Request.Method != http.MethodPost {
 		logger.Warn().Str("method", c.Request.Method).Msg("Invalid HTTP method for validate endpoint")
+		as.metrics.tokenValidation(validationEndpointValidate, validationOutcomeInvalidRequest)
 		c.String(http.StatusMethodNotAllowed, "Method not allowed")
 		return
 	}
 
-	// Get the requested resource endpoint from nginx x-forwarded-for header
-	requestURL := c.Request.Header.Get("X-Forwarded-For")
-	if requestURL == "" {
-		logger.Warn().Msg("Missing X-Forwarded-For header (resource endpoint)")
-		RespondWithError(c, ErrBadRequest("Missing X-Forwarded-For header (resource endpoint)"))
+	// The resource endpoint and original method arrive in X-Forwarded-For and X-Forwarded-Method
+	profile, _ := lookupProxyProfile(ProxyProfileValidate)
+	original, apiErr := profile.originalRequest(c.Request)
+	if apiErr != nil {
+		logger.Warn().Msg(apiErr.Message)
+		as.metrics.tokenValidation(validationEndpointValidate, validationOutcomeInvalidRequest)
+		RespondWithError(c, apiErr)
 		return
 	}
-	logger.Debug().Str("resource", requestURL).Msg("Validating access to resource")
+	requestURL, requestMethod := original.URL, original.Method
+	logger.Debug().Str("resource", requestURL).Str("method", requestMethod).Msg("Validating access to resource")
 
 	authHeader := c.Request.Header.Get("Authorization")
 	if authHeader == "" {
 		logger.Warn().Str("resource", requestURL).Msg("Missing Authorization header")
+		as.metrics.tokenValidation(validationEndpointValidate, validationOutcomeMissingToken)
 		RespondWithError(c, ErrUnauthorizedError("Missing Authorization header"))
 		return
 	}
@@ -117,38 +195,51 @@ func (as *authServer) validateHandler(c *gin.Context) {
 	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
 	if tokenString == authHeader {
 		logger.Warn().Str("resource", requestURL).Msg("Invalid Bearer token format")
+		as.metrics.tokenValidation(validationEndpointValidate, validationOutcomeMissingToken)
 		RespondWithError(c, ErrUnauthorizedError("Bearer token required"))
 		return
 	}
 
 	// Validate token
-	claims, err := as.validateJWT(tokenString)
+	claims, err := as.validateJWT(c.Request.Context(), tokenString)
 	if err != nil {
 		logger.Warn().Err(err).Str("resource", requestURL).Msg("JWT token validation failed")
+		as.metrics.tokenValidation(validationEndpointValidate, validationOutcomeInvalidToken)
 		RespondWithError(c, ErrUnauthorizedError("Invalid or expired token").WithOriginalError(err))
 		return
 	}
 
 	logger.Debug().Str("client_id", claims.ClientID).Str("resource", requestURL).Msg("JWT claims extracted")
 
-	// Validate that the requested resource URL is within the client's allowed scopes
+	if as.rateLimiter != nil {
+		if client, err := as.lookupClient(c.Request.Context(), claims.ClientID); err == nil && !as.limitClient(c, rateLimitValidate, client) {
+			as.metrics.tokenValidation(validationEndpointValidate, validationOutcomeRateLimited)
+			return
+		}
+	}
+
+	// The token's caller, as forwarded by the gateway, must be on a network the token and its client allow
+	if apiErr := as.authorizeSource(c.Request.Context(), claims, clientAddr(c)); apiErr != nil {
+		as.metrics.tokenValidation(validationEndpointValidate, validationOutcomeNetworkDenied)
+		RespondWithError(c, apiErr)
+		return
+	}
+
+	// Authorize the request against the client's endpoint rules, or its scopes if it has none
 	// Scopes represent endpoint URLs that the client is allowed to access
-	found := slices.Contains(claims.Scope, requestURL)
-	if !found {
-		logger.Warn().
-			Str("client_id", claims.ClientID).
-			Str("resource", requestURL).
-			Strs("allowed_scopes", claims.Scope).
-			Msg("Resource not in token scopes - access denied")
-		RespondWithError(c, ErrForbiddenError("Resource not in token scopes"))
+	if apiErr := as.authorizeRequest(c.Request.Context(), claims, requestMethod, requestURL); apiErr != nil {
+		as.metrics.tokenValidation(validationEndpointValidate, validationOutcome(apiErr))
+		RespondWithError(c, apiErr)
 		return
 	}
 
 	logger.Info().
 		Str("client_id", claims.ClientID).
+		Str("method", requestMethod).
 		Str("resource", requestURL).
 		Time("expires_at", claims.ExpiresAt.Time).
 		Msg("Token validated for resource - access granted")
+	as.metrics.tokenValidation(validationEndpointValidate, validationOutcomeGranted)
 
 	c.Header("Content-Type", "application/json")
 	encoder := json.NewEncoder(c.Writer)
@@ -188,9 +279,10 @@ func (as *authServer) revokeHandler(c *gin.Context) {
 	}
 
 	// Validate token first
-	claims, err := as.validateJWT(tokenString)
+	claims, err := as.validateJWT(c.Request.Context(), tokenString)
 	if err != nil {
 		logger.Warn().Err(err).Msg("JWT token validation failed during revocation")
+		as.auditRequest(c, AuditEntry{Action: AuditTokenRevoke, Outcome: AuditOutcomeFailure, Detail: "invalid or expired token"})
 		RespondWithError(c, ErrUnauthorizedError("Invalid or expired token").WithOriginalError(err))
 		return
 	}
@@ -202,15 +294,27 @@ func (as *authServer) revokeHandler(c *gin.Context) {
 		ClientID:  claims.ClientID,
 		TokenID:   claims.TokenID,
 		RevokedAt: time.Now(),
+		ExpiresAt: claims.ExpiresAt.Time,
 	}
 
 	if err := as.revokeToken(revokedToken); err != nil {
 		logger.Error().Err(err).Str("client_id", claims.ClientID).Str("token_id", claims.TokenID).Msg("Failed to revoke token")
+		as.auditRequest(c, AuditEntry{Action: AuditTokenRevoke, Outcome: AuditOutcomeFailure, Actor: claims.ClientID,
+			ClientID: claims.ClientID, TokenID: claims.TokenID, Detail: err.Error()})
 		RespondWithError(c, ErrInternalServerError("Failed to revoke token").WithOriginalError(err))
 		return
 	}
 
 	logger.Info().Str("client_id", claims.ClientID).Str("token_id", claims.TokenID).Msg("Token revoked successfully")
+	as.auditRequest(c, AuditEntry{Action: AuditTokenRevoke, Actor: claims.ClientID, ClientID: claims.ClientID, TokenID: claims.TokenID})
+
+	// Let other instances drop any state they hold for this token
+	as.publishEvent(Event{
+		Type:      EventTokenRevoked,
+		ClientID:  claims.ClientID,
+		TokenID:   claims.TokenID,
+		ExpiresAt: claims.ExpiresAt.Time,
+	})
 
 	c.Header("Content-Type", "application/json")
 	encoder := json.NewEncoder(c.Writer)
@@ -221,3 +325,68 @@ func (as *authServer) revokeHandler(c *gin.Context) {
 		c.AbortWithError(http.StatusBadRequest, err)
 	}
 }
+
+// Revoke all tokens handler
+// Revokes every token the calling client has been issued so far by recording a client-wide watermark
+func (as *authServer) revokeAllHandler(c *gin.Context) {
+	logger := GetRequestLogger(c)
+
+	claims, ok := as.authenticateBearer(c)
+	if !ok {
+		return
+	}
+
+	revokedBefore := time.Now()
+	logger.Debug().Str("client_id", claims.ClientID).Time("revoked_before", revokedBefore).Msg("Revoking all client tokens")
+
+	if err := as.revokeClientTokens(claims.ClientID, revokedBefore); err != nil {
+		logger.Error().Err(err).Str("client_id", claims.ClientID).Msg("Failed to revoke client tokens")
+		as.auditRequest(c, AuditEntry{Action: AuditClientRevokeTokens, Outcome: AuditOutcomeFailure, Actor: claims.ClientID,
+			ClientID: claims.ClientID, Detail: err.Error()})
+		RespondWithError(c, ErrInternalServerError("Failed to revoke tokens").WithOriginalError(err))
+		return
+	}
+
+	logger.Info().Str("client_id", claims.ClientID).Msg("All client tokens revoked successfully")
+	as.auditRequest(c, AuditEntry{Action: AuditClientRevokeTokens, Actor: claims.ClientID, ClientID: claims.ClientID,
+		Detail: "revoked_before=" + revokedBefore.UTC().Format(time.RFC3339Nano)})
+
+	as.publishEvent(Event{
+		Type:          EventClientRevoked,
+		ClientID:      claims.ClientID,
+		RevokedBefore: revokedBefore,
+	})
+
+	c.JSON(http.StatusOK, map[string]any{
+		"message":        "All tokens revoked successfully",
+		"revoked_before": revokedBefore,
+	})
+}
+
+// authenticateBearer validates the request's bearer token, responding with 401 on failure
+func (as *authServer) authenticateBearer(c *gin.Context) (*Claims, bool) {
+	logger := GetRequestLogger(c)
+
+	authHeader := c.Request.Header.Get("Authorization")
+	if authHeader == "" {
+		logger.Warn().Msg("Missing Authorization header")
+		RespondWithError(c, ErrUnauthorizedError("Authorization header required"))
+		return nil, false
+	}
+
+	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+	if tokenString == authHeader {
+		logger.Warn().Msg("Invalid Bearer token format")
+		RespondWithError(c, ErrUnauthorizedError("Bearer token required"))
+		return nil, false
+	}
+
+	claims, err := as.validateJWT(c.Request.Context(), tokenString)
+	if err != nil {
+		logger.Warn().Err(err).Msg("JWT token validation failed")
+		RespondWithError(c, ErrUnauthorizedError("Invalid or expired token").WithOriginalError(err))
+		return nil, false
+	}
+
+	return claims, true
+}
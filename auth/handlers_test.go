@@ -2,10 +2,12 @@ package auth
 
 import (
 	"bytes"
+	"database/sql"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -307,3 +309,113 @@ func TestTokenHandlerMissingClientID(t *testing.T) {
 	// In a real scenario, handlers should have proper nil checks
 	t.Skip("Skipping test that requires database - db is nil in tests")
 }
+
+func TestRevocationsHandler_MissingAuthorization(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	ctx, cancel := createTestContextFunc()
+	defer cancel()
+
+	server := &authServer{
+		jwtSecret: []byte("test-secret"),
+		ctx:       ctx,
+		cancel:    cancel,
+		events:    NewEventBus("test", NewLocalTransport()),
+	}
+
+	router := gin.New()
+	router.GET("/revocations", server.revocationsHandler)
+	router.GET("/revocations/stream", server.revocationStreamHandler)
+
+	for _, path := range []string{"/revocations", "/revocations/stream"} {
+		req, _ := http.NewRequest("GET", path, nil)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+
+		if recorder.Code != http.StatusUnauthorized {
+			t.Errorf("%s: expected status 401 for missing authorization, got %d", path, recorder.Code)
+		}
+	}
+}
+
+func TestParseEventID(t *testing.T) {
+	if id, err := parseEventID("42"); err != nil || id != 42 {
+		t.Errorf("Expected 42, got %d (%v)", id, err)
+	}
+	if _, err := parseEventID("-1"); err == nil {
+		t.Errorf("Expected error for negative ID")
+	}
+	if _, err := parseEventID("abc"); err == nil {
+		t.Errorf("Expected error for non-numeric ID")
+	}
+}
+
+func TestTokenHandler_RefusesUnavailableClient(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	ctx, cancel := createTestContextFunc()
+	defer cancel()
+
+	cache := NewClientCache(time.Minute, 10)
+	defer cache.Stop()
+
+	ended := time.Now().Add(-time.Hour)
+	cache.Set("disabled", &Clients{ClientID: "disabled", Secrets: []*ClientSecret{{ID: 1, Hash: "s3cret", Primary: true}}})
+	cache.Set("ended", &Clients{ClientID: "ended", Active: true, ValidUntil: &ended, Secrets: []*ClientSecret{{ID: 2, Hash: "s3cret", Primary: true}}})
+
+	server := &authServer{jwtSecret: []byte("test-secret"), ctx: ctx, cancel: cancel, clientCache: cache}
+	router := gin.New()
+	router.POST("/token", server.tokenHandler)
+
+	for _, clientID := range []string{"disabled", "ended"} {
+		body := `{"client_id": "` + clientID + `", "client_secret": "s3cret", "grant_type": "client_credentials"}`
+		req, _ := http.NewRequest("POST", "/token", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+
+		var apiErr APIError
+		json.Unmarshal(recorder.Body.Bytes(), &apiErr)
+		if recorder.Code != http.StatusUnauthorized || apiErr.Code != ErrInvalidClient {
+			t.Errorf("%s: expected 401 invalid_client, got %d: %s", clientID, recorder.Code, recorder.Body.String())
+		}
+	}
+}
+
+func TestTokenHandler_UnknownClientSkipsDatabase(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cheapSecretPolicy(t, SecretAlgorithmArgon2id)
+
+	ctx, cancel := createTestContextFunc()
+	defer cancel()
+
+	cache := NewClientCache(time.Minute, 10)
+	defer cache.Stop()
+	cache.SetMissing("ghost")
+
+	// Every query against a closed pool fails, so reaching the database shows up as a 500
+	db, _ := sql.Open("godror", "")
+	db.Close()
+
+	server := &authServer{jwtSecret: []byte("test-secret"), ctx: ctx, cancel: cancel, clientCache: cache, db: db}
+	router := gin.New()
+	router.POST("/token", server.tokenHandler)
+
+	send := func() int {
+		body := `{"client_id": "ghost", "client_secret": "s3cret", "grant_type": "client_credentials"}`
+		req, _ := http.NewRequest("POST", "/token", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		return recorder.Code
+	}
+
+	if code := send(); code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 from the cached unknown ID without a database call, got %d", code)
+	}
+
+	cache.Invalidate("ghost")
+	if code := send(); code != http.StatusInternalServerError {
+		t.Errorf("Expected the invalidated ID to be looked up again, got %d", code)
+	}
+}
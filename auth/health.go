@@ -0,0 +1,226 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+// Component and overall health states
+const (
+	HealthStatusOK       = "ok"
+	HealthStatusFail     = "fail"
+	HealthStatusReady    = "ready"
+	HealthStatusNotReady = "not_ready"
+	HealthStatusDraining = "draining"
+)
+
+// Components checked for readiness
+const (
+	healthComponentDatabase   = "database"
+	healthComponentTokenBatch = "token_batch"
+	healthComponentSigningKey = "signing_key"
+	healthComponentCache      = "client_cache"
+)
+
+// ComponentHealth is the result of one readiness check
+type ComponentHealth struct {
+	Status    string  `json:"status"`
+	Detail    string  `json:"detail,omitempty"`
+	LatencyMS float64 `json:"latency_ms,omitempty"`
+}
+
+// HealthReport is the readiness of the instance and each of its components
+type HealthReport struct {
+	Status     string                     `json:"status"`
+	Components map[string]ComponentHealth `json:"components"`
+	CheckedAt  time.Time                  `json:"checked_at"`
+}
+
+// failing lists the components that failed their check
+func (r HealthReport) failing() []string {
+	var names []string
+	for name, component := range r.Components {
+		if component.Status != HealthStatusOK {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// checkHealth runs every readiness check
+// A draining instance is never ready, whatever its components say
+func (as *authServer) checkHealth(ctx context.Context) HealthReport {
+	report := HealthReport{
+		Status: HealthStatusReady,
+		Components: map[string]ComponentHealth{
+			healthComponentDatabase:   as.checkDatabase(ctx),
+			healthComponentTokenBatch: as.checkTokenBatch(),
+			healthComponentSigningKey: as.checkSigningKey(),
+			healthComponentCache:      as.checkClientCache(),
+		},
+		CheckedAt: time.Now(),
+	}
+	if len(report.failing()) > 0 {
+		report.Status = HealthStatusNotReady
+	}
+	if as.draining.Load() {
+		report.Status = HealthStatusDraining
+	}
+	return report
+}
+
+// checkDatabase pings the database, failing if the ping errors or is slower than health.max_db_latency_ms
+func (as *authServer) checkDatabase(ctx context.Context) ComponentHealth {
+	if as.db == nil {
+		return ComponentHealth{Status: HealthStatusFail, Detail: "no database connection"}
+	}
+
+	limit := time.Duration(AppConfig.Health.MaxDBLatency) * time.Millisecond
+	ctx, cancel := context.WithTimeout(ctx, limit)
+	defer cancel()
+
+	start := time.Now()
+	err := as.db.PingContext(ctx)
+	latency := time.Since(start)
+	health := ComponentHealth{Status: HealthStatusOK, LatencyMS: float64(latency.Microseconds()) / 1000}
+	switch {
+	case err != nil && latency >= limit:
+		health.Status = HealthStatusFail
+		health.Detail = fmt.Sprintf("ping took longer than %s", limit)
+	case err != nil:
+		health.Status = HealthStatusFail
+		health.Detail = err.Error()
+	}
+	return health
+}
+
+// checkTokenBatch fails when more issued tokens await writing than health.max_batch_backlog
+func (as *authServer) checkTokenBatch() ComponentHealth {
+	if as.tokenBatcher == nil {
+		return ComponentHealth{Status: HealthStatusOK, Detail: "stateless mode, no token ledger"}
+	}
+
+	pending, limit := as.tokenBatcher.GetPendingCount(), AppConfig.Health.MaxBatchBacklog
+	health := ComponentHealth{Status: HealthStatusOK, Detail: fmt.Sprintf("%d tokens pending, limit %d", pending, limit)}
+	if pending > limit {
+		health.Status = HealthStatusFail
+	}
+	return health
+}
+
+// checkSigningKey fails when there is no key to sign new tokens with
+func (as *authServer) checkSigningKey() ComponentHealth {
+	if as.keyRing == nil {
+		if len(as.jwtSecret) == 0 {
+			return ComponentHealth{Status: HealthStatusFail, Detail: "no HS256 secret"}
+		}
+		return ComponentHealth{Status: HealthStatusOK, Detail: "HS256 shared secret"}
+	}
+
+	key := as.keyRing.Active()
+	if key == nil {
+		return ComponentHealth{Status: HealthStatusFail, Detail: "no active ES256 key"}
+	}
+	return ComponentHealth{Status: HealthStatusOK, Detail: "ES256 key " + key.ID}
+}
+
+// checkClientCache fails until the startup cache warm-up has finished
+func (as *authServer) checkClientCache() ComponentHealth {
+	if as.clientCache == nil {
+		return ComponentHealth{Status: HealthStatusFail, Detail: "no client cache"}
+	}
+	if !as.clientCache.IsWarm() {
+		return ComponentHealth{Status: HealthStatusFail, Detail: "warming up"}
+	}
+	return ComponentHealth{Status: HealthStatusOK, Detail: fmt.Sprintf("%d clients cached", as.clientCache.GetSize())}
+}
+
+// warmClientCache loads up to health.warm_clients active clients into the cache, then marks it warm
+// A failed warm-up still marks the cache warm; clients are then loaded on first use as usual
+func (as *authServer) warmClientCache() {
+	defer as.clientCache.SetWarm()
+
+	start := time.Now()
+	clientIDs, err := as.activeClientIDs(AppConfig.Health.WarmClients)
+	if err != nil {
+		log.Warn().Err(err).Msg("Client cache warm-up failed to list clients")
+		return
+	}
+
+	loaded := 0
+	for _, clientID := range clientIDs {
+		if as.ctx.Err() != nil {
+			return
+		}
+		client, err := as.clientByID(as.ctx, clientID)
+		if err != nil {
+			log.Warn().Err(err).Str("client_id", clientID).Msg("Client cache warm-up skipped client")
+			continue
+		}
+		as.clientCache.Set(clientID, client)
+		loaded++
+	}
+
+	log.Info().
+		Int("clients", loaded).
+		Str("duration", time.Since(start).String()).
+		Msg("Client cache warmed")
+}
+
+// activeClientIDs lists up to limit active, undeleted client IDs
+func (as *authServer) activeClientIDs(limit int) ([]string, error) {
+	ctx, cancel := context.WithTimeout(as.ctx, 5*time.Second)
+	defer cancel()
+
+	query := `SELECT client_id FROM clients WHERE deleted_at IS NULL AND NVL(active, 1) = 1
+		ORDER BY client_id FETCH FIRST :limit ROWS ONLY`
+	rows, err := as.db.QueryContext(ctx, query, sql.Named("limit", limit))
+	if err != nil {
+		return nil, fmt.Errorf("activeClientIDs: %v", err)
+	}
+	defer rows.Close()
+
+	var clientIDs []string
+	for rows.Next() {
+		var clientID string
+		if err := rows.Scan(&clientID); err != nil {
+			return nil, fmt.Errorf("activeClientIDs: %v", err)
+		}
+		clientIDs = append(clientIDs, clientID)
+	}
+	return clientIDs, rows.Err()
+}
+
+// healthzHandler reports liveness: the process is up and serving
+func (as *authServer) healthzHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": HealthStatusOK})
+}
+
+// readyzHandler reports whether the instance should receive traffic
+// Only failing component names are shown; details are on the admin API
+func (as *authServer) readyzHandler(c *gin.Context) {
+	report := as.checkHealth(c.Request.Context())
+	if report.Status != HealthStatusReady {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": report.Status, "failing": report.failing()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": report.Status})
+}
+
+// healthDetailsHandler shows every component's readiness check
+func (as *authServer) healthDetailsHandler(c *gin.Context) {
+	report := as.checkHealth(c.Request.Context())
+	status := http.StatusOK
+	if report.Status != HealthStatusReady {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, report)
+}
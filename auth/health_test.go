@@ -0,0 +1,96 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newHealthTestServer returns a stateless server with a warm cache and no database
+func newHealthTestServer(t *testing.T) *authServer {
+	previous := AppConfig.Health
+	AppConfig.Health = health{MaxDBLatency: 500, MaxBatchBacklog: 2}
+	t.Cleanup(func() { AppConfig.Health = previous })
+
+	ctx, cancel := createTestContextFunc()
+	t.Cleanup(cancel)
+	cache := NewClientCache(time.Minute, 10)
+	t.Cleanup(cache.Stop)
+	cache.SetWarm()
+	return &authServer{jwtSecret: []byte("test-secret"), ctx: ctx, cancel: cancel, clientCache: cache, stateless: true}
+}
+
+func TestHealthzHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	server := newHealthTestServer(t)
+
+	router := gin.New()
+	routes(router, server)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected 200, got %d", w.Code)
+	}
+}
+
+func TestReadyzHandler_ReportsFailingComponents(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	server := newHealthTestServer(t)
+
+	router := gin.New()
+	routes(router, server)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 without a database, got %d", w.Code)
+	}
+	var body struct {
+		Status  string   `json:"status"`
+		Failing []string `json:"failing"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if body.Status != HealthStatusNotReady || len(body.Failing) != 1 || body.Failing[0] != healthComponentDatabase {
+		t.Errorf("expected only the database to fail, got %+v", body)
+	}
+}
+
+func TestCheckHealth_Components(t *testing.T) {
+	server := newHealthTestServer(t)
+
+	report := server.checkHealth(t.Context())
+	for _, name := range []string{healthComponentTokenBatch, healthComponentSigningKey, healthComponentCache} {
+		if got := report.Components[name].Status; got != HealthStatusOK {
+			t.Errorf("%s: expected %s, got %s (%s)", name, HealthStatusOK, got, report.Components[name].Detail)
+		}
+	}
+
+	// A backlog over the limit, a key ring without an active key and a cold cache all fail
+	server.tokenBatcher = &TokenBatchWriter{tokens: make([]Token, 3)}
+	server.keyRing = NewKeyRing()
+	server.clientCache = NewClientCache(time.Minute, 10)
+	defer server.clientCache.Stop()
+
+	report = server.checkHealth(t.Context())
+	for _, name := range []string{healthComponentTokenBatch, healthComponentSigningKey, healthComponentCache} {
+		if got := report.Components[name].Status; got != HealthStatusFail {
+			t.Errorf("%s: expected %s, got %s", name, HealthStatusFail, got)
+		}
+	}
+}
+
+func TestCheckHealth_Draining(t *testing.T) {
+	server := newHealthTestServer(t)
+	server.draining.Store(true)
+
+	if report := server.checkHealth(t.Context()); report.Status != HealthStatusDraining {
+		t.Errorf("expected %s while draining, got %s", HealthStatusDraining, report.Status)
+	}
+}
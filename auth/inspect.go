@@ -0,0 +1,128 @@
+package auth
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// Runtime inspection covers only the instance that answers; each replica has its own cache, batch writer and pool
+
+// cacheStatsHandler reports the client cache's size, limits and hit statistics
+func (as *authServer) cacheStatsHandler(c *gin.Context) {
+	cc := as.clientCache
+	stats := cc.GetStats()
+	c.JSON(http.StatusOK, gin.H{
+		"size":        cc.GetSize(),
+		"max_size":    cc.maxSize,
+		"ttl_seconds": int64(cc.ttl.Seconds()),
+		"hits":        stats.Hits,
+		"misses":      stats.Misses,
+		"evicted":     stats.Evicted,
+		"hit_rate":    cc.GetHitRate(),
+		"warm":        cc.IsWarm(),
+	})
+}
+
+// cacheEntriesHandler lists the cached clients without their secrets
+func (as *authServer) cacheEntriesHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"clients": as.clientCache.Entries()})
+}
+
+// invalidateCacheEntryHandler drops one client from this instance's cache; the next request reloads it
+func (as *authServer) invalidateCacheEntryHandler(c *gin.Context) {
+	logger := GetRequestLogger(c)
+
+	clientID := c.Param("client_id")
+	as.clientCache.Invalidate(clientID)
+	actor := adminActor(c)
+	logger.Info().Str("client_id", clientID).Str("actor", actor.Subject).Msg("Client cache entry invalidated via admin API")
+	as.auditActor(actor, AuditEntry{Action: AuditCacheInvalidate, ClientID: clientID})
+	c.Status(http.StatusNoContent)
+}
+
+// clearCacheHandler drops every client from this instance's cache
+func (as *authServer) clearCacheHandler(c *gin.Context) {
+	logger := GetRequestLogger(c)
+
+	cleared := as.clientCache.GetSize()
+	as.clientCache.Clear()
+	actor := adminActor(c)
+	logger.Info().Int("cleared_entries", cleared).Str("actor", actor.Subject).Msg("Client cache cleared via admin API")
+	as.auditActor(actor, AuditEntry{Action: AuditCacheClear, Detail: "entries: " + strconv.Itoa(cleared)})
+	c.Status(http.StatusNoContent)
+}
+
+// batchStateHandler reports the token batch writer's queue and write history
+func (as *authServer) batchStateHandler(c *gin.Context) {
+	if as.tokenBatcher == nil {
+		RespondWithError(c, ErrConflictError("Tokens are not batched in "+TokenModeStateless+" token mode"))
+		return
+	}
+	c.JSON(http.StatusOK, as.tokenBatcher.State())
+}
+
+// flushBatchHandler hands the pending tokens to a database write without waiting for the flush interval
+// The write itself is asynchronous; in_flight and last_flushed_at in the response show its progress
+func (as *authServer) flushBatchHandler(c *gin.Context) {
+	logger := GetRequestLogger(c)
+
+	if as.tokenBatcher == nil {
+		RespondWithError(c, ErrConflictError("Tokens are not batched in "+TokenModeStateless+" token mode"))
+		return
+	}
+	flushed := as.tokenBatcher.Flush()
+	logger.Info().Int("tokens", flushed).Str("actor", adminActor(c).Subject).Msg("Token batch flushed via admin API")
+	c.JSON(http.StatusAccepted, gin.H{"flushed": flushed, "state": as.tokenBatcher.State()})
+}
+
+// dbStatsHandler reports the database connection pool statistics
+func (as *authServer) dbStatsHandler(c *gin.Context) {
+	stats := as.db.Stats()
+	c.JSON(http.StatusOK, gin.H{
+		"max_open_connections": stats.MaxOpenConnections,
+		"open_connections":     stats.OpenConnections,
+		"in_use":               stats.InUse,
+		"idle":                 stats.Idle,
+		"wait_count":           stats.WaitCount,
+		"wait_duration_ms":     stats.WaitDuration.Milliseconds(),
+		"max_idle_closed":      stats.MaxIdleClosed,
+		"max_idle_time_closed": stats.MaxIdleTimeClosed,
+		"max_lifetime_closed":  stats.MaxLifetimeClosed,
+	})
+}
+
+// logLevelRequest is the body of PUT /admin/log-level
+type logLevelRequest struct {
+	Level string `json:"level"`
+}
+
+// getLogLevelHandler reports this instance's log level
+func (as *authServer) getLogLevelHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"level": zerolog.GlobalLevel().String()})
+}
+
+// setLogLevelHandler changes this instance's log level until it restarts
+func (as *authServer) setLogLevelHandler(c *gin.Context) {
+	var req logLevelRequest
+	if apiErr := decodeAdminBody(c, &req); apiErr != nil {
+		RespondWithError(c, apiErr)
+		return
+	}
+	level, err := zerolog.ParseLevel(req.Level)
+	if err != nil || req.Level == "" {
+		RespondWithError(c, ErrBadRequest("level must be one of trace, debug, info, warn, error, fatal, panic or disabled"))
+		return
+	}
+
+	previous := SetLogLevel(level)
+	actor := adminActor(c)
+	// Logged at warn so the change is recorded whatever the new level
+	log.Warn().Str("level", level.String()).Str("previous_level", previous.String()).Str("actor", actor.Subject).
+		Msg("Log level changed via admin API")
+	as.auditActor(actor, AuditEntry{Action: AuditLogLevelChange, Detail: previous.String() + " -> " + level.String()})
+	c.JSON(http.StatusOK, gin.H{"level": level.String(), "previous_level": previous.String()})
+}
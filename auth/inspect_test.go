@@ -0,0 +1,122 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+)
+
+func TestCacheHandlers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cache := NewClientCache(time.Minute, 10)
+	defer cache.Stop()
+	cache.Set("billing", &Clients{ClientID: "billing", Name: "Billing", Active: true,
+		Secrets: []*ClientSecret{{}}})
+	cache.Set("reports", &Clients{ClientID: "reports", Name: "Reports"})
+
+	path := filepath.Join(t.TempDir(), "audit.log")
+	server := &authServer{clientCache: cache, auditLog: newFileAuditLog(t, path)}
+	router := gin.New()
+	router.GET("/admin/cache/clients", server.cacheEntriesHandler)
+	router.DELETE("/admin/cache/clients/:client_id", server.invalidateCacheEntryHandler)
+	router.DELETE("/admin/cache", server.clearCacheHandler)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", "/admin/cache/clients", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", recorder.Code)
+	}
+	var resp struct {
+		Clients []CacheEntryInfo `json:"clients"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(resp.Clients) != 2 || resp.Clients[0].ClientID != "billing" || resp.Clients[0].Secrets != 1 {
+		t.Errorf("Expected billing and reports, billing with one secret, got %+v", resp.Clients)
+	}
+
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest("DELETE", "/admin/cache/clients/billing", nil))
+	if recorder.Code != http.StatusNoContent || cache.GetSize() != 1 {
+		t.Errorf("Expected billing to be dropped, got %d with %d cached", recorder.Code, cache.GetSize())
+	}
+
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest("DELETE", "/admin/cache", nil))
+	if recorder.Code != http.StatusNoContent || cache.GetSize() != 0 {
+		t.Errorf("Expected an empty cache, got %d with %d cached", recorder.Code, cache.GetSize())
+	}
+
+	server.auditLog.Stop()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read audit file: %v", err)
+	}
+	entries := string(data)
+	if !strings.Contains(entries, `"action":"cache.invalidate"`) || !strings.Contains(entries, `"client_id":"billing"`) ||
+		!strings.Contains(entries, `"action":"cache.clear"`) || !strings.Contains(entries, `"detail":"entries: 1"`) {
+		t.Errorf("Expected invalidate and clear audit entries, got %s", entries)
+	}
+}
+
+func TestBatchStateHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	server := &authServer{}
+	router := gin.New()
+	router.GET("/admin/batch", server.batchStateHandler)
+
+	// Stateless servers have no batch writer
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", "/admin/batch", nil))
+	if recorder.Code != http.StatusConflict {
+		t.Errorf("Expected 409 without a batch writer, got %d", recorder.Code)
+	}
+
+	server.tokenBatcher = NewTokenBatchWriter(server, 10, time.Hour)
+	server.tokenBatcher.Add(Token{TokenID: "t1", ClientID: "billing"})
+	server.tokenBatcher.recordWrite(3, errors.New("ORA-03113"))
+
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", "/admin/batch", nil))
+	var state BatchWriterState
+	if err := json.Unmarshal(recorder.Body.Bytes(), &state); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if state.Pending != 1 || state.MaxBatch != 10 || state.Failed != 3 || state.LastError != "ORA-03113" || state.LastFlushedAt == nil {
+		t.Errorf("Unexpected batch state %+v", state)
+	}
+}
+
+func TestSetLogLevelHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	defer zerolog.SetGlobalLevel(zerolog.GlobalLevel())
+	SetLogLevel(zerolog.InfoLevel)
+
+	server := &authServer{}
+	router := gin.New()
+	router.PUT("/admin/log-level", server.setLogLevelHandler)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest("PUT", "/admin/log-level", strings.NewReader(`{"level": "loud"}`)))
+	if recorder.Code != http.StatusBadRequest || zerolog.GlobalLevel() != zerolog.InfoLevel {
+		t.Errorf("Expected 400 leaving the level at info, got %d at %s", recorder.Code, zerolog.GlobalLevel())
+	}
+
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest("PUT", "/admin/log-level", strings.NewReader(`{"level": "debug"}`)))
+	if recorder.Code != http.StatusOK || zerolog.GlobalLevel() != zerolog.DebugLevel {
+		t.Errorf("Expected 200 at debug, got %d at %s", recorder.Code, zerolog.GlobalLevel())
+	}
+}
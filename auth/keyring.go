@@ -0,0 +1,397 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"database/sql"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/rs/zerolog/log"
+)
+
+// Token signing algorithms
+const (
+	SigningAlgorithmHS256 = "HS256" // shared secret; resource servers need the secret to verify
+	SigningAlgorithmES256 = "ES256" // key ring; resource servers verify with public keys from /jwks
+)
+
+// signingKey is an ES256 key pair from the signing_keys table
+type signingKey struct {
+	ID        string
+	CreatedAt time.Time
+	RetiredAt *time.Time // Retired keys no longer sign but still verify until the grace period ends
+	private   *ecdsa.PrivateKey
+}
+
+// KeyRing holds the active signing key and every key still accepted for verification
+type KeyRing struct {
+	mu     sync.RWMutex
+	keys   map[string]*signingKey
+	active *signingKey
+}
+
+// JWK is a public key in JSON Web Key format
+type JWK struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+}
+
+// JWKSet is the document served at /jwks
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// NewKeyRing creates an empty key ring
+func NewKeyRing() *KeyRing {
+	return &KeyRing{keys: make(map[string]*signingKey)}
+}
+
+// Replace swaps in a freshly loaded key set; the newest unretired key becomes active
+func (kr *KeyRing) Replace(keys []*signingKey) {
+	byID := make(map[string]*signingKey, len(keys))
+	var active *signingKey
+	for _, key := range keys {
+		byID[key.ID] = key
+		if key.RetiredAt == nil && (active == nil || key.CreatedAt.After(active.CreatedAt)) {
+			active = key
+		}
+	}
+
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	kr.keys = byID
+	kr.active = active
+}
+
+// Active returns the key new tokens are signed with, or nil if there is none
+func (kr *KeyRing) Active() *signingKey {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+	return kr.active
+}
+
+// Lookup returns a verification key by ID
+func (kr *KeyRing) Lookup(kid string) (*signingKey, bool) {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+	key, exists := kr.keys[kid]
+	return key, exists
+}
+
+// JWKS returns the public half of every key, oldest first
+func (kr *KeyRing) JWKS() JWKSet {
+	kr.mu.RLock()
+	keys := make([]*signingKey, 0, len(kr.keys))
+	for _, key := range kr.keys {
+		keys = append(keys, key)
+	}
+	kr.mu.RUnlock()
+
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt.Before(keys[j].CreatedAt) })
+
+	set := JWKSet{Keys: make([]JWK, 0, len(keys))}
+	for _, key := range keys {
+		jwk, err := key.publicJWK()
+		if err != nil {
+			log.Error().Err(err).Str("key_id", key.ID).Msg("Failed to encode signing key as JWK")
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
+
+// publicJWK encodes the key's public half
+func (k *signingKey) publicJWK() (JWK, error) {
+	point, err := k.private.PublicKey.Bytes()
+	if err != nil {
+		return JWK{}, err
+	}
+	// Uncompressed P-256 point: 0x04 || X (32 bytes) || Y (32 bytes)
+	size := (len(point) - 1) / 2
+	return JWK{
+		Kty: "EC",
+		Crv: "P-256",
+		X:   base64.RawURLEncoding.EncodeToString(point[1 : 1+size]),
+		Y:   base64.RawURLEncoding.EncodeToString(point[1+size:]),
+		Kid: k.ID,
+		Alg: SigningAlgorithmES256,
+		Use: "sig",
+	}, nil
+}
+
+// generateSigningKey creates a new P-256 key with a random ID
+func generateSigningKey() (*signingKey, error) {
+	private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return &signingKey{
+		ID:        generateRandomString(8),
+		CreatedAt: time.Now(),
+		private:   private,
+	}, nil
+}
+
+// signToken signs claims with the active ring key, or the shared secret when no key ring is configured
+func (as *authServer) signToken(claims Claims) (string, error) {
+	if as.keyRing == nil {
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(as.jwtSecret)
+	}
+
+	key := as.keyRing.Active()
+	if key == nil {
+		return "", errors.New("no active signing key")
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.private)
+}
+
+// verificationKey picks the key a token must verify against
+// With a key ring, HS256 tokens are only accepted during the configured migration window
+func (as *authServer) verificationKey(token *jwt.Token) (any, error) {
+	switch token.Method.(type) {
+	case *jwt.SigningMethodHMAC:
+		if as.keyRing != nil && !time.Now().Before(as.hs256Until) {
+			return nil, errors.New("HS256 tokens are not accepted with a key ring")
+		}
+		return as.jwtSecret, nil
+	case *jwt.SigningMethodECDSA:
+		if as.keyRing == nil {
+			return nil, errors.New("ES256 tokens are not accepted without a key ring")
+		}
+		kid, _ := token.Header["kid"].(string)
+		key, exists := as.keyRing.Lookup(kid)
+		if !exists {
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+		return &key.private.PublicKey, nil
+	default:
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+}
+
+// loadSigningKeys reads every key that is active or still within its verification grace period
+func (as *authServer) loadSigningKeys() ([]*signingKey, error) {
+	ctx, cancel := context.WithTimeout(as.ctx, 5*time.Second)
+	defer cancel()
+
+	cutoff := time.Now().Add(-time.Duration(AppConfig.JWT.KeyGrace) * time.Minute)
+	query := `SELECT kid, private_key, created_at, retired_at FROM signing_keys
+		WHERE retired_at IS NULL OR retired_at > :cutoff`
+	rows, err := as.db.QueryContext(ctx, query, sql.Named("cutoff", cutoff))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []*signingKey
+	for rows.Next() {
+		var (
+			key       signingKey
+			pemData   string
+			retiredAt sql.NullTime
+		)
+		if err := rows.Scan(&key.ID, &pemData, &key.CreatedAt, &retiredAt); err != nil {
+			return nil, err
+		}
+		if key.private, err = parsePrivateKeyPEM(pemData); err != nil {
+			log.Error().Err(err).Str("key_id", key.ID).Msg("Skipping unreadable signing key")
+			continue
+		}
+		if retiredAt.Valid {
+			key.RetiredAt = &retiredAt.Time
+		}
+		keys = append(keys, &key)
+	}
+	return keys, rows.Err()
+}
+
+// insertSigningKey stores a new key; it becomes active once the ring is reloaded
+func (as *authServer) insertSigningKey(key *signingKey) error {
+	ctx, cancel := context.WithTimeout(as.ctx, 5*time.Second)
+	defer cancel()
+
+	pemData, err := privateKeyPEM(key.private)
+	if err != nil {
+		return err
+	}
+
+	_, err = as.db.ExecContext(ctx,
+		`INSERT INTO signing_keys (kid, algorithm, private_key, created_at) VALUES (:kid, :algorithm, :private_key, :created_at)`,
+		sql.Named("kid", key.ID),
+		sql.Named("algorithm", SigningAlgorithmES256),
+		sql.Named("private_key", pemData),
+		sql.Named("created_at", key.CreatedAt),
+	)
+	return err
+}
+
+// reloadKeyRing refreshes the key ring from the database
+func (as *authServer) reloadKeyRing() error {
+	keys, err := as.loadSigningKeys()
+	if err != nil {
+		return err
+	}
+	as.keyRing.Replace(keys)
+	return nil
+}
+
+// ensureSigningKey loads the key ring and creates a first key if none is active
+func (as *authServer) ensureSigningKey() error {
+	if err := as.reloadKeyRing(); err != nil {
+		return fmt.Errorf("failed to load signing keys: %w", err)
+	}
+	if as.keyRing.Active() != nil {
+		return nil
+	}
+
+	key, err := generateSigningKey()
+	if err != nil {
+		return fmt.Errorf("failed to generate signing key: %w", err)
+	}
+	if err := as.insertSigningKey(key); err != nil {
+		return fmt.Errorf("failed to store signing key: %w", err)
+	}
+	if err := as.reloadKeyRing(); err != nil {
+		return fmt.Errorf("failed to reload signing keys: %w", err)
+	}
+
+	log.Info().Str("key_id", key.ID).Msg("Created initial signing key")
+	as.publishEvent(Event{Type: EventKeyChanged, KeyID: key.ID})
+	return nil
+}
+
+// SigningKeyInfo describes the outcome of a key rotation
+type SigningKeyInfo struct {
+	KeyID       string    `json:"key_id"`
+	Algorithm   string    `json:"algorithm"`
+	CreatedAt   time.Time `json:"created_at"`
+	RetiredKeys []string  `json:"retired_keys"`
+}
+
+// rotateSigningKey retires every active key and stores a new one in a single transaction
+// Retired keys keep verifying for jwt.key_grace_minutes so outstanding tokens stay valid
+func (as *authServer) rotateSigningKey() (*SigningKeyInfo, error) {
+	if as.keyRing == nil {
+		return nil, fmt.Errorf("key rotation requires jwt.signing_algorithm %s", SigningAlgorithmES256)
+	}
+
+	key, err := generateSigningKey()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate signing key: %w", err)
+	}
+	pemData, err := privateKeyPEM(key.private)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(as.ctx, 5*time.Second)
+	defer cancel()
+
+	tx, err := as.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	info := &SigningKeyInfo{KeyID: key.ID, Algorithm: SigningAlgorithmES256, CreatedAt: key.CreatedAt, RetiredKeys: []string{}}
+	rows, err := tx.QueryContext(ctx, "SELECT kid FROM signing_keys WHERE retired_at IS NULL")
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var kid string
+		if err := rows.Scan(&kid); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		info.RetiredKeys = append(info.RetiredKeys, kid)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE signing_keys SET retired_at = :retired_at WHERE retired_at IS NULL",
+		sql.Named("retired_at", key.CreatedAt)); err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO signing_keys (kid, algorithm, private_key, created_at) VALUES (:kid, :algorithm, :private_key, :created_at)`,
+		sql.Named("kid", key.ID),
+		sql.Named("algorithm", SigningAlgorithmES256),
+		sql.Named("private_key", pemData),
+		sql.Named("created_at", key.CreatedAt)); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		log.Error().Err(err).Msg("Failed to commit signing key rotation")
+		return nil, err
+	}
+
+	if err := as.reloadKeyRing(); err != nil {
+		log.Error().Err(err).Str("key_id", key.ID).Msg("Failed to reload signing keys after rotation")
+	}
+
+	log.Info().Str("key_id", key.ID).Strs("retired_keys", info.RetiredKeys).Msg("Signing key rotated")
+	as.publishEvent(Event{Type: EventKeyChanged, KeyID: key.ID})
+	return info, nil
+}
+
+// privateKeyPEM encodes a private key as PKCS#8 PEM
+func privateKeyPEM(key *ecdsa.PrivateKey) (string, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return "", err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})), nil
+}
+
+// parsePrivateKeyPEM decodes a PKCS#8 PEM ECDSA private key
+func parsePrivateKeyPEM(data string) (*ecdsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("unexpected private key type %T", parsed)
+	}
+	return key, nil
+}
+
+// JWKS handler
+// Publishes the public keys resource servers use to verify tokens locally; empty in HS256 mode
+func (as *authServer) jwksHandler(c *gin.Context) {
+	set := JWKSet{Keys: []JWK{}}
+	if as.keyRing != nil {
+		set = as.keyRing.JWKS()
+	}
+
+	// Short cache so rotated keys reach resource servers quickly
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, set)
+}
@@ -0,0 +1,149 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func newTestSigningKey(t *testing.T, createdAt time.Time, retired bool) *signingKey {
+	t.Helper()
+
+	key, err := generateSigningKey()
+	if err != nil {
+		t.Fatalf("Failed to generate signing key: %v", err)
+	}
+	key.CreatedAt = createdAt
+	if retired {
+		retiredAt := createdAt.Add(time.Minute)
+		key.RetiredAt = &retiredAt
+	}
+	return key
+}
+
+func TestKeyRing_ActiveIsNewestUnretired(t *testing.T) {
+	now := time.Now()
+	older := newTestSigningKey(t, now.Add(-2*time.Hour), false)
+	newer := newTestSigningKey(t, now.Add(-time.Hour), false)
+	retired := newTestSigningKey(t, now, true)
+
+	ring := NewKeyRing()
+	ring.Replace([]*signingKey{older, retired, newer})
+
+	if active := ring.Active(); active == nil || active.ID != newer.ID {
+		t.Errorf("Expected newest unretired key %s to be active, got %+v", newer.ID, active)
+	}
+	if _, exists := ring.Lookup(retired.ID); !exists {
+		t.Error("Expected retired key to remain available for verification")
+	}
+	if keys := ring.JWKS().Keys; len(keys) != 3 || keys[0].Kid != older.ID {
+		t.Errorf("Expected 3 keys oldest first, got %+v", keys)
+	}
+}
+
+func TestSignToken_KeyRing(t *testing.T) {
+	key := newTestSigningKey(t, time.Now(), false)
+	server := &authServer{jwtSecret: []byte("test-secret"), keyRing: NewKeyRing()}
+	server.keyRing.Replace([]*signingKey{key})
+
+	claims := Claims{
+		ClientID: "client-1",
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+		},
+	}
+	signed, err := server.signToken(claims)
+	if err != nil {
+		t.Fatalf("signToken failed: %v", err)
+	}
+
+	token, err := jwt.ParseWithClaims(signed, &Claims{}, server.verificationKey)
+	if err != nil {
+		t.Fatalf("Expected ES256 token to verify: %v", err)
+	}
+	if token.Header["kid"] != key.ID {
+		t.Errorf("Expected kid %s, got %v", key.ID, token.Header["kid"])
+	}
+
+	// Tokens signed with a key that left the ring no longer verify
+	server.keyRing.Replace(nil)
+	if _, err := jwt.ParseWithClaims(signed, &Claims{}, server.verificationKey); err == nil {
+		t.Error("Expected token with unknown kid to be rejected")
+	}
+}
+
+func TestVerificationKey_HS256WithKeyRing(t *testing.T) {
+	claims := Claims{
+		ClientID: "client-1",
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+		},
+	}
+	signed, err := (&authServer{jwtSecret: []byte("test-secret")}).signToken(claims)
+	if err != nil {
+		t.Fatalf("signToken failed: %v", err)
+	}
+
+	server := &authServer{jwtSecret: []byte("test-secret"), keyRing: NewKeyRing()}
+	if _, err := jwt.ParseWithClaims(signed, &Claims{}, server.verificationKey); err == nil {
+		t.Error("Expected HS256 token to be rejected once a key ring is configured")
+	}
+
+	// Accepted during the migration window only
+	server.hs256Until = time.Now().Add(time.Minute)
+	if _, err := jwt.ParseWithClaims(signed, &Claims{}, server.verificationKey); err != nil {
+		t.Errorf("Expected HS256 token to verify during the migration window: %v", err)
+	}
+	server.hs256Until = time.Now().Add(-time.Second)
+	if _, err := jwt.ParseWithClaims(signed, &Claims{}, server.verificationKey); err == nil {
+		t.Error("Expected HS256 token to be rejected after the migration window")
+	}
+}
+
+func TestPrivateKeyPEM_RoundTrip(t *testing.T) {
+	key := newTestSigningKey(t, time.Now(), false)
+
+	data, err := privateKeyPEM(key.private)
+	if err != nil {
+		t.Fatalf("privateKeyPEM failed: %v", err)
+	}
+	parsed, err := parsePrivateKeyPEM(data)
+	if err != nil {
+		t.Fatalf("parsePrivateKeyPEM failed: %v", err)
+	}
+	if !parsed.Equal(key.private) {
+		t.Error("Expected parsed key to equal the original")
+	}
+}
+
+func TestJWKSHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	server := &authServer{jwtSecret: []byte("test-secret")}
+	router := gin.New()
+	router.GET("/jwks", server.jwksHandler)
+
+	// HS256 mode publishes an empty set
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", "/jwks", nil))
+	var set JWKSet
+	json.NewDecoder(recorder.Body).Decode(&set)
+	if recorder.Code != http.StatusOK || set.Keys == nil || len(set.Keys) != 0 {
+		t.Errorf("Expected empty key set, got %d %+v", recorder.Code, set)
+	}
+
+	server.keyRing = NewKeyRing()
+	server.keyRing.Replace([]*signingKey{newTestSigningKey(t, time.Now(), false)})
+
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", "/jwks", nil))
+	json.NewDecoder(recorder.Body).Decode(&set)
+	if len(set.Keys) != 1 || set.Keys[0].Kty != "EC" || set.Keys[0].Alg != SigningAlgorithmES256 {
+		t.Errorf("Expected one EC key, got %+v", set.Keys)
+	}
+}
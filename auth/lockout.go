@@ -0,0 +1,297 @@
+package auth
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Lockout subjects are "client:<client-id>" or "ip:<address>"
+const (
+	lockoutClientPrefix = "client:"
+	lockoutIPPrefix     = "ip:"
+)
+
+// ClientLockoutSubject names a client ID in lockout events and the admin API
+func ClientLockoutSubject(clientID string) string {
+	return lockoutClientPrefix + clientID
+}
+
+// IPLockoutSubject names a source IP in lockout events and the admin API
+func IPLockoutSubject(ip string) string {
+	return lockoutIPPrefix + ip
+}
+
+// LockoutInfo is a subject with recent failed secret checks, locked out or not
+type LockoutInfo struct {
+	Subject     string     `json:"subject"`
+	Failures    int        `json:"failures"`
+	LastFailure time.Time  `json:"last_failure,omitempty"`
+	LockedUntil *time.Time `json:"locked_until,omitempty"`
+}
+
+// lockoutPolicy is when failed secret checks slow down and lock out a subject
+type lockoutPolicy struct {
+	ClientThreshold int
+	IPThreshold     int
+	Window          time.Duration // Failures older than this are forgotten
+	Duration        time.Duration
+	BaseDelay       time.Duration
+	MaxDelay        time.Duration
+}
+
+// currentLockoutPolicy reads the lockout policy from the configuration
+func currentLockoutPolicy() lockoutPolicy {
+	cfg := AppConfig.Lockout
+	return lockoutPolicy{
+		ClientThreshold: cfg.ClientThreshold,
+		IPThreshold:     cfg.IPThreshold,
+		Window:          time.Duration(cfg.Window) * time.Minute,
+		Duration:        time.Duration(cfg.Duration) * time.Minute,
+		BaseDelay:       time.Duration(cfg.BaseDelay) * time.Millisecond,
+		MaxDelay:        time.Duration(cfg.MaxDelay) * time.Millisecond,
+	}
+}
+
+// threshold is how many failures lock the subject out; zero never locks
+func (p lockoutPolicy) threshold(subject string) int {
+	if strings.HasPrefix(subject, lockoutIPPrefix) {
+		return p.IPThreshold
+	}
+	return p.ClientThreshold
+}
+
+// delay doubles with every failure, from BaseDelay up to MaxDelay
+func (p lockoutPolicy) delay(failures int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < failures && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, p.MaxDelay)
+}
+
+// failureRecord counts a subject's recent failures
+type failureRecord struct {
+	failures    int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+// LockoutTracker counts failed client secret checks per client ID and source IP
+// Counts are kept per instance; lockouts and unlocks are shared through lockout events
+type LockoutTracker struct {
+	mu        sync.Mutex
+	records   map[string]*failureRecord
+	policy    lockoutPolicy
+	sweepTick *time.Ticker
+	done      chan struct{}
+	stopOnce  sync.Once
+}
+
+// NewLockoutTracker creates a tracker and starts its background sweep of forgotten failures
+func NewLockoutTracker(policy lockoutPolicy) *LockoutTracker {
+	lt := &LockoutTracker{
+		records:   make(map[string]*failureRecord),
+		policy:    policy,
+		sweepTick: time.NewTicker(time.Minute),
+		done:      make(chan struct{}),
+	}
+
+	go lt.backgroundSweep()
+
+	log.Info().
+		Int("client_threshold", policy.ClientThreshold).
+		Int("ip_threshold", policy.IPThreshold).
+		Str("duration", policy.Duration.String()).
+		Msg("Lockout tracker initialized")
+	return lt
+}
+
+// Locked returns when the latest lockout among subjects ends, if any of them is locked out
+func (lt *LockoutTracker) Locked(now time.Time, subjects ...string) (time.Time, bool) {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+
+	var until time.Time
+	for _, subject := range subjects {
+		if record, exists := lt.records[subject]; exists && record.lockedUntil.After(now) && record.lockedUntil.After(until) {
+			until = record.lockedUntil
+		}
+	}
+	return until, !until.IsZero()
+}
+
+// Failure records a failed secret check against each subject
+// It returns how long to hold the response and the subjects the failure has just locked out, with the lockout end
+func (lt *LockoutTracker) Failure(now time.Time, subjects ...string) (time.Duration, []string, time.Time) {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+
+	var (
+		worst  int
+		locked []string
+		until  = now.Add(lt.policy.Duration)
+	)
+	for _, subject := range subjects {
+		record, exists := lt.records[subject]
+		if !exists || now.Sub(record.lastFailure) > lt.policy.Window {
+			record = &failureRecord{}
+			lt.records[subject] = record
+		}
+		record.failures++
+		record.lastFailure = now
+		worst = max(worst, record.failures)
+
+		if threshold := lt.policy.threshold(subject); threshold > 0 && record.failures >= threshold {
+			record.failures = 0
+			record.lockedUntil = until
+			locked = append(locked, subject)
+		}
+	}
+	return lt.policy.delay(worst), locked, until
+}
+
+// Success forgets a subject's failures after a successful secret check
+func (lt *LockoutTracker) Success(subject string) {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+
+	if record, exists := lt.records[subject]; exists && record.lockedUntil.IsZero() {
+		delete(lt.records, subject)
+	}
+}
+
+// Lock applies a lockout made by another instance
+func (lt *LockoutTracker) Lock(subject string, until time.Time) {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+
+	record, exists := lt.records[subject]
+	if !exists {
+		record = &failureRecord{lastFailure: time.Now()}
+		lt.records[subject] = record
+	}
+	if until.After(record.lockedUntil) {
+		record.lockedUntil = until
+	}
+}
+
+// Clear forgets a subject's failures and lifts its lockout
+func (lt *LockoutTracker) Clear(subject string) bool {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+
+	_, exists := lt.records[subject]
+	delete(lt.records, subject)
+	return exists
+}
+
+// Lockouts lists subjects with recent failures or an active lockout, locked out first
+func (lt *LockoutTracker) Lockouts(now time.Time) []LockoutInfo {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+
+	infos := []LockoutInfo{}
+	for subject, record := range lt.records {
+		if !record.lockedUntil.After(now) && now.Sub(record.lastFailure) > lt.policy.Window {
+			continue
+		}
+		info := LockoutInfo{Subject: subject, Failures: record.failures, LastFailure: record.lastFailure}
+		if record.lockedUntil.After(now) {
+			lockedUntil := record.lockedUntil
+			info.LockedUntil = &lockedUntil
+		}
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		if (infos[i].LockedUntil != nil) != (infos[j].LockedUntil != nil) {
+			return infos[i].LockedUntil != nil
+		}
+		return infos[i].Subject < infos[j].Subject
+	})
+	return infos
+}
+
+// sweep drops records whose failures have been forgotten and whose lockout has ended
+func (lt *LockoutTracker) sweep(now time.Time) int {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+
+	removed := 0
+	for subject, record := range lt.records {
+		if !record.lockedUntil.After(now) && now.Sub(record.lastFailure) > lt.policy.Window {
+			delete(lt.records, subject)
+			removed++
+		}
+	}
+	return removed
+}
+
+// backgroundSweep removes stale records every minute until stopped
+func (lt *LockoutTracker) backgroundSweep() {
+	for {
+		select {
+		case <-lt.done:
+			lt.sweepTick.Stop()
+			log.Debug().Msg("Lockout tracker sweep stopped")
+			return
+		case <-lt.sweepTick.C:
+			if removed := lt.sweep(time.Now()); removed > 0 {
+				log.Debug().Int("removed", removed).Msg("Stale lockout records removed")
+			}
+		}
+	}
+}
+
+// Stop stops the background sweep
+func (lt *LockoutTracker) Stop() {
+	lt.stopOnce.Do(func() {
+		close(lt.done)
+	})
+	log.Info().Msg("Lockout tracker stopped")
+}
+
+// lockoutSubjects lists what a /token attempt is tracked under
+// Client IDs that could never be registered are tracked by source IP alone, so junk IDs don't pile up
+func lockoutSubjects(clientID, ip string) []string {
+	subjects := []string{IPLockoutSubject(ip)}
+	if clientIDPattern.MatchString(clientID) {
+		subjects = append(subjects, ClientLockoutSubject(clientID))
+	}
+	return subjects
+}
+
+// recordSecretFailure counts a failed secret check and announces any lockout it causes to every instance
+// It returns how long the caller should hold the error response
+func (as *authServer) recordSecretFailure(clientID, ip string) time.Duration {
+	if as.lockouts == nil {
+		return 0
+	}
+
+	delay, locked, until := as.lockouts.Failure(time.Now(), lockoutSubjects(clientID, ip)...)
+	for _, subject := range locked {
+		log.Warn().Str("subject", subject).Time("locked_until", until).Msg("Too many failed client secret checks, locking out")
+		as.publishEvent(Event{Type: EventLockout, Subject: subject, ExpiresAt: until})
+		as.enqueueLockoutWebhook(subject, until)
+		as.auditLog.Record(AuditEntry{Action: AuditLockout, Actor: clientID, ClientID: clientID, IP: ip,
+			Detail: subject + " locked until " + until.UTC().Format(time.RFC3339)})
+	}
+	return delay
+}
+
+// holdResponse waits before an error response, returning early if the client goes away
+func holdResponse(ctx context.Context, delay time.Duration) {
+	if delay <= 0 {
+		return
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+}
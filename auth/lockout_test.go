@@ -0,0 +1,129 @@
+package auth
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestLockoutPolicy_Delay(t *testing.T) {
+	policy := lockoutPolicy{BaseDelay: 250 * time.Millisecond, MaxDelay: time.Second}
+	want := []time.Duration{250 * time.Millisecond, 500 * time.Millisecond, time.Second, time.Second}
+	for i, expected := range want {
+		if got := policy.delay(i + 1); got != expected {
+			t.Errorf("delay(%d) = %v, want %v", i+1, got, expected)
+		}
+	}
+}
+
+func TestLockoutTracker_Threshold(t *testing.T) {
+	lt := NewLockoutTracker(lockoutPolicy{ClientThreshold: 3, IPThreshold: 10, Window: time.Minute, Duration: time.Hour})
+	defer lt.Stop()
+
+	now := time.Now()
+	client, ip := ClientLockoutSubject("billing"), IPLockoutSubject("10.0.0.1")
+	for i := 0; i < 2; i++ {
+		if _, locked, _ := lt.Failure(now, client, ip); len(locked) != 0 {
+			t.Fatalf("Failure %d: expected no lockout yet, got %v", i+1, locked)
+		}
+	}
+	if _, locked, until := lt.Failure(now, client, ip); len(locked) != 1 || locked[0] != client || !until.Equal(now.Add(time.Hour)) {
+		t.Fatalf("Expected only the client to be locked out for an hour, got %v until %v", locked, until)
+	}
+
+	if _, locked := lt.Locked(now, ip); locked {
+		t.Error("Expected the IP to stay below its threshold")
+	}
+	if until, locked := lt.Locked(now, ip, client); !locked || !until.Equal(now.Add(time.Hour)) {
+		t.Errorf("Expected the client to be locked out, got %v %v", until, locked)
+	}
+	if _, locked := lt.Locked(now.Add(2*time.Hour), client); locked {
+		t.Error("Expected the lockout to end")
+	}
+
+	lt.Clear(client)
+	if _, locked := lt.Locked(now, client); locked {
+		t.Error("Expected Clear to lift the lockout")
+	}
+}
+
+func TestLockoutTracker_ForgetsFailures(t *testing.T) {
+	lt := NewLockoutTracker(lockoutPolicy{ClientThreshold: 2, Window: time.Minute, Duration: time.Hour})
+	defer lt.Stop()
+
+	now := time.Now()
+	subject := ClientLockoutSubject("billing")
+
+	// Failures outside the window start over
+	lt.Failure(now, subject)
+	if _, locked, _ := lt.Failure(now.Add(2*time.Minute), subject); len(locked) != 0 {
+		t.Error("Expected an old failure to be forgotten")
+	}
+
+	// A successful check starts over too
+	lt.Success(subject)
+	if _, locked, _ := lt.Failure(now.Add(2*time.Minute), subject); len(locked) != 0 {
+		t.Error("Expected success to reset the failure count")
+	}
+
+	if removed := lt.sweep(now.Add(time.Hour)); removed != 1 || len(lt.Lockouts(now.Add(time.Hour))) != 0 {
+		t.Errorf("Expected the stale record to be swept, removed %d", removed)
+	}
+}
+
+func TestTokenHandler_LockoutAndUnlock(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cheapSecretPolicy(t, SecretAlgorithmArgon2id)
+
+	ctx, cancel := createTestContextFunc()
+	defer cancel()
+
+	cache := NewClientCache(time.Minute, 10)
+	defer cache.Stop()
+	cache.Set("billing", &Clients{ClientID: "billing", Active: true, Secrets: []*ClientSecret{{ID: 1, Hash: "s3cret", Primary: true}}})
+
+	server := &authServer{jwtSecret: []byte("test-secret"), ctx: ctx, cancel: cancel, clientCache: cache,
+		events:   NewEventBus("test", nil),
+		lockouts: NewLockoutTracker(lockoutPolicy{ClientThreshold: 2, IPThreshold: 100, Window: time.Minute, Duration: time.Minute})}
+	defer server.lockouts.Stop()
+	server.store = &Store{as: server}
+	server.registerEventHandlers()
+
+	router := gin.New()
+	router.POST("/token", server.tokenHandler)
+	router.POST("/admin/clients/:client_id/unlock", server.unlockClientHandler)
+
+	send := func(secret string) *httptest.ResponseRecorder {
+		body := `{"client_id": "billing", "client_secret": "` + secret + `", "grant_type": "client_credentials"}`
+		req, _ := http.NewRequest("POST", "/token", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	for i := 0; i < 2; i++ {
+		if recorder := send("wrong"); recorder.Code != http.StatusUnauthorized {
+			t.Fatalf("Attempt %d: expected 401, got %d", i+1, recorder.Code)
+		}
+	}
+
+	// Even the right secret is refused while locked out
+	recorder := send("s3cret")
+	if recorder.Code != http.StatusTooManyRequests || recorder.Header().Get("Retry-After") != "60" {
+		t.Fatalf("Expected 429 with Retry-After while locked out, got %d %v", recorder.Code, recorder.Header())
+	}
+
+	unlock := httptest.NewRecorder()
+	router.ServeHTTP(unlock, httptest.NewRequest("POST", "/admin/clients/billing/unlock", nil))
+	if unlock.Code != http.StatusNoContent {
+		t.Fatalf("Expected 204 from unlock, got %d", unlock.Code)
+	}
+	if recorder := send("wrong"); recorder.Code != http.StatusUnauthorized {
+		t.Errorf("Expected the unlocked client to be checked again, got %d", recorder.Code)
+	}
+}
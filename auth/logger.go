@@ -43,9 +43,11 @@ func GetLogger() zerolog.Logger {
 			logWriter = rotatingLog
 		}
 
+		// The level is global so the admin API can change it at runtime, see SetLogLevel
+		zerolog.SetGlobalLevel(zerolog.Level(AppConfig.Logging.Level))
+
 		// Create logger with context
 		logger := zerolog.New(logWriter).
-			Level(zerolog.Level(AppConfig.Logging.Level)).
 			With().
 			Timestamp().
 			Str("service", "auth_server").
@@ -63,6 +65,13 @@ func GetLogger() zerolog.Logger {
 	return log.Logger
 }
 
+// SetLogLevel changes the level of every logger at runtime, returning the previous level
+func SetLogLevel(level zerolog.Level) zerolog.Level {
+	previous := zerolog.GlobalLevel()
+	zerolog.SetGlobalLevel(level)
+	return previous
+}
+
 // LoggingMiddleware creates a Gin middleware that logs all HTTP requests
 func LoggingMiddleware() gin.HandlerFunc {
 	hostname, _ := os.Hostname()
@@ -72,13 +81,13 @@ func LoggingMiddleware() gin.HandlerFunc {
 		start := time.Now()
 		requestID := uuid.New().String()
 
-		// Create request-specific logger
-		logger := log.With().
+		// Create request-specific logger, correlated with the request's trace if it has one
+		logger := withTraceIDs(log.With().
 			Str("request_id", requestID).
-			Str("client_ip", c.ClientIP()).
+			Str("client_ip", clientIP(c)).
 			Str("host", hostname).
 			Int("pid", processID).
-			Str("user_agent", c.Request.UserAgent()).
+			Str("user_agent", c.Request.UserAgent()), c.Request.Context()).
 			Logger()
 
 		c.Set("logger", logger)
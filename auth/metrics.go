@@ -0,0 +1,257 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// metricsNamespace prefixes every metric the server defines
+const metricsNamespace = "auth"
+
+// Token request outcomes
+const (
+	tokenOutcomeIssued             = "issued"
+	tokenOutcomeInvalidRequest     = "invalid_request"
+	tokenOutcomeLockedOut          = "locked_out"
+	tokenOutcomeInvalidCredentials = "invalid_credentials"
+	tokenOutcomeClientUnavailable  = "client_unavailable"
+	tokenOutcomeNetworkDenied      = "network_denied"
+	tokenOutcomeRateLimited        = "rate_limited"
+	tokenOutcomeUnsupportedGrant   = "unsupported_grant"
+	tokenOutcomeError              = "error"
+)
+
+// Token validation outcomes
+const (
+	validationOutcomeGranted        = "granted"
+	validationOutcomeInvalidRequest = "invalid_request"
+	validationOutcomeMissingToken   = "missing_token"
+	validationOutcomeInvalidToken   = "invalid_token"
+	validationOutcomeRateLimited    = "rate_limited"
+	validationOutcomeNetworkDenied  = "network_denied"
+	validationOutcomeForbidden      = "forbidden"
+	validationOutcomeError          = "error"
+)
+
+// Endpoints that validate tokens, as the endpoint label of auth_token_validations_total
+const (
+	validationEndpointValidate    = "validate"
+	validationEndpointForwardAuth = "forward_auth"
+	validationEndpointExtAuthz    = "ext_authz"
+)
+
+// Metrics holds the server's Prometheus collectors on a registry of its own
+// A nil *Metrics records nothing, so tests and tools can leave it out
+type Metrics struct {
+	registry *prometheus.Registry
+
+	httpRequests       *prometheus.CounterVec
+	httpDuration       *prometheus.HistogramVec
+	tokenRequests      *prometheus.CounterVec
+	tokenValidations   *prometheus.CounterVec
+	batchFlushDuration prometheus.Histogram
+	batchFlushFailures prometheus.Counter
+	batchFlushedTokens prometheus.Counter
+}
+
+// NewMetrics creates the server's collectors
+// Cache, batch queue and DB pool figures are read from as when scraped
+func NewMetrics(as *authServer) *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by route, method and status code.",
+		}, []string{"route", "method", "status"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by route, method and status code.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method", "status"}),
+		tokenRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "token_requests_total",
+			Help:      "Token requests by outcome.",
+		}, []string{"outcome"}),
+		tokenValidations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "token_validations_total",
+			Help:      "Token validations by endpoint and outcome.",
+		}, []string{"endpoint", "outcome"}),
+		batchFlushDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "token_batch_flush_duration_seconds",
+			Help:      "Time taken to write a batch of issued tokens.",
+			Buckets:   prometheus.DefBuckets,
+		}),
+		batchFlushFailures: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "token_batch_flush_failures_total",
+			Help:      "Token batches that failed to write.",
+		}),
+		batchFlushedTokens: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "token_batch_flushed_tokens_total",
+			Help:      "Issued tokens written to the token table.",
+		}),
+	}
+
+	m.registry.MustRegister(
+		m.httpRequests,
+		m.httpDuration,
+		m.tokenRequests,
+		m.tokenValidations,
+		m.batchFlushDuration,
+		m.batchFlushFailures,
+		m.batchFlushedTokens,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	if cache := as.clientCache; cache != nil {
+		m.registry.MustRegister(
+			prometheus.NewCounterFunc(prometheus.CounterOpts{
+				Namespace: metricsNamespace,
+				Name:      "client_cache_hits_total",
+				Help:      "Client lookups served from the cache.",
+			}, func() float64 { return float64(cache.GetStats().Hits) }),
+			prometheus.NewCounterFunc(prometheus.CounterOpts{
+				Namespace: metricsNamespace,
+				Name:      "client_cache_misses_total",
+				Help:      "Client lookups that missed the cache.",
+			}, func() float64 { return float64(cache.GetStats().Misses) }),
+			prometheus.NewCounterFunc(prometheus.CounterOpts{
+				Namespace: metricsNamespace,
+				Name:      "client_cache_evictions_total",
+				Help:      "Clients evicted because the cache was full.",
+			}, func() float64 { return float64(cache.GetStats().Evicted) }),
+			prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+				Namespace: metricsNamespace,
+				Name:      "client_cache_entries",
+				Help:      "Clients currently cached.",
+			}, func() float64 { return float64(cache.GetSize()) }),
+		)
+	}
+
+	// Stateless servers have no batch writer, so the queue is reported empty
+	m.registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "token_batch_pending",
+		Help:      "Issued tokens waiting to be written.",
+	}, func() float64 {
+		if as.tokenBatcher == nil {
+			return 0
+		}
+		return float64(as.tokenBatcher.GetPendingCount())
+	}))
+
+	if as.db != nil {
+		m.registry.MustRegister(collectors.NewDBStatsCollector(as.db, "oracle"))
+	}
+	return m
+}
+
+// Middleware counts and times every request by its route pattern
+func (m *Metrics) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		// Unmatched paths share one label so scans can't blow up the series count
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		status := strconv.Itoa(c.Writer.Status())
+		m.httpRequests.WithLabelValues(route, c.Request.Method, status).Inc()
+		m.httpDuration.WithLabelValues(route, c.Request.Method, status).Observe(time.Since(start).Seconds())
+	}
+}
+
+// Handler serves the registry in the Prometheus exposition format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+// tokenRequest counts a /token request by outcome
+func (m *Metrics) tokenRequest(outcome string) {
+	if m == nil {
+		return
+	}
+	m.tokenRequests.WithLabelValues(outcome).Inc()
+}
+
+// tokenValidation counts a token validation by endpoint and outcome
+func (m *Metrics) tokenValidation(endpoint, outcome string) {
+	if m == nil {
+		return
+	}
+	m.tokenValidations.WithLabelValues(endpoint, outcome).Inc()
+}
+
+// batchFlushed records a token batch write
+func (m *Metrics) batchFlushed(size int, duration time.Duration, err error) {
+	if m == nil {
+		return
+	}
+	m.batchFlushDuration.Observe(duration.Seconds())
+	if err != nil {
+		m.batchFlushFailures.Inc()
+		return
+	}
+	m.batchFlushedTokens.Add(float64(size))
+}
+
+// validationOutcome classifies an authorization result for auth_token_validations_total
+func validationOutcome(apiErr *APIError) string {
+	if apiErr == nil {
+		return validationOutcomeGranted
+	}
+	switch apiErr.StatusCode {
+	case http.StatusUnauthorized:
+		return validationOutcomeInvalidToken
+	case http.StatusForbidden:
+		return validationOutcomeForbidden
+	case http.StatusBadRequest:
+		return validationOutcomeInvalidRequest
+	case http.StatusTooManyRequests:
+		return validationOutcomeRateLimited
+	}
+	return validationOutcomeError
+}
+
+// startMetrics serves /metrics on the configured metric port
+func (s *authServer) startMetrics() {
+	logger := GetLogger()
+	addr := fmt.Sprintf(":%d", AppConfig.MetricPort)
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", s.metrics.Handler())
+	s.metricsSrv = &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		logger.Info().
+			Str("address", addr).
+			Msg("Starting metrics server")
+
+		if err := s.metricsSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.Error().
+				Err(err).
+				Str("address", addr).
+				Msg("Metrics server error")
+		}
+	}()
+}
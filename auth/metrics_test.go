@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMetrics_TokenRequests(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	ctx, cancel := createTestContextFunc()
+	defer cancel()
+
+	cache := NewClientCache(time.Minute, 10)
+	defer cache.Stop()
+
+	server := &authServer{jwtSecret: []byte("test-secret"), ctx: ctx, cancel: cancel, clientCache: cache}
+	server.metrics = NewMetrics(server)
+
+	router := gin.New()
+	router.Use(server.metrics.Middleware())
+	router.POST("/token", server.tokenHandler)
+
+	req, _ := http.NewRequest("POST", "/token", bytes.NewBufferString("{not json"))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	recorder := httptest.NewRecorder()
+	server.metrics.Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	body := recorder.Body.String()
+
+	for _, want := range []string{
+		`auth_token_requests_total{outcome="invalid_request"} 1`,
+		`auth_http_requests_total{method="POST",route="/token",status="400"} 1`,
+		`auth_http_request_duration_seconds_count{method="POST",route="/token",status="400"} 1`,
+		`auth_client_cache_entries 0`,
+		`auth_token_batch_pending 0`,
+		`go_goroutines`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected metrics to contain %q", want)
+		}
+	}
+}
+
+func TestMetrics_NilRecordsNothing(t *testing.T) {
+	var metrics *Metrics
+	metrics.tokenRequest(tokenOutcomeIssued)
+	metrics.tokenValidation(validationEndpointValidate, validationOutcomeGranted)
+	metrics.batchFlushed(10, time.Second, nil)
+}
+
+func TestValidationOutcome(t *testing.T) {
+	tests := []struct {
+		apiErr *APIError
+		want   string
+	}{
+		{nil, validationOutcomeGranted},
+		{ErrUnauthorizedError("Invalid or expired token"), validationOutcomeInvalidToken},
+		{ErrForbiddenError("Resource not in token scopes"), validationOutcomeForbidden},
+		{ErrBadRequest("Missing X-Forwarded-Method header"), validationOutcomeInvalidRequest},
+		{ErrInternalServerError("Client scope configuration is invalid"), validationOutcomeError},
+	}
+	for _, test := range tests {
+		if got := validationOutcome(test.apiErr); got != test.want {
+			t.Errorf("validationOutcome(%v) = %q, want %q", test.apiErr, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,441 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Migration is one versioned schema change
+// Oracle commits DDL implicitly, so statements run one at a time and a failed migration is resumed by hand
+type Migration struct {
+	Version int
+	Name    string
+	// Table is the table the migration creates, if any; init-db.sql created some of these tables before
+	// migrations existed, so a database adopted at the baseline that already has it records the migration instead
+	Table      string
+	Statements []string
+}
+
+// MigrationState reports whether a migration has been applied
+type MigrationState struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+// baselineVersion is the schema init-db.sql created before migrations were introduced
+const baselineVersion = 1
+
+// Migrations lists every schema change in version order; append new ones, never edit applied ones
+var Migrations = []Migration{
+	{
+		Version: baselineVersion,
+		Name:    "baseline",
+		Statements: []string{
+			`CREATE TABLE clients (
+				client_id VARCHAR2(100) PRIMARY KEY,
+				client_secret VARCHAR2(255) NOT NULL,
+				client_name VARCHAR2(255),
+				access_token_ttl NUMBER(10) DEFAULT 3600,
+				allowed_scopes CLOB,
+				created_at TIMESTAMP DEFAULT SYSTIMESTAMP,
+				updated_at TIMESTAMP DEFAULT SYSTIMESTAMP,
+				active NUMBER(1) DEFAULT 1
+			)`,
+			`CREATE TABLE tokens (
+				token_id VARCHAR2(255) PRIMARY KEY,
+				client_id VARCHAR2(100) NOT NULL,
+				issued_at TIMESTAMP DEFAULT SYSTIMESTAMP,
+				expires_at TIMESTAMP NOT NULL,
+				revoked NUMBER(1) DEFAULT 0,
+				revoked_at TIMESTAMP,
+				created_at TIMESTAMP DEFAULT SYSTIMESTAMP,
+				CONSTRAINT fk_tokens_client FOREIGN KEY (client_id) REFERENCES clients(client_id)
+			)`,
+			`CREATE TABLE revoked_tokens (
+				id NUMBER GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+				token_id VARCHAR2(255) NOT NULL,
+				client_id VARCHAR2(100) NOT NULL,
+				revoked_at TIMESTAMP DEFAULT SYSTIMESTAMP,
+				CONSTRAINT fk_revoked_tokens_client FOREIGN KEY (client_id) REFERENCES clients(client_id)
+			)`,
+			`CREATE TABLE endpoints (
+				id NUMBER GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+				client_id VARCHAR2(100) NOT NULL,
+				scope VARCHAR2(255) NOT NULL,
+				method VARCHAR2(10) NOT NULL,
+				endpoint_url VARCHAR2(500) NOT NULL,
+				description VARCHAR2(500),
+				active NUMBER(1) DEFAULT 1,
+				created_at TIMESTAMP DEFAULT SYSTIMESTAMP,
+				CONSTRAINT fk_endpoints_client FOREIGN KEY (client_id) REFERENCES clients(client_id)
+			)`,
+			`CREATE INDEX idx_tokens_client_id ON tokens(client_id)`,
+			`CREATE INDEX idx_tokens_expires_at ON tokens(expires_at)`,
+			`CREATE INDEX idx_tokens_revoked ON tokens(revoked)`,
+			`CREATE INDEX idx_revoked_tokens_token_id ON revoked_tokens(token_id)`,
+			`CREATE INDEX idx_revoked_tokens_client_id ON revoked_tokens(client_id)`,
+			`CREATE INDEX idx_endpoints_client_id ON endpoints(client_id)`,
+		},
+	},
+	{
+		Version: 2,
+		Name:    "token denylist",
+		Table:   "token_denylist",
+		Statements: []string{
+			`CREATE TABLE token_denylist (
+				token_id VARCHAR2(255) PRIMARY KEY,
+				client_id VARCHAR2(100) NOT NULL,
+				expires_at TIMESTAMP NOT NULL,
+				revoked_at TIMESTAMP DEFAULT SYSTIMESTAMP
+			)`,
+			`CREATE INDEX idx_token_denylist_expires_at ON token_denylist(expires_at)`,
+		},
+	},
+	{
+		Version: 3,
+		Name:    "change feed",
+		Table:   "auth_events",
+		Statements: []string{
+			`CREATE TABLE auth_events (
+				id NUMBER GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+				event_type VARCHAR2(50) NOT NULL,
+				origin VARCHAR2(255) NOT NULL,
+				client_id VARCHAR2(100),
+				token_id VARCHAR2(255),
+				key_id VARCHAR2(100),
+				expires_at TIMESTAMP,
+				revoked_before TIMESTAMP,
+				created_at TIMESTAMP DEFAULT SYSTIMESTAMP
+			)`,
+			`CREATE INDEX idx_auth_events_created_at ON auth_events(created_at)`,
+		},
+	},
+	{
+		Version: 4,
+		Name:    "client revocations",
+		Table:   "client_revocations",
+		Statements: []string{
+			`CREATE TABLE client_revocations (
+				client_id VARCHAR2(100) PRIMARY KEY,
+				revoked_before TIMESTAMP NOT NULL,
+				CONSTRAINT fk_client_revocations_client FOREIGN KEY (client_id) REFERENCES clients(client_id)
+			)`,
+		},
+	},
+	{
+		Version: 5,
+		Name:    "signing keys",
+		Table:   "signing_keys",
+		Statements: []string{
+			`CREATE TABLE signing_keys (
+				kid VARCHAR2(64) PRIMARY KEY,
+				algorithm VARCHAR2(16) NOT NULL,
+				private_key CLOB NOT NULL,
+				created_at TIMESTAMP DEFAULT SYSTIMESTAMP,
+				retired_at TIMESTAMP
+			)`,
+		},
+	},
+	{
+		Version: 6,
+		Name:    "client soft delete and audit",
+		Statements: []string{
+			`ALTER TABLE clients ADD (deleted_at TIMESTAMP)`,
+			`CREATE TABLE client_audit (
+				id NUMBER GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+				client_id VARCHAR2(100) NOT NULL,
+				action VARCHAR2(50) NOT NULL,
+				actor VARCHAR2(255) NOT NULL,
+				request_id VARCHAR2(100),
+				remote_addr VARCHAR2(100),
+				changes CLOB,
+				created_at TIMESTAMP DEFAULT SYSTIMESTAMP
+			)`,
+			`CREATE INDEX idx_client_audit_client_id ON client_audit(client_id)`,
+		},
+	},
+	{
+		Version: 7,
+		Name:    "client secret rotation",
+		Statements: []string{
+			`CREATE TABLE client_secrets (
+				id NUMBER GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+				client_id VARCHAR2(100) NOT NULL,
+				secret_hash VARCHAR2(255) NOT NULL,
+				label VARCHAR2(100),
+				is_primary NUMBER(1) DEFAULT 0 NOT NULL,
+				created_at TIMESTAMP DEFAULT SYSTIMESTAMP,
+				expires_at TIMESTAMP,
+				last_used_at TIMESTAMP,
+				CONSTRAINT fk_client_secrets_client FOREIGN KEY (client_id) REFERENCES clients(client_id)
+			)`,
+			`INSERT INTO client_secrets (client_id, secret_hash, label, is_primary, created_at)
+				SELECT client_id, client_secret, 'initial', 1, NVL(created_at, SYSTIMESTAMP) FROM clients`,
+			`ALTER TABLE clients DROP COLUMN client_secret`,
+			`CREATE INDEX idx_client_secrets_client_id ON client_secrets(client_id)`,
+			`CREATE INDEX idx_client_secrets_expires_at ON client_secrets(expires_at)`,
+		},
+	},
+	{
+		Version: 8,
+		Name:    "client validity windows",
+		Statements: []string{
+			`ALTER TABLE clients ADD (valid_from TIMESTAMP, valid_until TIMESTAMP)`,
+		},
+	},
+	{
+		Version: 9,
+		Name:    "rate limits",
+		Statements: []string{
+			`ALTER TABLE clients ADD (token_rate NUMBER, token_burst NUMBER(10), validate_rate NUMBER, validate_burst NUMBER(10))`,
+			`CREATE TABLE rate_limit_hits (
+				id NUMBER GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+				limit_key VARCHAR2(200) NOT NULL,
+				instance_id VARCHAR2(255) NOT NULL,
+				hits NUMBER(10) NOT NULL,
+				created_at TIMESTAMP DEFAULT SYSTIMESTAMP
+			)`,
+			`CREATE INDEX idx_rate_limit_hits_created_at ON rate_limit_hits(created_at)`,
+		},
+	},
+	{
+		Version: 10,
+		Name:    "lockout events",
+		Statements: []string{
+			`ALTER TABLE auth_events ADD (subject VARCHAR2(255))`,
+		},
+	},
+	{
+		Version: 11,
+		Name:    "client networks",
+		Statements: []string{
+			`ALTER TABLE clients ADD (allowed_cidrs VARCHAR2(2000), cidrs_on_use NUMBER(1) DEFAULT 0, network_claim NUMBER(1) DEFAULT 0)`,
+		},
+	},
+	{
+		Version: 12,
+		Name:    "audit log",
+		Statements: []string{
+			`CREATE TABLE audit_log (
+				seq NUMBER(19) PRIMARY KEY,
+				created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+				action VARCHAR2(50) NOT NULL,
+				outcome VARCHAR2(20) NOT NULL,
+				actor VARCHAR2(255) NOT NULL,
+				client_id VARCHAR2(100),
+				token_id VARCHAR2(255),
+				remote_addr VARCHAR2(100),
+				request_id VARCHAR2(100),
+				detail VARCHAR2(4000),
+				prev_hash VARCHAR2(64) NOT NULL,
+				hash VARCHAR2(64) NOT NULL
+			)`,
+			`CREATE INDEX idx_audit_log_created_at ON audit_log(created_at)`,
+			`CREATE INDEX idx_audit_log_client_id ON audit_log(client_id, created_at)`,
+			`CREATE TABLE audit_head (
+				id NUMBER(1) PRIMARY KEY,
+				seq NUMBER(19) NOT NULL,
+				hash VARCHAR2(64) NOT NULL
+			)`,
+			`INSERT INTO audit_head (id, seq, hash) VALUES (1, 0, '` + auditGenesisHash + `')`,
+		},
+	},
+	{
+		Version: 13,
+		Name:    "webhooks",
+		Statements: []string{
+			`CREATE TABLE webhook_subscriptions (
+				id NUMBER GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+				url VARCHAR2(2000) NOT NULL,
+				secret VARCHAR2(255) NOT NULL,
+				event_types VARCHAR2(2000),
+				client_id VARCHAR2(100),
+				created_at TIMESTAMP DEFAULT SYSTIMESTAMP
+			)`,
+			`CREATE TABLE webhook_outbox (
+				id NUMBER GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+				event_id VARCHAR2(36) NOT NULL,
+				event_type VARCHAR2(50) NOT NULL,
+				subject VARCHAR2(255),
+				payload CLOB NOT NULL,
+				created_at TIMESTAMP DEFAULT SYSTIMESTAMP,
+				dispatched_at TIMESTAMP
+			)`,
+			`CREATE INDEX idx_webhook_outbox_dispatched ON webhook_outbox(dispatched_at)`,
+			`CREATE TABLE webhook_deliveries (
+				id NUMBER GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+				outbox_id NUMBER NOT NULL,
+				subscription_id NUMBER NOT NULL,
+				status VARCHAR2(20) NOT NULL,
+				attempts NUMBER(5) DEFAULT 0,
+				next_attempt_at TIMESTAMP,
+				last_status NUMBER(3),
+				last_error VARCHAR2(1000),
+				created_at TIMESTAMP DEFAULT SYSTIMESTAMP,
+				delivered_at TIMESTAMP
+			)`,
+			`CREATE INDEX idx_webhook_deliveries_due ON webhook_deliveries(status, next_attempt_at)`,
+			`CREATE INDEX idx_webhook_deliveries_sub ON webhook_deliveries(subscription_id)`,
+			`CREATE INDEX idx_webhook_deliveries_outbox ON webhook_deliveries(outbox_id)`,
+		},
+	},
+}
+
+// MigrationStatus lists every known migration with its applied time, if any
+func (s *Store) MigrationStatus(ctx context.Context) ([]MigrationState, error) {
+	applied, err := s.appliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	states := make([]MigrationState, 0, len(Migrations))
+	for _, m := range Migrations {
+		state := MigrationState{Version: m.Version, Name: m.Name}
+		if appliedAt, ok := applied[m.Version]; ok {
+			state.AppliedAt = &appliedAt
+		}
+		states = append(states, state)
+	}
+	return states, nil
+}
+
+// Migrate applies every pending migration in order and returns the ones it applied
+// A database created by init-db.sql before migrations existed is recorded at the baseline without re-running it,
+// along with any later migration whose table that init-db.sql already created
+func (s *Store) Migrate(ctx context.Context) ([]MigrationState, error) {
+	if err := s.ensureMigrationTable(ctx); err != nil {
+		return nil, err
+	}
+
+	applied, err := s.appliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	adopted := false
+	if len(applied) == 0 {
+		exists, err := s.tableExists(ctx, "CLIENTS")
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			log.Info().Msg("Existing schema found, recording baseline migration")
+			if err := s.recordMigration(ctx, Migrations[0]); err != nil {
+				return nil, err
+			}
+			applied[baselineVersion] = time.Now()
+			adopted = true
+		}
+	}
+
+	var done []MigrationState
+	for _, m := range Migrations {
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+		if adopted && m.Table != "" {
+			exists, err := s.tableExists(ctx, strings.ToUpper(m.Table))
+			if err != nil {
+				return done, err
+			}
+			if exists {
+				log.Info().Int("version", m.Version).Str("table", m.Table).Msg("Table already exists, recording migration")
+				if err := s.recordMigration(ctx, m); err != nil {
+					return done, err
+				}
+				continue
+			}
+		}
+		for i, statement := range m.Statements {
+			stmtCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+			_, err := s.db.ExecContext(stmtCtx, statement)
+			cancel()
+			if err != nil {
+				return done, fmt.Errorf("migration %d (%s) statement %d: %w", m.Version, m.Name, i+1, err)
+			}
+		}
+		if err := s.recordMigration(ctx, m); err != nil {
+			return done, err
+		}
+
+		now := time.Now()
+		log.Info().Int("version", m.Version).Str("name", m.Name).Msg("Migration applied")
+		done = append(done, MigrationState{Version: m.Version, Name: m.Name, AppliedAt: &now})
+	}
+	return done, nil
+}
+
+// ensureMigrationTable creates schema_migrations on first use
+func (s *Store) ensureMigrationTable(ctx context.Context) error {
+	exists, err := s.tableExists(ctx, "SCHEMA_MIGRATIONS")
+	if err != nil || exists {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	_, err = s.db.ExecContext(ctx, `CREATE TABLE schema_migrations (
+		version NUMBER(10) PRIMARY KEY,
+		name VARCHAR2(255) NOT NULL,
+		applied_at TIMESTAMP DEFAULT SYSTIMESTAMP
+	)`)
+	return err
+}
+
+// appliedMigrations maps applied versions to when they were applied
+func (s *Store) appliedMigrations(ctx context.Context) (map[int]time.Time, error) {
+	applied := make(map[int]time.Time)
+	exists, err := s.tableExists(ctx, "SCHEMA_MIGRATIONS")
+	if err != nil || !exists {
+		return applied, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			version   int
+			appliedAt sql.NullTime
+		)
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt.Time
+	}
+	return applied, rows.Err()
+}
+
+// recordMigration marks a migration as applied
+func (s *Store) recordMigration(ctx context.Context, m Migration) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, "INSERT INTO schema_migrations (version, name) VALUES (:version, :name)",
+		sql.Named("version", m.Version),
+		sql.Named("name", m.Name))
+	return err
+}
+
+// tableExists reports whether the connected schema owns a table; name must be upper case
+func (s *Store) tableExists(ctx context.Context, name string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var count int
+	err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM user_tables WHERE table_name = :name",
+		sql.Named("name", name)).Scan(&count)
+	return count > 0, err
+}
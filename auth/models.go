@@ -3,10 +3,16 @@ package auth
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"net/http"
+	"net/netip"
+	"sync/atomic"
 	"time"
 
+	"auth-server/scope"
+
 	"github.com/golang-jwt/jwt/v5"
+	"google.golang.org/grpc"
 )
 
 // type Storage interface {
@@ -42,21 +48,72 @@ import (
 
 type authServer struct {
 	// storage   Storage
-	jwtSecret    []byte
-	ctx          context.Context
-	cancel       context.CancelFunc
-	httpSrv      *http.Server
-	db           *sql.DB
-	clientCache  *ClientCache      // In-memory client cache
-	tokenBatcher *TokenBatchWriter // Batch token writer for async writes
+	jwtSecret      []byte
+	ctx            context.Context
+	cancel         context.CancelFunc
+	httpSrv        *http.Server
+	metricsSrv     *http.Server // Serves /metrics on metric_port
+	adminSrv       *http.Server // Serves the admin API and pprof on admin.address
+	grpcSrv        *grpc.Server // Envoy ext_authz server, nil unless enabled
+	db             *sql.DB
+	clientCache    *ClientCache                // In-memory client cache
+	tokenBatcher   *TokenBatchWriter           // Batch token writer for async writes (stateful mode)
+	denylistPurger *DenylistPurger             // Expired denylist cleanup (stateless mode)
+	secretMonitor  *SecretExpiryMonitor        // Warns about client secrets nearing expiry
+	stateless      bool                        // Skip token persistence and check revocations against the denylist
+	events         *EventBus                   // Cross-instance change propagation
+	watermarks     *RevocationWatermarks       // Client-wide revocation cutoffs
+	keyRing        *KeyRing                    // ES256 signing keys; nil signs with jwtSecret (HS256)
+	hs256Until     time.Time                   // HS256 tokens verify until then despite the key ring (migration window)
+	store          *Store                      // Client administration for the admin API
+	rateLimiter    *RateLimiter                // Per-client and per-IP request limits; nil disables them
+	lockouts       *LockoutTracker             // Failed secret checks per client ID and IP; nil disables lockout
+	metrics        *Metrics                    // Prometheus collectors; nil records nothing
+	auditLog       *AuditLog                   // Hash-chained security audit entries; nil records nothing
+	webhooks       *WebhookDispatcher          // Sends outbox events to webhook subscriptions; nil when disabled
+	draining       atomic.Bool                 // Set when shutdown starts; readiness then fails
+	stopTracing    func(context.Context) error // Flushes and stops span export; nil when tracing is off
 }
 
 type Clients struct {
 	ClientID       string
-	ClientSecret   string
+	Secrets        []*ClientSecret // Unexpired secrets, primary first
 	Name           string
 	AccessTokenTTL int32
 	AllowedScopes  []string
+	Endpoints      []Endpoints // Active method-aware endpoint rules; empty means scopes alone decide access
+	Active         bool
+	ValidFrom      *time.Time // Tokens are refused before this time, if set
+	ValidUntil     *time.Time // Tokens are refused from this time on, if set
+	// Per-client overrides of the configured rate limits; nil uses the default
+	TokenRateLimit    *RateLimit
+	ValidateRateLimit *RateLimit
+	// Networks tokens may be requested from; empty allows any
+	AllowedCIDRs      []netip.Prefix
+	EnforceCIDRsOnUse bool // Also check AllowedCIDRs against the caller at /validate
+	NetworkClaim      bool // Bind tokens to the network they were issued to
+
+	matcher atomic.Pointer[scope.Matcher] // Compiled scope and endpoint patterns, built once per cached client
+}
+
+// Reasons a client may not obtain tokens
+var (
+	errClientDisabled    = errors.New("client is disabled")
+	errClientNotYetValid = errors.New("client is not yet valid")
+	errClientExpired     = errors.New("client validity has ended")
+)
+
+// checkAvailable reports why the client may not obtain tokens at now, or nil if it may
+func (c *Clients) checkAvailable(now time.Time) error {
+	switch {
+	case !c.Active:
+		return errClientDisabled
+	case c.ValidFrom != nil && now.Before(*c.ValidFrom):
+		return errClientNotYetValid
+	case c.ValidUntil != nil && !now.Before(*c.ValidUntil):
+		return errClientExpired
+	}
+	return nil
 }
 
 type Endpoints struct {
@@ -81,6 +138,7 @@ type RevokedToken struct {
 	ClientID  string    `json:"client_id"`
 	TokenID   string    `json:"token_id"`
 	RevokedAt time.Time `json:"revoked_at"`
+	ExpiresAt time.Time `json:"expires_at"`
 }
 
 // JWT Claims
@@ -88,6 +146,7 @@ type Claims struct {
 	ClientID string   `json:"client_id"`
 	TokenID  string   `json:"token_id"`
 	Scope    []string `json:"scope"`
+	Network  string   `json:"network,omitempty"` // CIDR the token was issued to and may only be used from
 	jwt.RegisteredClaims
 }
 
@@ -119,3 +178,28 @@ type TokenValidationResponse struct {
 	ExpiresAt time.Time `json:"expires_at"`
 	Scopes    []string  `json:"scopes"`
 }
+
+// Revocation notice kinds
+const (
+	RevocationKindToken  = "token"
+	RevocationKindClient = "client"
+)
+
+// RevocationNotice is a single entry of the revocation feed served to resource servers
+// Kind "token" revokes one JTI; kind "client" revokes every token the client was issued at or before RevokedBefore
+type RevocationNotice struct {
+	ID            int64      `json:"id"`
+	Kind          string     `json:"kind"`
+	ClientID      string     `json:"client_id"`
+	TokenID       string     `json:"token_id,omitempty"`
+	ExpiresAt     *time.Time `json:"expires_at,omitempty"`
+	RevokedBefore *time.Time `json:"revoked_before,omitempty"`
+	RevokedAt     time.Time  `json:"revoked_at"`
+}
+
+// RevocationPage is a page of the revocation feed for catch-up reads
+type RevocationPage struct {
+	Revocations []RevocationNotice `json:"revocations"`
+	NextSince   int64              `json:"next_since"`
+	HasMore     bool               `json:"has_more"`
+}
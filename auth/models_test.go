@@ -91,7 +91,7 @@ func TestClientStruct(t *testing.T) {
 	scopes := []string{"read", "write"}
 	client := Clients{
 		ClientID:       "test-client",
-		ClientSecret:   "test-secret",
+		Secrets:        []*ClientSecret{{ID: 1, Hash: "test-secret", Primary: true}},
 		Name:           "Test Client",
 		AccessTokenTTL: 3600,
 		AllowedScopes:  scopes,
@@ -101,8 +101,8 @@ func TestClientStruct(t *testing.T) {
 		t.Errorf("Expected ClientID='test-client', got %s", client.ClientID)
 	}
 
-	if client.ClientSecret != "test-secret" {
-		t.Errorf("Expected ClientSecret='test-secret', got %s", client.ClientSecret)
+	if len(client.Secrets) != 1 || client.Secrets[0].Hash != "test-secret" {
+		t.Errorf("Expected one secret 'test-secret', got %+v", client.Secrets)
 	}
 
 	if client.Name != "Test Client" {
@@ -171,3 +171,25 @@ func TestAuthServerStruct(t *testing.T) {
 		t.Errorf("Expected context to be set")
 	}
 }
+
+func TestClients_CheckAvailable(t *testing.T) {
+	now := time.Now()
+	past, future := now.Add(-time.Hour), now.Add(time.Hour)
+
+	tests := []struct {
+		name   string
+		client *Clients
+		want   error
+	}{
+		{"active", &Clients{Active: true}, nil},
+		{"disabled", &Clients{Active: false}, errClientDisabled},
+		{"inside window", &Clients{Active: true, ValidFrom: &past, ValidUntil: &future}, nil},
+		{"not yet valid", &Clients{Active: true, ValidFrom: &future}, errClientNotYetValid},
+		{"ended", &Clients{Active: true, ValidUntil: &past}, errClientExpired},
+	}
+	for _, tt := range tests {
+		if got := tt.client.checkAvailable(now); got != tt.want {
+			t.Errorf("%s: checkAvailable() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+// clientIPKey caches the resolved client address in the gin context
+const clientIPKey = "client_ip"
+
+// errNetworkNotAllowed is why a client may not obtain tokens from an address
+var errNetworkNotAllowed = errors.New("address is outside the client's allowed networks")
+
+// clientIPs resolves request client addresses; nil believes no forwarded headers
+// It is built from the network configuration by applyDefaults
+var clientIPs *clientIPResolver
+
+// clientIPResolver finds a request's client address, believing forwarded headers only from trusted proxies
+type clientIPResolver struct {
+	trusted []netip.Prefix
+	headers []string
+}
+
+// newClientIPResolver creates a resolver for the network configuration
+func newClientIPResolver(cfg network) (*clientIPResolver, error) {
+	trusted, err := parseCIDRs(cfg.TrustedProxies)
+	if err != nil {
+		return nil, err
+	}
+	return &clientIPResolver{trusted: trusted, headers: cfg.ClientIPHeaders}, nil
+}
+
+// resolve returns the client address of req, or the peer address if no trusted proxy vouches for one
+func (r *clientIPResolver) resolve(req *http.Request) netip.Addr {
+	peer := parseAddr(req.RemoteAddr)
+	if r == nil || !r.trustedProxy(peer) {
+		return peer
+	}
+
+	for _, name := range r.headers {
+		values := req.Header.Values(name)
+		if len(values) == 0 {
+			continue
+		}
+		if addr := r.fromList(strings.Join(values, ",")); addr.IsValid() {
+			return addr
+		}
+	}
+	return peer
+}
+
+// fromList reads a comma-separated proxy chain such as X-Forwarded-For from the right
+// The first address not belonging to a trusted proxy is the client; entries left of it could be forged
+func (r *clientIPResolver) fromList(list string) netip.Addr {
+	var addr netip.Addr
+	entries := strings.Split(list, ",")
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := parseAddr(strings.TrimSpace(entries[i]))
+		if !entry.IsValid() {
+			break
+		}
+		addr = entry
+		if !r.trustedProxy(addr) {
+			break
+		}
+	}
+	return addr
+}
+
+// trustedProxy reports whether addr belongs to a trusted proxy
+func (r *clientIPResolver) trustedProxy(addr netip.Addr) bool {
+	return addr.IsValid() && containsAddr(r.trusted, addr)
+}
+
+// clientAddr returns the resolved client address of the request, resolving it once
+func clientAddr(c *gin.Context) netip.Addr {
+	if value, exists := c.Get(clientIPKey); exists {
+		return value.(netip.Addr)
+	}
+	addr := clientIPs.resolve(c.Request)
+	c.Set(clientIPKey, addr)
+	return addr
+}
+
+// clientIP returns the resolved client address of the request as a string, empty if unknown
+// Use it rather than gin's ClientIP, which believes X-Forwarded-For from anyone
+func clientIP(c *gin.Context) string {
+	if addr := clientAddr(c); addr.IsValid() {
+		return addr.String()
+	}
+	return ""
+}
+
+// parseAddr reads an IP address with or without a port, unmapping IPv4-in-IPv6
+func parseAddr(value string) netip.Addr {
+	if addrPort, err := netip.ParseAddrPort(value); err == nil {
+		return addrPort.Addr().Unmap()
+	}
+	if addr, err := netip.ParseAddr(value); err == nil {
+		return addr.Unmap()
+	}
+	return netip.Addr{}
+}
+
+// parseCIDRs reads addresses and CIDRs; a plain address is a network of one
+func parseCIDRs(values []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(values))
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if prefix, err := netip.ParsePrefix(value); err == nil {
+			// Requests are matched unmapped, so ::ffff:10.0.0.0/104 becomes 10.0.0.0/8
+			if prefix.Addr().Is4In6() && prefix.Bits() >= 96 {
+				prefix = netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()-96)
+			}
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidCIDR, value)
+		}
+		addr = addr.Unmap()
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes, nil
+}
+
+// canonicalCIDRs validates an allowlist and rewrites it in canonical CIDR form
+func canonicalCIDRs(values []string) ([]string, error) {
+	prefixes, err := parseCIDRs(values)
+	if err != nil {
+		return nil, err
+	}
+	cidrs := make([]string, len(prefixes))
+	for i, prefix := range prefixes {
+		cidrs[i] = prefix.String()
+	}
+	return cidrs, nil
+}
+
+// containsAddr reports whether any of the networks contains addr
+func containsAddr(networks []netip.Prefix, addr netip.Addr) bool {
+	for _, network := range networks {
+		if network.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowsAddr reports whether the client's allowlist admits addr; an empty allowlist admits everyone
+func (c *Clients) allowsAddr(addr netip.Addr) bool {
+	return len(c.AllowedCIDRs) == 0 || containsAddr(c.AllowedCIDRs, addr)
+}
+
+// issuanceNetwork checks addr against the client's allowlist and returns the network a token issued to it belongs to
+// That is the allowlist entry containing addr, or addr alone when the client has no allowlist
+func (c *Clients) issuanceNetwork(addr netip.Addr) (netip.Prefix, error) {
+	if len(c.AllowedCIDRs) == 0 {
+		return netip.PrefixFrom(addr, addr.BitLen()), nil
+	}
+	for _, network := range c.AllowedCIDRs {
+		if network.Contains(addr) {
+			return network, nil
+		}
+	}
+	return netip.Prefix{}, errNetworkNotAllowed
+}
+
+// authorizeSource checks that a token is used from a network its client and the token itself allow
+// addr is the caller the token came from, as forwarded by the gateway
+func (as *authServer) authorizeSource(ctx context.Context, claims *Claims, addr netip.Addr) *APIError {
+	if claims.Network != "" {
+		network, err := netip.ParsePrefix(claims.Network)
+		if err != nil || !network.Contains(addr) {
+			log.Warn().
+				Str("client_id", claims.ClientID).
+				Str("network", claims.Network).
+				Str("client_ip", addr.String()).
+				Msg("Token used outside its issuance network - access denied")
+			return ErrForbiddenError("Token is not valid from this network")
+		}
+	}
+
+	client, err := as.lookupClient(ctx, claims.ClientID)
+	if err != nil {
+		log.Warn().Err(err).Str("client_id", claims.ClientID).Msg("Client lookup failed during network check")
+		return ErrForbiddenError("Client is not authorized").WithOriginalError(err)
+	}
+	if client.EnforceCIDRsOnUse && !client.allowsAddr(addr) {
+		log.Warn().
+			Str("client_id", claims.ClientID).
+			Str("client_ip", addr.String()).
+			Msg("Token used outside the client's allowed networks - access denied")
+		return ErrForbiddenError("Client may not use tokens from this network")
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"slices"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestClientIPResolver_Resolve(t *testing.T) {
+	resolver, err := newClientIPResolver(network{
+		TrustedProxies:  []string{"10.0.0.0/8"},
+		ClientIPHeaders: []string{"X-Real-IP", "X-Forwarded-For"},
+	})
+	if err != nil {
+		t.Fatalf("newClientIPResolver: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		resolver *clientIPResolver
+		remote   string
+		headers  map[string]string
+		want     string
+	}{
+		{"untrusted peer", resolver, "203.0.113.5:1234", map[string]string{"X-Real-IP": "198.51.100.7"}, "203.0.113.5"},
+		{"trusted peer", resolver, "10.0.0.2:1234", map[string]string{"X-Real-IP": "198.51.100.7"}, "198.51.100.7"},
+		{"forwarded chain", resolver, "10.0.0.2:1234", map[string]string{"X-Forwarded-For": "192.0.2.1, 198.51.100.7, 10.0.0.3"}, "198.51.100.7"},
+		{"resource URL header", resolver, "10.0.0.2:1234", map[string]string{"X-Forwarded-For": "http://localhost:3000/api/users"}, "10.0.0.2"},
+		{"no resolver", nil, "10.0.0.2:1234", map[string]string{"X-Real-IP": "198.51.100.7"}, "10.0.0.2"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/token", nil)
+			req.RemoteAddr = test.remote
+			for name, value := range test.headers {
+				req.Header.Set(name, value)
+			}
+			if got := test.resolver.resolve(req); got.String() != test.want {
+				t.Errorf("Expected %s, got %s", test.want, got)
+			}
+		})
+	}
+}
+
+func TestCanonicalCIDRs(t *testing.T) {
+	cidrs, err := canonicalCIDRs([]string{"10.1.2.3/8", "192.0.2.7", "::ffff:172.16.0.0/108", "2001:db8::1/32"})
+	if err != nil {
+		t.Fatalf("canonicalCIDRs: %v", err)
+	}
+	want := []string{"10.0.0.0/8", "192.0.2.7/32", "172.16.0.0/12", "2001:db8::/32"}
+	if !slices.Equal(cidrs, want) {
+		t.Errorf("Expected %v, got %v", want, cidrs)
+	}
+
+	if _, err := canonicalCIDRs([]string{"batch-cluster"}); !errors.Is(err, ErrInvalidCIDR) {
+		t.Errorf("Expected ErrInvalidCIDR, got %v", err)
+	}
+}
+
+func TestClients_IssuanceNetwork(t *testing.T) {
+	batch := netip.MustParsePrefix("10.20.0.0/16")
+	client := &Clients{AllowedCIDRs: []netip.Prefix{batch}}
+
+	if network, err := client.issuanceNetwork(netip.MustParseAddr("10.20.3.4")); err != nil || network != batch {
+		t.Errorf("Expected the matching allowlist entry, got %v %v", network, err)
+	}
+	if _, err := client.issuanceNetwork(netip.MustParseAddr("192.0.2.1")); !errors.Is(err, errNetworkNotAllowed) {
+		t.Errorf("Expected errNetworkNotAllowed, got %v", err)
+	}
+
+	// Without an allowlist a token is bound to the single address it was issued to
+	open := &Clients{}
+	if network, err := open.issuanceNetwork(netip.MustParseAddr("192.0.2.1")); err != nil || network.String() != "192.0.2.1/32" {
+		t.Errorf("Expected 192.0.2.1/32, got %v %v", network, err)
+	}
+}
+
+func TestAuthorizeSource(t *testing.T) {
+	server := newAuthorizeTestServer(t, &Clients{
+		ClientID:          "batch",
+		AllowedCIDRs:      []netip.Prefix{netip.MustParsePrefix("10.20.0.0/16")},
+		EnforceCIDRsOnUse: true,
+	})
+	inside, outside := netip.MustParseAddr("10.20.3.4"), netip.MustParseAddr("192.0.2.1")
+
+	if apiErr := server.authorizeSource(context.Background(), &Claims{ClientID: "batch"}, inside); apiErr != nil {
+		t.Errorf("Expected a caller on the allowlist to pass, got %v", apiErr)
+	}
+	if apiErr := server.authorizeSource(context.Background(), &Claims{ClientID: "batch"}, outside); apiErr == nil || apiErr.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected 403 for a caller outside the allowlist, got %v", apiErr)
+	}
+
+	claims := &Claims{ClientID: "batch", Network: "10.20.3.0/24"}
+	if apiErr := server.authorizeSource(context.Background(), claims, netip.MustParseAddr("10.20.9.9")); apiErr == nil || apiErr.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected 403 for a caller outside the token's network, got %v", apiErr)
+	}
+	if apiErr := server.authorizeSource(context.Background(), claims, netip.Addr{}); apiErr == nil {
+		t.Error("Expected an unknown caller to be refused a network-bound token")
+	}
+}
+
+func TestTokenHandler_OutsideAllowedNetwork(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cheapSecretPolicy(t, SecretAlgorithmArgon2id)
+
+	server := newAuthorizeTestServer(t, &Clients{
+		ClientID:     "batch",
+		Active:       true,
+		Secrets:      []*ClientSecret{{ID: 1, Hash: "s3cret", Primary: true}},
+		AllowedCIDRs: []netip.Prefix{netip.MustParsePrefix("10.20.0.0/16")},
+	})
+
+	router := gin.New()
+	router.POST("/token", server.tokenHandler)
+
+	body := `{"client_id": "batch", "client_secret": "s3cret", "grant_type": "client_credentials"}`
+	req := httptest.NewRequest("POST", "/token", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Real-IP", "10.20.3.4") // Not believed from an untrusted peer
+	req.RemoteAddr = "192.0.2.1:4321"
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 outside the allowed networks, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+}
+
+func TestGenerateJWT_NetworkClaim(t *testing.T) {
+	server := newAuthorizeTestServer(t, &Clients{ClientID: "batch"})
+	server.stateless = true
+
+	tokenString, _, err := server.generateJWT(context.Background(), "batch", netip.MustParsePrefix("10.20.0.0/16"))
+	if err != nil {
+		t.Fatalf("generateJWT failed: %v", err)
+	}
+	claims := &Claims{}
+	if _, err := jwt.ParseWithClaims(tokenString, claims, server.verificationKey); err != nil {
+		t.Fatalf("Failed to parse token: %v", err)
+	}
+	if claims.Network != "10.20.0.0/16" {
+		t.Errorf("Expected the network claim, got %q", claims.Network)
+	}
+}
@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"context"
 	"testing"
 	"time"
 )
@@ -13,7 +14,6 @@ func BenchmarkClientCache(b *testing.B) {
 	// Create test client
 	testClient := &Clients{
 		ClientID:      "test-client-1",
-		ClientSecret:  "secret-123",
 		AllowedScopes: []string{"read:tokens", "write:tokens"},
 	}
 
@@ -25,13 +25,13 @@ func BenchmarkClientCache(b *testing.B) {
 	// Benchmark cache hits (99% of requests)
 	b.Run("CacheHit", func(b *testing.B) {
 		for i := 0; i < b.N; i++ {
-			_, _ = cache.Get("test-client-1")
+			_, _ = cache.Get(context.Background(), "test-client-1")
 		}
 	})
 
 	b.Run("CacheMiss", func(b *testing.B) {
 		for i := 0; i < b.N; i++ {
-			_, _ = cache.Get("non-existent")
+			_, _ = cache.Get(context.Background(), "non-existent")
 		}
 	})
 }
@@ -53,7 +53,6 @@ func BenchmarkGenerateJWTWithCache(b *testing.B) {
 	// Pre-populate cache
 	testClient := &Clients{
 		ClientID:      "bench-client",
-		ClientSecret:  "secret",
 		AllowedScopes: []string{"read:tokens", "write:tokens"},
 	}
 	as.clientCache.Set("bench-client", testClient)
@@ -63,7 +62,7 @@ func BenchmarkGenerateJWTWithCache(b *testing.B) {
 	// Benchmark token generation with cache (new code path)
 	for i := 0; i < b.N; i++ {
 		// Simulate the new generateJWT code path
-		if cachedClient, found := as.clientCache.Get("bench-client"); found {
+		if cachedClient, found := as.clientCache.Get(context.Background(), "bench-client"); found {
 			// This is now a cache hit - should be very fast
 			_ = cachedClient
 		}
@@ -77,7 +76,6 @@ func BenchmarkConcurrentCacheAccess(b *testing.B) {
 
 	testClient := &Clients{
 		ClientID:      "concurrent-test",
-		ClientSecret:  "secret",
 		AllowedScopes: []string{"read:tokens"},
 	}
 	cache.Set("concurrent-test", testClient)
@@ -87,7 +85,7 @@ func BenchmarkConcurrentCacheAccess(b *testing.B) {
 	// Run concurrent reads (simulating parallel token requests)
 	b.RunParallel(func(pb *testing.PB) {
 		for pb.Next() {
-			_, _ = cache.Get("concurrent-test")
+			_, _ = cache.Get(context.Background(), "concurrent-test")
 		}
 	})
 }
@@ -105,7 +103,6 @@ func BenchmarkMemoryUsage(b *testing.B) {
 		for i := 0; i < 100; i++ {
 			client := &Clients{
 				ClientID:      "client-100",
-				ClientSecret:  "secret",
 				AllowedScopes: []string{"read:tokens"},
 			}
 			cache.Set("client-100", client)
@@ -119,7 +116,6 @@ func BenchmarkMemoryUsage(b *testing.B) {
 		for i := 0; i < 1000; i++ {
 			client := &Clients{
 				ClientID:      "client-1000",
-				ClientSecret:  "secret",
 				AllowedScopes: []string{"read:tokens"},
 			}
 			cache.Set("client-1000", client)
@@ -0,0 +1,520 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+// Rate limit state sharing modes
+const (
+	RateLimitModeLocal = "local"
+	RateLimitModeDB    = "db"
+)
+
+// Rate limited endpoints, used in bucket keys
+const (
+	rateLimitToken    = "token"
+	rateLimitValidate = "validate"
+)
+
+// rateDecisionKey holds the most restrictive decision made for the request, which its headers describe
+const rateDecisionKey = "rate_limit_decision"
+
+// RateLimit is a token bucket: Rate requests per second on average, in bursts of up to Burst
+// A zero Rate means no limit; a zero Burst allows one second's worth of requests at once
+type RateLimit struct {
+	Rate  float64 `json:"rate" mapstructure:"rate"`
+	Burst int     `json:"burst,omitempty" mapstructure:"burst"`
+}
+
+// Enabled reports whether the limit restricts anything
+func (l RateLimit) Enabled() bool {
+	return l.Rate > 0
+}
+
+// capacity is the bucket size
+func (l RateLimit) capacity() float64 {
+	if l.Burst > 0 {
+		return float64(l.Burst)
+	}
+	return math.Max(1, math.Ceil(l.Rate))
+}
+
+// valid reports whether a stored override makes sense
+func (l RateLimit) valid() bool {
+	return l.Rate >= 0 && l.Burst >= 0 && (l.Rate > 0 || l.Burst == 0)
+}
+
+// RateDecision is the outcome of taking one request from a bucket
+type RateDecision struct {
+	Allowed    bool
+	Limit      int           // Bucket capacity
+	Remaining  int           // Requests left right now
+	Reset      time.Duration // Until the bucket is full again
+	RetryAfter time.Duration // Until the next request is allowed, when refused
+}
+
+// rateBucket is one key's token bucket
+// tokens may go below zero when other instances report hits, so the bucket refills before allowing again
+type rateBucket struct {
+	limit   RateLimit
+	tokens  float64
+	updated time.Time
+	pending int // Local hits not yet shared with other instances
+}
+
+// refill adds the tokens earned since the last update
+func (b *rateBucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.updated).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(b.limit.capacity(), b.tokens+elapsed*b.limit.Rate)
+	}
+	b.updated = now
+}
+
+// RateLimiter keeps token buckets for clients and source IPs
+// In db mode, hits are exchanged with other instances through the rate_limit_hits table, so each
+// instance drains its buckets by the cluster's traffic and limits hold approximately across replicas
+type RateLimiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*rateBucket
+	shared    *rateLimitSync // nil in local mode
+	sweepTick *time.Ticker
+	done      chan struct{}
+	stopOnce  sync.Once
+}
+
+// NewRateLimiter creates a limiter and starts its idle bucket sweeper
+// Parameters: db - shared state store in db mode, nil keeps all state local
+func NewRateLimiter(db *sql.DB, instanceID string, syncInterval time.Duration) *RateLimiter {
+	rl := &RateLimiter{
+		buckets:   make(map[string]*rateBucket),
+		sweepTick: time.NewTicker(time.Minute),
+		done:      make(chan struct{}),
+	}
+	if db != nil {
+		rl.shared = newRateLimitSync(rl, db, instanceID, syncInterval)
+	}
+
+	go rl.backgroundSweep()
+
+	log.Info().
+		Bool("shared", rl.shared != nil).
+		Msg("Rate limiter initialized")
+	return rl
+}
+
+// Allow takes one request from key's bucket, creating it full on first use
+func (rl *RateLimiter) Allow(key string, limit RateLimit, now time.Time) RateDecision {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	bucket, exists := rl.buckets[key]
+	if !exists {
+		bucket = &rateBucket{limit: limit, tokens: limit.capacity(), updated: now}
+		rl.buckets[key] = bucket
+	}
+	if bucket.limit != limit {
+		// An override changed; keep the bucket's level within the new size
+		bucket.limit = limit
+		bucket.tokens = math.Min(bucket.tokens, limit.capacity())
+	}
+	bucket.refill(now)
+
+	decision := RateDecision{Limit: int(limit.capacity())}
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		bucket.pending++
+		decision.Allowed = true
+	} else {
+		decision.RetryAfter = time.Duration((1 - bucket.tokens) / limit.Rate * float64(time.Second))
+	}
+	decision.Remaining = max(0, int(bucket.tokens))
+	decision.Reset = time.Duration((limit.capacity() - bucket.tokens) / limit.Rate * float64(time.Second))
+	return decision
+}
+
+// debit removes hits made on other instances from key's bucket
+// Keys this instance has not seen recently are skipped; they start with a full bucket here anyway
+func (rl *RateLimiter) debit(key string, hits int, now time.Time) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	bucket, exists := rl.buckets[key]
+	if !exists {
+		return
+	}
+	bucket.refill(now)
+	// Debt is capped at one bucket, so a burst elsewhere cannot lock a key out here for long
+	bucket.tokens = math.Max(-bucket.limit.capacity(), bucket.tokens-float64(hits))
+}
+
+// takePending returns and resets the local hits of every bucket that has any
+func (rl *RateLimiter) takePending() map[string]int {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	pending := make(map[string]int)
+	for key, bucket := range rl.buckets {
+		if bucket.pending > 0 {
+			pending[key] = bucket.pending
+			bucket.pending = 0
+		}
+	}
+	return pending
+}
+
+// sweep drops buckets that have refilled completely and have nothing left to share
+func (rl *RateLimiter) sweep(now time.Time) int {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	removed := 0
+	for key, bucket := range rl.buckets {
+		bucket.refill(now)
+		if bucket.pending == 0 && bucket.tokens >= bucket.limit.capacity() {
+			delete(rl.buckets, key)
+			removed++
+		}
+	}
+	return removed
+}
+
+// backgroundSweep removes idle buckets every minute until stopped
+func (rl *RateLimiter) backgroundSweep() {
+	for {
+		select {
+		case <-rl.done:
+			rl.sweepTick.Stop()
+			log.Debug().Msg("Rate limiter sweep stopped")
+			return
+		case <-rl.sweepTick.C:
+			if removed := rl.sweep(time.Now()); removed > 0 {
+				log.Debug().Int("removed", removed).Msg("Idle rate limit buckets removed")
+			}
+		}
+	}
+}
+
+// Start begins sharing hits with other instances in db mode
+func (rl *RateLimiter) Start() error {
+	if rl.shared == nil {
+		return nil
+	}
+	return rl.shared.Start()
+}
+
+// Stop stops the sweeper and hit sharing
+func (rl *RateLimiter) Stop() {
+	rl.stopOnce.Do(func() {
+		close(rl.done)
+		if rl.shared != nil {
+			rl.shared.Stop()
+		}
+	})
+	log.Info().Msg("Rate limiter stopped")
+}
+
+// rateLimitSync exchanges bucket hits with other instances through the rate_limit_hits table
+// Like the DB change feed, each instance inserts its own rows and reads rows newer than the last it has seen.
+// IDs are allocated before commit, so IDs skipped by a sync are kept as gaps and re-read until they time out
+type rateLimitSync struct {
+	limiter    *RateLimiter
+	db         *sql.DB
+	instanceID string
+	interval   time.Duration
+	lastID     int64
+	gaps       []hitGap // Skipped ID ranges in ID order, oldest first
+	syncTick   *time.Ticker
+	done       chan struct{}
+	stopOnce   sync.Once
+}
+
+// hitGap is a range of IDs a sync skipped, whose rows may still commit
+type hitGap struct {
+	from, to  int64
+	expiresAt time.Time
+}
+
+const (
+	// rateLimitHitRetention is how long shared hit rows are kept
+	rateLimitHitRetention = time.Minute
+	// rateLimitGapTimeout is how long skipped IDs are watched; inserts time out after 5 seconds,
+	// so a gap still empty after this was rolled back or never used (identity caching)
+	rateLimitGapTimeout = 15 * time.Second
+	// maxRateLimitGaps bounds the ID ranges re-read on every sync
+	maxRateLimitGaps = 100
+)
+
+// newRateLimitSync creates the db mode state exchange
+// Parameters: interval - how often hits are exchanged, which bounds how far limits can overshoot
+func newRateLimitSync(rl *RateLimiter, db *sql.DB, instanceID string, interval time.Duration) *rateLimitSync {
+	if interval <= 0 {
+		log.Warn().Dur("interval", interval).Msg("Invalid rate limit sync interval, using default 1 second")
+		interval = time.Second
+	}
+	return &rateLimitSync{
+		limiter:    rl,
+		db:         db,
+		instanceID: instanceID,
+		interval:   interval,
+		done:       make(chan struct{}),
+	}
+}
+
+// Start positions the exchange at the newest row and begins syncing
+func (s *rateLimitSync) Start() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := s.db.QueryRowContext(ctx, "SELECT NVL(MAX(id), 0) FROM rate_limit_hits").Scan(&s.lastID); err != nil {
+		log.Error().Err(err).Msg("Failed to read rate limit hit position")
+		return fmt.Errorf("failed to read rate limit hit position: %w", err)
+	}
+
+	s.syncTick = time.NewTicker(s.interval)
+	go s.backgroundSync()
+
+	log.Info().
+		Int64("last_hit_id", s.lastID).
+		Str("sync_interval", s.interval.String()).
+		Msg("Rate limit state sharing started")
+	return nil
+}
+
+// backgroundSync publishes local hits and applies remote ones on every tick
+func (s *rateLimitSync) backgroundSync() {
+	purgeEvery := max(1, int(rateLimitHitRetention/s.interval))
+	ticks := 0
+
+	for {
+		select {
+		case <-s.done:
+			s.syncTick.Stop()
+			log.Debug().Msg("Rate limit state sharing stopped")
+			return
+		case <-s.syncTick.C:
+			if err := s.publish(s.limiter.takePending()); err != nil {
+				log.Error().Err(err).Msg("Failed to share rate limit hits")
+			}
+			if err := s.apply(); err != nil {
+				log.Error().Err(err).Int64("last_hit_id", s.lastID).Msg("Failed to read shared rate limit hits")
+			}
+
+			ticks++
+			if ticks%purgeEvery == 0 {
+				s.purge()
+			}
+		}
+	}
+}
+
+// publish inserts one row per key with this instance's hits since the last sync
+func (s *rateLimitSync) publish(pending map[string]int) error {
+	if len(pending) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, "INSERT INTO rate_limit_hits (limit_key, instance_id, hits) VALUES (:limit_key, :instance_id, :hits)")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for key, hits := range pending {
+		if _, err := stmt.ExecContext(ctx,
+			sql.Named("limit_key", key),
+			sql.Named("instance_id", s.instanceID),
+			sql.Named("hits", hits)); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// apply debits the hits other instances shared since the last sync, including late commits into gaps
+func (s *rateLimitSync) apply() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	s.gaps = slices.DeleteFunc(s.gaps, func(gap hitGap) bool {
+		return now.After(gap.expiresAt)
+	})
+
+	ranges := []string{"id > :since"}
+	args := []any{sql.Named("since", s.lastID)}
+	for i, gap := range s.gaps {
+		ranges = append(ranges, fmt.Sprintf("id BETWEEN :gap%d_from AND :gap%d_to", i, i))
+		args = append(args, sql.Named(fmt.Sprintf("gap%d_from", i), gap.from), sql.Named(fmt.Sprintf("gap%d_to", i), gap.to))
+	}
+
+	// This instance's rows are read too, so their IDs are not taken for gaps
+	query := `SELECT id, limit_key, instance_id, hits FROM rate_limit_hits
+		WHERE ` + strings.Join(ranges, " OR ") + ` ORDER BY id FETCH FIRST 5000 ROWS ONLY`
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			id         int64
+			key        string
+			instanceID string
+			hits       int
+		)
+		if err := rows.Scan(&id, &key, &instanceID, &hits); err != nil {
+			return err
+		}
+		s.track(id, now)
+		if instanceID != s.instanceID {
+			s.limiter.debit(key, hits, now)
+		}
+	}
+	return rows.Err()
+}
+
+// track moves past id, keeping any IDs skipped on the way as a gap, or removes id from the gap it filled
+func (s *rateLimitSync) track(id int64, now time.Time) {
+	if id > s.lastID {
+		if id > s.lastID+1 {
+			s.gaps = append(s.gaps, hitGap{from: s.lastID + 1, to: id - 1, expiresAt: now.Add(rateLimitGapTimeout)})
+			if len(s.gaps) > maxRateLimitGaps {
+				log.Warn().Int64("from", s.gaps[0].from).Int64("to", s.gaps[0].to).Msg("Too many rate limit hit gaps, dropping the oldest")
+				s.gaps = s.gaps[1:]
+			}
+		}
+		s.lastID = id
+		return
+	}
+
+	for i, gap := range s.gaps {
+		if id < gap.from || id > gap.to {
+			continue
+		}
+		var rest []hitGap
+		if id > gap.from {
+			rest = append(rest, hitGap{from: gap.from, to: id - 1, expiresAt: gap.expiresAt})
+		}
+		if id < gap.to {
+			rest = append(rest, hitGap{from: id + 1, to: gap.to, expiresAt: gap.expiresAt})
+		}
+		s.gaps = slices.Replace(s.gaps, i, i+1, rest...)
+		return
+	}
+}
+
+// purge removes rows older than the retention window
+func (s *rateLimitSync) purge() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, "DELETE FROM rate_limit_hits WHERE created_at < :cutoff",
+		sql.Named("cutoff", time.Now().Add(-rateLimitHitRetention)))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to purge old rate limit hits")
+		return
+	}
+	if removed, _ := result.RowsAffected(); removed > 0 {
+		log.Debug().Int64("removed", removed).Msg("Old rate limit hits purged")
+	}
+}
+
+// Stop stops syncing
+func (s *rateLimitSync) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.done)
+	})
+}
+
+// endpointLimits returns the configured limits for an endpoint
+func endpointLimits(endpoint string) rateLimitEndpoint {
+	if endpoint == rateLimitValidate {
+		return AppConfig.RateLimit.Validate
+	}
+	return AppConfig.RateLimit.Token
+}
+
+// clientRateLimit is the client's override for an endpoint, or the configured default
+func clientRateLimit(client *Clients, endpoint string) RateLimit {
+	override := client.TokenRateLimit
+	if endpoint == rateLimitValidate {
+		override = client.ValidateRateLimit
+	}
+	if override != nil {
+		return *override
+	}
+	return endpointLimits(endpoint).Client
+}
+
+// limitIP is middleware that limits an endpoint per source IP before any work is done
+func (as *authServer) limitIP(endpoint string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit := endpointLimits(endpoint).IP
+		if !as.checkRateLimit(c, endpoint+":ip:"+clientIP(c), limit) {
+			if endpoint == rateLimitToken {
+				as.metrics.tokenRequest(tokenOutcomeRateLimited)
+			} else {
+				as.metrics.tokenValidation(validationEndpointValidate, validationOutcomeRateLimited)
+			}
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// limitClient limits an identified client on an endpoint; false means a 429 was sent
+func (as *authServer) limitClient(c *gin.Context, endpoint string, client *Clients) bool {
+	return as.checkRateLimit(c, endpoint+":client:"+client.ClientID, clientRateLimit(client, endpoint))
+}
+
+// checkRateLimit takes a request from key's bucket and sets the RateLimit headers
+// Refused requests get a 429 with Retry-After; the headers always describe the tightest bucket checked
+func (as *authServer) checkRateLimit(c *gin.Context, key string, limit RateLimit) bool {
+	if as.rateLimiter == nil || !limit.Enabled() {
+		return true
+	}
+
+	decision := as.rateLimiter.Allow(key, limit, time.Now())
+	if previous, ok := c.Get(rateDecisionKey); !ok || decision.Remaining <= previous.(RateDecision).Remaining || !decision.Allowed {
+		c.Set(rateDecisionKey, decision)
+		c.Header("RateLimit-Limit", strconv.Itoa(decision.Limit))
+		c.Header("RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+		c.Header("RateLimit-Reset", strconv.Itoa(ceilSeconds(decision.Reset)))
+	}
+	if decision.Allowed {
+		return true
+	}
+
+	logger := GetRequestLogger(c)
+	logger.Warn().Str("rate_limit_key", key).Dur("retry_after", decision.RetryAfter).Msg("Rate limit exceeded")
+	c.Header("Retry-After", strconv.Itoa(ceilSeconds(decision.RetryAfter)))
+	RespondWithError(c, ErrTooManyRequestsError("Rate limit exceeded"))
+	return false
+}
+
+// ceilSeconds rounds a duration up to whole seconds for headers
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,148 @@
+package auth
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRateLimiter_Allow(t *testing.T) {
+	rl := NewRateLimiter(nil, "test", 0)
+	defer rl.Stop()
+
+	limit := RateLimit{Rate: 1, Burst: 2}
+	now := time.Now()
+	for i := 0; i < 2; i++ {
+		if decision := rl.Allow("token:client:billing", limit, now); !decision.Allowed || decision.Remaining != 1-i {
+			t.Fatalf("Request %d: expected to be allowed with %d remaining, got %+v", i+1, 1-i, decision)
+		}
+	}
+
+	decision := rl.Allow("token:client:billing", limit, now)
+	if decision.Allowed || decision.RetryAfter != time.Second || decision.Limit != 2 {
+		t.Fatalf("Expected the third request to wait 1s, got %+v", decision)
+	}
+	if decision := rl.Allow("token:client:other", limit, now); !decision.Allowed {
+		t.Error("Expected other keys to have their own bucket")
+	}
+
+	if decision := rl.Allow("token:client:billing", limit, now.Add(time.Second)); !decision.Allowed {
+		t.Errorf("Expected the bucket to refill after a second, got %+v", decision)
+	}
+}
+
+func TestRateLimiter_Debit(t *testing.T) {
+	rl := NewRateLimiter(nil, "test", 0)
+	defer rl.Stop()
+
+	limit := RateLimit{Rate: 1, Burst: 5}
+	now := time.Now()
+	rl.Allow("key", limit, now)
+	if pending := rl.takePending(); pending["key"] != 1 {
+		t.Fatalf("Expected one pending hit, got %v", pending)
+	}
+	if pending := rl.takePending(); len(pending) != 0 {
+		t.Fatalf("Expected pending hits to be reset, got %v", pending)
+	}
+
+	// Other instances used up the bucket and more; the debt is capped at one bucket
+	rl.debit("key", 100, now)
+	rl.debit("unknown", 100, now)
+	if decision := rl.Allow("key", limit, now.Add(5*time.Second)); decision.Allowed {
+		t.Errorf("Expected remote hits to drain the bucket, got %+v", decision)
+	}
+	if decision := rl.Allow("key", limit, now.Add(6*time.Second)); !decision.Allowed {
+		t.Errorf("Expected the capped debt to be repaid after a bucket's worth of time, got %+v", decision)
+	}
+	if _, exists := rl.buckets["unknown"]; exists {
+		t.Error("Expected hits for unseen keys to be ignored")
+	}
+}
+
+func TestRateLimiter_Sweep(t *testing.T) {
+	rl := NewRateLimiter(nil, "test", 0)
+	defer rl.Stop()
+
+	now := time.Now()
+	rl.Allow("idle", RateLimit{Rate: 10}, now)
+	rl.takePending()
+	rl.Allow("busy", RateLimit{Rate: 10}, now)
+
+	if removed := rl.sweep(now.Add(time.Second)); removed != 1 || rl.buckets["busy"] == nil {
+		t.Errorf("Expected only the idle bucket to be swept, removed %d", removed)
+	}
+}
+
+func TestRateLimitSync_TracksGaps(t *testing.T) {
+	now := time.Now()
+	s := &rateLimitSync{lastID: 10}
+
+	// 11-13 and 15 were allocated but not yet committed when 14 and 16 were read
+	s.track(14, now)
+	s.track(16, now)
+	if len(s.gaps) != 2 || s.gaps[0] != (hitGap{11, 13, now.Add(rateLimitGapTimeout)}) || s.gaps[1].from != 15 || s.gaps[1].to != 15 {
+		t.Fatalf("Expected gaps 11-13 and 15, got %+v", s.gaps)
+	}
+
+	// Late commits fill their gaps without moving the position back
+	s.track(12, now)
+	s.track(15, now)
+	if s.lastID != 16 || len(s.gaps) != 2 || s.gaps[0].to != 11 || s.gaps[1].from != 13 || s.gaps[1].to != 13 {
+		t.Errorf("Expected gaps 11 and 13 at 16, got %+v at %d", s.gaps, s.lastID)
+	}
+
+	// The number of watched ranges is bounded
+	for i := range maxRateLimitGaps {
+		s.track(int64(18+2*i), now)
+	}
+	if len(s.gaps) != maxRateLimitGaps || s.gaps[0].from == 11 {
+		t.Errorf("Expected the oldest gaps to be dropped, got %d starting at %d", len(s.gaps), s.gaps[0].from)
+	}
+}
+
+func TestTokenHandler_RateLimitedPerIP(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	saved := AppConfig.RateLimit
+	t.Cleanup(func() { AppConfig.RateLimit = saved })
+	AppConfig.RateLimit.Token = rateLimitEndpoint{IP: RateLimit{Rate: 0.5, Burst: 1}}
+
+	ctx, cancel := createTestContextFunc()
+	defer cancel()
+
+	cache := NewClientCache(time.Minute, 10)
+	defer cache.Stop()
+	cache.Set("billing", &Clients{ClientID: "billing", Active: true, Secrets: []*ClientSecret{{ID: 1, Hash: "s3cret", Primary: true}}})
+
+	server := &authServer{jwtSecret: []byte("test-secret"), ctx: ctx, cancel: cancel, clientCache: cache,
+		rateLimiter: NewRateLimiter(nil, "test", 0)}
+	defer server.rateLimiter.Stop()
+
+	router := gin.New()
+	router.POST("/token", server.limitIP(rateLimitToken), server.tokenHandler)
+
+	send := func() *httptest.ResponseRecorder {
+		body := `{"client_id": "billing", "client_secret": "wrong", "grant_type": "client_credentials"}`
+		req, _ := http.NewRequest("POST", "/token", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	if recorder := send(); recorder.Code != http.StatusUnauthorized || recorder.Header().Get("RateLimit-Remaining") != "0" {
+		t.Fatalf("Expected the first request through to the handler, got %d %v", recorder.Code, recorder.Header())
+	}
+
+	recorder := send()
+	if recorder.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected 429, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	if recorder.Header().Get("Retry-After") != "2" || recorder.Header().Get("RateLimit-Limit") != "1" {
+		t.Errorf("Unexpected rate limit headers: %v", recorder.Header())
+	}
+}
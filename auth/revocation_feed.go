@@ -0,0 +1,205 @@
+package auth
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+)
+
+// RevocationFeedScope must be present in a token to read the revocation feed
+const RevocationFeedScope = "revocations:read"
+
+const (
+	defaultRevocationPageSize = 100
+	maxRevocationPageSize     = 1000
+	revocationStreamBuffer    = 256
+	revocationStreamKeepAlive = 15 * time.Second
+)
+
+// revocationEventTypes are the event types exposed through the revocation feed
+var revocationEventTypes = []EventType{EventTokenRevoked, EventClientRevoked}
+
+// Revocations catch-up handler
+// Returns revocations with an ID greater than ?since=, oldest first, for resource servers resyncing their state
+// Recent revocations that committed late are repeated below since, so readers skip IDs they already applied
+func (as *authServer) revocationsHandler(c *gin.Context) {
+	logger := GetRequestLogger(c)
+
+	if _, ok := as.authorizeRevocationFeed(c); !ok {
+		return
+	}
+
+	since, err := parseEventID(c.DefaultQuery("since", "0"))
+	if err != nil {
+		RespondWithError(c, ErrBadRequest("Invalid since parameter").WithOriginalError(err))
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultRevocationPageSize)))
+	if err != nil || limit <= 0 {
+		RespondWithError(c, ErrBadRequest("Invalid limit parameter"))
+		return
+	}
+	limit = min(limit, maxRevocationPageSize)
+
+	// Read one extra row to learn whether another page follows
+	events, err := as.events.EventsSince(c.Request.Context(), since, limit+1, revocationEventTypes...)
+	if err != nil {
+		logger.Error().Err(err).Int64("since", since).Msg("Failed to read revocation feed")
+		RespondWithError(c, ErrInternalServerError("Failed to read revocations").WithOriginalError(err))
+		return
+	}
+
+	// Recent revocations at or below since may be repeated; only newer ones count toward the page size
+	page := RevocationPage{
+		Revocations: make([]RevocationNotice, 0, min(len(events), limit)),
+		NextSince:   since,
+	}
+	newer := 0
+	for _, event := range events {
+		if event.ID > since {
+			if newer == limit {
+				page.HasMore = true
+				break
+			}
+			newer++
+		}
+		page.Revocations = append(page.Revocations, toRevocationNotice(event))
+		page.NextSince = max(page.NextSince, event.ID)
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// Revocations stream handler
+// Pushes revocations as Server-Sent Events; reconnecting clients resume from Last-Event-ID
+func (as *authServer) revocationStreamHandler(c *gin.Context) {
+	logger := GetRequestLogger(c)
+
+	claims, ok := as.authorizeRevocationFeed(c)
+	if !ok {
+		return
+	}
+
+	lastID := c.GetHeader("Last-Event-ID")
+	if lastID == "" {
+		lastID = c.DefaultQuery("since", "0")
+	}
+	lastSent, err := parseEventID(lastID)
+	if err != nil {
+		RespondWithError(c, ErrBadRequest("Invalid Last-Event-ID").WithOriginalError(err))
+		return
+	}
+
+	// Listen before catching up so nothing published in between is missed
+	live, stopListening := as.events.Listen(revocationStreamBuffer, revocationEventTypes...)
+	defer stopListening()
+
+	// The stream outlives the server's write timeout
+	http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // Disable nginx response buffering
+	c.Status(http.StatusOK)
+
+	logger.Info().Str("client_id", claims.ClientID).Int64("last_event_id", lastSent).Msg("Revocation stream opened")
+
+	// Late-committing revocations can arrive below the last sent ID, so duplicates are skipped by ID
+	// and each event carries the highest ID sent so far as its position
+	sent := newEventWindow(lastSent)
+	lookback := 2 * time.Duration(AppConfig.Events.Lookback) * time.Second
+	send := func(event Event) {
+		if !sent.add(event) {
+			return
+		}
+		c.Render(-1, sse.Event{
+			Id:    strconv.FormatInt(sent.highest, 10),
+			Event: "revocation",
+			Data:  toRevocationNotice(event),
+		})
+	}
+
+	for {
+		since := sent.highest
+		events, err := as.events.EventsSince(c.Request.Context(), since, maxRevocationPageSize, revocationEventTypes...)
+		if err != nil {
+			logger.Error().Err(err).Int64("since", since).Msg("Failed to read revocation feed for stream catch-up")
+			return
+		}
+		newer := 0
+		for _, event := range events {
+			if event.ID > since {
+				newer++
+			}
+			send(event)
+		}
+		c.Writer.Flush()
+		if newer < maxRevocationPageSize {
+			break
+		}
+	}
+
+	keepAlive := time.NewTicker(revocationStreamKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			logger.Info().Str("client_id", claims.ClientID).Msg("Revocation stream closed by client")
+			return
+		case event, open := <-live:
+			if !open {
+				// Fell behind or shutting down; the client reconnects with Last-Event-ID
+				logger.Warn().Str("client_id", claims.ClientID).Int64("last_event_id", sent.highest).Msg("Revocation stream terminated")
+				return
+			}
+			send(event)
+			c.Writer.Flush()
+		case <-keepAlive.C:
+			sent.forget(time.Now().Add(-lookback))
+			c.Writer.WriteString(": keep-alive\n\n")
+			c.Writer.Flush()
+		}
+	}
+}
+
+// authorizeRevocationFeed requires a valid token carrying RevocationFeedScope
+func (as *authServer) authorizeRevocationFeed(c *gin.Context) (*Claims, bool) {
+	logger := GetRequestLogger(c)
+
+	claims, ok := as.authenticateBearer(c)
+	if !ok {
+		return nil, false
+	}
+
+	if !slices.Contains(claims.Scope, RevocationFeedScope) {
+		logger.Warn().Str("client_id", claims.ClientID).Msg("Token lacks revocation feed scope")
+		RespondWithError(c, ErrForbiddenError("Token lacks the "+RevocationFeedScope+" scope"))
+		return nil, false
+	}
+
+	if as.events == nil {
+		RespondWithError(c, ErrServiceUnavailableError("Revocation feed is not available"))
+		return nil, false
+	}
+
+	return claims, true
+}
+
+// parseEventID parses a feed position, rejecting negative values
+func parseEventID(value string) (int64, error) {
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if id < 0 {
+		return 0, strconv.ErrRange
+	}
+	return id, nil
+}
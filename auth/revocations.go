@@ -0,0 +1,166 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// RevocationWatermarks holds client-wide revocation cutoffs in memory
+// Every token a client was issued at or before its watermark is treated as revoked
+type RevocationWatermarks struct {
+	mu       sync.RWMutex
+	byClient map[string]time.Time
+}
+
+// NewRevocationWatermarks creates an empty watermark set
+func NewRevocationWatermarks() *RevocationWatermarks {
+	return &RevocationWatermarks{
+		byClient: make(map[string]time.Time),
+	}
+}
+
+// Set records a watermark for a client; watermarks only ever move forward
+func (w *RevocationWatermarks) Set(clientID string, revokedBefore time.Time) {
+	if clientID == "" || revokedBefore.IsZero() {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if current, exists := w.byClient[clientID]; !exists || revokedBefore.After(current) {
+		w.byClient[clientID] = revokedBefore
+	}
+}
+
+// Get returns the watermark for a client, if any
+func (w *RevocationWatermarks) Get(clientID string) (time.Time, bool) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	revokedBefore, exists := w.byClient[clientID]
+	return revokedBefore, exists
+}
+
+// Revoked reports whether a token issued at issuedAt falls under the client's watermark
+// JWT iat has second precision, so a token issued in the same second as the watermark is also revoked
+func (w *RevocationWatermarks) Revoked(clientID string, issuedAt time.Time) bool {
+	revokedBefore, exists := w.Get(clientID)
+	if !exists {
+		return false
+	}
+	return !issuedAt.After(revokedBefore.Truncate(time.Second))
+}
+
+// revokeClientTokens records a client-wide watermark and, in stateful mode, marks the ledger rows revoked
+func (as *authServer) revokeClientTokens(clientID string, revokedBefore time.Time) error {
+	ctx, cancel := context.WithTimeout(as.ctx, 10*time.Second)
+	defer cancel()
+
+	tx, err := as.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to begin transaction for client revocation")
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `MERGE INTO client_revocations r
+		USING (SELECT :client_id AS client_id FROM dual) s
+		ON (r.client_id = s.client_id)
+		WHEN MATCHED THEN
+			UPDATE SET r.revoked_before = :revoked_before WHERE r.revoked_before < :revoked_before
+		WHEN NOT MATCHED THEN
+			INSERT (client_id, revoked_before) VALUES (:client_id, :revoked_before)`
+	if _, err := tx.ExecContext(ctx, query,
+		sql.Named("client_id", clientID),
+		sql.Named("revoked_before", revokedBefore)); err != nil {
+		log.Error().Err(err).Str("client_id", clientID).Msg("Failed to record client revocation watermark")
+		return err
+	}
+
+	if !as.stateless {
+		query = "UPDATE tokens SET revoked = 1, revoked_at = :revoked_at WHERE client_id = :client_id AND revoked = 0 AND issued_at <= :revoked_at"
+		if _, err := tx.ExecContext(ctx, query,
+			sql.Named("revoked_at", revokedBefore),
+			sql.Named("client_id", clientID)); err != nil {
+			log.Error().Err(err).Str("client_id", clientID).Msg("Failed to revoke client tokens")
+			return err
+		}
+	}
+	if err := enqueueWebhook(ctx, tx, WebhookClientTokensRevoked, clientID, map[string]any{
+		"client_id":      clientID,
+		"revoked_before": revokedBefore,
+	}); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Error().Err(err).Msg("Failed to commit client revocation transaction")
+		return err
+	}
+
+	if as.watermarks != nil {
+		as.watermarks.Set(clientID, revokedBefore)
+	}
+
+	log.Info().Str("client_id", clientID).Time("revoked_before", revokedBefore).Msg("Client tokens revoked")
+	return nil
+}
+
+// loadRevocationWatermarks reads every stored watermark into memory at startup
+func (as *authServer) loadRevocationWatermarks() error {
+	ctx, cancel := context.WithTimeout(as.ctx, 10*time.Second)
+	defer cancel()
+
+	rows, err := as.db.QueryContext(ctx, "SELECT client_id, revoked_before FROM client_revocations")
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to load client revocation watermarks")
+		return err
+	}
+	defer rows.Close()
+
+	loaded := 0
+	for rows.Next() {
+		var clientID string
+		var revokedBefore time.Time
+		if err := rows.Scan(&clientID, &revokedBefore); err != nil {
+			return err
+		}
+		as.watermarks.Set(clientID, revokedBefore)
+		loaded++
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	log.Info().Int("count", loaded).Msg("Client revocation watermarks loaded")
+	return nil
+}
+
+// toRevocationNotice converts a revocation event into its public representation
+func toRevocationNotice(event Event) RevocationNotice {
+	notice := RevocationNotice{
+		ID:        event.ID,
+		ClientID:  event.ClientID,
+		RevokedAt: event.CreatedAt,
+	}
+
+	switch event.Type {
+	case EventTokenRevoked:
+		notice.Kind = RevocationKindToken
+		notice.TokenID = event.TokenID
+		if !event.ExpiresAt.IsZero() {
+			expiresAt := event.ExpiresAt
+			notice.ExpiresAt = &expiresAt
+		}
+	case EventClientRevoked:
+		notice.Kind = RevocationKindClient
+		revokedBefore := event.RevokedBefore
+		notice.RevokedBefore = &revokedBefore
+	}
+	return notice
+}
@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRevocationWatermarks_Revoked(t *testing.T) {
+	w := NewRevocationWatermarks()
+	cutoff := time.Date(2025, 1, 1, 12, 0, 0, 500, time.UTC)
+	w.Set("client-1", cutoff)
+
+	tests := []struct {
+		name     string
+		clientID string
+		issuedAt time.Time
+		revoked  bool
+	}{
+		{"issued before watermark", "client-1", cutoff.Add(-time.Minute), true},
+		{"issued in watermark second", "client-1", cutoff.Truncate(time.Second), true},
+		{"issued after watermark", "client-1", cutoff.Add(time.Second), false},
+		{"other client", "client-2", cutoff.Add(-time.Minute), false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := w.Revoked(test.clientID, test.issuedAt); got != test.revoked {
+				t.Errorf("Expected revoked=%v, got %v", test.revoked, got)
+			}
+		})
+	}
+}
+
+func TestRevocationWatermarks_OnlyMovesForward(t *testing.T) {
+	w := NewRevocationWatermarks()
+	later := time.Now()
+	earlier := later.Add(-time.Hour)
+
+	w.Set("client-1", later)
+	w.Set("client-1", earlier)
+	w.Set("client-1", time.Time{})
+
+	got, found := w.Get("client-1")
+	if !found || !got.Equal(later) {
+		t.Errorf("Expected watermark %v, got %v (found=%v)", later, got, found)
+	}
+}
+
+func TestToRevocationNotice(t *testing.T) {
+	expiresAt := time.Now().Add(time.Minute)
+	notice := toRevocationNotice(Event{ID: 7, Type: EventTokenRevoked, ClientID: "client-1", TokenID: "jti-1", ExpiresAt: expiresAt})
+	if notice.Kind != RevocationKindToken || notice.TokenID != "jti-1" || notice.ExpiresAt == nil {
+		t.Errorf("Unexpected token notice: %+v", notice)
+	}
+
+	revokedBefore := time.Now()
+	notice = toRevocationNotice(Event{ID: 8, Type: EventClientRevoked, ClientID: "client-1", RevokedBefore: revokedBefore})
+	if notice.Kind != RevocationKindClient || notice.RevokedBefore == nil || !notice.RevokedBefore.Equal(revokedBefore) {
+		t.Errorf("Unexpected client notice: %+v", notice)
+	}
+}
@@ -7,14 +7,68 @@ import (
 )
 
 func routes(r *gin.Engine, s *authServer) {
+	r.GET("/healthz", s.healthzHandler)
+	r.GET("/readyz", s.readyzHandler)
+
 	service := r.Group("auth-server")
 	api := service.Group("/v1")
 	v1 := api.Group("/oauth")
-	v1.POST("/token", s.tokenHandler)
-	v1.POST("/validate", s.validateHandler)
+	v1.POST("/token", s.limitIP(rateLimitToken), s.tokenHandler)
+	v1.POST("/validate", s.limitIP(rateLimitValidate), s.validateHandler)
+	v1.GET("/auth-request", s.authRequestHandler)
+	v1.GET("/forward-auth", s.forwardAuthHandler)
+	v1.GET("/forward-auth/:profile", s.forwardAuthHandler)
 	v1.POST("/revoke", s.revokeHandler)
-	// v1.POST("/revoke-all", s.revokeAllHandler)
+	v1.POST("/revoke-all", s.revokeAllHandler)
+	v1.GET("/revocations", s.revocationsHandler)
+	v1.GET("/revocations/stream", s.revocationStreamHandler)
+	v1.GET("/jwks", s.jwksHandler)
 	v1.GET("/", func(c *gin.Context) {
 		c.String(http.StatusOK, "ok")
 	})
 }
+
+// adminRoutes registers the admin listener's routes; none of them are served on the public port
+func adminRoutes(r *gin.Engine, s *authServer) {
+	api := r.Group("auth-server/v1")
+	// authctl --server verifies and revokes tokens through the admin API's base URL
+	api.GET("/oauth/jwks", s.jwksHandler)
+	api.POST("/oauth/revoke", s.revokeHandler)
+
+	admin := api.Group("/admin", s.requireAdmin)
+	admin.POST("/clients", s.createClientHandler)
+	admin.GET("/clients", s.listClientsHandler)
+	admin.GET("/clients/:client_id", s.getClientHandler)
+	admin.PATCH("/clients/:client_id", s.updateClientHandler)
+	admin.DELETE("/clients/:client_id", s.deleteClientHandler)
+	admin.POST("/clients/:client_id/restore", s.restoreClientHandler)
+	admin.GET("/clients/:client_id/audit", s.clientAuditHandler)
+	admin.GET("/clients/:client_id/secrets", s.listClientSecretsHandler)
+	admin.POST("/clients/:client_id/secrets", s.addClientSecretHandler)
+	admin.POST("/clients/:client_id/secrets/:secret_id/promote", s.promoteClientSecretHandler)
+	admin.POST("/clients/:client_id/secrets/:secret_id/expire", s.expireClientSecretHandler)
+	admin.POST("/clients/:client_id/unlock", s.unlockClientHandler)
+	admin.GET("/lockouts", s.listLockoutsHandler)
+	admin.POST("/ips/:ip/unlock", s.unlockIPHandler)
+	admin.POST("/keys/rotate", s.rotateKeyHandler)
+	admin.GET("/health/details", s.healthDetailsHandler)
+	admin.GET("/cache/stats", s.cacheStatsHandler)
+	admin.GET("/cache/clients", s.cacheEntriesHandler)
+	admin.DELETE("/cache/clients/:client_id", s.invalidateCacheEntryHandler)
+	admin.DELETE("/cache", s.clearCacheHandler)
+	admin.GET("/batch", s.batchStateHandler)
+	admin.POST("/batch/flush", s.flushBatchHandler)
+	admin.GET("/db/stats", s.dbStatsHandler)
+	admin.GET("/log-level", s.getLogLevelHandler)
+	admin.PUT("/log-level", s.setLogLevelHandler)
+	admin.GET("/audit", s.auditEntriesHandler)
+	admin.GET("/audit/verify", s.auditVerifyHandler)
+	admin.POST("/webhooks", s.createWebhookHandler)
+	admin.GET("/webhooks", s.listWebhooksHandler)
+	admin.DELETE("/webhooks/:webhook_id", s.deleteWebhookHandler)
+	admin.GET("/webhooks/:webhook_id/deliveries", s.webhookDeliveriesHandler)
+
+	if AppConfig.Admin.Pprof {
+		pprofRoutes(r, s)
+	}
+}
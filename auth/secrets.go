@@ -0,0 +1,335 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Client secrets are stored as hashes in PHC string format, so every hash carries its own parameters:
+//
+//	$argon2id$v=19$m=19456,t=2,p=1$<salt>$<key>
+//	$2b$12$<salt and key>
+//
+// Rows written before hashing still hold the plaintext secret. They are compared in constant time
+// and rehashed on the first successful login, as are hashes whose parameters no longer match the config
+
+// Client secret hashing algorithms
+const (
+	SecretAlgorithmArgon2id = "argon2id"
+	SecretAlgorithmBcrypt   = "bcrypt"
+)
+
+// Default hashing parameters (OWASP password storage recommendations)
+const (
+	DefaultArgon2MemoryKiB   = 19 * 1024
+	DefaultArgon2Iterations  = 2
+	DefaultArgon2Parallelism = 1
+	DefaultBcryptCost        = 12
+)
+
+const (
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+)
+
+var errMalformedSecretHash = errors.New("malformed client secret hash")
+
+// secretDigestKey keys the digests of verified secrets kept on cached clients; it never leaves the process
+var secretDigestKey = func() []byte {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(fmt.Sprintf("secret digest key: %v", err))
+	}
+	return key
+}()
+
+// secretPolicy is how new client secrets are hashed
+type secretPolicy struct {
+	Algorithm  string
+	Argon2     argon2Params
+	BcryptCost int
+}
+
+// argon2Params are the tunable argon2id parameters recorded in each hash
+type argon2Params struct {
+	Memory      uint32 // KiB
+	Iterations  uint32
+	Parallelism uint8
+}
+
+// currentSecretPolicy reads the hashing policy from the configuration, falling back to the defaults
+func currentSecretPolicy() secretPolicy {
+	cfg := AppConfig.ClientSecrets
+	policy := secretPolicy{
+		Algorithm: cfg.Algorithm,
+		Argon2: argon2Params{
+			Memory:      uint32(cfg.Argon2Memory),
+			Iterations:  uint32(cfg.Argon2Iterations),
+			Parallelism: uint8(cfg.Argon2Parallelism),
+		},
+		BcryptCost: cfg.BcryptCost,
+	}
+	if policy.Algorithm == "" {
+		policy.Algorithm = SecretAlgorithmArgon2id
+	}
+	if policy.Argon2.Memory == 0 {
+		policy.Argon2.Memory = DefaultArgon2MemoryKiB
+	}
+	if policy.Argon2.Iterations == 0 {
+		policy.Argon2.Iterations = DefaultArgon2Iterations
+	}
+	if policy.Argon2.Parallelism == 0 {
+		policy.Argon2.Parallelism = DefaultArgon2Parallelism
+	}
+	if policy.BcryptCost == 0 {
+		policy.BcryptCost = DefaultBcryptCost
+	}
+	return policy
+}
+
+// HashClientSecret hashes a client secret for storage with the configured algorithm
+func HashClientSecret(secret string) (string, error) {
+	return currentSecretPolicy().hash(secret)
+}
+
+func (p secretPolicy) hash(secret string) (string, error) {
+	if p.Algorithm == SecretAlgorithmBcrypt {
+		hash, err := bcrypt.GenerateFromPassword([]byte(secret), p.BcryptCost)
+		if err != nil {
+			return "", fmt.Errorf("hash client secret: %v", err)
+		}
+		return string(hash), nil
+	}
+
+	salt := make([]byte, argon2SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("hash client secret: %v", err)
+	}
+	key := argon2.IDKey([]byte(secret), salt, p.Argon2.Iterations, p.Argon2.Memory, p.Argon2.Parallelism, argon2KeyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version,
+		p.Argon2.Memory, p.Argon2.Iterations, p.Argon2.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// verify checks a presented secret against a stored hash or legacy plaintext secret in constant time
+// needsRehash is set on success when the stored value is plaintext or was hashed under another policy
+func (p secretPolicy) verify(stored, presented string) (ok, needsRehash bool, err error) {
+	switch {
+	case strings.HasPrefix(stored, "$argon2id$"):
+		params, salt, key, err := parseArgon2Hash(stored)
+		if err != nil {
+			return false, false, err
+		}
+		computed := argon2.IDKey([]byte(presented), salt, params.Iterations, params.Memory, params.Parallelism, uint32(len(key)))
+		if subtle.ConstantTimeCompare(computed, key) != 1 {
+			return false, false, nil
+		}
+		return true, p.Algorithm != SecretAlgorithmArgon2id || params != p.Argon2 || len(key) != argon2KeyLength, nil
+
+	case strings.HasPrefix(stored, "$2a$"), strings.HasPrefix(stored, "$2b$"), strings.HasPrefix(stored, "$2y$"):
+		err := bcrypt.CompareHashAndPassword([]byte(stored), []byte(presented))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, false, nil
+		}
+		if err != nil {
+			return false, false, err
+		}
+		cost, _ := bcrypt.Cost([]byte(stored))
+		return true, p.Algorithm != SecretAlgorithmBcrypt || cost != p.BcryptCost, nil
+
+	case strings.HasPrefix(stored, "$"):
+		// An unknown scheme is never mistaken for a plaintext secret
+		return false, false, errMalformedSecretHash
+
+	default:
+		// Legacy plaintext: comparing digests hides both the secret's length and where it differs
+		want := sha256.Sum256([]byte(stored))
+		got := sha256.Sum256([]byte(presented))
+		if subtle.ConstantTimeCompare(want[:], got[:]) != 1 {
+			return false, false, nil
+		}
+		return true, true, nil
+	}
+}
+
+// parseArgon2Hash splits $argon2id$v=19$m=..,t=..,p=..$salt$key into its parts
+func parseArgon2Hash(encoded string) (argon2Params, []byte, []byte, error) {
+	var params argon2Params
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		return params, nil, nil, errMalformedSecretHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, errMalformedSecretHash
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return params, nil, nil, errMalformedSecretHash
+	}
+	if params.Memory == 0 || params.Iterations == 0 || params.Parallelism == 0 {
+		return params, nil, nil, errMalformedSecretHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, errMalformedSecretHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, errMalformedSecretHash
+	}
+	return params, salt, key, nil
+}
+
+// secretDigest is a fast keyed digest of a client's secret, used to recognise an already verified secret
+func secretDigest(clientID, secret string) [sha256.Size]byte {
+	mac := hmac.New(sha256.New, secretDigestKey)
+	mac.Write([]byte(clientID))
+	mac.Write([]byte{0})
+	mac.Write([]byte(secret))
+
+	var digest [sha256.Size]byte
+	copy(digest[:], mac.Sum(nil))
+	return digest
+}
+
+// verifyClientSecret checks a presented secret against each of the client's unexpired secrets
+// A verified secret is remembered as a keyed digest, so cached clients skip the slow hash
+func (as *authServer) verifyClientSecret(client *Clients, presented string) bool {
+	now := time.Now()
+	digest := secretDigest(client.ClientID, presented)
+	for _, secret := range client.Secrets {
+		if known := secret.verified.Load(); known != nil && !secret.expired(now) && hmac.Equal(known[:], digest[:]) {
+			as.recordSecretUse(client.ClientID, secret, now)
+			return true
+		}
+	}
+
+	// Every unexpired secret is checked, even after a match, and the hash work is padded to
+	// secretCheckSlots, so the time taken does not depend on which secrets are stored
+	policy := currentSecretPolicy()
+	var (
+		matched     *ClientSecret
+		needsRehash bool
+		hashed      int
+	)
+	for _, secret := range client.Secrets {
+		if secret.expired(now) {
+			continue
+		}
+		ok, rehash, err := policy.verify(secret.Hash, presented)
+		if err != nil {
+			log.Error().Err(err).Str("client_id", client.ClientID).Int64("secret_id", secret.ID).Msg("Failed to verify client secret")
+			continue
+		}
+		if isSecretHash(secret.Hash) {
+			hashed++
+		}
+		if ok && matched == nil {
+			matched, needsRehash = secret, rehash
+		}
+	}
+	verifyDummySecret(presented, secretCheckSlots-hashed)
+
+	if matched == nil {
+		return false
+	}
+	matched.verified.Store(&digest)
+	as.recordSecretUse(client.ClientID, matched, now)
+	if needsRehash && as.db != nil {
+		go as.rehashClientSecret(client.ClientID, matched.ID, matched.Hash, presented)
+	}
+	return true
+}
+
+// secretCheckSlots is the least number of slow hash checks a secret verification performs
+// Enough for a client rotating between two secrets; legacy plaintext secrets and unknown client IDs
+// make up the difference with checks against a dummy hash
+const secretCheckSlots = 2
+
+// isSecretHash reports whether a stored secret is a slow hash rather than legacy plaintext
+func isSecretHash(stored string) bool {
+	for _, prefix := range []string{"$argon2id$", "$2a$", "$2b$", "$2y$"} {
+		if strings.HasPrefix(stored, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// dummySecret is a hash of a random secret that unknown client IDs are checked against
+// Made lazily and remade when the policy changes, so it always costs what a real check costs
+var dummySecret struct {
+	sync.Mutex
+	policy secretPolicy
+	hash   string
+}
+
+// verifyDummySecret runs checks slow hash checks that cannot succeed, so unknown client IDs
+// cannot be told apart from known ones by how long /token takes to refuse them
+func verifyDummySecret(presented string, checks int) {
+	if checks <= 0 {
+		return
+	}
+	policy := currentSecretPolicy()
+
+	dummySecret.Lock()
+	if dummySecret.hash == "" || dummySecret.policy != policy {
+		hash, err := policy.hash(generateRandomString(32))
+		if err != nil {
+			dummySecret.Unlock()
+			log.Error().Err(err).Msg("Failed to create dummy client secret hash")
+			return
+		}
+		dummySecret.policy, dummySecret.hash = policy, hash
+	}
+	hash := dummySecret.hash
+	dummySecret.Unlock()
+
+	for range checks {
+		policy.verify(hash, presented)
+	}
+}
+
+// rehashClientSecret replaces a plaintext or outdated stored secret with a hash under the current policy
+// The update only applies while the row still holds the verified value, so a concurrent change wins
+func (as *authServer) rehashClientSecret(clientID string, secretID int64, stored, secret string) {
+	hash, err := HashClientSecret(secret)
+	if err != nil {
+		log.Error().Err(err).Str("client_id", clientID).Msg("Failed to rehash client secret")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(as.ctx, 5*time.Second)
+	defer cancel()
+
+	query := "UPDATE client_secrets SET secret_hash = :new_hash WHERE id = :id AND secret_hash = :old_hash"
+	result, err := as.db.ExecContext(ctx, query,
+		sql.Named("new_hash", hash),
+		sql.Named("id", secretID),
+		sql.Named("old_hash", stored))
+	if err != nil {
+		log.Error().Err(err).Str("client_id", clientID).Int64("secret_id", secretID).Msg("Failed to store rehashed client secret")
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		log.Debug().Str("client_id", clientID).Int64("secret_id", secretID).Msg("Client secret changed before rehash, skipping")
+		return
+	}
+	log.Info().Str("client_id", clientID).Int64("secret_id", secretID).Msg("Client secret rehashed")
+}
@@ -0,0 +1,148 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// cheapSecretPolicy keeps hashing fast in tests
+func cheapSecretPolicy(t *testing.T, algorithm string) {
+	t.Helper()
+	saved := AppConfig.ClientSecrets
+	t.Cleanup(func() { AppConfig.ClientSecrets = saved })
+	AppConfig.ClientSecrets = clientSecrets{
+		Algorithm:         algorithm,
+		Argon2Memory:      64,
+		Argon2Iterations:  1,
+		Argon2Parallelism: 1,
+		BcryptCost:        4,
+	}
+}
+
+func TestHashClientSecret_RoundTrip(t *testing.T) {
+	for _, algorithm := range []string{SecretAlgorithmArgon2id, SecretAlgorithmBcrypt} {
+		t.Run(algorithm, func(t *testing.T) {
+			cheapSecretPolicy(t, algorithm)
+
+			hash, err := HashClientSecret("s3cret")
+			if err != nil {
+				t.Fatalf("HashClientSecret failed: %v", err)
+			}
+			if !strings.HasPrefix(hash, "$") || strings.Contains(hash, "s3cret") {
+				t.Fatalf("Expected a PHC-style hash, got %q", hash)
+			}
+
+			policy := currentSecretPolicy()
+			if ok, rehash, err := policy.verify(hash, "s3cret"); !ok || rehash || err != nil {
+				t.Errorf("Expected the secret to verify without rehash, got ok=%v rehash=%v err=%v", ok, rehash, err)
+			}
+			if ok, _, _ := policy.verify(hash, "wrong"); ok {
+				t.Error("Expected a wrong secret to be rejected")
+			}
+		})
+	}
+}
+
+func TestVerifySecret_NeedsRehash(t *testing.T) {
+	cheapSecretPolicy(t, SecretAlgorithmArgon2id)
+	hash, _ := HashClientSecret("s3cret")
+
+	// Legacy plaintext rows verify and are flagged for migration
+	policy := currentSecretPolicy()
+	if ok, rehash, err := policy.verify("s3cret", "s3cret"); !ok || !rehash || err != nil {
+		t.Errorf("Expected plaintext to verify and need rehash, got ok=%v rehash=%v err=%v", ok, rehash, err)
+	}
+	if ok, _, _ := policy.verify("s3cret", "s3cret-longer"); ok {
+		t.Error("Expected a different plaintext secret to be rejected")
+	}
+
+	// A stronger policy flags hashes made with the old parameters
+	AppConfig.ClientSecrets.Argon2Iterations = 2
+	if ok, rehash, _ := currentSecretPolicy().verify(hash, "s3cret"); !ok || !rehash {
+		t.Errorf("Expected outdated parameters to need rehash, got ok=%v rehash=%v", ok, rehash)
+	}
+
+	// Switching algorithms flags every existing hash
+	AppConfig.ClientSecrets.Algorithm = SecretAlgorithmBcrypt
+	if ok, rehash, _ := currentSecretPolicy().verify(hash, "s3cret"); !ok || !rehash {
+		t.Errorf("Expected an argon2id hash to need rehash under bcrypt, got ok=%v rehash=%v", ok, rehash)
+	}
+}
+
+func TestVerifySecret_Malformed(t *testing.T) {
+	for _, stored := range []string{"$argon2id$v=19$m=0,t=1,p=1$c2FsdA$a2V5", "$argon2id$broken", "$scrypt$abc"} {
+		if ok, _, err := currentSecretPolicy().verify(stored, stored); ok || err == nil {
+			t.Errorf("Expected %q to be rejected as malformed, got ok=%v err=%v", stored, ok, err)
+		}
+	}
+}
+
+func TestVerifyClientSecret_CachesDigest(t *testing.T) {
+	cheapSecretPolicy(t, SecretAlgorithmArgon2id)
+	hash, _ := HashClientSecret("s3cret")
+
+	server := &authServer{jwtSecret: []byte("test-secret")}
+	secret := &ClientSecret{ID: 1, Hash: hash, Primary: true}
+	client := &Clients{ClientID: "billing", Secrets: []*ClientSecret{secret}}
+
+	if server.verifyClientSecret(client, "wrong") || secret.verified.Load() != nil {
+		t.Fatal("Expected a wrong secret to be rejected and not remembered")
+	}
+	if !server.verifyClientSecret(client, "s3cret") || secret.verified.Load() == nil {
+		t.Fatal("Expected the secret to verify and be remembered")
+	}
+
+	// The fast path alone accepts the remembered secret
+	secret.Hash = "$argon2id$unusable"
+	if !server.verifyClientSecret(client, "s3cret") {
+		t.Error("Expected the remembered digest to skip the slow hash")
+	}
+	if server.verifyClientSecret(client, "wrong") {
+		t.Error("Expected the digest not to accept other secrets")
+	}
+}
+
+func TestVerifyClientSecret_Rotation(t *testing.T) {
+	cheapSecretPolicy(t, SecretAlgorithmArgon2id)
+	oldHash, _ := HashClientSecret("old")
+	newHash, _ := HashClientSecret("new")
+
+	server := &authServer{jwtSecret: []byte("test-secret")}
+	expiresAt := time.Now().Add(time.Hour)
+	previous := &ClientSecret{ID: 1, Hash: oldHash, ExpiresAt: &expiresAt}
+	client := &Clients{ClientID: "billing", Secrets: []*ClientSecret{
+		{ID: 2, Hash: newHash, Primary: true},
+		previous,
+	}}
+
+	// Both secrets work during the grace period
+	for _, presented := range []string{"new", "old"} {
+		if !server.verifyClientSecret(client, presented) {
+			t.Errorf("Expected %q to verify during the grace period", presented)
+		}
+	}
+
+	// Once the grace period ends, even a remembered secret is refused
+	expiresAt = time.Now().Add(-time.Second)
+	if server.verifyClientSecret(client, "old") {
+		t.Error("Expected the expired secret to be rejected")
+	}
+	if !server.verifyClientSecret(client, "new") {
+		t.Error("Expected the primary secret to keep working")
+	}
+}
+
+func TestIsSecretHash(t *testing.T) {
+	cheapSecretPolicy(t, SecretAlgorithmArgon2id)
+	argonHash, _ := HashClientSecret("s3cret")
+	cheapSecretPolicy(t, SecretAlgorithmBcrypt)
+	bcryptHash, _ := HashClientSecret("s3cret")
+
+	// Legacy plaintext rows cost no hashing themselves, so dummy checks make up for them
+	for stored, want := range map[string]bool{argonHash: true, bcryptHash: true, "s3cret": false, "$scrypt$abc": false} {
+		if got := isSecretHash(stored); got != want {
+			t.Errorf("isSecretHash(%.12q) = %v, want %v", stored, got, want)
+		}
+	}
+}
@@ -2,12 +2,16 @@ package auth
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
+	"net"
 	"net/http"
 	"time"
 
+	authv3 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
 	"github.com/gin-gonic/gin"
 	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
 )
 
 var JWTsecret = []byte("67d81e2c5717548a4ee1bd1e81395746")
@@ -18,10 +22,14 @@ func (s *authServer) Start() {
 
 	// Apply middleware in the correct order
 	router.Use(
+		TracingMiddleware(),  // Span the whole chain, continuing traceparent
 		LoggingMiddleware(),  // Log all requests
 		CORSMiddleware(),     // Handle CORS
 		RecoveryMiddleware(), // Handle panics
 	)
+	if s.metrics != nil {
+		router.Use(s.metrics.Middleware()) // Count and time requests per route
+	}
 
 	// Register routes
 	routes(router, s)
@@ -56,6 +64,47 @@ func (s *authServer) Start() {
 				Msg("HTTP server error")
 		}
 	}()
+
+	if AppConfig.ExtAuthz.Enabled {
+		s.startExtAuthz()
+	}
+	if s.metrics != nil && AppConfig.MetricPort > 0 {
+		s.startMetrics()
+	}
+	if AppConfig.Admin.Enabled {
+		s.startAdmin()
+	}
+}
+
+// startExtAuthz serves Envoy's ext_authz gRPC API on its own port
+func (s *authServer) startExtAuthz() {
+	logger := GetLogger()
+	addr := fmt.Sprintf(":%d", AppConfig.ExtAuthz.Port)
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		logger.Error().
+			Err(err).
+			Str("address", addr).
+			Msg("Failed to listen for ext_authz gRPC server")
+		return
+	}
+
+	s.grpcSrv = grpc.NewServer()
+	authv3.RegisterAuthorizationServer(s.grpcSrv, newExtAuthzServer(s))
+
+	go func() {
+		logger.Info().
+			Str("address", addr).
+			Msg("Starting ext_authz gRPC server")
+
+		if err := s.grpcSrv.Serve(listener); err != nil {
+			logger.Error().
+				Err(err).
+				Str("address", addr).
+				Msg("ext_authz gRPC server error")
+		}
+	}()
 }
 
 // NewAuthServer creates a new auth server instance with comprehensive initialization
@@ -64,9 +113,24 @@ func NewAuthServer() *authServer {
 	logger := GetLogger()
 	ctx, cancel := context.WithCancel(context.Background())
 
+	// Start tracing before anything that records spans
+	var stopTracing func(context.Context) error
+	if AppConfig.Tracing.Enabled {
+		var err error
+		stopTracing, err = startTracing(ctx, AppConfig.Tracing)
+		if err != nil {
+			logger.Error().Err(err).Msg("Failed to start tracing")
+			cancel()
+			return nil
+		}
+		logger.Info().
+			Str("exporter", AppConfig.Tracing.Exporter).
+			Float64("sample_ratio", AppConfig.Tracing.SampleRatio).
+			Msg("Tracing enabled")
+	}
+
 	// Initialize database connection
-	// Oracle DSN format: user/password@host:port/service_name
-	dbURL := fmt.Sprintf("sys/Oracle123!@%s:%d/XE", AppConfig.Database.Host, AppConfig.Database.Port)
+	dbURL := DatabaseDSN()
 	db, err := newDbClient(dbURL)
 	if err != nil {
 		logger.Error().
@@ -91,11 +155,99 @@ func NewAuthServer() *authServer {
 		cancel:      cancel,
 		db:          db,
 		clientCache: clientCache,
+		stateless:   AppConfig.JWT.TokenMode == TokenModeStateless,
+		watermarks:  NewRevocationWatermarks(),
+		stopTracing: stopTracing,
+	}
+	authServer.store = &Store{db: db, as: authServer}
+
+	if err := authServer.loadRevocationWatermarks(); err != nil {
+		logger.Error().Err(err).Msg("Failed to load client revocation watermarks")
+		cancel()
+		db.Close()
+		return nil
+	}
+
+	// Open the audit log before anything that records to it
+	if AppConfig.Audit.Enabled {
+		authServer.auditLog, err = NewAuditLog(AppConfig.Audit, db)
+		if err != nil {
+			logger.Error().Err(err).Msg("Failed to open audit log")
+			cancel()
+			db.Close()
+			return nil
+		}
+	}
+
+	// These must come after authServer is created since they need a reference to it
+	if authServer.stateless {
+		// Stateless mode: no token ledger, only a denylist of revoked JTIs to keep tidy
+		purgeInterval := time.Duration(AppConfig.JWT.DenylistPurgeInterval) * time.Second
+		authServer.denylistPurger = NewDenylistPurger(authServer, purgeInterval)
+	} else {
+		// Initialize token batch writer (batch 1000 tokens, flush every 5 seconds)
+		authServer.tokenBatcher = NewTokenBatchWriter(authServer, 1000, 5*time.Second)
+	}
+
+	logger.Info().Str("token_mode", AppConfig.JWT.TokenMode).Msg("Token tracking mode configured")
+
+	// Collectors read the cache, batch writer and pool when scraped, so they only need the server
+	authServer.metrics = NewMetrics(authServer)
+
+	authServer.secretMonitor = NewSecretExpiryMonitor(authServer,
+		time.Duration(AppConfig.ClientSecrets.ExpiryCheckInterval)*time.Minute,
+		time.Duration(AppConfig.ClientSecrets.ExpiryWarning)*24*time.Hour)
+
+	// Slow down and lock out repeated secret guessing; must exist before lockout events arrive
+	if AppConfig.Lockout.Enabled {
+		authServer.lockouts = NewLockoutTracker(currentLockoutPolicy())
+	}
+
+	// Subscribe caches to changes made by other instances
+	instanceID := newInstanceID()
+	authServer.events = NewEventBus(instanceID, newEventTransport(db))
+	authServer.registerEventHandlers()
+	if err := authServer.events.Start(); err != nil {
+		logger.Error().Err(err).Msg("Failed to start event propagation")
+		authServer.Shutdown(context.Background())
+		return nil
+	}
+
+	// Send queued change events to webhook subscribers
+	if AppConfig.Webhooks.Enabled {
+		authServer.webhooks = NewWebhookDispatcher(authServer)
 	}
 
-	// Initialize token batch writer (batch 1000 tokens, flush every 5 seconds)
-	// This must come after authServer is created since it needs a reference to it
-	authServer.tokenBatcher = NewTokenBatchWriter(authServer, 1000, 5*time.Second)
+	// Readiness waits for active clients to be cached; the event bus started above keeps them current
+	go authServer.warmClientCache()
+
+	// Limit requests per client and source IP, sharing hits through the database in db mode
+	if AppConfig.RateLimit.Enabled {
+		var shared *sql.DB
+		if AppConfig.RateLimit.Mode == RateLimitModeDB {
+			shared = db
+		}
+		authServer.rateLimiter = NewRateLimiter(shared, instanceID, time.Duration(AppConfig.RateLimit.SyncInterval)*time.Millisecond)
+		if err := authServer.rateLimiter.Start(); err != nil {
+			logger.Error().Err(err).Msg("Failed to start rate limit state sharing")
+			authServer.Shutdown(context.Background())
+			return nil
+		}
+	}
+
+	// Load the signing key ring; new tokens are signed with its newest key
+	if AppConfig.JWT.SigningAlgorithm == SigningAlgorithmES256 {
+		authServer.keyRing = NewKeyRing()
+		if err := authServer.ensureSigningKey(); err != nil {
+			logger.Error().Err(err).Msg("Failed to initialize signing key ring")
+			authServer.Shutdown(context.Background())
+			return nil
+		}
+		if migration := min(AppConfig.JWT.HS256Migration, AppConfig.JWT.AccessDuration); migration > 0 {
+			authServer.hs256Until = time.Now().Add(time.Duration(migration) * time.Minute)
+			logger.Warn().Time("until", authServer.hs256Until).Msg("Accepting HS256 tokens during the ES256 migration window")
+		}
+	}
 
 	logger.Info().Msg("Auth server initialized successfully")
 	return authServer
@@ -106,19 +258,104 @@ func NewAuthServer() *authServer {
 func (s *authServer) Shutdown(ctx context.Context) error {
 	logger := GetLogger()
 
-	// Step 1: Stop accepting new token writes (flush any pending)
+	// Step 0: Fail readiness while still serving, so load balancers stop sending traffic first
+	s.draining.Store(true)
+	if s.httpSrv != nil {
+		if drain := time.Duration(AppConfig.Health.DrainDelay) * time.Second; drain > 0 {
+			logger.Info().Str("drain_delay", drain.String()).Msg("Draining before shutdown...")
+			select {
+			case <-time.After(drain):
+			case <-ctx.Done():
+			}
+		}
+	}
+
+	// Step 1: Stop serving, letting in-flight requests finish while every component is still up
+	// Revocation streams never finish on their own, so they are ended first
+	if s.events != nil {
+		s.events.closeListeners()
+	}
+	var shutdownErr error
+	if s.grpcSrv != nil {
+		logger.Info().Msg("Shutting down ext_authz gRPC server...")
+		stopped := make(chan struct{})
+		go func() {
+			s.grpcSrv.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-ctx.Done():
+			s.grpcSrv.Stop()
+		}
+	}
+	if s.httpSrv != nil {
+		logger.Info().Msg("Shutting down HTTP server...")
+		if err := s.httpSrv.Shutdown(ctx); err != nil {
+			logger.Error().Err(err).Msg("HTTP server shutdown error")
+			shutdownErr = fmt.Errorf("HTTP server shutdown error: %w", err)
+		} else {
+			logger.Info().Msg("HTTP server shutdown complete")
+		}
+	}
+	if s.adminSrv != nil {
+		logger.Info().Msg("Shutting down admin server...")
+		if err := s.adminSrv.Shutdown(ctx); err != nil {
+			logger.Warn().Err(err).Msg("Admin server shutdown error")
+		}
+	}
+	if s.metricsSrv != nil {
+		if err := s.metricsSrv.Shutdown(ctx); err != nil {
+			logger.Warn().Err(err).Msg("Metrics server shutdown error")
+		}
+	}
+
+	// Step 2: Stop accepting new token writes (flush any pending)
 	if s.tokenBatcher != nil {
 		logger.Info().Msg("Stopping token batch writer...")
 		s.tokenBatcher.Stop()
 	}
+	if s.denylistPurger != nil {
+		logger.Info().Msg("Stopping token denylist purger...")
+		s.denylistPurger.Stop()
+	}
+	if s.secretMonitor != nil {
+		s.secretMonitor.Stop()
+	}
+
+	if s.rateLimiter != nil {
+		s.rateLimiter.Stop()
+	}
+	if s.lockouts != nil {
+		s.lockouts.Stop()
+	}
+	if s.webhooks != nil {
+		logger.Info().Msg("Stopping webhook dispatcher...")
+		s.webhooks.Stop()
+	}
+
+	// Step 3: Stop receiving change events from other instances
+	if s.events != nil {
+		logger.Info().Msg("Stopping event bus...")
+		s.events.Stop()
+	}
 
-	// Step 2: Stop accepting new cache operations
+	// Step 4: Stop accepting new cache operations
 	if s.clientCache != nil {
 		logger.Info().Msg("Stopping client cache...")
 		s.clientCache.Stop()
 	}
 
-	// Step 3: Close database connection
+	// Step 5: Write queued audit entries
+	if s.auditLog != nil {
+		logger.Info().Msg("Stopping audit log...")
+		s.auditLog.Stop()
+	}
+
+	// Step 6: Cancel main context, then close the database connection last
+	if s.cancel != nil {
+		s.cancel()
+	}
 	if s.db != nil {
 		logger.Info().Msg("Closing database connection...")
 		if err := s.db.Close(); err != nil {
@@ -126,21 +363,16 @@ func (s *authServer) Shutdown(ctx context.Context) error {
 		}
 	}
 
-	// Step 4: Cancel main context
-	if s.cancel != nil {
-		s.cancel()
-	}
-
-	// Step 5: Shutdown HTTP server
-	if s.httpSrv != nil {
-		logger.Info().Msg("Shutting down HTTP server...")
-		if err := s.httpSrv.Shutdown(ctx); err != nil {
-			logger.Error().Err(err).Msg("HTTP server shutdown error")
-			return fmt.Errorf("HTTP server shutdown error: %w", err)
+	// Step 7: Export spans still buffered
+	if s.stopTracing != nil {
+		if err := s.stopTracing(ctx); err != nil {
+			logger.Warn().Err(err).Msg("Error flushing traces")
 		}
-		logger.Info().Msg("HTTP server shutdown complete")
 	}
 
+	if shutdownErr != nil {
+		return shutdownErr
+	}
 	logger.Info().Msg("Auth server shutdown complete")
 	return nil
 }
@@ -1,7 +1,11 @@
 package auth
 
 import (
+	"context"
+	"net"
+	"net/http"
 	"testing"
+	"time"
 )
 
 func TestNewAuthServer_Creation(t *testing.T) {
@@ -169,3 +173,51 @@ func TestAuthServer_PortConfiguration(t *testing.T) {
 		t.Errorf("Expected addr=':8080', got %s", addr)
 	}
 }
+
+func TestAuthServer_Shutdown_ServesInFlightRequestsFirst(t *testing.T) {
+	saved := AppConfig
+	defer func() { AppConfig = saved }()
+	AppConfig.Health.DrainDelay = 0
+
+	ctx, cancel := createTestContextFunc()
+	defer cancel()
+
+	server := &authServer{ctx: ctx, cancel: cancel, events: NewEventBus("instance-a", nil)}
+	started := make(chan struct{}, 2)
+	var ctxErrInFlight error
+	mux := http.NewServeMux()
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		time.Sleep(100 * time.Millisecond)
+		ctxErrInFlight = server.ctx.Err()
+	})
+	mux.HandleFunc("/stream", func(w http.ResponseWriter, r *http.Request) {
+		live, stop := server.events.Listen(1)
+		defer stop()
+		started <- struct{}{}
+		for range live {
+		}
+	})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	server.httpSrv = &http.Server{Handler: mux}
+	go server.httpSrv.Serve(listener)
+
+	for _, path := range []string{"/slow", "/stream"} {
+		go http.Get("http://" + listener.Addr().String() + path)
+	}
+	<-started
+	<-started
+
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer shutdownCancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		t.Fatalf("Expected open streams not to hold up shutdown, got %v", err)
+	}
+	if ctxErrInFlight != nil {
+		t.Errorf("Expected the in-flight request to finish before components stopped, got %v", ctxErrInFlight)
+	}
+}
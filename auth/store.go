@@ -0,0 +1,876 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Store errors
+var (
+	ErrClientNotFound = errors.New("client not found")
+	ErrClientExists   = errors.New("client already exists")
+	ErrClientDeleted  = errors.New("client is deleted")
+	ErrInvalidWindow  = errors.New("valid_from must be before valid_until")
+	ErrInvalidLimit   = errors.New("rate limits need a positive rate for their burst, and neither may be negative")
+	ErrInvalidCIDR    = errors.New("not an IP address or CIDR")
+)
+
+// ClientRecord is a client as stored, without its secret
+type ClientRecord struct {
+	ClientID       string     `json:"client_id"`
+	Name           string     `json:"name"`
+	AccessTokenTTL int32      `json:"access_token_ttl"`
+	AllowedScopes  []string   `json:"allowed_scopes"`
+	Active         bool       `json:"active"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	DeletedAt      *time.Time `json:"deleted_at,omitempty"`
+	ValidFrom      *time.Time `json:"valid_from,omitempty"`
+	ValidUntil     *time.Time `json:"valid_until,omitempty"`
+	// Rate limit overrides; absent means the configured defaults apply
+	TokenRateLimit    *RateLimit `json:"token_rate_limit,omitempty"`
+	ValidateRateLimit *RateLimit `json:"validate_rate_limit,omitempty"`
+	// Networks tokens may be requested from, in CIDR form; empty allows any
+	AllowedCIDRs      []string `json:"allowed_cidrs"`
+	EnforceCIDRsOnUse bool     `json:"enforce_cidrs_on_use"`
+	NetworkClaim      bool     `json:"network_claim"`
+}
+
+// ClientUpdate holds the fields to change on a client; nil fields are left alone
+// A zero ValidFrom or ValidUntil removes that bound, and a rate limit with a zero rate removes the override
+type ClientUpdate struct {
+	Name           *string    `json:"name,omitempty"`
+	AccessTokenTTL *int32     `json:"access_token_ttl,omitempty"`
+	AllowedScopes  *[]string  `json:"allowed_scopes,omitempty"`
+	Active         *bool      `json:"active,omitempty"`
+	ValidFrom      *time.Time `json:"valid_from,omitempty"`
+	ValidUntil     *time.Time `json:"valid_until,omitempty"`
+	// Rate limit overrides for /token and /validate
+	TokenRateLimit    *RateLimit `json:"token_rate_limit,omitempty"`
+	ValidateRateLimit *RateLimit `json:"validate_rate_limit,omitempty"`
+	// Network restrictions; an empty AllowedCIDRs allows any network
+	AllowedCIDRs      *[]string `json:"allowed_cidrs,omitempty"`
+	EnforceCIDRsOnUse *bool     `json:"enforce_cidrs_on_use,omitempty"`
+	NetworkClaim      *bool     `json:"network_claim,omitempty"`
+	// RevokeTokens also revokes every token issued to the client so far, e.g. when disabling it
+	RevokeTokens bool `json:"revoke_tokens,omitempty"`
+}
+
+// ClientFilter narrows a client listing
+type ClientFilter struct {
+	Active         *bool  // Only clients with this active flag
+	Search         string // Case-insensitive substring of the client ID or name
+	IncludeDeleted bool   // Also list soft-deleted clients
+	Limit          int
+	Offset         int
+}
+
+// ClientPage is one page of a client listing
+type ClientPage struct {
+	Clients []ClientRecord `json:"clients"`
+	Total   int            `json:"total"`
+	Limit   int            `json:"limit"`
+	Offset  int            `json:"offset"`
+}
+
+// Listing bounds
+const (
+	DefaultClientPageSize = 50
+	MaxClientPageSize     = 500
+)
+
+// Client audit actions
+const (
+	ClientAuditCreated  = "client.created"
+	ClientAuditUpdated  = "client.updated"
+	ClientAuditDeleted  = "client.deleted"
+	ClientAuditRestored = "client.restored"
+)
+
+// Actor identifies who made an administrative change
+type Actor struct {
+	Subject   string `json:"subject"` // Admin token's client ID, or "authctl:<user>" for direct changes
+	RequestID string `json:"request_id,omitempty"`
+	IP        string `json:"ip,omitempty"`
+}
+
+// FieldChange is the before and after value of one changed field
+type FieldChange struct {
+	Old any `json:"old"`
+	New any `json:"new"`
+}
+
+// ClientAuditEntry is one recorded change to a client
+type ClientAuditEntry struct {
+	ID        int64                  `json:"id"`
+	ClientID  string                 `json:"client_id"`
+	Action    string                 `json:"action"`
+	Actor     Actor                  `json:"actor"`
+	Changes   map[string]FieldChange `json:"changes,omitempty"`
+	CreatedAt time.Time              `json:"created_at"`
+}
+
+// Store manages clients, tokens and keys directly in the database
+// The admin API and authctl both use it; changes are announced to every instance through the change feed
+type Store struct {
+	db *sql.DB
+	as *authServer
+}
+
+// NewStore wraps an open database connection for use outside a running server
+func NewStore(db *sql.DB) *Store {
+	ctx, cancel := context.WithCancel(context.Background())
+	as := &authServer{
+		jwtSecret:  JWTsecret,
+		ctx:        ctx,
+		cancel:     cancel,
+		db:         db,
+		stateless:  AppConfig.JWT.TokenMode == TokenModeStateless,
+		watermarks: NewRevocationWatermarks(),
+		events:     NewEventBus("authctl-"+newInstanceID(), newEventTransport(db)),
+	}
+	if AppConfig.JWT.SigningAlgorithm == SigningAlgorithmES256 {
+		as.keyRing = NewKeyRing()
+	}
+	return &Store{db: db, as: as}
+}
+
+// OpenStore connects to the configured database
+func OpenStore() (*Store, error) {
+	db, err := newDbClient(DatabaseDSN())
+	if err != nil {
+		return nil, err
+	}
+	store := NewStore(db)
+
+	// The audit file belongs to the server, whose chain it continues; authctl only writes to the shared db sink
+	if cfg := AppConfig.Audit; cfg.Enabled && slices.Contains(cfg.Sinks, AuditSinkDB) {
+		cfg.Sinks = []string{AuditSinkDB}
+		if store.as.auditLog, err = NewAuditLog(cfg, db); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	return store, nil
+}
+
+// Close writes queued audit entries and releases the database connection
+func (s *Store) Close() error {
+	s.as.auditLog.Stop()
+	s.as.cancel()
+	return s.db.Close()
+}
+
+// DatabaseDSN builds the Oracle DSN for the configured database
+// Oracle DSN format: user/password@host:port/service_name
+func DatabaseDSN() string {
+	return fmt.Sprintf("sys/Oracle123!@%s:%d/XE", AppConfig.Database.Host, AppConfig.Database.Port)
+}
+
+// GenerateClientSecret returns a random URL-safe client secret
+func GenerateClientSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// clientColumns are selected in scanClientRecord order
+const clientColumns = "client_id, client_name, access_token_ttl, allowed_scopes, active, created_at, updated_at, deleted_at, valid_from, valid_until, " +
+	"token_rate, token_burst, validate_rate, validate_burst, allowed_cidrs, cidrs_on_use, network_claim"
+
+// CreateClient stores a new active client with a hash of the given secret as its primary secret
+func (s *Store) CreateClient(ctx context.Context, client ClientRecord, secret string, actor Actor) (*ClientRecord, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if !validWindow(client.ValidFrom, client.ValidUntil) {
+		return nil, ErrInvalidWindow
+	}
+	scopes, err := json.Marshal(nonNilStrings(client.AllowedScopes))
+	if err != nil {
+		return nil, err
+	}
+	hash, err := HashClientSecret(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var count int
+	if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM clients WHERE client_id = :client_id",
+		sql.Named("client_id", client.ClientID)).Scan(&count); err != nil {
+		return nil, fmt.Errorf("createClient %s: %v", client.ClientID, err)
+	}
+	if count > 0 {
+		return nil, ErrClientExists
+	}
+
+	query := `INSERT INTO clients (client_id, client_name, access_token_ttl, allowed_scopes, active, valid_from, valid_until)
+		VALUES (:client_id, :client_name, :access_token_ttl, :allowed_scopes, 1, :valid_from, :valid_until)`
+	if _, err := tx.ExecContext(ctx, query,
+		sql.Named("client_id", client.ClientID),
+		sql.Named("client_name", client.Name),
+		sql.Named("access_token_ttl", client.AccessTokenTTL),
+		sql.Named("allowed_scopes", string(scopes)),
+		sql.Named("valid_from", optionalTime(client.ValidFrom)),
+		sql.Named("valid_until", optionalTime(client.ValidUntil))); err != nil {
+		log.Error().Err(err).Str("client_id", client.ClientID).Msg("Failed to create client")
+		return nil, fmt.Errorf("createClient %s: %v", client.ClientID, err)
+	}
+	if _, err := insertClientSecret(ctx, tx, client.ClientID, hash, initialSecretLabel, true, nil); err != nil {
+		return nil, err
+	}
+
+	created, err := s.lockClient(ctx, tx, client.ClientID)
+	if err != nil {
+		return nil, err
+	}
+	changes := map[string]FieldChange{
+		"name":             {New: created.Name},
+		"access_token_ttl": {New: created.AccessTokenTTL},
+		"allowed_scopes":   {New: created.AllowedScopes},
+		"active":           {New: created.Active},
+	}
+	if created.ValidFrom != nil {
+		changes["valid_from"] = FieldChange{New: created.ValidFrom}
+	}
+	if created.ValidUntil != nil {
+		changes["valid_until"] = FieldChange{New: created.ValidUntil}
+	}
+	if err := s.commitClientChange(ctx, tx, client.ClientID, ClientAuditCreated, changes, actor); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// GetClient reads a client by ID, including soft-deleted clients
+func (s *Store) GetClient(ctx context.Context, clientID string) (*ClientRecord, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := "SELECT " + clientColumns + " FROM clients WHERE client_id = :client_id"
+	client, err := scanClientRecord(s.db.QueryRowContext(ctx, query, sql.Named("client_id", clientID)))
+	if err == sql.ErrNoRows {
+		return nil, ErrClientNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getClient %s: %v", clientID, err)
+	}
+	return client, nil
+}
+
+// ListClients returns a page of clients ordered by ID
+func (s *Store) ListClients(ctx context.Context, filter ClientFilter) (*ClientPage, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	filter.Limit, filter.Offset = clampPage(filter.Limit, filter.Offset)
+	where, args := filter.where()
+
+	page := &ClientPage{Clients: []ClientRecord{}, Limit: filter.Limit, Offset: filter.Offset}
+	if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM clients"+where, args...).Scan(&page.Total); err != nil {
+		return nil, fmt.Errorf("listClients: %v", err)
+	}
+
+	query := "SELECT " + clientColumns + " FROM clients" + where +
+		" ORDER BY client_id OFFSET :offset ROWS FETCH NEXT :limit ROWS ONLY"
+	args = append(args, sql.Named("offset", filter.Offset), sql.Named("limit", filter.Limit))
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("listClients: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		client, err := scanClientRecord(rows)
+		if err != nil {
+			return nil, fmt.Errorf("listClients: %v", err)
+		}
+		page.Clients = append(page.Clients, *client)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("listClients: %v", err)
+	}
+	return page, nil
+}
+
+// UpdateClient applies the non-nil fields of update and returns the result
+// Deleted clients must be restored before they can be changed
+func (s *Store) UpdateClient(ctx context.Context, clientID string, update ClientUpdate, actor Actor) (*ClientRecord, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if update.AllowedCIDRs != nil {
+		cidrs, err := canonicalCIDRs(*update.AllowedCIDRs)
+		if err != nil {
+			return nil, err
+		}
+		update.AllowedCIDRs = &cidrs
+	}
+	sets, args, err := update.assignments()
+	if err != nil {
+		return nil, err
+	}
+	for _, limit := range []*RateLimit{update.TokenRateLimit, update.ValidateRateLimit} {
+		if limit != nil && !limit.valid() {
+			return nil, ErrInvalidLimit
+		}
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	current, err := s.lockClient(ctx, tx, clientID)
+	if err != nil {
+		return nil, err
+	}
+	if current.DeletedAt != nil {
+		return nil, ErrClientDeleted
+	}
+	changes := update.changes(current)
+	if len(changes) == 0 && !update.RevokeTokens {
+		return current, nil
+	}
+	if !validWindow(pickTime(update.ValidFrom, current.ValidFrom), pickTime(update.ValidUntil, current.ValidUntil)) {
+		return nil, ErrInvalidWindow
+	}
+
+	if len(changes) > 0 {
+		query := "UPDATE clients SET " + strings.Join(append(sets, "updated_at = SYSTIMESTAMP"), ", ") +
+			" WHERE client_id = :client_id"
+		if _, err := tx.ExecContext(ctx, query, append(args, sql.Named("client_id", clientID))...); err != nil {
+			log.Error().Err(err).Str("client_id", clientID).Msg("Failed to update client")
+			return nil, fmt.Errorf("updateClient %s: %v", clientID, err)
+		}
+	}
+
+	revokedBefore := time.Now()
+	if update.RevokeTokens {
+		changes["tokens_revoked_before"] = FieldChange{New: revokedBefore}
+	}
+
+	updated, err := s.lockClient(ctx, tx, clientID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.commitClientChange(ctx, tx, clientID, ClientAuditUpdated, changes, actor); err != nil {
+		return nil, err
+	}
+
+	if update.RevokeTokens {
+		if err := s.revokeClientTokens(clientID, revokedBefore, actor); err != nil {
+			return nil, err
+		}
+	}
+	return updated, nil
+}
+
+// revokeClientTokens revokes every token issued to a client up to revokedBefore and tells every instance
+func (s *Store) revokeClientTokens(clientID string, revokedBefore time.Time, actor Actor) error {
+	entry := AuditEntry{Action: AuditClientRevokeTokens, ClientID: clientID,
+		Detail: "revoked_before=" + revokedBefore.UTC().Format(time.RFC3339Nano)}
+	if err := s.as.revokeClientTokens(clientID, revokedBefore); err != nil {
+		entry.Outcome, entry.Detail = AuditOutcomeFailure, err.Error()
+		s.as.auditActor(actor, entry)
+		return fmt.Errorf("revokeClientTokens %s: %v", clientID, err)
+	}
+	s.as.auditActor(actor, entry)
+	s.as.publishEvent(Event{
+		Type:          EventClientRevoked,
+		ClientID:      clientID,
+		RevokedBefore: revokedBefore,
+	})
+	return nil
+}
+
+// DeleteClient soft-deletes a client; it can no longer obtain tokens but keeps its history
+func (s *Store) DeleteClient(ctx context.Context, clientID string, actor Actor) (*ClientRecord, error) {
+	return s.setDeleted(ctx, clientID, true, actor)
+}
+
+// RestoreClient undoes a soft delete
+func (s *Store) RestoreClient(ctx context.Context, clientID string, actor Actor) (*ClientRecord, error) {
+	return s.setDeleted(ctx, clientID, false, actor)
+}
+
+// setDeleted sets or clears deleted_at; repeating either is a no-op
+func (s *Store) setDeleted(ctx context.Context, clientID string, deleted bool, actor Actor) (*ClientRecord, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	current, err := s.lockClient(ctx, tx, clientID)
+	if err != nil {
+		return nil, err
+	}
+	if (current.DeletedAt != nil) == deleted {
+		return current, nil
+	}
+
+	query := "UPDATE clients SET deleted_at = NULL, updated_at = SYSTIMESTAMP WHERE client_id = :client_id"
+	action := ClientAuditRestored
+	if deleted {
+		query = "UPDATE clients SET deleted_at = SYSTIMESTAMP, updated_at = SYSTIMESTAMP WHERE client_id = :client_id"
+		action = ClientAuditDeleted
+	}
+	if _, err := tx.ExecContext(ctx, query, sql.Named("client_id", clientID)); err != nil {
+		log.Error().Err(err).Str("client_id", clientID).Str("action", action).Msg("Failed to change client deletion state")
+		return nil, fmt.Errorf("%s %s: %v", action, clientID, err)
+	}
+
+	updated, err := s.lockClient(ctx, tx, clientID)
+	if err != nil {
+		return nil, err
+	}
+	changes := map[string]FieldChange{"deleted_at": {Old: current.DeletedAt, New: updated.DeletedAt}}
+	if err := s.commitClientChange(ctx, tx, clientID, action, changes, actor); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// ClientAudit returns a client's recorded changes, newest first
+func (s *Store) ClientAudit(ctx context.Context, clientID string, limit int) ([]ClientAuditEntry, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	limit, _ = clampPage(limit, 0)
+	query := `SELECT id, client_id, action, actor, request_id, remote_addr, changes, created_at
+		FROM client_audit WHERE client_id = :client_id
+		ORDER BY id DESC FETCH FIRST :limit ROWS ONLY`
+	rows, err := s.db.QueryContext(ctx, query, sql.Named("client_id", clientID), sql.Named("limit", limit))
+	if err != nil {
+		return nil, fmt.Errorf("clientAudit %s: %v", clientID, err)
+	}
+	defer rows.Close()
+
+	entries := []ClientAuditEntry{}
+	for rows.Next() {
+		var (
+			entry                      ClientAuditEntry
+			requestID, remote, changes sql.NullString
+		)
+		if err := rows.Scan(&entry.ID, &entry.ClientID, &entry.Action, &entry.Actor.Subject,
+			&requestID, &remote, &changes, &entry.CreatedAt); err != nil {
+			return nil, fmt.Errorf("clientAudit %s: %v", clientID, err)
+		}
+		entry.Actor.RequestID = requestID.String
+		entry.Actor.IP = remote.String
+		if changes.Valid && changes.String != "" {
+			if err := json.Unmarshal([]byte(changes.String), &entry.Changes); err != nil {
+				log.Warn().Err(err).Int64("audit_id", entry.ID).Msg("Unreadable client audit changes")
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// lockClient reads a client inside tx, locking the row until the transaction ends
+func (s *Store) lockClient(ctx context.Context, tx *sql.Tx, clientID string) (*ClientRecord, error) {
+	query := "SELECT " + clientColumns + " FROM clients WHERE client_id = :client_id FOR UPDATE"
+	client, err := scanClientRecord(tx.QueryRowContext(ctx, query, sql.Named("client_id", clientID)))
+	if err == sql.ErrNoRows {
+		return nil, ErrClientNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("lockClient %s: %v", clientID, err)
+	}
+	return client, nil
+}
+
+// commitClientChange records the audit entry, commits, and tells every instance to drop the cached client
+func (s *Store) commitClientChange(ctx context.Context, tx *sql.Tx, clientID, action string, changes map[string]FieldChange, actor Actor) error {
+	data, err := json.Marshal(changes)
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO client_audit (client_id, action, actor, request_id, remote_addr, changes)
+		VALUES (:client_id, :action, :actor, :request_id, :remote_addr, :changes)`
+	if _, err := tx.ExecContext(ctx, query,
+		sql.Named("client_id", clientID),
+		sql.Named("action", action),
+		sql.Named("actor", actor.Subject),
+		sql.Named("request_id", actor.RequestID),
+		sql.Named("remote_addr", actor.IP),
+		sql.Named("changes", string(data))); err != nil {
+		log.Error().Err(err).Str("client_id", clientID).Str("action", action).Msg("Failed to record client audit entry")
+		return fmt.Errorf("%s %s: %v", action, clientID, err)
+	}
+	if err := enqueueWebhook(ctx, tx, action, clientID, map[string]any{
+		"client_id": clientID,
+		"actor":     actor.Subject,
+		"changes":   changes,
+	}); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Error().Err(err).Str("client_id", clientID).Str("action", action).Msg("Failed to commit client change")
+		return err
+	}
+
+	log.Info().Str("client_id", clientID).Str("action", action).Str("actor", actor.Subject).Msg("Client changed")
+	s.as.auditActor(actor, AuditEntry{Action: action, ClientID: clientID, Detail: string(data)})
+	s.as.publishEvent(Event{Type: EventClientChanged, ClientID: clientID})
+	return nil
+}
+
+// ClearLockout lifts a client ID's or source IP's lockout on every running instance
+// Lockouts live in server memory, so this only announces the change; see ClientLockoutSubject
+func (s *Store) ClearLockout(subject string, actor Actor) {
+	log.Info().Str("subject", subject).Str("actor", actor.Subject).Msg("Clearing lockout")
+	s.as.auditActor(actor, AuditEntry{Action: AuditLockoutClear, Detail: subject})
+	s.as.publishEvent(Event{Type: EventLockoutEnded, Subject: subject})
+}
+
+// RevokeToken verifies a token and revokes it using the configured token mode
+func (s *Store) RevokeToken(ctx context.Context, tokenString string, actor Actor) (*Claims, error) {
+	claims, err := s.VerifyToken(ctx, tokenString)
+	if err != nil {
+		s.as.auditActor(actor, AuditEntry{Action: AuditTokenRevoke, Outcome: AuditOutcomeFailure, Detail: err.Error()})
+		return nil, err
+	}
+
+	revokedToken := RevokedToken{
+		ClientID:  claims.ClientID,
+		TokenID:   claims.TokenID,
+		RevokedAt: time.Now(),
+		ExpiresAt: claims.ExpiresAt.Time,
+	}
+	entry := AuditEntry{Action: AuditTokenRevoke, ClientID: claims.ClientID, TokenID: claims.TokenID}
+	if err := s.as.revokeToken(revokedToken); err != nil {
+		entry.Outcome, entry.Detail = AuditOutcomeFailure, err.Error()
+		s.as.auditActor(actor, entry)
+		return nil, err
+	}
+	s.as.auditActor(actor, entry)
+
+	s.as.publishEvent(Event{
+		Type:      EventTokenRevoked,
+		ClientID:  claims.ClientID,
+		TokenID:   claims.TokenID,
+		ExpiresAt: claims.ExpiresAt.Time,
+	})
+	return claims, nil
+}
+
+// VerifyToken checks a token's signature, claims and revocation status as /validate does
+func (s *Store) VerifyToken(ctx context.Context, tokenString string) (*Claims, error) {
+	if s.as.keyRing != nil {
+		if err := s.as.reloadKeyRing(); err != nil {
+			return nil, fmt.Errorf("failed to load signing keys: %w", err)
+		}
+	}
+	if err := s.as.loadRevocationWatermarks(); err != nil {
+		return nil, fmt.Errorf("failed to load revocation watermarks: %w", err)
+	}
+	return s.as.validateJWT(ctx, tokenString)
+}
+
+// RotateSigningKey retires the active ES256 key in favour of a new one
+func (s *Store) RotateSigningKey(ctx context.Context, actor Actor) (*SigningKeyInfo, error) {
+	info, err := s.as.rotateSigningKey()
+	if err != nil {
+		s.as.auditActor(actor, AuditEntry{Action: AuditKeyRotate, Outcome: AuditOutcomeFailure, Detail: err.Error()})
+		return nil, err
+	}
+	s.as.auditActor(actor, AuditEntry{Action: AuditKeyRotate, Detail: "key " + info.KeyID})
+	return info, nil
+}
+
+// scanClientRecord reads a client row selected with clientColumns
+func scanClientRecord(row interface{ Scan(...any) error }) (*ClientRecord, error) {
+	var (
+		client    ClientRecord
+		name      sql.NullString
+		scopes    sql.NullString
+		active    sql.NullInt64
+		createdAt sql.NullTime
+		updatedAt sql.NullTime
+		deletedAt sql.NullTime
+		validFrom sql.NullTime
+		validTo   sql.NullTime
+
+		tokenRate, validateRate   sql.NullFloat64
+		tokenBurst, validateBurst sql.NullInt64
+		cidrs                     sql.NullString
+		cidrsOnUse, networkClaim  sql.NullInt64
+	)
+	if err := row.Scan(&client.ClientID, &name, &client.AccessTokenTTL, &scopes, &active, &createdAt, &updatedAt, &deletedAt,
+		&validFrom, &validTo, &tokenRate, &tokenBurst, &validateRate, &validateBurst, &cidrs, &cidrsOnUse, &networkClaim); err != nil {
+		return nil, err
+	}
+
+	var err error
+	if client.AllowedScopes, err = parseStringArray(scopes.String); err != nil {
+		return nil, err
+	}
+	client.AllowedScopes = nonNilStrings(client.AllowedScopes)
+	if client.AllowedCIDRs, err = parseStringArray(cidrs.String); err != nil {
+		return nil, err
+	}
+	client.AllowedCIDRs = nonNilStrings(client.AllowedCIDRs)
+	client.EnforceCIDRsOnUse = cidrsOnUse.Int64 == 1
+	client.NetworkClaim = networkClaim.Int64 == 1
+	client.Name = name.String
+	client.Active = !active.Valid || active.Int64 == 1
+	client.CreatedAt = createdAt.Time
+	client.UpdatedAt = updatedAt.Time
+	if deletedAt.Valid {
+		client.DeletedAt = &deletedAt.Time
+	}
+	if validFrom.Valid {
+		client.ValidFrom = &validFrom.Time
+	}
+	if validTo.Valid {
+		client.ValidUntil = &validTo.Time
+	}
+	client.TokenRateLimit = scanRateLimit(tokenRate, tokenBurst)
+	client.ValidateRateLimit = scanRateLimit(validateRate, validateBurst)
+	return &client, nil
+}
+
+// where builds the WHERE clause and arguments for a listing
+func (f ClientFilter) where() (string, []any) {
+	var (
+		conditions []string
+		args       []any
+	)
+	if !f.IncludeDeleted {
+		conditions = append(conditions, "deleted_at IS NULL")
+	}
+	if f.Active != nil {
+		conditions = append(conditions, "active = :active")
+		args = append(args, sql.Named("active", boolToInt(*f.Active)))
+	}
+	if f.Search != "" {
+		conditions = append(conditions, "(LOWER(client_id) LIKE :search OR LOWER(client_name) LIKE :search)")
+		args = append(args, sql.Named("search", "%"+strings.ToLower(f.Search)+"%"))
+	}
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// assignments builds the SET clauses and arguments for an update
+func (u ClientUpdate) assignments() ([]string, []any, error) {
+	var (
+		sets []string
+		args []any
+	)
+	if u.Name != nil {
+		sets = append(sets, "client_name = :client_name")
+		args = append(args, sql.Named("client_name", *u.Name))
+	}
+	if u.AccessTokenTTL != nil {
+		sets = append(sets, "access_token_ttl = :access_token_ttl")
+		args = append(args, sql.Named("access_token_ttl", *u.AccessTokenTTL))
+	}
+	if u.AllowedScopes != nil {
+		scopes, err := json.Marshal(nonNilStrings(*u.AllowedScopes))
+		if err != nil {
+			return nil, nil, err
+		}
+		sets = append(sets, "allowed_scopes = :allowed_scopes")
+		args = append(args, sql.Named("allowed_scopes", string(scopes)))
+	}
+	if u.Active != nil {
+		sets = append(sets, "active = :active")
+		args = append(args, sql.Named("active", boolToInt(*u.Active)))
+	}
+	if u.ValidFrom != nil {
+		sets = append(sets, "valid_from = :valid_from")
+		args = append(args, sql.Named("valid_from", nullTime(*u.ValidFrom)))
+	}
+	if u.ValidUntil != nil {
+		sets = append(sets, "valid_until = :valid_until")
+		args = append(args, sql.Named("valid_until", nullTime(*u.ValidUntil)))
+	}
+	if u.TokenRateLimit != nil {
+		sets = append(sets, "token_rate = :token_rate", "token_burst = :token_burst")
+		args = append(args, rateLimitArgs("token", u.TokenRateLimit)...)
+	}
+	if u.ValidateRateLimit != nil {
+		sets = append(sets, "validate_rate = :validate_rate", "validate_burst = :validate_burst")
+		args = append(args, rateLimitArgs("validate", u.ValidateRateLimit)...)
+	}
+	if u.AllowedCIDRs != nil {
+		cidrs, err := json.Marshal(nonNilStrings(*u.AllowedCIDRs))
+		if err != nil {
+			return nil, nil, err
+		}
+		sets = append(sets, "allowed_cidrs = :allowed_cidrs")
+		args = append(args, sql.Named("allowed_cidrs", string(cidrs)))
+	}
+	if u.EnforceCIDRsOnUse != nil {
+		sets = append(sets, "cidrs_on_use = :cidrs_on_use")
+		args = append(args, sql.Named("cidrs_on_use", boolToInt(*u.EnforceCIDRsOnUse)))
+	}
+	if u.NetworkClaim != nil {
+		sets = append(sets, "network_claim = :network_claim")
+		args = append(args, sql.Named("network_claim", boolToInt(*u.NetworkClaim)))
+	}
+	return sets, args, nil
+}
+
+// changes lists the fields the update would actually change on current
+func (u ClientUpdate) changes(current *ClientRecord) map[string]FieldChange {
+	changes := make(map[string]FieldChange)
+	if u.Name != nil && *u.Name != current.Name {
+		changes["name"] = FieldChange{Old: current.Name, New: *u.Name}
+	}
+	if u.AccessTokenTTL != nil && *u.AccessTokenTTL != current.AccessTokenTTL {
+		changes["access_token_ttl"] = FieldChange{Old: current.AccessTokenTTL, New: *u.AccessTokenTTL}
+	}
+	if u.AllowedScopes != nil && !slices.Equal(*u.AllowedScopes, current.AllowedScopes) {
+		changes["allowed_scopes"] = FieldChange{Old: current.AllowedScopes, New: nonNilStrings(*u.AllowedScopes)}
+	}
+	if u.Active != nil && *u.Active != current.Active {
+		changes["active"] = FieldChange{Old: current.Active, New: *u.Active}
+	}
+	if u.ValidFrom != nil && !sameTime(pickTime(u.ValidFrom, nil), current.ValidFrom) {
+		changes["valid_from"] = FieldChange{Old: current.ValidFrom, New: pickTime(u.ValidFrom, nil)}
+	}
+	if u.ValidUntil != nil && !sameTime(pickTime(u.ValidUntil, nil), current.ValidUntil) {
+		changes["valid_until"] = FieldChange{Old: current.ValidUntil, New: pickTime(u.ValidUntil, nil)}
+	}
+	if u.TokenRateLimit != nil && !sameRateLimit(pickRateLimit(u.TokenRateLimit, nil), current.TokenRateLimit) {
+		changes["token_rate_limit"] = FieldChange{Old: current.TokenRateLimit, New: pickRateLimit(u.TokenRateLimit, nil)}
+	}
+	if u.ValidateRateLimit != nil && !sameRateLimit(pickRateLimit(u.ValidateRateLimit, nil), current.ValidateRateLimit) {
+		changes["validate_rate_limit"] = FieldChange{Old: current.ValidateRateLimit, New: pickRateLimit(u.ValidateRateLimit, nil)}
+	}
+	if u.AllowedCIDRs != nil && !slices.Equal(nonNilStrings(*u.AllowedCIDRs), current.AllowedCIDRs) {
+		changes["allowed_cidrs"] = FieldChange{Old: current.AllowedCIDRs, New: nonNilStrings(*u.AllowedCIDRs)}
+	}
+	if u.EnforceCIDRsOnUse != nil && *u.EnforceCIDRsOnUse != current.EnforceCIDRsOnUse {
+		changes["enforce_cidrs_on_use"] = FieldChange{Old: current.EnforceCIDRsOnUse, New: *u.EnforceCIDRsOnUse}
+	}
+	if u.NetworkClaim != nil && *u.NetworkClaim != current.NetworkClaim {
+		changes["network_claim"] = FieldChange{Old: current.NetworkClaim, New: *u.NetworkClaim}
+	}
+	return changes
+}
+
+// clampPage applies the default and maximum page size
+func clampPage(limit, offset int) (int, int) {
+	if limit <= 0 {
+		limit = DefaultClientPageSize
+	}
+	return min(limit, MaxClientPageSize), max(offset, 0)
+}
+
+// pickTime resolves an optional time update against the current value; a zero update clears it
+func pickTime(update, current *time.Time) *time.Time {
+	switch {
+	case update == nil:
+		return current
+	case update.IsZero():
+		return nil
+	}
+	return update
+}
+
+// sameTime compares optional times
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+// validWindow reports whether an optional validity window is non-empty
+func validWindow(from, until *time.Time) bool {
+	return from == nil || until == nil || from.Before(*until)
+}
+
+// optionalTime binds an optional time, absent or zero as NULL
+func optionalTime(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+	return nullTime(*t)
+}
+
+// pickRateLimit resolves an optional rate limit update against the current value; a zero rate clears it
+func pickRateLimit(update, current *RateLimit) *RateLimit {
+	switch {
+	case update == nil:
+		return current
+	case !update.Enabled():
+		return nil
+	}
+	return update
+}
+
+// sameRateLimit compares optional rate limits
+func sameRateLimit(a, b *RateLimit) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// rateLimitArgs binds an override's rate and burst columns, both NULL when it is cleared
+func rateLimitArgs(prefix string, limit *RateLimit) []any {
+	var (
+		rate  sql.NullFloat64
+		burst sql.NullInt64
+	)
+	if limit := pickRateLimit(limit, nil); limit != nil {
+		rate = sql.NullFloat64{Float64: limit.Rate, Valid: true}
+		burst = sql.NullInt64{Int64: int64(limit.Burst), Valid: limit.Burst > 0}
+	}
+	return []any{sql.Named(prefix+"_rate", rate), sql.Named(prefix+"_burst", burst)}
+}
+
+// scanRateLimit reads an override's columns; a NULL rate means there is none
+func scanRateLimit(rate sql.NullFloat64, burst sql.NullInt64) *RateLimit {
+	if !rate.Valid {
+		return nil
+	}
+	return &RateLimit{Rate: rate.Float64, Burst: int(burst.Int64)}
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// nonNilStrings keeps empty lists encoding as [] rather than null
+func nonNilStrings(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
@@ -0,0 +1,164 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMigrations_Ordered(t *testing.T) {
+	if len(Migrations) == 0 || Migrations[0].Version != baselineVersion {
+		t.Fatal("Expected the baseline to be the first migration")
+	}
+	for i, m := range Migrations {
+		if i > 0 && m.Version <= Migrations[i-1].Version {
+			t.Errorf("Migration %d (%s) is out of order", m.Version, m.Name)
+		}
+		if len(m.Statements) == 0 {
+			t.Errorf("Migration %d (%s) has no statements", m.Version, m.Name)
+		}
+		for _, statement := range m.Statements {
+			if strings.HasSuffix(strings.TrimSpace(statement), ";") {
+				t.Errorf("Migration %d statement must not end with ';': %.40s", m.Version, statement)
+			}
+		}
+	}
+}
+
+func TestMigrations_BaselineIsOriginalSchema(t *testing.T) {
+	var tables []string
+	for _, statement := range Migrations[0].Statements {
+		if name, ok := strings.CutPrefix(statement, "CREATE TABLE "); ok {
+			tables = append(tables, strings.Fields(name)[0])
+		}
+	}
+	if got := strings.Join(tables, " "); got != "clients tokens revoked_tokens endpoints" {
+		t.Errorf("Expected the baseline to create only the original tables, got %s", got)
+	}
+
+	// Tables added after the baseline are recorded rather than recreated on adopted databases
+	for _, m := range Migrations[1:] {
+		if m.Table != "" && !strings.HasPrefix(m.Statements[0], "CREATE TABLE "+m.Table+" (") {
+			t.Errorf("Migration %d (%s) must start by creating %s", m.Version, m.Name, m.Table)
+		}
+	}
+}
+
+func TestClientUpdate_Assignments(t *testing.T) {
+	name := "Billing"
+	active := false
+	scopes := []string{}
+
+	sets, args, err := ClientUpdate{Name: &name, Active: &active, AllowedScopes: &scopes}.assignments()
+	if err != nil {
+		t.Fatalf("assignments failed: %v", err)
+	}
+	want := "client_name = :client_name, allowed_scopes = :allowed_scopes, active = :active"
+	if got := strings.Join(sets, ", "); got != want || len(args) != 3 {
+		t.Errorf("Expected %q with 3 args, got %q with %d", want, got, len(args))
+	}
+
+	if sets, _, _ := (ClientUpdate{}).assignments(); len(sets) != 0 {
+		t.Errorf("Expected empty update to change nothing, got %v", sets)
+	}
+}
+
+func TestClientUpdate_Changes(t *testing.T) {
+	current := &ClientRecord{Name: "Billing", AccessTokenTTL: 600, AllowedScopes: []string{"a"}, Active: true}
+	name := "Billing"
+	ttl := int32(900)
+	scopes := []string{"a"}
+
+	changes := ClientUpdate{Name: &name, AccessTokenTTL: &ttl, AllowedScopes: &scopes}.changes(current)
+	if len(changes) != 1 || changes["access_token_ttl"].Old != int32(600) || changes["access_token_ttl"].New != int32(900) {
+		t.Errorf("Expected only the TTL change to be recorded, got %+v", changes)
+	}
+}
+
+func TestClientFilter_Where(t *testing.T) {
+	where, args := ClientFilter{}.where()
+	if where != " WHERE deleted_at IS NULL" || len(args) != 0 {
+		t.Errorf("Expected deleted clients to be hidden by default, got %q %v", where, args)
+	}
+
+	active := true
+	where, args = ClientFilter{Active: &active, Search: "Bill", IncludeDeleted: true}.where()
+	if strings.Contains(where, "deleted_at") || len(args) != 2 {
+		t.Errorf("Unexpected filter %q with %d args", where, len(args))
+	}
+}
+
+func TestClampPage(t *testing.T) {
+	tests := []struct {
+		limit, offset         int
+		wantLimit, wantOffset int
+	}{
+		{0, 0, DefaultClientPageSize, 0},
+		{10, -5, 10, 0},
+		{MaxClientPageSize + 1, 20, MaxClientPageSize, 20},
+	}
+	for _, tt := range tests {
+		limit, offset := clampPage(tt.limit, tt.offset)
+		if limit != tt.wantLimit || offset != tt.wantOffset {
+			t.Errorf("clampPage(%d, %d) = %d, %d; want %d, %d", tt.limit, tt.offset, limit, offset, tt.wantLimit, tt.wantOffset)
+		}
+	}
+}
+
+func TestClientUpdate_ValidityWindow(t *testing.T) {
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	until := from.Add(30 * 24 * time.Hour)
+	current := &ClientRecord{ValidFrom: &from}
+
+	// A zero time clears the bound
+	changes := ClientUpdate{ValidFrom: &time.Time{}, ValidUntil: &until}.changes(current)
+	if changes["valid_from"].New != (*time.Time)(nil) || changes["valid_until"].New != &until {
+		t.Errorf("Expected valid_from cleared and valid_until set, got %+v", changes)
+	}
+	if len(ClientUpdate{ValidFrom: &from}.changes(current)) != 0 {
+		t.Error("Expected an unchanged valid_from to record no change")
+	}
+
+	if !validWindow(&from, &until) || validWindow(&until, &from) || !validWindow(nil, &from) {
+		t.Error("Unexpected validWindow result")
+	}
+}
+
+func TestClientUpdate_RateLimits(t *testing.T) {
+	current := &ClientRecord{TokenRateLimit: &RateLimit{Rate: 5, Burst: 10}}
+	update := ClientUpdate{TokenRateLimit: &RateLimit{}, ValidateRateLimit: &RateLimit{Rate: 100}}
+
+	sets, args, _ := update.assignments()
+	if len(sets) != 4 || len(args) != 4 {
+		t.Errorf("Expected rate and burst columns for both limits, got %v", sets)
+	}
+
+	// A zero rate clears the override
+	changes := update.changes(current)
+	if changes["token_rate_limit"].New != (*RateLimit)(nil) || *changes["validate_rate_limit"].New.(*RateLimit) != (RateLimit{Rate: 100}) {
+		t.Errorf("Expected the token limit cleared and the validate limit set, got %+v", changes)
+	}
+	if len(ClientUpdate{TokenRateLimit: &RateLimit{Rate: 5, Burst: 10}}.changes(current)) != 0 {
+		t.Error("Expected an unchanged limit to record no change")
+	}
+}
+
+func TestClientUpdate_Networks(t *testing.T) {
+	current := &ClientRecord{AllowedCIDRs: []string{"10.20.0.0/16"}}
+	cidrs := []string{}
+	claim := true
+	update := ClientUpdate{AllowedCIDRs: &cidrs, NetworkClaim: &claim}
+
+	sets, args, _ := update.assignments()
+	if len(sets) != 2 || len(args) != 2 {
+		t.Errorf("Expected the allowlist and network claim columns, got %v", sets)
+	}
+
+	changes := update.changes(current)
+	if len(changes["allowed_cidrs"].New.([]string)) != 0 || changes["network_claim"].New != true {
+		t.Errorf("Expected the allowlist cleared and the claim turned on, got %+v", changes)
+	}
+	if _, changed := changes["enforce_cidrs_on_use"]; changed {
+		t.Error("Expected an absent field to record no change")
+	}
+}
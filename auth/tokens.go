@@ -1,13 +1,16 @@
 package auth
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/hex"
 	"fmt"
+	"net/netip"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Generate random string
@@ -30,10 +33,14 @@ func generateRandomString(length int) string {
 // }
 
 // Generate JWT token with cached client scopes and async token persistence
-func (as *authServer) generateJWT(clientID string) (string, string, error) {
+// A valid network is carried in the token, which is then only accepted from that network
+func (as *authServer) generateJWT(ctx context.Context, clientID string, network netip.Prefix) (_ string, _ string, err error) {
+	ctx, span := tracer().Start(ctx, "authServer.generateJWT", trace.WithAttributes(attrClientID.String(clientID)))
+	defer endSpan(span, &err)
+
 	// ✅ Try cache first for client scopes (avoids DB query ~99% of time)
 	var scopes []string
-	if client, found := as.clientCache.Get(clientID); found {
+	if client, found := as.clientCache.Get(ctx, clientID); found {
 		if client == nil {
 			log.Error().Str("client_id", clientID).Msg("Cache returned nil client")
 			return "", "", fmt.Errorf("cached client is nil")
@@ -41,7 +48,6 @@ func (as *authServer) generateJWT(clientID string) (string, string, error) {
 		scopes = client.AllowedScopes
 	} else {
 		// Cache miss - fetch from database
-		var err error
 		scopes, err = as.getClientScopes(clientID)
 		if err != nil {
 			log.Error().Err(err).Str("client_id", clientID).Msg("Failed to fetch client scopes")
@@ -50,6 +56,7 @@ func (as *authServer) generateJWT(clientID string) (string, string, error) {
 	}
 
 	tokenID := generateRandomString(16)
+	span.SetAttributes(attrTokenID.String(tokenID))
 	now := time.Now()
 	expiresAt := now.Add(time.Minute * 2)
 
@@ -65,8 +72,15 @@ func (as *authServer) generateJWT(clientID string) (string, string, error) {
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(as.jwtSecret)
+	if network.IsValid() {
+		claims.Network = network.String()
+	}
+
+	if AppConfig.JWT.Audience != "" {
+		claims.Audience = jwt.ClaimStrings{AppConfig.JWT.Audience}
+	}
+
+	tokenString, err := as.signToken(claims)
 	if err != nil {
 		log.Error().Err(err).Str("client_id", clientID).Msg("Failed to sign JWT token")
 		return "", "", err
@@ -82,20 +96,25 @@ func (as *authServer) generateJWT(clientID string) (string, string, error) {
 	}
 
 	// ✅ Queue token for batch insertion (non-blocking)
-	as.tokenBatcher.Add(tokenInfo)
+	// Stateless mode keeps no issuance ledger, so nothing is written
+	if !as.stateless {
+		as.tokenBatcher.Add(tokenInfo)
+	}
 
 	return tokenString, tokenID, nil
 }
 
 // Validate JWT token
-func (as *authServer) validateJWT(tokenString string) (*Claims, error) {
+func (as *authServer) validateJWT(ctx context.Context, tokenString string) (_ *Claims, err error) {
+	ctx, span := tracer().Start(ctx, "authServer.validateJWT")
+	defer endSpan(span, &err)
+
 	log.Debug().Msg("Validating JWT token signature and claims")
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (any, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return as.jwtSecret, nil
-	})
+	var options []jwt.ParserOption
+	if AppConfig.JWT.Audience != "" {
+		options = append(options, jwt.WithAudience(AppConfig.JWT.Audience))
+	}
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, as.verificationKey, options...)
 
 	if err != nil {
 		log.Warn().Err(err).Msg("JWT token parsing failed")
@@ -104,9 +123,10 @@ func (as *authServer) validateJWT(tokenString string) (*Claims, error) {
 
 	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
 		log.Debug().Str("client_id", claims.ClientID).Str("token_id", claims.TokenID).Msg("JWT token signature valid")
+		span.SetAttributes(attrClientID.String(claims.ClientID), attrTokenID.String(claims.TokenID))
 
-		// Check if token is revoked
-		revoked, err := as.isTokenRevoked(claims.TokenID)
+		// Check if token is revoked (ledger lookup or denylist, depending on mode)
+		revoked, err := as.checkRevoked(ctx, claims.TokenID)
 		if err != nil {
 			log.Warn().Err(err).Str("token_id", claims.TokenID).Msg("Failed to check token revocation status")
 			return nil, fmt.Errorf("error checking token revocation: %v", err)
@@ -115,6 +135,10 @@ func (as *authServer) validateJWT(tokenString string) (*Claims, error) {
 			log.Warn().Str("client_id", claims.ClientID).Str("token_id", claims.TokenID).Msg("Token has been revoked")
 			return nil, fmt.Errorf("token has been revoked")
 		}
+		if as.watermarks != nil && claims.IssuedAt != nil && as.watermarks.Revoked(claims.ClientID, claims.IssuedAt.Time) {
+			log.Warn().Str("client_id", claims.ClientID).Str("token_id", claims.TokenID).Msg("Token revoked by client-wide revocation")
+			return nil, fmt.Errorf("token has been revoked")
+		}
 		log.Debug().Str("client_id", claims.ClientID).Str("token_id", claims.TokenID).Msg("Token is valid and not revoked")
 		return claims, nil
 	}
@@ -122,3 +146,11 @@ func (as *authServer) validateJWT(tokenString string) (*Claims, error) {
 	log.Warn().Msg("JWT token validation failed - invalid token")
 	return nil, fmt.Errorf("invalid token")
 }
+
+// checkRevoked looks up revocation status using the configured token mode
+func (as *authServer) checkRevoked(ctx context.Context, tokenID string) (bool, error) {
+	if as.stateless {
+		return as.isTokenDenylisted(ctx, tokenID)
+	}
+	return as.isTokenRevoked(ctx, tokenID)
+}
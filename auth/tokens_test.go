@@ -1,6 +1,8 @@
 package auth
 
 import (
+	"context"
+	"net/netip"
 	"testing"
 	"time"
 )
@@ -124,3 +126,29 @@ func isHexChar(c rune) bool {
 		(c >= 'a' && c <= 'f') ||
 		(c >= 'A' && c <= 'F')
 }
+
+func TestGenerateJWT_StatelessSkipsBatchWriter(t *testing.T) {
+	ctx, cancel := createTestContextFunc()
+	defer cancel()
+
+	cache := NewClientCache(time.Minute, 10)
+	defer cache.Stop()
+	cache.Set("test-client", &Clients{ClientID: "test-client", AllowedScopes: []string{"read"}})
+
+	// tokenBatcher is nil: any attempt to persist the token would panic
+	server := &authServer{
+		jwtSecret:   []byte("test-secret"),
+		ctx:         ctx,
+		cancel:      cancel,
+		clientCache: cache,
+		stateless:   true,
+	}
+
+	tokenString, tokenID, err := server.generateJWT(context.Background(), "test-client", netip.Prefix{})
+	if err != nil {
+		t.Fatalf("generateJWT failed: %v", err)
+	}
+	if tokenString == "" || tokenID == "" {
+		t.Errorf("Expected token and token ID, got %q and %q", tokenString, tokenID)
+	}
+}
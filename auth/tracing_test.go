@@ -0,0 +1,116 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const testTraceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+// recordSpans sends the server's spans to an in-memory exporter for the rest of the test
+func recordSpans(t *testing.T) *tracetest.InMemoryExporter {
+	exporter := tracetest.NewInMemoryExporter()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return exporter
+}
+
+func TestTracingMiddleware_ContinuesTraceparent(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	exporter := recordSpans(t)
+	cache := NewClientCache(time.Minute, 10)
+	defer cache.Stop()
+
+	router := gin.New()
+	router.Use(TracingMiddleware(), LoggingMiddleware())
+	router.GET("/clients/:id", func(c *gin.Context) {
+		cache.Get(c.Request.Context(), c.Param("id"))
+		c.Status(http.StatusNoContent)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/clients/batch", nil)
+	req.Header.Set("traceparent", testTraceparent)
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	cacheSpan, serverSpan := spans[0], spans[1]
+
+	if serverSpan.Name != "GET /clients/:id" || serverSpan.SpanKind != trace.SpanKindServer {
+		t.Errorf("unexpected server span %q (%v)", serverSpan.Name, serverSpan.SpanKind)
+	}
+	if got := serverSpan.SpanContext.TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("server span did not continue the caller's trace, got trace ID %s", got)
+	}
+	if got := serverSpan.Parent.SpanID().String(); got != "00f067aa0ba902b7" {
+		t.Errorf("server span parent = %s, want the traceparent span", got)
+	}
+
+	if cacheSpan.Name != "ClientCache.Get" {
+		t.Errorf("unexpected child span %q", cacheSpan.Name)
+	}
+	if cacheSpan.Parent.SpanID() != serverSpan.SpanContext.SpanID() {
+		t.Error("cache span should be a child of the request span")
+	}
+}
+
+func TestWithTraceIDs(t *testing.T) {
+	var buf bytes.Buffer
+	logger := withTraceIDs(zerolog.New(&buf).With(), context.Background()).Logger()
+	logger.Info().Msg("untraced")
+	if strings.Contains(buf.String(), "trace_id") {
+		t.Errorf("untraced request logged a trace ID: %s", buf.String())
+	}
+
+	buf.Reset()
+	ctx := traceContext.Extract(context.Background(), propagation.MapCarrier{"traceparent": testTraceparent})
+	logger = withTraceIDs(zerolog.New(&buf).With(), ctx).Logger()
+	logger.Info().Msg("traced")
+	if !strings.Contains(buf.String(), `"trace_id":"4bf92f3577b34da6a3ce929d0e0e4736"`) ||
+		!strings.Contains(buf.String(), `"span_id":"00f067aa0ba902b7"`) {
+		t.Errorf("expected trace and span IDs in log line, got %s", buf.String())
+	}
+}
+
+func TestStartTracing_FileExporter(t *testing.T) {
+	previous := otel.GetTracerProvider()
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	path := filepath.Join(t.TempDir(), "traces.json")
+	stop, err := startTracing(context.Background(), tracing{Exporter: TracingExporterFile, Path: path, SampleRatio: 1})
+	if err != nil {
+		t.Fatalf("startTracing failed: %v", err)
+	}
+
+	_, span := tracer().Start(context.Background(), "TokenBatchWriter.flush")
+	span.End()
+	if err := stop(context.Background()); err != nil {
+		t.Fatalf("stopping tracing failed: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read trace file: %v", err)
+	}
+	if !strings.Contains(string(data), `"Name":"TokenBatchWriter.flush"`) {
+		t.Errorf("span missing from trace file: %s", data)
+	}
+}
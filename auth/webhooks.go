@@ -0,0 +1,832 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+// Webhook event types; client and secret changes use their client audit actions, e.g. client.created
+const (
+	WebhookTokenRevoked        = "token.revoked"
+	WebhookClientTokensRevoked = "client.tokens_revoked"
+	WebhookLockout             = "lockout.triggered"
+)
+
+// WebhookEventTypes lists every event a subscription can filter on
+var WebhookEventTypes = []string{
+	ClientAuditCreated, ClientAuditUpdated, ClientAuditDeleted, ClientAuditRestored,
+	ClientAuditSecretAdded, ClientAuditSecretPromoted, ClientAuditSecretExpired,
+	WebhookTokenRevoked, WebhookClientTokensRevoked, WebhookLockout,
+}
+
+// cloudEventTypePrefix namespaces webhook events as CloudEvents types, e.g. auth-server.token.revoked
+const cloudEventTypePrefix = "auth-server."
+
+// Webhook delivery states
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliveryDelivered = "delivered"
+	WebhookDeliveryFailed    = "failed"
+)
+
+// WebhookSignatureHeader carries "t=<unix seconds>,v1=<hex HMAC-SHA256 of "<t>.<body>">" keyed with the subscription secret
+const WebhookSignatureHeader = "Webhook-Signature"
+
+// Webhook worker limits
+const (
+	webhookBatchSize     = 100
+	webhookWorkers       = 4
+	webhookMaxErrorChars = 1000
+)
+
+var (
+	ErrWebhookNotFound = errors.New("webhook subscription not found")
+	ErrInvalidWebhook  = errors.New("webhook url must be an absolute http or https URL")
+	ErrBadSignature    = errors.New("webhook signature does not match")
+)
+
+// CloudEvent is a webhook payload in the CloudEvents 1.0 structured JSON format
+type CloudEvent struct {
+	SpecVersion     string          `json:"specversion"`
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Subject         string          `json:"subject,omitempty"` // Client ID, or the lockout subject
+	Time            time.Time       `json:"time"`
+	DataContentType string          `json:"datacontenttype"`
+	Data            json.RawMessage `json:"data"`
+}
+
+// WebhookSubscription sends matching events to a URL
+type WebhookSubscription struct {
+	ID        int64     `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`              // Event types or prefixes such as client.*; empty means every event
+	ClientID  string    `json:"client_id,omitempty"` // Only events about this client; empty means every client
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// matches reports whether the subscription wants an event of eventType about subject
+func (s *WebhookSubscription) matches(eventType, subject string) bool {
+	if s.ClientID != "" && s.ClientID != subject {
+		return false
+	}
+	if len(s.Events) == 0 {
+		return true
+	}
+	for _, filter := range s.Events {
+		if filter == eventType || filter == "*" {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(filter, "*"); ok && strings.HasPrefix(eventType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery is one event's delivery to one subscription
+type WebhookDelivery struct {
+	ID             int64      `json:"id"`
+	SubscriptionID int64      `json:"subscription_id"`
+	EventID        string     `json:"event_id"`
+	EventType      string     `json:"event_type"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	NextAttemptAt  *time.Time `json:"next_attempt_at,omitempty"`
+	LastStatus     int        `json:"last_status,omitempty"` // HTTP status of the last attempt
+	LastError      string     `json:"last_error,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+}
+
+// enqueueWebhook adds an event to the webhook outbox through tx
+// Written in the transaction making the change, the event is sent if and only if the change commits
+func enqueueWebhook(ctx context.Context, tx interface {
+	ExecContext(context.Context, string, ...any) (sql.Result, error)
+}, eventType, subject string, data any) error {
+	if !AppConfig.Webhooks.Enabled {
+		return nil
+	}
+
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	event := CloudEvent{
+		SpecVersion:     "1.0",
+		ID:              uuid.New().String(),
+		Source:          AppConfig.Webhooks.Source,
+		Type:            cloudEventTypePrefix + eventType,
+		Subject:         subject,
+		Time:            time.Now().UTC(),
+		DataContentType: "application/json",
+		Data:            encoded,
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO webhook_outbox (event_id, event_type, subject, payload)
+		VALUES (:event_id, :event_type, :subject, :payload)`
+	if _, err := tx.ExecContext(ctx, query,
+		sql.Named("event_id", event.ID),
+		sql.Named("event_type", eventType),
+		sql.Named("subject", subject),
+		sql.Named("payload", string(payload))); err != nil {
+		log.Error().Err(err).Str("event_type", eventType).Str("subject", subject).Msg("Failed to add webhook event to outbox")
+		return fmt.Errorf("enqueueWebhook %s: %v", eventType, err)
+	}
+	return nil
+}
+
+// enqueueLockoutWebhook announces a lockout; lockouts live in memory, so there is no transaction to join
+// Client lockouts have the client ID as their subject so client filters apply to them
+func (as *authServer) enqueueLockoutWebhook(subject string, until time.Time) {
+	if !AppConfig.Webhooks.Enabled || as.db == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(as.ctx, 5*time.Second)
+	defer cancel()
+
+	eventSubject := subject
+	if clientID, ok := strings.CutPrefix(subject, lockoutClientPrefix); ok {
+		eventSubject = clientID
+	}
+	if err := enqueueWebhook(ctx, as.db, WebhookLockout, eventSubject, map[string]any{
+		"subject":      subject,
+		"locked_until": until,
+	}); err != nil {
+		log.Error().Err(err).Str("subject", subject).Msg("Failed to queue lockout webhook")
+	}
+}
+
+// webhookMAC is the HMAC-SHA256 of "<timestamp>.<body>"
+func webhookMAC(secret string, timestamp int64, body []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return mac.Sum(nil)
+}
+
+// signWebhook returns the signature header value for body sent at timestamp
+func signWebhook(secret string, timestamp int64, body []byte) string {
+	return fmt.Sprintf("t=%d,v1=%s", timestamp, hex.EncodeToString(webhookMAC(secret, timestamp, body)))
+}
+
+// VerifyWebhookSignature checks a Webhook-Signature header against the raw request body
+// Signatures older than tolerance are refused so captured requests can't be replayed later
+func VerifyWebhookSignature(secret, header string, body []byte, tolerance time.Duration) error {
+	var (
+		timestamp int64
+		signature []byte
+	)
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			timestamp, _ = strconv.ParseInt(value, 10, 64)
+		case "v1":
+			signature, _ = hex.DecodeString(value)
+		}
+	}
+	if timestamp == 0 || len(signature) == 0 {
+		return ErrBadSignature
+	}
+	if age := time.Since(time.Unix(timestamp, 0)); age > tolerance || age < -tolerance {
+		return fmt.Errorf("%w: timestamp outside tolerance", ErrBadSignature)
+	}
+
+	if !hmac.Equal(signature, webhookMAC(secret, timestamp, body)) {
+		return ErrBadSignature
+	}
+	return nil
+}
+
+// webhookBackoff is how long to wait after the given number of failed attempts
+func webhookBackoff(attempts int) time.Duration {
+	base := time.Duration(AppConfig.Webhooks.BaseBackoff) * time.Second
+	limit := time.Duration(AppConfig.Webhooks.MaxBackoff) * time.Second
+	delay := base
+	for i := 1; i < attempts && delay < limit; i++ {
+		delay *= 2
+	}
+	return min(delay, limit)
+}
+
+// claimedDelivery is a due delivery leased to this instance
+type claimedDelivery struct {
+	id             int64
+	subscriptionID int64
+	attempts       int
+	url            string
+	secret         string
+	payload        string
+}
+
+// WebhookDispatcher sends outbox events to matching subscriptions
+// Every instance runs one; rows are claimed with SKIP LOCKED, so each event is fanned out and each delivery attempted by one instance
+type WebhookDispatcher struct {
+	authServer  *authServer
+	httpClient  *http.Client
+	lease       time.Duration
+	pollTick    *time.Ticker
+	cleanupTick *time.Ticker
+	done        chan struct{}
+	stopped     chan struct{}
+}
+
+// NewWebhookDispatcher creates a dispatcher and starts its background goroutine
+func NewWebhookDispatcher(as *authServer) *WebhookDispatcher {
+	cfg := AppConfig.Webhooks
+	timeout := time.Duration(cfg.Timeout) * time.Millisecond
+	wd := &WebhookDispatcher{
+		authServer: as,
+		httpClient: &http.Client{
+			Timeout: timeout,
+			// A redirect could point the signed payload anywhere; receivers must be configured with their final URL
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+		// Deliveries left by an instance that died mid-send become due again after the lease
+		lease:       2*timeout + time.Duration(cfg.PollInterval)*time.Millisecond,
+		pollTick:    time.NewTicker(time.Duration(cfg.PollInterval) * time.Millisecond),
+		cleanupTick: time.NewTicker(time.Hour),
+		done:        make(chan struct{}),
+		stopped:     make(chan struct{}),
+	}
+
+	go wd.backgroundDispatch()
+
+	log.Info().
+		Int("poll_interval_ms", cfg.PollInterval).
+		Int("max_attempts", cfg.MaxAttempts).
+		Msg("Webhook dispatcher initialized")
+	return wd
+}
+
+// backgroundDispatch fans out and delivers events on every tick until stopped
+func (wd *WebhookDispatcher) backgroundDispatch() {
+	defer close(wd.stopped)
+	for {
+		select {
+		case <-wd.done:
+			wd.pollTick.Stop()
+			wd.cleanupTick.Stop()
+			return
+		case <-wd.pollTick.C:
+			wd.dispatch()
+		case <-wd.cleanupTick.C:
+			wd.cleanup()
+		}
+	}
+}
+
+// dispatch turns new outbox events into deliveries, then attempts every due delivery
+func (wd *WebhookDispatcher) dispatch() {
+	if _, err := wd.fanOut(); err != nil {
+		log.Error().Err(err).Msg("Failed to fan out webhook events")
+	}
+
+	deliveries, err := wd.claimDue()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to claim due webhook deliveries")
+		return
+	}
+
+	var wg sync.WaitGroup
+	workers := make(chan struct{}, webhookWorkers)
+	for _, delivery := range deliveries {
+		workers <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() { <-workers; wg.Done() }()
+			status, err := wd.send(delivery)
+			wd.recordAttempt(delivery, status, err)
+		}()
+	}
+	wg.Wait()
+}
+
+// fanOut creates a delivery for every subscription matching each undispatched outbox event
+func (wd *WebhookDispatcher) fanOut() (int, error) {
+	as := wd.authServer
+	ctx, cancel := context.WithTimeout(as.ctx, 10*time.Second)
+	defer cancel()
+
+	subscriptions, err := listWebhooks(ctx, as.db)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := as.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// Oracle locks rows as they are fetched, so reading only a batch locks only that batch
+	rows, err := tx.QueryContext(ctx, `SELECT id, event_type, subject FROM webhook_outbox
+		WHERE dispatched_at IS NULL ORDER BY id FOR UPDATE SKIP LOCKED`)
+	if err != nil {
+		return 0, fmt.Errorf("fanOut: %v", err)
+	}
+	type outboxEvent struct {
+		id        int64
+		eventType string
+		subject   sql.NullString
+	}
+	var events []outboxEvent
+	for len(events) < webhookBatchSize && rows.Next() {
+		var event outboxEvent
+		if err := rows.Scan(&event.id, &event.eventType, &event.subject); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("fanOut: %v", err)
+		}
+		events = append(events, event)
+	}
+	rows.Close()
+	if len(events) == 0 {
+		return 0, nil
+	}
+
+	for _, event := range events {
+		for i := range subscriptions {
+			if !subscriptions[i].matches(event.eventType, event.subject.String) {
+				continue
+			}
+			if _, err := tx.ExecContext(ctx, `INSERT INTO webhook_deliveries (outbox_id, subscription_id, status, attempts, next_attempt_at)
+				VALUES (:outbox_id, :subscription_id, :status, 0, SYSTIMESTAMP)`,
+				sql.Named("outbox_id", event.id),
+				sql.Named("subscription_id", subscriptions[i].ID),
+				sql.Named("status", WebhookDeliveryPending)); err != nil {
+				return 0, fmt.Errorf("fanOut: %v", err)
+			}
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE webhook_outbox SET dispatched_at = SYSTIMESTAMP WHERE id = :id",
+			sql.Named("id", event.id)); err != nil {
+			return 0, fmt.Errorf("fanOut: %v", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	log.Debug().Int("events", len(events)).Msg("Webhook events fanned out")
+	return len(events), nil
+}
+
+// claimDue leases up to a batch of due deliveries to this instance
+func (wd *WebhookDispatcher) claimDue() ([]claimedDelivery, error) {
+	as := wd.authServer
+	ctx, cancel := context.WithTimeout(as.ctx, 10*time.Second)
+	defer cancel()
+
+	tx, err := as.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `SELECT d.id, d.subscription_id, d.attempts, s.url, s.secret, o.payload
+		FROM webhook_deliveries d
+		JOIN webhook_subscriptions s ON s.id = d.subscription_id
+		JOIN webhook_outbox o ON o.id = d.outbox_id
+		WHERE d.status = :status AND d.next_attempt_at <= SYSTIMESTAMP
+		ORDER BY d.next_attempt_at
+		FOR UPDATE OF d.next_attempt_at SKIP LOCKED`,
+		sql.Named("status", WebhookDeliveryPending))
+	if err != nil {
+		return nil, fmt.Errorf("claimDue: %v", err)
+	}
+	var deliveries []claimedDelivery
+	for len(deliveries) < webhookBatchSize && rows.Next() {
+		var d claimedDelivery
+		if err := rows.Scan(&d.id, &d.subscriptionID, &d.attempts, &d.url, &d.secret, &d.payload); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("claimDue: %v", err)
+		}
+		deliveries = append(deliveries, d)
+	}
+	rows.Close()
+
+	leaseUntil := time.Now().Add(wd.lease)
+	for _, d := range deliveries {
+		if _, err := tx.ExecContext(ctx, "UPDATE webhook_deliveries SET next_attempt_at = :lease_until WHERE id = :id",
+			sql.Named("lease_until", leaseUntil), sql.Named("id", d.id)); err != nil {
+			return nil, fmt.Errorf("claimDue: %v", err)
+		}
+	}
+	return deliveries, tx.Commit()
+}
+
+// send posts a delivery's event, returning the response status
+func (wd *WebhookDispatcher) send(d claimedDelivery) (int, error) {
+	body := []byte(d.payload)
+	req, err := http.NewRequestWithContext(wd.authServer.ctx, http.MethodPost, d.url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/cloudevents+json")
+	req.Header.Set("User-Agent", "auth-server-webhooks")
+	req.Header.Set(WebhookSignatureHeader, signWebhook(d.secret, time.Now().Unix(), body))
+
+	resp, err := wd.httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("endpoint answered %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// recordAttempt marks a delivery delivered, schedules its retry, or gives up after webhooks.max_attempts
+func (wd *WebhookDispatcher) recordAttempt(d claimedDelivery, status int, sendErr error) {
+	as := wd.authServer
+	ctx, cancel := context.WithTimeout(as.ctx, 5*time.Second)
+	defer cancel()
+
+	attempts := d.attempts + 1
+	logger := log.With().Int64("delivery_id", d.id).Int64("subscription_id", d.subscriptionID).Int("attempts", attempts).Logger()
+
+	var (
+		query string
+		args  = []any{sql.Named("id", d.id), sql.Named("attempts", attempts), sql.Named("last_status", status)}
+	)
+	switch {
+	case sendErr == nil:
+		query = `UPDATE webhook_deliveries SET status = :status, attempts = :attempts, last_status = :last_status,
+			last_error = NULL, next_attempt_at = NULL, delivered_at = SYSTIMESTAMP WHERE id = :id`
+		args = append(args, sql.Named("status", WebhookDeliveryDelivered))
+		logger.Debug().Msg("Webhook delivered")
+	case attempts >= AppConfig.Webhooks.MaxAttempts:
+		query = `UPDATE webhook_deliveries SET status = :status, attempts = :attempts, last_status = :last_status,
+			last_error = :last_error, next_attempt_at = NULL WHERE id = :id`
+		args = append(args, sql.Named("status", WebhookDeliveryFailed), sql.Named("last_error", truncate(sendErr.Error(), webhookMaxErrorChars)))
+		logger.Error().Err(sendErr).Msg("Webhook delivery failed, giving up")
+	default:
+		next := time.Now().Add(webhookBackoff(attempts))
+		query = `UPDATE webhook_deliveries SET attempts = :attempts, last_status = :last_status,
+			last_error = :last_error, next_attempt_at = :next_attempt_at WHERE id = :id`
+		args = append(args, sql.Named("last_error", truncate(sendErr.Error(), webhookMaxErrorChars)), sql.Named("next_attempt_at", next))
+		logger.Warn().Err(sendErr).Time("next_attempt_at", next).Msg("Webhook delivery failed, will retry")
+	}
+
+	if _, err := as.db.ExecContext(ctx, query, args...); err != nil {
+		// The lease runs out and the delivery is attempted again
+		logger.Error().Err(err).Msg("Failed to record webhook delivery attempt")
+	}
+}
+
+// cleanup removes finished deliveries and dispatched events older than webhooks.retention_hours
+func (wd *WebhookDispatcher) cleanup() {
+	as := wd.authServer
+	ctx, cancel := context.WithTimeout(as.ctx, 30*time.Second)
+	defer cancel()
+
+	cutoff := time.Now().Add(-time.Duration(AppConfig.Webhooks.Retention) * time.Hour)
+	if _, err := as.db.ExecContext(ctx, `DELETE FROM webhook_deliveries
+		WHERE status <> :status AND created_at < :cutoff`,
+		sql.Named("status", WebhookDeliveryPending), sql.Named("cutoff", cutoff)); err != nil {
+		log.Error().Err(err).Msg("Failed to purge old webhook deliveries")
+		return
+	}
+	if _, err := as.db.ExecContext(ctx, `DELETE FROM webhook_outbox o
+		WHERE o.dispatched_at < :cutoff
+		AND NOT EXISTS (SELECT 1 FROM webhook_deliveries d WHERE d.outbox_id = o.id)`,
+		sql.Named("cutoff", cutoff)); err != nil {
+		log.Error().Err(err).Msg("Failed to purge old webhook events")
+	}
+}
+
+// Stop stops the background goroutine, waiting for deliveries in progress
+func (wd *WebhookDispatcher) Stop() {
+	close(wd.done)
+	<-wd.stopped
+	log.Info().Msg("Webhook dispatcher stopped")
+}
+
+// truncate cuts s to at most n bytes
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return strings.ToValidUTF8(s[:n], "")
+}
+
+// nullTimePtr returns the time, or nil if it is NULL
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+// webhookColumns are selected in scanWebhook order
+const webhookColumns = "id, url, event_types, client_id, created_at"
+
+// scanWebhook reads a webhook_subscriptions row
+func scanWebhook(row interface{ Scan(...any) error }) (*WebhookSubscription, error) {
+	var (
+		sub      WebhookSubscription
+		events   sql.NullString
+		clientID sql.NullString
+	)
+	if err := row.Scan(&sub.ID, &sub.URL, &events, &clientID, &sub.CreatedAt); err != nil {
+		return nil, err
+	}
+	sub.ClientID = clientID.String
+	sub.Events = []string{}
+	if events.Valid && events.String != "" {
+		if err := json.Unmarshal([]byte(events.String), &sub.Events); err != nil {
+			return nil, fmt.Errorf("webhook %d: invalid event_types: %v", sub.ID, err)
+		}
+	}
+	return &sub, nil
+}
+
+// listWebhooks reads every subscription
+func listWebhooks(ctx context.Context, db *sql.DB) ([]WebhookSubscription, error) {
+	rows, err := db.QueryContext(ctx, "SELECT "+webhookColumns+" FROM webhook_subscriptions ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("listWebhooks: %v", err)
+	}
+	defer rows.Close()
+
+	subscriptions := []WebhookSubscription{}
+	for rows.Next() {
+		sub, err := scanWebhook(rows)
+		if err != nil {
+			return nil, fmt.Errorf("listWebhooks: %v", err)
+		}
+		subscriptions = append(subscriptions, *sub)
+	}
+	return subscriptions, rows.Err()
+}
+
+// validWebhookURL reports whether u is an absolute http or https URL
+func validWebhookURL(u string) bool {
+	parsed, err := url.Parse(u)
+	return err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
+
+// CreateWebhook adds a subscription signing its payloads with secret
+func (s *Store) CreateWebhook(ctx context.Context, sub WebhookSubscription, secret string, actor Actor) (*WebhookSubscription, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if !validWebhookURL(sub.URL) {
+		return nil, ErrInvalidWebhook
+	}
+	if err := validateWebhookEvents(sub.Events); err != nil {
+		return nil, err
+	}
+	events, err := json.Marshal(nonNilStrings(sub.Events))
+	if err != nil {
+		return nil, err
+	}
+
+	query := `INSERT INTO webhook_subscriptions (url, secret, event_types, client_id)
+		VALUES (:url, :secret, :event_types, :client_id)
+		RETURNING id INTO :id`
+	if _, err := s.db.ExecContext(ctx, query,
+		sql.Named("url", sub.URL),
+		sql.Named("secret", secret),
+		sql.Named("event_types", string(events)),
+		sql.Named("client_id", sub.ClientID),
+		sql.Named("id", sql.Out{Dest: &sub.ID})); err != nil {
+		log.Error().Err(err).Str("url", sub.URL).Msg("Failed to create webhook subscription")
+		return nil, fmt.Errorf("createWebhook: %v", err)
+	}
+
+	created, err := scanWebhook(s.db.QueryRowContext(ctx, "SELECT "+webhookColumns+" FROM webhook_subscriptions WHERE id = :id",
+		sql.Named("id", sub.ID)))
+	if err != nil {
+		return nil, fmt.Errorf("createWebhook: %v", err)
+	}
+
+	log.Info().Int64("webhook_id", created.ID).Str("url", created.URL).Str("actor", actor.Subject).Msg("Webhook subscription created")
+	s.as.auditActor(actor, AuditEntry{Action: AuditWebhookCreated, ClientID: created.ClientID,
+		Detail: fmt.Sprintf("webhook %d: %s %v", created.ID, created.URL, created.Events)})
+	return created, nil
+}
+
+// ListWebhooks returns every subscription; secrets are never shown again after creation
+func (s *Store) ListWebhooks(ctx context.Context) ([]WebhookSubscription, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	return listWebhooks(ctx, s.db)
+}
+
+// DeleteWebhook removes a subscription together with its deliveries, including pending ones
+func (s *Store) DeleteWebhook(ctx context.Context, id int64, actor Actor) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM webhook_deliveries WHERE subscription_id = :id", sql.Named("id", id)); err != nil {
+		return fmt.Errorf("deleteWebhook %d: %v", id, err)
+	}
+	result, err := tx.ExecContext(ctx, "DELETE FROM webhook_subscriptions WHERE id = :id", sql.Named("id", id))
+	if err != nil {
+		return fmt.Errorf("deleteWebhook %d: %v", id, err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrWebhookNotFound
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	log.Info().Int64("webhook_id", id).Str("actor", actor.Subject).Msg("Webhook subscription deleted")
+	s.as.auditActor(actor, AuditEntry{Action: AuditWebhookDeleted, Detail: fmt.Sprintf("webhook %d", id)})
+	return nil
+}
+
+// WebhookDeliveries returns a subscription's deliveries, newest first, up to limit
+func (s *Store) WebhookDeliveries(ctx context.Context, id int64, limit int) ([]WebhookDelivery, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var count int
+	if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM webhook_subscriptions WHERE id = :id",
+		sql.Named("id", id)).Scan(&count); err != nil {
+		return nil, fmt.Errorf("webhookDeliveries %d: %v", id, err)
+	}
+	if count == 0 {
+		return nil, ErrWebhookNotFound
+	}
+
+	limit, _ = clampPage(limit, 0)
+	query := `SELECT d.id, d.subscription_id, o.event_id, o.event_type, d.status, d.attempts, d.next_attempt_at,
+			d.last_status, d.last_error, d.created_at, d.delivered_at
+		FROM webhook_deliveries d JOIN webhook_outbox o ON o.id = d.outbox_id
+		WHERE d.subscription_id = :id
+		ORDER BY d.id DESC FETCH FIRST :limit ROWS ONLY`
+	rows, err := s.db.QueryContext(ctx, query, sql.Named("id", id), sql.Named("limit", limit))
+	if err != nil {
+		return nil, fmt.Errorf("webhookDeliveries %d: %v", id, err)
+	}
+	defer rows.Close()
+
+	deliveries := []WebhookDelivery{}
+	for rows.Next() {
+		var (
+			d           WebhookDelivery
+			nextAttempt sql.NullTime
+			lastStatus  sql.NullInt64
+			lastError   sql.NullString
+			deliveredAt sql.NullTime
+		)
+		if err := rows.Scan(&d.ID, &d.SubscriptionID, &d.EventID, &d.EventType, &d.Status, &d.Attempts, &nextAttempt,
+			&lastStatus, &lastError, &d.CreatedAt, &deliveredAt); err != nil {
+			return nil, fmt.Errorf("webhookDeliveries %d: %v", id, err)
+		}
+		d.NextAttemptAt = nullTimePtr(nextAttempt)
+		d.LastStatus = int(lastStatus.Int64)
+		d.LastError = lastError.String
+		d.DeliveredAt = nullTimePtr(deliveredAt)
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+// createWebhookRequest is the body of POST /admin/webhooks
+type createWebhookRequest struct {
+	URL      string   `json:"url"`
+	Events   []string `json:"events"`
+	ClientID string   `json:"client_id"`
+}
+
+// createWebhookResponse reveals the signing secret; it is never returned again
+type createWebhookResponse struct {
+	WebhookSubscription
+	Secret string `json:"secret"`
+}
+
+// validateWebhookEvents checks that every filter names a known event type or ends in *
+func validateWebhookEvents(events []string) error {
+	for _, event := range events {
+		if !strings.HasSuffix(event, "*") && !slices.Contains(WebhookEventTypes, event) {
+			return fmt.Errorf("unknown event %q; known events are %s, or a prefix ending in *", event, strings.Join(WebhookEventTypes, ", "))
+		}
+	}
+	return nil
+}
+
+// createWebhookHandler subscribes a URL to events with a server-generated signing secret
+func (as *authServer) createWebhookHandler(c *gin.Context) {
+	logger := GetRequestLogger(c)
+
+	var req createWebhookRequest
+	if apiErr := decodeAdminBody(c, &req); apiErr != nil {
+		RespondWithError(c, apiErr)
+		return
+	}
+	if apiErr := ValidateRequest(c, func() error {
+		if !validWebhookURL(req.URL) {
+			return ErrInvalidWebhook
+		}
+		if req.ClientID != "" && !clientIDPattern.MatchString(req.ClientID) {
+			return errors.New("client_id is not a valid client ID")
+		}
+		return validateWebhookEvents(req.Events)
+	}); apiErr != nil {
+		RespondWithError(c, apiErr)
+		return
+	}
+
+	secret, err := GenerateClientSecret()
+	if err != nil {
+		RespondWithError(c, ErrInternalServerError("Failed to generate webhook secret").WithOriginalError(err))
+		return
+	}
+
+	sub, err := as.store.CreateWebhook(c.Request.Context(), WebhookSubscription{
+		URL:      req.URL,
+		Events:   req.Events,
+		ClientID: req.ClientID,
+	}, secret, adminActor(c))
+	if err != nil {
+		respondStoreError(c, err)
+		return
+	}
+
+	logger.Info().Int64("webhook_id", sub.ID).Msg("Webhook subscription created via admin API")
+	c.Header("Cache-Control", "no-store")
+	c.Header("Location", c.Request.URL.Path+"/"+strconv.FormatInt(sub.ID, 10))
+	c.JSON(http.StatusCreated, createWebhookResponse{WebhookSubscription: *sub, Secret: secret})
+}
+
+// listWebhooksHandler lists every webhook subscription
+func (as *authServer) listWebhooksHandler(c *gin.Context) {
+	subscriptions, err := as.store.ListWebhooks(c.Request.Context())
+	if err != nil {
+		respondStoreError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"webhooks": subscriptions})
+}
+
+// deleteWebhookHandler removes a subscription; its undelivered events are dropped
+func (as *authServer) deleteWebhookHandler(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("webhook_id"), 10, 64)
+	if err != nil {
+		RespondWithError(c, ErrBadRequest("Invalid webhook ID"))
+		return
+	}
+	if err := as.store.DeleteWebhook(c.Request.Context(), id, adminActor(c)); err != nil {
+		respondStoreError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// webhookDeliveriesHandler lists a subscription's recent deliveries, newest first, up to ?limit
+func (as *authServer) webhookDeliveriesHandler(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("webhook_id"), 10, 64)
+	if err != nil {
+		RespondWithError(c, ErrBadRequest("Invalid webhook ID"))
+		return
+	}
+	limit, err := queryInt(c, "limit")
+	if err != nil {
+		RespondWithError(c, ErrBadRequest(err.Error()))
+		return
+	}
+
+	deliveries, err := as.store.WebhookDeliveries(c.Request.Context(), id, limit)
+	if err != nil {
+		respondStoreError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"deliveries": deliveries})
+}
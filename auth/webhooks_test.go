@@ -0,0 +1,157 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	body := []byte(`{"specversion":"1.0","type":"auth-server.token.revoked"}`)
+	now := time.Now().Unix()
+
+	tests := []struct {
+		name    string
+		secret  string
+		header  string
+		body    []byte
+		wantErr bool
+	}{
+		{"valid", "s3cret", signWebhook("s3cret", now, body), body, false},
+		{"wrong secret", "other", signWebhook("s3cret", now, body), body, true},
+		{"tampered body", "s3cret", signWebhook("s3cret", now, body), []byte(`{"type":"forged"}`), true},
+		{"stale timestamp", "s3cret", signWebhook("s3cret", now-600, body), body, true},
+		{"future timestamp", "s3cret", signWebhook("s3cret", now+600, body), body, true},
+		{"missing signature", "s3cret", fmt.Sprintf("t=%d", now), body, true},
+		{"garbage", "s3cret", "not a signature", body, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyWebhookSignature(tt.secret, tt.header, tt.body, 5*time.Minute)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("expected error=%v, got %v", tt.wantErr, err)
+			}
+			if err != nil && !errors.Is(err, ErrBadSignature) {
+				t.Errorf("expected ErrBadSignature, got %v", err)
+			}
+		})
+	}
+}
+
+func TestWebhookSubscription_Matches(t *testing.T) {
+	tests := []struct {
+		name      string
+		sub       WebhookSubscription
+		eventType string
+		subject   string
+		want      bool
+	}{
+		{"no filters", WebhookSubscription{}, WebhookTokenRevoked, "billing", true},
+		{"exact type", WebhookSubscription{Events: []string{WebhookTokenRevoked}}, WebhookTokenRevoked, "billing", true},
+		{"other type", WebhookSubscription{Events: []string{WebhookTokenRevoked}}, WebhookLockout, "billing", false},
+		{"wildcard", WebhookSubscription{Events: []string{"*"}}, WebhookLockout, "billing", true},
+		{"prefix", WebhookSubscription{Events: []string{"client.*"}}, WebhookClientTokensRevoked, "billing", true},
+		{"prefix mismatch", WebhookSubscription{Events: []string{"client.*"}}, WebhookTokenRevoked, "billing", false},
+		{"client filter", WebhookSubscription{ClientID: "billing"}, WebhookTokenRevoked, "billing", true},
+		{"other client", WebhookSubscription{ClientID: "billing"}, WebhookTokenRevoked, "reports", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.sub.matches(tt.eventType, tt.subject); got != tt.want {
+				t.Errorf("matches(%q, %q) = %v, want %v", tt.eventType, tt.subject, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateWebhookEvents(t *testing.T) {
+	if err := validateWebhookEvents([]string{WebhookTokenRevoked, "client.*", "*"}); err != nil {
+		t.Errorf("expected known events and prefixes to be accepted, got %v", err)
+	}
+	if err := validateWebhookEvents([]string{"token.revokd"}); err == nil {
+		t.Error("expected an unknown event to be rejected")
+	}
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	saved := AppConfig
+	defer func() { AppConfig = saved }()
+	AppConfig.Webhooks.BaseBackoff = 10
+	AppConfig.Webhooks.MaxBackoff = 60
+
+	for attempts, want := range map[int]time.Duration{
+		1: 10 * time.Second,
+		2: 20 * time.Second,
+		3: 40 * time.Second,
+		4: 60 * time.Second,
+		9: 60 * time.Second,
+	} {
+		if got := webhookBackoff(attempts); got != want {
+			t.Errorf("webhookBackoff(%d) = %v, want %v", attempts, got, want)
+		}
+	}
+}
+
+// failingExec fails every statement, standing in for a transaction
+type failingExec struct{ calls int }
+
+func (f *failingExec) ExecContext(context.Context, string, ...any) (sql.Result, error) {
+	f.calls++
+	return nil, errors.New("exec called")
+}
+
+func TestEnqueueWebhook_DisabledWritesNothing(t *testing.T) {
+	saved := AppConfig
+	defer func() { AppConfig = saved }()
+	AppConfig.Webhooks.Enabled = false
+
+	tx := &failingExec{}
+	if err := enqueueWebhook(t.Context(), tx, WebhookTokenRevoked, "billing", nil); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if tx.calls != 0 {
+		t.Errorf("expected no outbox write, got %d", tx.calls)
+	}
+
+	AppConfig.Webhooks.Enabled = true
+	if err := enqueueWebhook(t.Context(), tx, WebhookTokenRevoked, "billing", nil); err == nil || tx.calls != 1 {
+		t.Errorf("expected the failed outbox write to be returned, got %v after %d calls", err, tx.calls)
+	}
+}
+
+func TestWebhookDispatcher_SendSignsPayload(t *testing.T) {
+	payload := `{"specversion":"1.0","id":"1","type":"auth-server.token.revoked"}`
+	var verifyErr error
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		verifyErr = VerifyWebhookSignature("s3cret", r.Header.Get(WebhookSignatureHeader), body, time.Minute)
+		if r.Header.Get("Content-Type") != "application/cloudevents+json" {
+			verifyErr = fmt.Errorf("unexpected content type %q", r.Header.Get("Content-Type"))
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer endpoint.Close()
+
+	wd := &WebhookDispatcher{authServer: &authServer{ctx: t.Context()}, httpClient: endpoint.Client()}
+	status, err := wd.send(claimedDelivery{url: endpoint.URL, secret: "s3cret", payload: payload})
+	if err != nil || status != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %v", status, err)
+	}
+	if verifyErr != nil {
+		t.Errorf("receiver rejected the delivery: %v", verifyErr)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+	if status, err := wd.send(claimedDelivery{url: failing.URL, secret: "s3cret", payload: payload}); err == nil || status != http.StatusServiceUnavailable {
+		t.Errorf("expected a 503 to fail the attempt, got %d: %v", status, err)
+	}
+}
@@ -0,0 +1,243 @@
+// Package client talks to the auth server on behalf of a machine-to-machine client.
+//
+// A Client wraps the token, validate and revoke endpoints with typed requests
+// and responses. A TokenSource caches the client's access token until shortly
+// before it expires, and a Transport injects that token into outgoing requests:
+//
+//	c, err := client.New(client.Config{
+//		BaseURL:      "http://auth-server:8080/auth-server/v1/oauth",
+//		ClientID:     "test-client-1",
+//		ClientSecret: "secret",
+//	})
+//	ts := c.TokenSource()
+//	defer ts.Close()
+//	httpClient := &http.Client{Transport: &client.Transport{Source: ts}}
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	defaultTimeout       = 10 * time.Second
+	defaultRefreshBefore = 30 * time.Second
+)
+
+// Config configures a Client
+type Config struct {
+	// BaseURL is the OAuth route group, e.g. http://localhost:8080/auth-server/v1/oauth
+	BaseURL      string
+	ClientID     string
+	ClientSecret string
+	// HTTPClient defaults to a client with a 10 second timeout
+	HTTPClient *http.Client
+	// RefreshBefore is how long before expiry a TokenSource replaces its token, 30 seconds by default
+	RefreshBefore time.Duration
+}
+
+// Client calls the auth server's OAuth endpoints; it is safe for concurrent use
+type Client struct {
+	config     Config
+	baseURL    string
+	httpClient *http.Client
+}
+
+// Token is an access token issued by the server
+type Token struct {
+	AccessToken string    `json:"access_token"`
+	TokenType   string    `json:"token_type"`
+	ExpiresIn   int64     `json:"expires_in"`
+	Expiry      time.Time `json:"-"`
+}
+
+// Valid reports whether the token is present and not yet expired
+func (t *Token) Valid() bool {
+	return t != nil && t.AccessToken != "" && time.Now().Before(t.Expiry)
+}
+
+// expiresWithin reports whether the token expires within d
+func (t *Token) expiresWithin(d time.Duration) bool {
+	return !time.Now().Add(d).Before(t.Expiry)
+}
+
+// IntrospectRequest describes the protected request a token is checked against
+type IntrospectRequest struct {
+	// URL is the resource endpoint the token must grant access to
+	URL string
+	// Method is the HTTP method of the resource request; required for clients with endpoint rules
+	Method string
+}
+
+// Introspection is the server's answer for a token that grants access to a resource
+type Introspection struct {
+	Valid     bool      `json:"valid"`
+	ClientID  string    `json:"client_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Scopes    []string  `json:"scopes"`
+}
+
+// Error is an error response returned by the server
+type Error struct {
+	StatusCode  int    `json:"-"`
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
+	RequestID   string `json:"request_id,omitempty"`
+}
+
+func (e *Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("auth server returned status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("auth server returned %s (status %d): %s", e.Code, e.StatusCode, e.Description)
+}
+
+// IsUnauthorized reports whether err is a 401 from the server
+func IsUnauthorized(err error) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusUnauthorized
+}
+
+// New creates a client from config
+func New(config Config) (*Client, error) {
+	if config.BaseURL == "" {
+		return nil, errors.New("client: BaseURL is required")
+	}
+	if config.ClientID == "" || config.ClientSecret == "" {
+		return nil, errors.New("client: ClientID and ClientSecret are required")
+	}
+
+	if config.RefreshBefore <= 0 {
+		config.RefreshBefore = defaultRefreshBefore
+	}
+
+	httpClient := config.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: defaultTimeout}
+	}
+
+	return &Client{
+		config:     config,
+		baseURL:    strings.TrimSuffix(config.BaseURL, "/"),
+		httpClient: httpClient,
+	}, nil
+}
+
+// Token requests a new access token with the client credentials grant, bypassing any cache
+func (c *Client) Token(ctx context.Context) (*Token, error) {
+	req, err := c.tokenRequest(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	issuedAt := time.Now()
+	var token Token
+	if err := c.do(req, &token); err != nil {
+		return nil, err
+	}
+	if token.AccessToken == "" {
+		return nil, errors.New("client: token response has no access_token")
+	}
+	token.Expiry = issuedAt.Add(time.Duration(token.ExpiresIn) * time.Second)
+	return &token, nil
+}
+
+// tokenRequest builds a client_credentials request with the credentials in a JSON body
+func (c *Client) tokenRequest(ctx context.Context) (*http.Request, error) {
+	payload, err := json.Marshal(map[string]string{
+		"grant_type":    "client_credentials",
+		"client_id":     c.config.ClientID,
+		"client_secret": c.config.ClientSecret,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/token", bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return req, nil
+}
+
+// Introspect checks whether accessToken grants access to the described resource request
+// A token that is invalid or lacks access yields an *Error with status 401 or 403
+func (c *Client) Introspect(ctx context.Context, accessToken string, resource IntrospectRequest) (*Introspection, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/validate", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("X-Forwarded-For", resource.URL)
+	if resource.Method != "" {
+		req.Header.Set("X-Forwarded-Method", resource.Method)
+	}
+
+	var introspection Introspection
+	if err := c.do(req, &introspection); err != nil {
+		return nil, err
+	}
+	return &introspection, nil
+}
+
+// Revoke revokes accessToken
+func (c *Client) Revoke(ctx context.Context, accessToken string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/revoke", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	return c.do(req, nil)
+}
+
+// RevokeAll revokes every token issued to the client owning accessToken, returning the cutoff time
+func (c *Client) RevokeAll(ctx context.Context, accessToken string) (time.Time, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/revoke-all", nil)
+	if err != nil {
+		return time.Time{}, err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	var resp struct {
+		RevokedBefore time.Time `json:"revoked_before"`
+	}
+	if err := c.do(req, &resp); err != nil {
+		return time.Time{}, err
+	}
+	return resp.RevokedBefore, nil
+}
+
+// do sends req and decodes a 2xx JSON response into out, or returns an *Error
+func (c *Client) do(req *http.Request, out any) error {
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		apiErr := &Error{StatusCode: resp.StatusCode}
+		// Best effort: non-JSON error bodies leave only the status code
+		json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(apiErr)
+		return apiErr
+	}
+
+	if out == nil {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("client: failed to decode %s response: %w", req.URL.Path, err)
+	}
+	return nil
+}
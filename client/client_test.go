@@ -0,0 +1,242 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeAuthServer mimics the auth server's token, validate and revoke endpoints
+type fakeAuthServer struct {
+	*httptest.Server
+	tokenCalls atomic.Int32
+	expiresIn  int64
+	delay      time.Duration
+}
+
+func newFakeAuthServer(t *testing.T) *fakeAuthServer {
+	t.Helper()
+
+	fake := &fakeAuthServer{expiresIn: 120}
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(fake.delay)
+
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["client_id"] != "client-1" || body["client_secret"] != "secret-1" {
+			writeError(w, http.StatusUnauthorized, "invalid_client", "Invalid client credentials")
+			return
+		}
+
+		n := fake.tokenCalls.Add(1)
+		json.NewEncoder(w).Encode(map[string]any{
+			"access_token": fmt.Sprintf("token-%d", n),
+			"token_type":   "Bearer",
+			"expires_in":   fake.expiresIn,
+		})
+	})
+
+	mux.HandleFunc("POST /validate", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token-1" {
+			writeError(w, http.StatusUnauthorized, "unauthorized", "Invalid or expired token")
+			return
+		}
+		if r.Header.Get("X-Forwarded-For") != "http://localhost:3000/api/users" {
+			writeError(w, http.StatusForbidden, "forbidden", "Resource not in token scopes")
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"valid":      true,
+			"client_id":  "client-1",
+			"expires_at": time.Now().Add(time.Minute),
+			"scopes":     []string{"http://localhost:3000/api/users"},
+		})
+	})
+
+	mux.HandleFunc("POST /revoke", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"message": "Token revoked successfully"})
+	})
+
+	mux.HandleFunc("GET /resource", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "Bearer token-1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(r.Header.Get("Authorization")))
+	})
+
+	fake.Server = httptest.NewServer(mux)
+	t.Cleanup(fake.Close)
+	return fake
+}
+
+func writeError(w http.ResponseWriter, status int, code, description string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": code, "error_description": description, "request_id": "req-1"})
+}
+
+func newTestClient(t *testing.T, fake *fakeAuthServer) *Client {
+	t.Helper()
+
+	c, err := New(Config{
+		BaseURL:      fake.URL,
+		ClientID:     "client-1",
+		ClientSecret: "secret-1",
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	return c
+}
+
+func TestClientToken(t *testing.T) {
+	fake := newFakeAuthServer(t)
+
+	token, err := newTestClient(t, fake).Token(context.Background())
+	if err != nil {
+		t.Fatalf("Token failed: %v", err)
+	}
+	if !token.Valid() {
+		t.Errorf("Expected a valid token, got %+v", token)
+	}
+	if time.Until(token.Expiry) > 2*time.Minute {
+		t.Errorf("Expected expiry from expires_in, got %v", token.Expiry)
+	}
+}
+
+func TestClientToken_InvalidCredentials(t *testing.T) {
+	fake := newFakeAuthServer(t)
+
+	c, _ := New(Config{BaseURL: fake.URL, ClientID: "client-1", ClientSecret: "wrong"})
+	_, err := c.Token(context.Background())
+	if !IsUnauthorized(err) {
+		t.Fatalf("Expected unauthorized error, got %v", err)
+	}
+
+	apiErr := err.(*Error)
+	if apiErr.Code != "invalid_client" || apiErr.RequestID != "req-1" {
+		t.Errorf("Expected decoded error body, got %+v", apiErr)
+	}
+}
+
+func TestNew_RequiresCredentials(t *testing.T) {
+	if _, err := New(Config{BaseURL: "http://x", ClientID: "a"}); err == nil {
+		t.Error("Expected error for missing client secret")
+	}
+}
+
+func TestTokenSource_CachesAndSharesFetch(t *testing.T) {
+	fake := newFakeAuthServer(t)
+	fake.delay = 50 * time.Millisecond
+
+	ts := newTestClient(t, fake).TokenSource()
+	defer ts.Close()
+
+	var wg sync.WaitGroup
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := ts.Token(context.Background()); err != nil {
+				t.Errorf("Token failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if _, err := ts.Token(context.Background()); err != nil {
+		t.Fatalf("Token failed: %v", err)
+	}
+	if calls := fake.tokenCalls.Load(); calls != 1 {
+		t.Errorf("Expected a single token request, got %d", calls)
+	}
+}
+
+func TestTokenSource_RefreshesNearExpiry(t *testing.T) {
+	fake := newFakeAuthServer(t)
+	fake.expiresIn = 2 // margin is capped at one second
+
+	ts := newTestClient(t, fake).TokenSource()
+	defer ts.Close()
+
+	first, err := ts.Token(context.Background())
+	if err != nil {
+		t.Fatalf("Token failed: %v", err)
+	}
+
+	// The background refresh replaces the token before it expires
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		if current, _ := ts.Token(context.Background()); current.AccessToken != first.AccessToken {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Error("Expected the token to be refreshed in the background")
+}
+
+func TestTransport_InjectsTokenAndInvalidatesOn401(t *testing.T) {
+	fake := newFakeAuthServer(t)
+
+	ts := newTestClient(t, fake).TokenSource()
+	defer ts.Close()
+	httpClient := &http.Client{Transport: &Transport{Source: ts}}
+
+	// The fake resource server rejects token-1, forcing a new token
+	resp, err := httpClient.Get(fake.URL + "/resource")
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("Expected 401 for first token, got %d", resp.StatusCode)
+	}
+
+	resp, err = httpClient.Get(fake.URL + "/resource")
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected 200 with refreshed token, got %d", resp.StatusCode)
+	}
+	if calls := fake.tokenCalls.Load(); calls != 2 {
+		t.Errorf("Expected token to be refetched after 401, got %d token requests", calls)
+	}
+}
+
+func TestClientIntrospectAndRevoke(t *testing.T) {
+	fake := newFakeAuthServer(t)
+	c := newTestClient(t, fake)
+
+	introspection, err := c.Introspect(context.Background(), "token-1", IntrospectRequest{
+		URL:    "http://localhost:3000/api/users",
+		Method: http.MethodGet,
+	})
+	if err != nil {
+		t.Fatalf("Introspect failed: %v", err)
+	}
+	if !introspection.Valid || introspection.ClientID != "client-1" || len(introspection.Scopes) != 1 {
+		t.Errorf("Unexpected introspection: %+v", introspection)
+	}
+
+	_, err = c.Introspect(context.Background(), "token-1", IntrospectRequest{URL: "http://localhost:3000/api/admin"})
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected 403 error, got %v", err)
+	}
+
+	if err := c.Revoke(context.Background(), "token-1"); err != nil {
+		t.Errorf("Revoke failed: %v", err)
+	}
+}
@@ -0,0 +1,136 @@
+package client
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// minRefreshDelay keeps a failing background refresh from spinning
+const minRefreshDelay = time.Second
+
+// TokenSource hands out a cached access token, fetching a new one shortly before expiry
+// Concurrent callers share a single in-flight token request. Safe for concurrent use.
+type TokenSource struct {
+	client        *Client
+	refreshBefore time.Duration
+
+	mu     sync.RWMutex
+	token  *Token
+	timer  *time.Timer // background refresh, scheduled after every successful fetch
+	closed bool
+
+	group singleflight.Group
+}
+
+// TokenSource returns a new caching token source for the client
+func (c *Client) TokenSource() *TokenSource {
+	return &TokenSource{
+		client:        c,
+		refreshBefore: c.config.RefreshBefore,
+	}
+}
+
+// Token returns the cached token, fetching a new one if it is missing or expired
+func (ts *TokenSource) Token(ctx context.Context) (*Token, error) {
+	ts.mu.RLock()
+	token := ts.token
+	ts.mu.RUnlock()
+
+	switch {
+	case token == nil || !token.Valid():
+		return ts.refresh(ctx)
+	case token.expiresWithin(ts.margin(token)):
+		// Still usable: replace it in the background rather than making this caller wait
+		go ts.refresh(context.Background())
+	}
+	return token, nil
+}
+
+// margin is how long before expiry token is replaced, capped at half its lifetime for short-lived tokens
+func (ts *TokenSource) margin(token *Token) time.Duration {
+	return min(ts.refreshBefore, time.Duration(token.ExpiresIn)*time.Second/2)
+}
+
+// Invalidate drops the cached token so the next call fetches a new one
+// Call it when a resource server rejects the token, e.g. after revocation
+func (ts *TokenSource) Invalidate() {
+	ts.mu.Lock()
+	ts.token = nil
+	ts.mu.Unlock()
+}
+
+// Close stops background refreshes
+func (ts *TokenSource) Close() {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	ts.closed = true
+	if ts.timer != nil {
+		ts.timer.Stop()
+		ts.timer = nil
+	}
+}
+
+// refresh fetches a new token, sharing the request with concurrent callers
+func (ts *TokenSource) refresh(ctx context.Context) (*Token, error) {
+	// The shared fetch must not be cancelled by whichever caller happened to start it
+	result := ts.group.DoChan("token", func() (any, error) {
+		fetchCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), defaultTimeout)
+		defer cancel()
+		return ts.client.Token(fetchCtx)
+	})
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-result:
+		if res.Err != nil {
+			ts.scheduleRetry()
+			return nil, res.Err
+		}
+		token := res.Val.(*Token)
+		ts.store(token)
+		return token, nil
+	}
+}
+
+// store caches token and schedules its background replacement
+func (ts *TokenSource) store(token *Token) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	// A slower concurrent fetch must not replace a newer token
+	if ts.token != nil && ts.token.Expiry.After(token.Expiry) {
+		return
+	}
+	ts.token = token
+	ts.scheduleLocked(time.Until(token.Expiry) - ts.margin(token))
+}
+
+// scheduleRetry retries a failed fetch in the background while the cached token is still usable
+func (ts *TokenSource) scheduleRetry() {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if ts.token.Valid() {
+		ts.scheduleLocked(min(time.Until(ts.token.Expiry)/2, ts.margin(ts.token)))
+	}
+}
+
+// scheduleLocked (re)arms the background refresh timer; ts.mu must be held
+func (ts *TokenSource) scheduleLocked(delay time.Duration) {
+	if ts.closed {
+		return
+	}
+	delay = max(delay, minRefreshDelay)
+
+	if ts.timer != nil {
+		ts.timer.Stop()
+	}
+	ts.timer = time.AfterFunc(delay, func() {
+		ts.refresh(context.Background())
+	})
+}
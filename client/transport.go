@@ -0,0 +1,41 @@
+package client
+
+import (
+	"net/http"
+)
+
+// Transport is an http.RoundTripper that authenticates requests with tokens from Source
+type Transport struct {
+	Source *TokenSource
+	// Base defaults to http.DefaultTransport
+	Base http.RoundTripper
+}
+
+// RoundTrip adds a bearer token to req and sends it with the base transport
+// A 401 response invalidates the cached token so the next request fetches a new one
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.Source.Token(req.Context())
+	if err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+
+	// RoundTrippers must not modify the caller's request
+	authReq := req.Clone(req.Context())
+	authReq.Header.Set("Authorization", "Bearer "+token.AccessToken)
+
+	resp, err := t.base().RoundTrip(authReq)
+	if err == nil && resp.StatusCode == http.StatusUnauthorized {
+		t.Source.Invalidate()
+	}
+	return resp, err
+}
+
+func (t *Transport) base() http.RoundTripper {
+	if t.Base != nil {
+		return t.Base
+	}
+	return http.DefaultTransport
+}
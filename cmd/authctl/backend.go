@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os/user"
+	"time"
+
+	"auth-server/auth"
+)
+
+// errDirectOnly and errServerOnly report commands that need the other backend
+var (
+	errDirectOnly = errors.New("this command needs --direct access to the database")
+	errServerOnly = errors.New("this command needs a running server; use --server")
+)
+
+// createdClient is a new client together with its secret, which is shown only once
+type createdClient struct {
+	auth.ClientRecord
+	ClientSecret string `json:"client_secret"`
+}
+
+// createdSecret is a new client secret, which is shown only once
+type createdSecret struct {
+	auth.ClientSecretInfo
+	ClientSecret string `json:"client_secret"`
+}
+
+// createdWebhook is a new webhook subscription with its signing secret, which is shown only once
+type createdWebhook struct {
+	auth.WebhookSubscription
+	Secret string `json:"secret"`
+}
+
+// backend is where authctl reads and changes state: a server's admin API or the database itself
+type backend interface {
+	CreateClient(ctx context.Context, client auth.ClientRecord) (*createdClient, error)
+	GetClient(ctx context.Context, clientID string) (*auth.ClientRecord, error)
+	ListClients(ctx context.Context, filter auth.ClientFilter) (*auth.ClientPage, error)
+	UpdateClient(ctx context.Context, clientID string, update auth.ClientUpdate) (*auth.ClientRecord, error)
+	DeleteClient(ctx context.Context, clientID string) (*auth.ClientRecord, error)
+	RestoreClient(ctx context.Context, clientID string) (*auth.ClientRecord, error)
+
+	ListSecrets(ctx context.Context, clientID string) ([]auth.ClientSecretInfo, error)
+	AddSecret(ctx context.Context, clientID, label string, expiresAt *time.Time) (*createdSecret, error)
+	PromoteSecret(ctx context.Context, clientID string, secretID int64) (*auth.ClientSecretInfo, error)
+	ExpireSecret(ctx context.Context, clientID string, secretID int64, grace time.Duration) (*auth.ClientSecretInfo, error)
+
+	VerifyToken(ctx context.Context, token string) (*auth.Claims, error)
+	RevokeToken(ctx context.Context, token string) error
+
+	RotateKey(ctx context.Context) (*auth.SigningKeyInfo, error)
+	CacheStats(ctx context.Context) (map[string]any, error)
+	CacheEntries(ctx context.Context) ([]auth.CacheEntryInfo, error)
+	ClearCache(ctx context.Context, clientID string) error
+	BatchState(ctx context.Context) (*auth.BatchWriterState, error)
+	FlushBatch(ctx context.Context) (*auth.BatchWriterState, error)
+	DBStats(ctx context.Context) (map[string]any, error)
+	LogLevel(ctx context.Context) (string, error)
+	SetLogLevel(ctx context.Context, level string) (string, error)
+	ListLockouts(ctx context.Context) ([]auth.LockoutInfo, error)
+	UnlockClient(ctx context.Context, clientID string) error
+	UnlockIP(ctx context.Context, ip string) error
+
+	AuditEntries(ctx context.Context, filter auth.AuditFilter) ([]auth.AuditEntry, error)
+	VerifyAudit(ctx context.Context) (map[string]*auth.AuditVerification, error)
+
+	CreateWebhook(ctx context.Context, sub auth.WebhookSubscription) (*createdWebhook, error)
+	ListWebhooks(ctx context.Context) ([]auth.WebhookSubscription, error)
+	DeleteWebhook(ctx context.Context, id int64) error
+	WebhookDeliveries(ctx context.Context, id int64, limit int) ([]auth.WebhookDelivery, error)
+
+	MigrationStatus(ctx context.Context) ([]auth.MigrationState, error)
+	Migrate(ctx context.Context) ([]auth.MigrationState, error)
+
+	Close() error
+}
+
+// directBackend works against the configured database through auth.Store
+type directBackend struct {
+	store *auth.Store
+	actor auth.Actor // Recorded in the client audit trail
+}
+
+// localActor names the operating system user running authctl
+func localActor() auth.Actor {
+	name := "unknown"
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	return auth.Actor{Subject: "authctl:" + name}
+}
+
+func (b *directBackend) CreateClient(ctx context.Context, client auth.ClientRecord) (*createdClient, error) {
+	secret, err := auth.GenerateClientSecret()
+	if err != nil {
+		return nil, err
+	}
+	record, err := b.store.CreateClient(ctx, client, secret, b.actor)
+	if err != nil {
+		return nil, err
+	}
+	return &createdClient{ClientRecord: *record, ClientSecret: secret}, nil
+}
+
+func (b *directBackend) GetClient(ctx context.Context, clientID string) (*auth.ClientRecord, error) {
+	return b.store.GetClient(ctx, clientID)
+}
+
+func (b *directBackend) ListClients(ctx context.Context, filter auth.ClientFilter) (*auth.ClientPage, error) {
+	return b.store.ListClients(ctx, filter)
+}
+
+func (b *directBackend) UpdateClient(ctx context.Context, clientID string, update auth.ClientUpdate) (*auth.ClientRecord, error) {
+	return b.store.UpdateClient(ctx, clientID, update, b.actor)
+}
+
+func (b *directBackend) DeleteClient(ctx context.Context, clientID string) (*auth.ClientRecord, error) {
+	return b.store.DeleteClient(ctx, clientID, b.actor)
+}
+
+func (b *directBackend) RestoreClient(ctx context.Context, clientID string) (*auth.ClientRecord, error) {
+	return b.store.RestoreClient(ctx, clientID, b.actor)
+}
+
+func (b *directBackend) ListSecrets(ctx context.Context, clientID string) ([]auth.ClientSecretInfo, error) {
+	return b.store.ListClientSecrets(ctx, clientID)
+}
+
+func (b *directBackend) AddSecret(ctx context.Context, clientID, label string, expiresAt *time.Time) (*createdSecret, error) {
+	secret, err := auth.GenerateClientSecret()
+	if err != nil {
+		return nil, err
+	}
+	info, err := b.store.AddClientSecret(ctx, clientID, label, expiresAt, secret, b.actor)
+	if err != nil {
+		return nil, err
+	}
+	return &createdSecret{ClientSecretInfo: *info, ClientSecret: secret}, nil
+}
+
+func (b *directBackend) PromoteSecret(ctx context.Context, clientID string, secretID int64) (*auth.ClientSecretInfo, error) {
+	return b.store.PromoteClientSecret(ctx, clientID, secretID, b.actor)
+}
+
+func (b *directBackend) ExpireSecret(ctx context.Context, clientID string, secretID int64, grace time.Duration) (*auth.ClientSecretInfo, error) {
+	return b.store.ExpireClientSecret(ctx, clientID, secretID, grace, b.actor)
+}
+
+func (b *directBackend) VerifyToken(ctx context.Context, token string) (*auth.Claims, error) {
+	return b.store.VerifyToken(ctx, token)
+}
+
+func (b *directBackend) RevokeToken(ctx context.Context, token string) error {
+	_, err := b.store.RevokeToken(ctx, token, b.actor)
+	return err
+}
+
+func (b *directBackend) RotateKey(ctx context.Context) (*auth.SigningKeyInfo, error) {
+	return b.store.RotateSigningKey(ctx, b.actor)
+}
+
+// CacheStats needs a server: the cache lives in each server process, not in the database
+func (b *directBackend) CacheStats(ctx context.Context) (map[string]any, error) {
+	return nil, errServerOnly
+}
+
+// The cache, batch writer, connection pool and log level belong to a server process
+func (b *directBackend) CacheEntries(ctx context.Context) ([]auth.CacheEntryInfo, error) {
+	return nil, errServerOnly
+}
+
+func (b *directBackend) ClearCache(ctx context.Context, clientID string) error {
+	return errServerOnly
+}
+
+func (b *directBackend) BatchState(ctx context.Context) (*auth.BatchWriterState, error) {
+	return nil, errServerOnly
+}
+
+func (b *directBackend) FlushBatch(ctx context.Context) (*auth.BatchWriterState, error) {
+	return nil, errServerOnly
+}
+
+func (b *directBackend) DBStats(ctx context.Context) (map[string]any, error) {
+	return nil, errServerOnly
+}
+
+func (b *directBackend) LogLevel(ctx context.Context) (string, error) {
+	return "", errServerOnly
+}
+
+func (b *directBackend) SetLogLevel(ctx context.Context, level string) (string, error) {
+	return "", errServerOnly
+}
+
+// ListLockouts needs a server: failure counts live in each server process, not in the database
+func (b *directBackend) ListLockouts(ctx context.Context) ([]auth.LockoutInfo, error) {
+	return nil, errServerOnly
+}
+
+func (b *directBackend) UnlockClient(ctx context.Context, clientID string) error {
+	b.store.ClearLockout(auth.ClientLockoutSubject(clientID), b.actor)
+	return nil
+}
+
+func (b *directBackend) UnlockIP(ctx context.Context, ip string) error {
+	b.store.ClearLockout(auth.IPLockoutSubject(ip), b.actor)
+	return nil
+}
+
+func (b *directBackend) AuditEntries(ctx context.Context, filter auth.AuditFilter) ([]auth.AuditEntry, error) {
+	return b.store.AuditEntries(ctx, filter)
+}
+
+// VerifyAudit checks the db sink only: audit files are written by, and kept beside, each server
+func (b *directBackend) VerifyAudit(ctx context.Context) (map[string]*auth.AuditVerification, error) {
+	result, err := b.store.VerifyAuditLog(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]*auth.AuditVerification{auth.AuditSinkDB: result}, nil
+}
+
+func (b *directBackend) CreateWebhook(ctx context.Context, sub auth.WebhookSubscription) (*createdWebhook, error) {
+	secret, err := auth.GenerateClientSecret()
+	if err != nil {
+		return nil, err
+	}
+	created, err := b.store.CreateWebhook(ctx, sub, secret, b.actor)
+	if err != nil {
+		return nil, err
+	}
+	return &createdWebhook{WebhookSubscription: *created, Secret: secret}, nil
+}
+
+func (b *directBackend) ListWebhooks(ctx context.Context) ([]auth.WebhookSubscription, error) {
+	return b.store.ListWebhooks(ctx)
+}
+
+func (b *directBackend) DeleteWebhook(ctx context.Context, id int64) error {
+	return b.store.DeleteWebhook(ctx, id, b.actor)
+}
+
+func (b *directBackend) WebhookDeliveries(ctx context.Context, id int64, limit int) ([]auth.WebhookDelivery, error) {
+	return b.store.WebhookDeliveries(ctx, id, limit)
+}
+
+func (b *directBackend) MigrationStatus(ctx context.Context) ([]auth.MigrationState, error) {
+	return b.store.MigrationStatus(ctx)
+}
+
+func (b *directBackend) Migrate(ctx context.Context) ([]auth.MigrationState, error) {
+	return b.store.Migrate(ctx)
+}
+
+func (b *directBackend) Close() error {
+	return b.store.Close()
+}
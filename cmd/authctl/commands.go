@@ -0,0 +1,1350 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"auth-server/auth"
+)
+
+// command runs one authctl subcommand with its remaining arguments
+type command func(ctx context.Context, e *env, args []string) error
+
+var commands = map[string]command{
+	"client create":  clientCreate,
+	"client list":    clientList,
+	"client show":    clientShow,
+	"client update":  clientUpdate,
+	"client disable": clientDisable,
+	"client delete":  clientDelete,
+	"client restore": clientRestore,
+	"secret list":    secretList,
+	"secret add":     secretAdd,
+	"secret promote": secretPromote,
+	"secret expire":  secretExpire,
+	"scope add":      scopeAdd,
+	"scope remove":   scopeRemove,
+	"token decode":   tokenDecode,
+	"token verify":   tokenVerify,
+	"token revoke":   tokenRevoke,
+	"key rotate":     keyRotate,
+	"cache stats":    cacheStats,
+	"cache list":     cacheList,
+	"cache clear":    cacheClear,
+	"batch status":   batchStatus,
+	"batch flush":    batchFlush,
+	"db stats":       dbStats,
+	"log level":      logLevel,
+	"lockout list":   lockoutList,
+	"lockout unlock": lockoutUnlock,
+	"audit list":     auditList,
+	"audit verify":   auditVerify,
+	"webhook add":    webhookAdd,
+	"webhook list":   webhookList,
+	"webhook remove": webhookRemove,
+	"webhook log":    webhookLog,
+	"migrate status": migrateStatus,
+	"migrate up":     migrateUp,
+}
+
+// stringList is a repeatable string flag
+type stringList []string
+
+func (s *stringList) String() string { return strings.Join(*s, ",") }
+
+func (s *stringList) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// timeFlag is an RFC 3339 time flag; "none" sets it without a time, which clears a bound on update
+type timeFlag struct {
+	time *time.Time
+}
+
+func (t *timeFlag) String() string {
+	if t.time == nil {
+		return ""
+	}
+	return t.time.Format(time.RFC3339)
+}
+
+func (t *timeFlag) Set(value string) error {
+	if value == "none" {
+		t.time = nil
+		return nil
+	}
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return errors.New("expected an RFC 3339 time such as 2026-01-31T00:00:00Z, or none")
+	}
+	t.time = &parsed
+	return nil
+}
+
+// orZero returns the time, or a zero time that clears the bound when the flag was "none"
+func (t *timeFlag) orZero() *time.Time {
+	if t.time == nil {
+		return &time.Time{}
+	}
+	return t.time
+}
+
+// limitFlag is a rate limit flag, RATE[:BURST] in requests per second; "none" removes the override
+type limitFlag struct {
+	limit auth.RateLimit
+}
+
+func (l *limitFlag) String() string {
+	return formatLimit(&l.limit)
+}
+
+func (l *limitFlag) Set(value string) error {
+	if value == "none" {
+		l.limit = auth.RateLimit{}
+		return nil
+	}
+	rate, burst, hasBurst := strings.Cut(value, ":")
+	parsed, err := strconv.ParseFloat(rate, 64)
+	if err != nil || parsed <= 0 {
+		return errors.New("expected RATE[:BURST] with a positive rate per second, such as 5 or 5:20, or none")
+	}
+	l.limit = auth.RateLimit{Rate: parsed}
+	if hasBurst {
+		if l.limit.Burst, err = strconv.Atoi(burst); err != nil || l.limit.Burst <= 0 {
+			return errors.New("the burst must be a positive whole number")
+		}
+	}
+	return nil
+}
+
+// newFlagSet creates a subcommand flag set that reports errors through stderr
+func newFlagSet(e *env, name, args string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(e.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(e.stderr, "Usage: authctl %s %s\n", name, args)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseArgs parses flags wherever they appear and returns the positional arguments
+// The flag package stops at the first positional argument, so parsing resumes after each one
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// exactArgs parses flags and requires exactly n positional arguments
+func exactArgs(fs *flag.FlagSet, args []string, n int) ([]string, error) {
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return nil, err
+	}
+	if len(positional) != n {
+		fs.Usage()
+		return nil, fmt.Errorf("expected %d argument(s), got %d", n, len(positional))
+	}
+	return positional, nil
+}
+
+func clientCreate(ctx context.Context, e *env, args []string) error {
+	fs := newFlagSet(e, "client create", "--id <client-id> [--name <name>] [--ttl <seconds>] [--scope <scope>]... [--valid-from <time>] [--valid-until <time>]")
+	var (
+		client                auth.ClientRecord
+		scopes                stringList
+		ttl                   int
+		validFrom, validUntil timeFlag
+	)
+	fs.StringVar(&client.ClientID, "id", "", "client ID (required)")
+	fs.StringVar(&client.Name, "name", "", "display name")
+	fs.IntVar(&ttl, "ttl", 3600, "access token lifetime in seconds")
+	fs.Var(&scopes, "scope", "allowed scope; repeat for several")
+	fs.Var(&validFrom, "valid-from", "refuse tokens before this RFC 3339 time")
+	fs.Var(&validUntil, "valid-until", "refuse tokens from this RFC 3339 time on")
+	if _, err := exactArgs(fs, args, 0); err != nil {
+		return err
+	}
+	client.ValidFrom = validFrom.time
+	client.ValidUntil = validUntil.time
+	if client.ClientID == "" {
+		fs.Usage()
+		return errors.New("--id is required")
+	}
+	if ttl <= 0 {
+		return errors.New("--ttl must be positive")
+	}
+	client.AccessTokenTTL = int32(ttl)
+	client.AllowedScopes = scopes
+
+	b, err := e.Backend()
+	if err != nil {
+		return err
+	}
+	created, err := b.CreateClient(ctx, client)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(e.stderr, "Store the client secret now; it cannot be shown again.")
+	fields := append(clientFields(&created.ClientRecord), [2]string{"Client Secret", created.ClientSecret})
+	return e.out.Fields(created, fields)
+}
+
+func clientList(ctx context.Context, e *env, args []string) error {
+	fs := newFlagSet(e, "client list", "[--active true|false] [--search <text>] [--limit <n>] [--offset <n>]")
+	var (
+		filter auth.ClientFilter
+		active string
+	)
+	fs.StringVar(&active, "active", "", "only active (true) or disabled (false) clients")
+	fs.StringVar(&filter.Search, "search", "", "match client IDs and names containing this text")
+	fs.IntVar(&filter.Limit, "limit", auth.DefaultClientPageSize, "page size")
+	fs.IntVar(&filter.Offset, "offset", 0, "clients to skip")
+	if _, err := exactArgs(fs, args, 0); err != nil {
+		return err
+	}
+	if active != "" {
+		value, err := strconv.ParseBool(active)
+		if err != nil {
+			return fmt.Errorf("--active: %w", err)
+		}
+		filter.Active = &value
+	}
+
+	b, err := e.Backend()
+	if err != nil {
+		return err
+	}
+	page, err := b.ListClients(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	rows := make([][]string, 0, len(page.Clients))
+	for _, client := range page.Clients {
+		rows = append(rows, []string{
+			client.ClientID,
+			client.Name,
+			strconv.Itoa(int(client.AccessTokenTTL)),
+			strconv.FormatBool(client.Active),
+			formatList(client.AllowedScopes),
+		})
+	}
+	if err := e.out.Print(page, []string{"CLIENT ID", "NAME", "TTL", "ACTIVE", "SCOPES"}, rows); err != nil {
+		return err
+	}
+	if e.opts.output == outputTable && page.Total > len(page.Clients) {
+		fmt.Fprintf(e.stderr, "Showing %d-%d of %d clients\n", page.Offset+1, page.Offset+len(page.Clients), page.Total)
+	}
+	return nil
+}
+
+func clientShow(ctx context.Context, e *env, args []string) error {
+	fs := newFlagSet(e, "client show", "<client-id>")
+	positional, err := exactArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	b, err := e.Backend()
+	if err != nil {
+		return err
+	}
+	client, err := b.GetClient(ctx, positional[0])
+	if err != nil {
+		return err
+	}
+	return e.out.Fields(client, clientFields(client))
+}
+
+func clientUpdate(ctx context.Context, e *env, args []string) error {
+	fs := newFlagSet(e, "client update", "<client-id> [--name <name>] [--ttl <seconds>] [--active true|false] [--valid-from <time>|none] [--valid-until <time>|none] [--token-limit <rate[:burst]>|none] [--validate-limit <rate[:burst]>|none] [--allowed-cidrs <cidr>[,<cidr>...]|none] [--enforce-cidrs-on-use true|false] [--network-claim true|false] [--revoke-tokens]")
+	var (
+		name                  string
+		ttl                   int
+		active                bool
+		validFrom, validUntil timeFlag
+		tokenLimit            limitFlag
+		validateLimit         limitFlag
+		allowedCIDRs          string
+		cidrsOnUse            bool
+		networkClaim          bool
+		revoke                bool
+	)
+	fs.StringVar(&name, "name", "", "display name")
+	fs.IntVar(&ttl, "ttl", 0, "access token lifetime in seconds")
+	fs.BoolVar(&active, "active", true, "whether the client may obtain tokens")
+	fs.Var(&validFrom, "valid-from", "refuse tokens before this RFC 3339 time; none removes the bound")
+	fs.Var(&validUntil, "valid-until", "refuse tokens from this RFC 3339 time on; none removes the bound")
+	fs.Var(&tokenLimit, "token-limit", "/token requests per second and burst; none restores the configured default")
+	fs.Var(&validateLimit, "validate-limit", "/validate requests per second and burst; none restores the configured default")
+	fs.StringVar(&allowedCIDRs, "allowed-cidrs", "", "comma-separated networks tokens may be requested from; none allows any")
+	fs.BoolVar(&cidrsOnUse, "enforce-cidrs-on-use", false, "also check the allowed networks when tokens are validated")
+	fs.BoolVar(&networkClaim, "network-claim", false, "bind tokens to the network they were issued to")
+	fs.BoolVar(&revoke, "revoke-tokens", false, "also revoke every token issued to the client so far")
+	positional, err := exactArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	// Only flags given on the command line are changed
+	var update auth.ClientUpdate
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "name":
+			update.Name = &name
+		case "ttl":
+			value := int32(ttl)
+			update.AccessTokenTTL = &value
+		case "active":
+			update.Active = &active
+		case "valid-from":
+			update.ValidFrom = validFrom.orZero()
+		case "valid-until":
+			update.ValidUntil = validUntil.orZero()
+		case "token-limit":
+			update.TokenRateLimit = &tokenLimit.limit
+		case "validate-limit":
+			update.ValidateRateLimit = &validateLimit.limit
+		case "allowed-cidrs":
+			cidrs := []string{}
+			if allowedCIDRs != "none" {
+				for _, cidr := range strings.Split(allowedCIDRs, ",") {
+					if cidr = strings.TrimSpace(cidr); cidr != "" {
+						cidrs = append(cidrs, cidr)
+					}
+				}
+			}
+			update.AllowedCIDRs = &cidrs
+		case "enforce-cidrs-on-use":
+			update.EnforceCIDRsOnUse = &cidrsOnUse
+		case "network-claim":
+			update.NetworkClaim = &networkClaim
+		case "revoke-tokens":
+			update.RevokeTokens = revoke
+		}
+	})
+	if update == (auth.ClientUpdate{}) {
+		fs.Usage()
+		return errors.New("nothing to update")
+	}
+	if update.AccessTokenTTL != nil && ttl <= 0 {
+		return errors.New("--ttl must be positive")
+	}
+
+	return applyUpdate(ctx, e, positional[0], update)
+}
+
+func clientDisable(ctx context.Context, e *env, args []string) error {
+	fs := newFlagSet(e, "client disable", "<client-id> [--revoke-tokens]")
+	revoke := fs.Bool("revoke-tokens", false, "also revoke every token issued to the client so far")
+	positional, err := exactArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	active := false
+	return applyUpdate(ctx, e, positional[0], auth.ClientUpdate{Active: &active, RevokeTokens: *revoke})
+}
+
+func clientDelete(ctx context.Context, e *env, args []string) error {
+	fs := newFlagSet(e, "client delete", "<client-id>")
+	positional, err := exactArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	b, err := e.Backend()
+	if err != nil {
+		return err
+	}
+	client, err := b.DeleteClient(ctx, positional[0])
+	if err != nil {
+		return err
+	}
+	return e.out.Fields(client, clientFields(client))
+}
+
+func clientRestore(ctx context.Context, e *env, args []string) error {
+	fs := newFlagSet(e, "client restore", "<client-id>")
+	positional, err := exactArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	b, err := e.Backend()
+	if err != nil {
+		return err
+	}
+	client, err := b.RestoreClient(ctx, positional[0])
+	if err != nil {
+		return err
+	}
+	return e.out.Fields(client, clientFields(client))
+}
+
+func secretList(ctx context.Context, e *env, args []string) error {
+	fs := newFlagSet(e, "secret list", "<client-id>")
+	positional, err := exactArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	b, err := e.Backend()
+	if err != nil {
+		return err
+	}
+	secrets, err := b.ListSecrets(ctx, positional[0])
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	rows := make([][]string, 0, len(secrets))
+	for _, secret := range secrets {
+		status := "active"
+		switch {
+		case secret.ExpiresAt != nil && !now.Before(*secret.ExpiresAt):
+			status = "expired"
+		case secret.Primary:
+			status = "primary"
+		}
+		rows = append(rows, []string{
+			strconv.FormatInt(secret.ID, 10),
+			secret.Label,
+			status,
+			formatTime(secret.CreatedAt),
+			formatOptionalTime(secret.ExpiresAt),
+			formatOptionalTime(secret.LastUsedAt),
+		})
+	}
+	return e.out.Print(secrets, []string{"ID", "LABEL", "STATUS", "CREATED", "EXPIRES", "LAST USED"}, rows)
+}
+
+func secretAdd(ctx context.Context, e *env, args []string) error {
+	fs := newFlagSet(e, "secret add", "<client-id> [--label <label>] [--expires-in <duration>]")
+	var (
+		label     string
+		expiresIn time.Duration
+	)
+	fs.StringVar(&label, "label", "", "label to tell secrets apart, e.g. a deployment or date")
+	fs.DurationVar(&expiresIn, "expires-in", 0, "expire the new secret after this long (default never)")
+	positional, err := exactArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	if expiresIn < 0 {
+		return errors.New("--expires-in must be positive")
+	}
+	var expiresAt *time.Time
+	if expiresIn > 0 {
+		at := time.Now().Add(expiresIn)
+		expiresAt = &at
+	}
+
+	b, err := e.Backend()
+	if err != nil {
+		return err
+	}
+	created, err := b.AddSecret(ctx, positional[0], label, expiresAt)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(e.stderr, "Store the client secret now; it cannot be shown again.")
+	fields := append(secretFields(&created.ClientSecretInfo), [2]string{"Client Secret", created.ClientSecret})
+	return e.out.Fields(created, fields)
+}
+
+func secretPromote(ctx context.Context, e *env, args []string) error {
+	fs := newFlagSet(e, "secret promote", "<client-id> <secret-id>")
+	positional, err := exactArgs(fs, args, 2)
+	if err != nil {
+		return err
+	}
+	secretID, err := strconv.ParseInt(positional[1], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid secret ID %q", positional[1])
+	}
+
+	b, err := e.Backend()
+	if err != nil {
+		return err
+	}
+	info, err := b.PromoteSecret(ctx, positional[0], secretID)
+	if err != nil {
+		return err
+	}
+	return e.out.Fields(info, secretFields(info))
+}
+
+func secretExpire(ctx context.Context, e *env, args []string) error {
+	fs := newFlagSet(e, "secret expire", "<client-id> <secret-id> [--grace <duration>]")
+	var grace time.Duration
+	fs.DurationVar(&grace, "grace", 0, "keep the secret valid for this long (default expire now)")
+	positional, err := exactArgs(fs, args, 2)
+	if err != nil {
+		return err
+	}
+	secretID, err := strconv.ParseInt(positional[1], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid secret ID %q", positional[1])
+	}
+	if grace < 0 {
+		return errors.New("--grace must not be negative")
+	}
+
+	b, err := e.Backend()
+	if err != nil {
+		return err
+	}
+	info, err := b.ExpireSecret(ctx, positional[0], secretID, grace)
+	if err != nil {
+		return err
+	}
+	return e.out.Fields(info, secretFields(info))
+}
+
+// secretFields lists a secret's attributes for table output
+func secretFields(secret *auth.ClientSecretInfo) [][2]string {
+	return [][2]string{
+		{"Secret ID", strconv.FormatInt(secret.ID, 10)},
+		{"Client ID", secret.ClientID},
+		{"Label", secret.Label},
+		{"Primary", strconv.FormatBool(secret.Primary)},
+		{"Created", formatTime(secret.CreatedAt)},
+		{"Expires", formatOptionalTime(secret.ExpiresAt)},
+		{"Last Used", formatOptionalTime(secret.LastUsedAt)},
+	}
+}
+
+func scopeAdd(ctx context.Context, e *env, args []string) error {
+	return changeScopes(ctx, e, "scope add", args, func(scopes []string, changes []string) []string {
+		for _, scope := range changes {
+			if !slices.Contains(scopes, scope) {
+				scopes = append(scopes, scope)
+			}
+		}
+		return scopes
+	})
+}
+
+func scopeRemove(ctx context.Context, e *env, args []string) error {
+	return changeScopes(ctx, e, "scope remove", args, func(scopes []string, changes []string) []string {
+		return slices.DeleteFunc(scopes, func(scope string) bool {
+			return slices.Contains(changes, scope)
+		})
+	})
+}
+
+// changeScopes reads a client's scopes, edits them and writes them back
+func changeScopes(ctx context.Context, e *env, name string, args []string, edit func(scopes, changes []string) []string) error {
+	fs := newFlagSet(e, name, "<client-id> <scope>...")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) < 2 {
+		fs.Usage()
+		return errors.New("expected a client ID and at least one scope")
+	}
+
+	b, err := e.Backend()
+	if err != nil {
+		return err
+	}
+	client, err := b.GetClient(ctx, positional[0])
+	if err != nil {
+		return err
+	}
+
+	scopes := edit(slices.Clone(client.AllowedScopes), positional[1:])
+	if slices.Equal(scopes, client.AllowedScopes) {
+		return e.out.Fields(client, clientFields(client))
+	}
+	return applyUpdate(ctx, e, client.ClientID, auth.ClientUpdate{AllowedScopes: &scopes})
+}
+
+// applyUpdate sends an update and prints the resulting client
+func applyUpdate(ctx context.Context, e *env, clientID string, update auth.ClientUpdate) error {
+	b, err := e.Backend()
+	if err != nil {
+		return err
+	}
+	client, err := b.UpdateClient(ctx, clientID, update)
+	if err != nil {
+		return err
+	}
+	return e.out.Fields(client, clientFields(client))
+}
+
+// clientFields lists a client's attributes for table output
+func clientFields(client *auth.ClientRecord) [][2]string {
+	fields := [][2]string{
+		{"Client ID", client.ClientID},
+		{"Name", client.Name},
+		{"Token TTL", strconv.Itoa(int(client.AccessTokenTTL)) + "s"},
+		{"Active", strconv.FormatBool(client.Active)},
+		{"Scopes", formatList(client.AllowedScopes)},
+		{"Created", formatTime(client.CreatedAt)},
+		{"Updated", formatTime(client.UpdatedAt)},
+	}
+	if client.ValidFrom != nil {
+		fields = append(fields, [2]string{"Valid From", formatTime(*client.ValidFrom)})
+	}
+	if client.ValidUntil != nil {
+		fields = append(fields, [2]string{"Valid Until", formatTime(*client.ValidUntil)})
+	}
+	if client.TokenRateLimit != nil {
+		fields = append(fields, [2]string{"Token Limit", formatLimit(client.TokenRateLimit)})
+	}
+	if client.ValidateRateLimit != nil {
+		fields = append(fields, [2]string{"Validate Limit", formatLimit(client.ValidateRateLimit)})
+	}
+	if len(client.AllowedCIDRs) > 0 {
+		fields = append(fields, [2]string{"Allowed CIDRs", formatList(client.AllowedCIDRs)})
+		fields = append(fields, [2]string{"CIDRs On Use", strconv.FormatBool(client.EnforceCIDRsOnUse)})
+	}
+	if client.NetworkClaim {
+		fields = append(fields, [2]string{"Network Claim", "true"})
+	}
+	if client.DeletedAt != nil {
+		fields = append(fields, [2]string{"Deleted", formatTime(*client.DeletedAt)})
+	}
+	return fields
+}
+
+// decodedToken is a JWT's header and claims, unverified
+type decodedToken struct {
+	Header map[string]any `json:"header"`
+	Claims map[string]any `json:"claims"`
+}
+
+func tokenDecode(ctx context.Context, e *env, args []string) error {
+	fs := newFlagSet(e, "token decode", "[<token>|-]")
+	token, err := tokenArg(e, fs, args)
+	if err != nil {
+		return err
+	}
+
+	decoded, err := decodeToken(token)
+	if err != nil {
+		return err
+	}
+
+	var rows [][]string
+	for _, part := range []struct {
+		name   string
+		values map[string]any
+	}{{"header", decoded.Header}, {"claim", decoded.Claims}} {
+		keys := make([]string, 0, len(part.values))
+		for key := range part.values {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			rows = append(rows, []string{part.name, key, formatClaim(key, part.values[key])})
+		}
+	}
+	return e.out.Print(decoded, []string{"PART", "NAME", "VALUE"}, rows)
+}
+
+func tokenVerify(ctx context.Context, e *env, args []string) error {
+	fs := newFlagSet(e, "token verify", "[<token>|-]")
+	token, err := tokenArg(e, fs, args)
+	if err != nil {
+		return err
+	}
+
+	b, err := e.Backend()
+	if err != nil {
+		return err
+	}
+	claims, err := b.VerifyToken(ctx, token)
+	if err != nil {
+		return fmt.Errorf("token is not valid: %w", err)
+	}
+
+	fields := [][2]string{
+		{"Valid", "true"},
+		{"Client ID", claims.ClientID},
+		{"Token ID", claims.TokenID},
+		{"Scopes", formatList(claims.Scope)},
+	}
+	if claims.IssuedAt != nil {
+		fields = append(fields, [2]string{"Issued", formatTime(claims.IssuedAt.Time)})
+	}
+	if claims.ExpiresAt != nil {
+		fields = append(fields, [2]string{"Expires", formatTime(claims.ExpiresAt.Time)})
+	}
+	return e.out.Fields(claims, fields)
+}
+
+func tokenRevoke(ctx context.Context, e *env, args []string) error {
+	fs := newFlagSet(e, "token revoke", "[<token>|-]")
+	token, err := tokenArg(e, fs, args)
+	if err != nil {
+		return err
+	}
+
+	b, err := e.Backend()
+	if err != nil {
+		return err
+	}
+	if err := b.RevokeToken(ctx, token); err != nil {
+		return err
+	}
+
+	result := map[string]any{"revoked": true}
+	fields := [][2]string{{"Revoked", "true"}}
+	if decoded, err := decodeToken(token); err == nil {
+		result["token_id"] = decoded.Claims["token_id"]
+		result["client_id"] = decoded.Claims["client_id"]
+		fields = append(fields,
+			[2]string{"Client ID", fmt.Sprint(decoded.Claims["client_id"])},
+			[2]string{"Token ID", fmt.Sprint(decoded.Claims["token_id"])})
+	}
+	return e.out.Fields(result, fields)
+}
+
+// tokenArg reads the token from the single argument, or from stdin when it is "-" or missing
+func tokenArg(e *env, fs *flag.FlagSet, args []string) (string, error) {
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case len(positional) > 1:
+		fs.Usage()
+		return "", errors.New("expected at most one token")
+	case len(positional) == 1 && positional[0] != "-":
+		return positional[0], nil
+	}
+
+	line, err := bufio.NewReader(e.stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	token := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "Bearer "))
+	if token == "" {
+		return "", errors.New("no token given")
+	}
+	return token, nil
+}
+
+// decodeToken splits a JWT and decodes its header and claims without checking the signature
+func decodeToken(token string) (*decodedToken, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("token must have three dot-separated parts")
+	}
+
+	decoded := &decodedToken{}
+	for i, target := range []*map[string]any{&decoded.Header, &decoded.Claims} {
+		data, err := base64.RawURLEncoding.DecodeString(parts[i])
+		if err != nil {
+			return nil, fmt.Errorf("malformed token segment %d: %w", i+1, err)
+		}
+		if err := json.Unmarshal(data, target); err != nil {
+			return nil, fmt.Errorf("malformed token segment %d: %w", i+1, err)
+		}
+	}
+	return decoded, nil
+}
+
+// formatClaim renders a claim value, showing NumericDate claims as times
+func formatClaim(name string, value any) string {
+	switch v := value.(type) {
+	case float64:
+		if name == "exp" || name == "iat" || name == "nbf" {
+			return formatTime(time.Unix(int64(v), 0))
+		}
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case []any:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			items = append(items, fmt.Sprint(item))
+		}
+		return formatList(items)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func keyRotate(ctx context.Context, e *env, args []string) error {
+	fs := newFlagSet(e, "key rotate", "")
+	if _, err := exactArgs(fs, args, 0); err != nil {
+		return err
+	}
+
+	b, err := e.Backend()
+	if err != nil {
+		return err
+	}
+	info, err := b.RotateKey(ctx)
+	if err != nil {
+		return err
+	}
+	return e.out.Fields(info, [][2]string{
+		{"Key ID", info.KeyID},
+		{"Algorithm", info.Algorithm},
+		{"Created", formatTime(info.CreatedAt)},
+		{"Retired", formatList(info.RetiredKeys)},
+	})
+}
+
+func cacheStats(ctx context.Context, e *env, args []string) error {
+	fs := newFlagSet(e, "cache stats", "")
+	if _, err := exactArgs(fs, args, 0); err != nil {
+		return err
+	}
+
+	b, err := e.Backend()
+	if err != nil {
+		return err
+	}
+	stats, err := b.CacheStats(ctx)
+	if err != nil {
+		return err
+	}
+	return printStats(e, stats)
+}
+
+// printStats prints a server's statistics as fields sorted by name
+func printStats(e *env, stats map[string]any) error {
+	keys := make([]string, 0, len(stats))
+	for key := range stats {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	fields := make([][2]string, 0, len(keys))
+	for _, key := range keys {
+		fields = append(fields, [2]string{key, formatClaim(key, stats[key])})
+	}
+	return e.out.Fields(stats, fields)
+}
+
+func cacheList(ctx context.Context, e *env, args []string) error {
+	fs := newFlagSet(e, "cache list", "")
+	if _, err := exactArgs(fs, args, 0); err != nil {
+		return err
+	}
+
+	b, err := e.Backend()
+	if err != nil {
+		return err
+	}
+	entries, err := b.CacheEntries(ctx)
+	if err != nil {
+		return err
+	}
+
+	rows := make([][]string, 0, len(entries))
+	for _, entry := range entries {
+		rows = append(rows, []string{
+			entry.ClientID,
+			entry.Name,
+			strconv.FormatBool(entry.Active),
+			strconv.Itoa(entry.Secrets),
+			formatTime(entry.CachedAt),
+			formatTime(entry.ExpiresAt),
+		})
+	}
+	return e.out.Print(entries, []string{"CLIENT ID", "NAME", "ACTIVE", "SECRETS", "CACHED", "EXPIRES"}, rows)
+}
+
+// cacheClear drops one client from the server's cache, or all of them without an argument
+func cacheClear(ctx context.Context, e *env, args []string) error {
+	fs := newFlagSet(e, "cache clear", "[<client-id>]")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 1 {
+		fs.Usage()
+		return errors.New("give at most one client ID")
+	}
+	var clientID string
+	if len(positional) == 1 {
+		clientID = positional[0]
+	}
+
+	b, err := e.Backend()
+	if err != nil {
+		return err
+	}
+	if err := b.ClearCache(ctx, clientID); err != nil {
+		return err
+	}
+	if clientID != "" {
+		fmt.Fprintf(e.stderr, "Client %s dropped from the cache.\n", clientID)
+	} else {
+		fmt.Fprintln(e.stderr, "Cache cleared.")
+	}
+	return nil
+}
+
+func batchStatus(ctx context.Context, e *env, args []string) error {
+	fs := newFlagSet(e, "batch status", "")
+	if _, err := exactArgs(fs, args, 0); err != nil {
+		return err
+	}
+
+	b, err := e.Backend()
+	if err != nil {
+		return err
+	}
+	state, err := b.BatchState(ctx)
+	if err != nil {
+		return err
+	}
+	return e.out.Fields(state, batchFields(state))
+}
+
+// batchFlush starts writing the pending tokens; the write finishes in the background
+func batchFlush(ctx context.Context, e *env, args []string) error {
+	fs := newFlagSet(e, "batch flush", "")
+	if _, err := exactArgs(fs, args, 0); err != nil {
+		return err
+	}
+
+	b, err := e.Backend()
+	if err != nil {
+		return err
+	}
+	state, err := b.FlushBatch(ctx)
+	if err != nil {
+		return err
+	}
+	return e.out.Fields(state, batchFields(state))
+}
+
+func batchFields(state *auth.BatchWriterState) [][2]string {
+	return [][2]string{
+		{"Pending", strconv.Itoa(state.Pending)},
+		{"In Flight", strconv.FormatInt(state.InFlight, 10)},
+		{"Max Batch", strconv.Itoa(state.MaxBatch)},
+		{"Flush Interval", (time.Duration(state.FlushIntervalMS) * time.Millisecond).String()},
+		{"Written", strconv.FormatInt(state.Written, 10)},
+		{"Failed", strconv.FormatInt(state.Failed, 10)},
+		{"Last Flushed", formatOptionalTime(state.LastFlushedAt)},
+		{"Last Error", formatOptional(state.LastError)},
+	}
+}
+
+func dbStats(ctx context.Context, e *env, args []string) error {
+	fs := newFlagSet(e, "db stats", "")
+	if _, err := exactArgs(fs, args, 0); err != nil {
+		return err
+	}
+
+	b, err := e.Backend()
+	if err != nil {
+		return err
+	}
+	stats, err := b.DBStats(ctx)
+	if err != nil {
+		return err
+	}
+	return printStats(e, stats)
+}
+
+// logLevel prints the server's log level, or changes it until the server restarts
+func logLevel(ctx context.Context, e *env, args []string) error {
+	fs := newFlagSet(e, "log level", "[<level>]")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 1 {
+		fs.Usage()
+		return errors.New("give at most one level")
+	}
+
+	b, err := e.Backend()
+	if err != nil {
+		return err
+	}
+	var level string
+	if len(positional) == 1 {
+		level, err = b.SetLogLevel(ctx, positional[0])
+	} else {
+		level, err = b.LogLevel(ctx)
+	}
+	if err != nil {
+		return err
+	}
+	return e.out.Fields(map[string]string{"level": level}, [][2]string{{"Level", level}})
+}
+
+func lockoutList(ctx context.Context, e *env, args []string) error {
+	fs := newFlagSet(e, "lockout list", "")
+	if _, err := exactArgs(fs, args, 0); err != nil {
+		return err
+	}
+
+	b, err := e.Backend()
+	if err != nil {
+		return err
+	}
+	lockouts, err := b.ListLockouts(ctx)
+	if err != nil {
+		return err
+	}
+
+	rows := make([][]string, 0, len(lockouts))
+	for _, lockout := range lockouts {
+		rows = append(rows, []string{
+			lockout.Subject,
+			strconv.Itoa(lockout.Failures),
+			formatTime(lockout.LastFailure),
+			formatOptionalTime(lockout.LockedUntil),
+		})
+	}
+	return e.out.Print(lockouts, []string{"SUBJECT", "FAILURES", "LAST FAILURE", "LOCKED UNTIL"}, rows)
+}
+
+func lockoutUnlock(ctx context.Context, e *env, args []string) error {
+	fs := newFlagSet(e, "lockout unlock", "--client <client-id> | --ip <address>")
+	var clientID, ip string
+	fs.StringVar(&clientID, "client", "", "client ID to unlock")
+	fs.StringVar(&ip, "ip", "", "source IP address to unlock")
+	if _, err := exactArgs(fs, args, 0); err != nil {
+		return err
+	}
+	if (clientID == "") == (ip == "") {
+		fs.Usage()
+		return errors.New("give exactly one of --client or --ip")
+	}
+	if ip != "" && net.ParseIP(ip) == nil {
+		return fmt.Errorf("--ip: %q is not an IP address", ip)
+	}
+
+	b, err := e.Backend()
+	if err != nil {
+		return err
+	}
+	if clientID != "" {
+		err = b.UnlockClient(ctx, clientID)
+	} else {
+		err = b.UnlockIP(ctx, ip)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(e.stderr, "Lockout cleared.")
+	return nil
+}
+
+func auditList(ctx context.Context, e *env, args []string) error {
+	fs := newFlagSet(e, "audit list", "[--client <client-id>] [--action <action>] [--since <time>] [--until <time>] [--limit <n>] [--offset <n>]")
+	var (
+		filter       auth.AuditFilter
+		since, until timeFlag
+	)
+	fs.StringVar(&filter.ClientID, "client", "", "only entries about this client")
+	fs.StringVar(&filter.Action, "action", "", "only this action, such as token.issue or client.updated")
+	fs.Var(&since, "since", "only entries at or after this RFC 3339 time")
+	fs.Var(&until, "until", "only entries before this RFC 3339 time")
+	fs.IntVar(&filter.Limit, "limit", auth.DefaultClientPageSize, "page size")
+	fs.IntVar(&filter.Offset, "offset", 0, "entries to skip")
+	if _, err := exactArgs(fs, args, 0); err != nil {
+		return err
+	}
+	filter.Since, filter.Until = since.time, until.time
+
+	b, err := e.Backend()
+	if err != nil {
+		return err
+	}
+	entries, err := b.AuditEntries(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	rows := make([][]string, 0, len(entries))
+	for _, entry := range entries {
+		rows = append(rows, []string{
+			strconv.FormatInt(entry.Seq, 10),
+			formatTime(entry.Time),
+			entry.Action,
+			entry.Outcome,
+			entry.Actor,
+			entry.ClientID,
+			entry.IP,
+			entry.Detail,
+		})
+	}
+	return e.out.Print(entries, []string{"SEQ", "TIME", "ACTION", "OUTCOME", "ACTOR", "CLIENT ID", "IP", "DETAIL"}, rows)
+}
+
+// auditVerify checks the audit chains, failing if any is broken
+// --file checks an audit file on this host and needs neither a server nor the database
+func auditVerify(ctx context.Context, e *env, args []string) error {
+	fs := newFlagSet(e, "audit verify", "[--file <path>]")
+	var path string
+	fs.StringVar(&path, "file", "", "verify this audit file instead of the server's audit logs")
+	if _, err := exactArgs(fs, args, 0); err != nil {
+		return err
+	}
+
+	var results map[string]*auth.AuditVerification
+	if path != "" {
+		result, err := auth.VerifyAuditFile(path)
+		if err != nil {
+			return err
+		}
+		results = map[string]*auth.AuditVerification{auth.AuditSinkFile: result}
+	} else {
+		b, err := e.Backend()
+		if err != nil {
+			return err
+		}
+		if results, err = b.VerifyAudit(ctx); err != nil {
+			return err
+		}
+	}
+
+	sinks := make([]string, 0, len(results))
+	for sink := range results {
+		sinks = append(sinks, sink)
+	}
+	sort.Strings(sinks)
+	rows := make([][]string, 0, len(sinks))
+	var broken []string
+	for _, sink := range sinks {
+		result := results[sink]
+		problem := "-"
+		if !result.Valid {
+			problem = fmt.Sprintf("entry %d: %s", result.BrokenSeq, result.Problem)
+			broken = append(broken, sink)
+		}
+		rows = append(rows, []string{sink, strconv.FormatInt(result.Entries, 10), strconv.FormatBool(result.Valid), problem})
+	}
+	if err := e.out.Print(results, []string{"SINK", "ENTRIES", "VALID", "PROBLEM"}, rows); err != nil {
+		return err
+	}
+	if len(broken) > 0 {
+		return fmt.Errorf("audit chain broken in %s", strings.Join(broken, ", "))
+	}
+	return nil
+}
+
+func webhookAdd(ctx context.Context, e *env, args []string) error {
+	fs := newFlagSet(e, "webhook add", "--url <url> [--event <type>]... [--client <client-id>]")
+	var (
+		sub    auth.WebhookSubscription
+		events stringList
+	)
+	fs.StringVar(&sub.URL, "url", "", "http or https URL to POST events to")
+	fs.Var(&events, "event", "event type or prefix such as client.*; repeat for more (default every event)")
+	fs.StringVar(&sub.ClientID, "client", "", "only events about this client")
+	if _, err := exactArgs(fs, args, 0); err != nil {
+		return err
+	}
+	if sub.URL == "" {
+		fs.Usage()
+		return errors.New("--url is required")
+	}
+	sub.Events = events
+
+	b, err := e.Backend()
+	if err != nil {
+		return err
+	}
+	created, err := b.CreateWebhook(ctx, sub)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(e.stderr, "Store the signing secret now; it cannot be shown again.")
+	fields := append(webhookFields(&created.WebhookSubscription), [2]string{"Secret", created.Secret})
+	return e.out.Fields(created, fields)
+}
+
+func webhookList(ctx context.Context, e *env, args []string) error {
+	fs := newFlagSet(e, "webhook list", "")
+	if _, err := exactArgs(fs, args, 0); err != nil {
+		return err
+	}
+
+	b, err := e.Backend()
+	if err != nil {
+		return err
+	}
+	subscriptions, err := b.ListWebhooks(ctx)
+	if err != nil {
+		return err
+	}
+
+	rows := make([][]string, 0, len(subscriptions))
+	for _, sub := range subscriptions {
+		rows = append(rows, []string{
+			strconv.FormatInt(sub.ID, 10),
+			sub.URL,
+			formatWebhookEvents(sub.Events),
+			formatOptional(sub.ClientID),
+			formatTime(sub.CreatedAt),
+		})
+	}
+	return e.out.Print(subscriptions, []string{"ID", "URL", "EVENTS", "CLIENT ID", "CREATED"}, rows)
+}
+
+func webhookRemove(ctx context.Context, e *env, args []string) error {
+	fs := newFlagSet(e, "webhook remove", "<webhook-id>")
+	positional, err := exactArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	id, err := strconv.ParseInt(positional[0], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid webhook ID %q", positional[0])
+	}
+
+	b, err := e.Backend()
+	if err != nil {
+		return err
+	}
+	if err := b.DeleteWebhook(ctx, id); err != nil {
+		return err
+	}
+	fmt.Fprintln(e.stderr, "Webhook removed; its undelivered events were dropped.")
+	return nil
+}
+
+func webhookLog(ctx context.Context, e *env, args []string) error {
+	fs := newFlagSet(e, "webhook log", "<webhook-id> [--limit <n>]")
+	var limit int
+	fs.IntVar(&limit, "limit", auth.DefaultClientPageSize, "most recent deliveries to show")
+	positional, err := exactArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	id, err := strconv.ParseInt(positional[0], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid webhook ID %q", positional[0])
+	}
+
+	b, err := e.Backend()
+	if err != nil {
+		return err
+	}
+	deliveries, err := b.WebhookDeliveries(ctx, id, limit)
+	if err != nil {
+		return err
+	}
+
+	rows := make([][]string, 0, len(deliveries))
+	for _, d := range deliveries {
+		lastStatus := "-"
+		if d.LastStatus != 0 {
+			lastStatus = strconv.Itoa(d.LastStatus)
+		}
+		rows = append(rows, []string{
+			strconv.FormatInt(d.ID, 10),
+			d.EventType,
+			d.Status,
+			strconv.Itoa(d.Attempts),
+			lastStatus,
+			formatOptional(d.LastError),
+			formatTime(d.CreatedAt),
+			formatOptionalTime(d.DeliveredAt),
+		})
+	}
+	return e.out.Print(deliveries, []string{"ID", "EVENT", "STATUS", "ATTEMPTS", "LAST STATUS", "LAST ERROR", "CREATED", "DELIVERED"}, rows)
+}
+
+func webhookFields(sub *auth.WebhookSubscription) [][2]string {
+	return [][2]string{
+		{"ID", strconv.FormatInt(sub.ID, 10)},
+		{"URL", sub.URL},
+		{"Events", formatWebhookEvents(sub.Events)},
+		{"Client ID", formatOptional(sub.ClientID)},
+		{"Created", formatTime(sub.CreatedAt)},
+	}
+}
+
+// formatWebhookEvents shows an empty filter as the every-event wildcard it means
+func formatWebhookEvents(events []string) string {
+	if len(events) == 0 {
+		return "*"
+	}
+	return strings.Join(events, ",")
+}
+
+func migrateStatus(ctx context.Context, e *env, args []string) error {
+	fs := newFlagSet(e, "migrate status", "")
+	if _, err := exactArgs(fs, args, 0); err != nil {
+		return err
+	}
+
+	b, err := e.Backend()
+	if err != nil {
+		return err
+	}
+	states, err := b.MigrationStatus(ctx)
+	if err != nil {
+		return err
+	}
+	return printMigrations(e, states)
+}
+
+func migrateUp(ctx context.Context, e *env, args []string) error {
+	fs := newFlagSet(e, "migrate up", "")
+	if _, err := exactArgs(fs, args, 0); err != nil {
+		return err
+	}
+
+	b, err := e.Backend()
+	if err != nil {
+		return err
+	}
+	applied, err := b.Migrate(ctx)
+	if printErr := printMigrations(e, applied); printErr != nil && err == nil {
+		err = printErr
+	}
+	if err == nil && len(applied) == 0 && e.opts.output == outputTable {
+		fmt.Fprintln(e.stderr, "Schema is up to date.")
+	}
+	return err
+}
+
+// printMigrations lists migrations with their applied times
+func printMigrations(e *env, states []auth.MigrationState) error {
+	if states == nil {
+		states = []auth.MigrationState{}
+	}
+	rows := make([][]string, 0, len(states))
+	for _, state := range states {
+		applied := "pending"
+		if state.AppliedAt != nil {
+			applied = formatTime(*state.AppliedAt)
+		}
+		rows = append(rows, []string{strconv.Itoa(state.Version), state.Name, applied})
+	}
+	return e.out.Print(states, []string{"VERSION", "NAME", "APPLIED"}, rows)
+}
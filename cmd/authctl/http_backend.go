@@ -0,0 +1,392 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"auth-server/auth"
+	"auth-server/middleware"
+)
+
+// httpBackend works through a running server's admin API
+type httpBackend struct {
+	baseURL    string // e.g. http://127.0.0.1:8081/auth-server/v1
+	token      string
+	httpClient *http.Client
+}
+
+func newHTTPBackend(baseURL, token string) *httpBackend {
+	return &httpBackend{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		token:      token,
+		httpClient: &http.Client{},
+	}
+}
+
+// serverError is an error response in the server's APIError format
+type serverError struct {
+	StatusCode  int    `json:"-"`
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
+	RequestID   string `json:"request_id"`
+}
+
+func (e *serverError) Error() string {
+	msg := fmt.Sprintf("server returned %d", e.StatusCode)
+	if e.Code != "" {
+		msg += ": " + e.Code
+	}
+	if e.Description != "" {
+		msg += ": " + e.Description
+	}
+	if e.RequestID != "" {
+		msg += " (request " + e.RequestID + ")"
+	}
+	return msg
+}
+
+func (b *httpBackend) CreateClient(ctx context.Context, client auth.ClientRecord) (*createdClient, error) {
+	body := map[string]any{
+		"client_id":        client.ClientID,
+		"name":             client.Name,
+		"access_token_ttl": client.AccessTokenTTL,
+		"allowed_scopes":   client.AllowedScopes,
+	}
+	if client.ValidFrom != nil {
+		body["valid_from"] = client.ValidFrom
+	}
+	if client.ValidUntil != nil {
+		body["valid_until"] = client.ValidUntil
+	}
+	var created createdClient
+	err := b.do(ctx, http.MethodPost, "/admin/clients", b.token, body, &created)
+	return &created, err
+}
+
+func (b *httpBackend) GetClient(ctx context.Context, clientID string) (*auth.ClientRecord, error) {
+	var client auth.ClientRecord
+	err := b.do(ctx, http.MethodGet, "/admin/clients/"+url.PathEscape(clientID), b.token, nil, &client)
+	return &client, err
+}
+
+func (b *httpBackend) ListClients(ctx context.Context, filter auth.ClientFilter) (*auth.ClientPage, error) {
+	query := url.Values{}
+	if filter.Active != nil {
+		query.Set("active", strconv.FormatBool(*filter.Active))
+	}
+	if filter.Search != "" {
+		query.Set("q", filter.Search)
+	}
+	if filter.Limit > 0 {
+		query.Set("limit", strconv.Itoa(filter.Limit))
+	}
+	if filter.Offset > 0 {
+		query.Set("offset", strconv.Itoa(filter.Offset))
+	}
+
+	path := "/admin/clients"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	var page auth.ClientPage
+	err := b.do(ctx, http.MethodGet, path, b.token, nil, &page)
+	return &page, err
+}
+
+func (b *httpBackend) UpdateClient(ctx context.Context, clientID string, update auth.ClientUpdate) (*auth.ClientRecord, error) {
+	var client auth.ClientRecord
+	err := b.do(ctx, http.MethodPatch, "/admin/clients/"+url.PathEscape(clientID), b.token, update, &client)
+	return &client, err
+}
+
+func (b *httpBackend) DeleteClient(ctx context.Context, clientID string) (*auth.ClientRecord, error) {
+	var client auth.ClientRecord
+	err := b.do(ctx, http.MethodDelete, "/admin/clients/"+url.PathEscape(clientID), b.token, nil, &client)
+	return &client, err
+}
+
+func (b *httpBackend) RestoreClient(ctx context.Context, clientID string) (*auth.ClientRecord, error) {
+	var client auth.ClientRecord
+	err := b.do(ctx, http.MethodPost, "/admin/clients/"+url.PathEscape(clientID)+"/restore", b.token, nil, &client)
+	return &client, err
+}
+
+func (b *httpBackend) ListSecrets(ctx context.Context, clientID string) ([]auth.ClientSecretInfo, error) {
+	var list struct {
+		Secrets []auth.ClientSecretInfo `json:"secrets"`
+	}
+	err := b.do(ctx, http.MethodGet, secretsPath(clientID), b.token, nil, &list)
+	return list.Secrets, err
+}
+
+func (b *httpBackend) AddSecret(ctx context.Context, clientID, label string, expiresAt *time.Time) (*createdSecret, error) {
+	body := map[string]any{"label": label}
+	if expiresAt != nil {
+		body["expires_at"] = expiresAt
+	}
+	var created createdSecret
+	err := b.do(ctx, http.MethodPost, secretsPath(clientID), b.token, body, &created)
+	return &created, err
+}
+
+func (b *httpBackend) PromoteSecret(ctx context.Context, clientID string, secretID int64) (*auth.ClientSecretInfo, error) {
+	var info auth.ClientSecretInfo
+	path := secretsPath(clientID) + "/" + strconv.FormatInt(secretID, 10) + "/promote"
+	err := b.do(ctx, http.MethodPost, path, b.token, nil, &info)
+	return &info, err
+}
+
+func (b *httpBackend) ExpireSecret(ctx context.Context, clientID string, secretID int64, grace time.Duration) (*auth.ClientSecretInfo, error) {
+	var info auth.ClientSecretInfo
+	path := secretsPath(clientID) + "/" + strconv.FormatInt(secretID, 10) + "/expire"
+	body := map[string]any{"grace_seconds": int64(grace / time.Second)}
+	err := b.do(ctx, http.MethodPost, path, b.token, body, &info)
+	return &info, err
+}
+
+// secretsPath is the admin API collection of a client's secrets
+func secretsPath(clientID string) string {
+	return "/admin/clients/" + url.PathEscape(clientID) + "/secrets"
+}
+
+// VerifyToken checks the signature against the server's JWKS; only ES256 tokens can be verified this way
+// Revocation is not checked, since that needs the server's own state
+func (b *httpBackend) VerifyToken(ctx context.Context, token string) (*auth.Claims, error) {
+	verifier, err := middleware.New(middleware.Config{
+		Keys: middleware.NewJWKS(b.baseURL+"/oauth/jwks", b.httpClient),
+	})
+	if err != nil {
+		return nil, err
+	}
+	claims, err := verifier.Verify(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	return &auth.Claims{
+		ClientID:         claims.ClientID,
+		TokenID:          claims.TokenID,
+		Scope:            claims.Scope,
+		RegisteredClaims: claims.RegisteredClaims,
+	}, nil
+}
+
+// RevokeToken presents the token to /revoke, which revokes the bearer token itself
+func (b *httpBackend) RevokeToken(ctx context.Context, token string) error {
+	return b.do(ctx, http.MethodPost, "/oauth/revoke", token, nil, nil)
+}
+
+func (b *httpBackend) RotateKey(ctx context.Context) (*auth.SigningKeyInfo, error) {
+	var info auth.SigningKeyInfo
+	err := b.do(ctx, http.MethodPost, "/admin/keys/rotate", b.token, nil, &info)
+	return &info, err
+}
+
+func (b *httpBackend) CacheStats(ctx context.Context) (map[string]any, error) {
+	var stats map[string]any
+	err := b.do(ctx, http.MethodGet, "/admin/cache/stats", b.token, nil, &stats)
+	return stats, err
+}
+
+func (b *httpBackend) CacheEntries(ctx context.Context) ([]auth.CacheEntryInfo, error) {
+	var resp struct {
+		Clients []auth.CacheEntryInfo `json:"clients"`
+	}
+	err := b.do(ctx, http.MethodGet, "/admin/cache/clients", b.token, nil, &resp)
+	return resp.Clients, err
+}
+
+// ClearCache drops one client from the server's cache, or every client when clientID is empty
+func (b *httpBackend) ClearCache(ctx context.Context, clientID string) error {
+	path := "/admin/cache"
+	if clientID != "" {
+		path += "/clients/" + url.PathEscape(clientID)
+	}
+	return b.do(ctx, http.MethodDelete, path, b.token, nil, nil)
+}
+
+func (b *httpBackend) BatchState(ctx context.Context) (*auth.BatchWriterState, error) {
+	var state auth.BatchWriterState
+	err := b.do(ctx, http.MethodGet, "/admin/batch", b.token, nil, &state)
+	return &state, err
+}
+
+func (b *httpBackend) FlushBatch(ctx context.Context) (*auth.BatchWriterState, error) {
+	var resp struct {
+		State auth.BatchWriterState `json:"state"`
+	}
+	err := b.do(ctx, http.MethodPost, "/admin/batch/flush", b.token, nil, &resp)
+	return &resp.State, err
+}
+
+func (b *httpBackend) DBStats(ctx context.Context) (map[string]any, error) {
+	var stats map[string]any
+	err := b.do(ctx, http.MethodGet, "/admin/db/stats", b.token, nil, &stats)
+	return stats, err
+}
+
+func (b *httpBackend) LogLevel(ctx context.Context) (string, error) {
+	var resp struct {
+		Level string `json:"level"`
+	}
+	err := b.do(ctx, http.MethodGet, "/admin/log-level", b.token, nil, &resp)
+	return resp.Level, err
+}
+
+func (b *httpBackend) SetLogLevel(ctx context.Context, level string) (string, error) {
+	var resp struct {
+		Level string `json:"level"`
+	}
+	err := b.do(ctx, http.MethodPut, "/admin/log-level", b.token, map[string]string{"level": level}, &resp)
+	return resp.Level, err
+}
+
+func (b *httpBackend) ListLockouts(ctx context.Context) ([]auth.LockoutInfo, error) {
+	var resp struct {
+		Lockouts []auth.LockoutInfo `json:"lockouts"`
+	}
+	err := b.do(ctx, http.MethodGet, "/admin/lockouts", b.token, nil, &resp)
+	return resp.Lockouts, err
+}
+
+func (b *httpBackend) UnlockClient(ctx context.Context, clientID string) error {
+	return b.do(ctx, http.MethodPost, "/admin/clients/"+url.PathEscape(clientID)+"/unlock", b.token, nil, nil)
+}
+
+func (b *httpBackend) UnlockIP(ctx context.Context, ip string) error {
+	return b.do(ctx, http.MethodPost, "/admin/ips/"+url.PathEscape(ip)+"/unlock", b.token, nil, nil)
+}
+
+func (b *httpBackend) AuditEntries(ctx context.Context, filter auth.AuditFilter) ([]auth.AuditEntry, error) {
+	query := url.Values{}
+	if filter.ClientID != "" {
+		query.Set("client_id", filter.ClientID)
+	}
+	if filter.Action != "" {
+		query.Set("action", filter.Action)
+	}
+	if filter.Since != nil {
+		query.Set("since", filter.Since.Format(time.RFC3339))
+	}
+	if filter.Until != nil {
+		query.Set("until", filter.Until.Format(time.RFC3339))
+	}
+	if filter.Limit > 0 {
+		query.Set("limit", strconv.Itoa(filter.Limit))
+	}
+	if filter.Offset > 0 {
+		query.Set("offset", strconv.Itoa(filter.Offset))
+	}
+
+	path := "/admin/audit"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	var resp struct {
+		Entries []auth.AuditEntry `json:"entries"`
+	}
+	err := b.do(ctx, http.MethodGet, path, b.token, nil, &resp)
+	return resp.Entries, err
+}
+
+func (b *httpBackend) VerifyAudit(ctx context.Context) (map[string]*auth.AuditVerification, error) {
+	var resp struct {
+		Sinks map[string]*auth.AuditVerification `json:"sinks"`
+	}
+	err := b.do(ctx, http.MethodGet, "/admin/audit/verify", b.token, nil, &resp)
+	return resp.Sinks, err
+}
+
+func (b *httpBackend) CreateWebhook(ctx context.Context, sub auth.WebhookSubscription) (*createdWebhook, error) {
+	req := map[string]any{"url": sub.URL, "events": sub.Events, "client_id": sub.ClientID}
+	var created createdWebhook
+	if err := b.do(ctx, http.MethodPost, "/admin/webhooks", b.token, req, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+func (b *httpBackend) ListWebhooks(ctx context.Context) ([]auth.WebhookSubscription, error) {
+	var resp struct {
+		Webhooks []auth.WebhookSubscription `json:"webhooks"`
+	}
+	err := b.do(ctx, http.MethodGet, "/admin/webhooks", b.token, nil, &resp)
+	return resp.Webhooks, err
+}
+
+func (b *httpBackend) DeleteWebhook(ctx context.Context, id int64) error {
+	return b.do(ctx, http.MethodDelete, "/admin/webhooks/"+strconv.FormatInt(id, 10), b.token, nil, nil)
+}
+
+func (b *httpBackend) WebhookDeliveries(ctx context.Context, id int64, limit int) ([]auth.WebhookDelivery, error) {
+	path := "/admin/webhooks/" + strconv.FormatInt(id, 10) + "/deliveries"
+	if limit > 0 {
+		path += "?limit=" + strconv.Itoa(limit)
+	}
+	var resp struct {
+		Deliveries []auth.WebhookDelivery `json:"deliveries"`
+	}
+	err := b.do(ctx, http.MethodGet, path, b.token, nil, &resp)
+	return resp.Deliveries, err
+}
+
+// Migrations change the schema under the server, so they are never run through it
+func (b *httpBackend) MigrationStatus(ctx context.Context) ([]auth.MigrationState, error) {
+	return nil, errDirectOnly
+}
+
+func (b *httpBackend) Migrate(ctx context.Context) ([]auth.MigrationState, error) {
+	return nil, errDirectOnly
+}
+
+func (b *httpBackend) Close() error {
+	b.httpClient.CloseIdleConnections()
+	return nil
+}
+
+// do sends a JSON request with a bearer token and decodes a JSON response into out
+func (b *httpBackend) do(ctx context.Context, method, path, token string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, b.baseURL+path, body)
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := b.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		apiErr := &serverError{StatusCode: resp.StatusCode}
+		json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(apiErr)
+		return apiErr
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
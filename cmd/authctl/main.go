@@ -0,0 +1,180 @@
+// Command authctl manages auth-server clients, tokens, signing keys and the database schema.
+//
+// It talks to a running server's admin API (--server) or, with --direct, to the
+// configured database itself:
+//
+//	authctl --server http://127.0.0.1:8081/auth-server/v1 --token $ADMIN_TOKEN client list
+//	authctl --direct client create --id billing --scope http://localhost:3000/api/invoices
+//	authctl --direct migrate up
+//	authctl audit verify --file ./logs/audit.log
+//	authctl --direct webhook add --url https://siem.example.com/hooks --event 'client.*' --event token.revoked
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"auth-server/auth"
+
+	_ "github.com/godror/godror" // Register Oracle driver
+	"github.com/rs/zerolog"
+)
+
+const usage = `Usage: authctl [global flags] <command> [flags]
+
+Commands:
+  client create   Register a client and print its generated secret
+  client list     List clients
+  client show     Show a client
+  client update   Change a client's name, token TTL, active flag, validity window, rate limits or networks
+  client disable  Deactivate a client, optionally revoking its tokens
+  client delete   Soft-delete a client
+  client restore  Restore a deleted client
+  secret list     List a client's secrets
+  secret add      Add a secondary secret and print it
+  secret promote  Make a secret the client's primary
+  secret expire   Expire a non-primary secret, optionally after a grace period
+  scope add       Grant scopes to a client
+  scope remove    Withdraw scopes from a client
+  token decode    Print a token's header and claims without verifying it
+  token verify    Verify a token's signature and claims
+  token revoke    Revoke a token
+  key rotate      Retire the active signing key and create a new one
+  cache stats     Show the server's client cache statistics
+  cache list      List the clients in the server's cache
+  cache clear     Drop one client, or every client, from the server's cache
+  batch status    Show the server's token batch writer queue and write history
+  batch flush     Write the server's pending tokens now
+  db stats        Show the server's database connection pool statistics
+  log level       Show or change the server's log level
+  lockout list    List client IDs and IPs with failed secret checks
+  lockout unlock  Lift a client ID's or IP's lockout
+  audit list      List audit log entries, filtered by client, action and time
+  audit verify    Check the audit logs' hash chains for tampering
+  webhook add     Subscribe a URL to events and print its signing secret
+  webhook list    List webhook subscriptions
+  webhook remove  Delete a webhook subscription and its undelivered events
+  webhook log     Show a webhook's recent deliveries and their status
+  migrate status  List schema migrations
+  migrate up      Apply pending schema migrations
+
+Global flags:
+`
+
+// options are the global flags shared by every command
+type options struct {
+	server  string
+	token   string
+	direct  bool
+	output  string
+	timeout time.Duration
+	verbose bool
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	if err := run(ctx, os.Args[1:], os.Stdin, os.Stdout, os.Stderr); err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintf(os.Stderr, "authctl: %v\n", err)
+		}
+		os.Exit(1)
+	}
+}
+
+// run parses the global flags and dispatches to a command
+func run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	var opts options
+	fs := flag.NewFlagSet("authctl", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&opts.server, "server", os.Getenv("AUTHCTL_SERVER"), "auth server admin listener base URL, e.g. http://127.0.0.1:8081/auth-server/v1 (env AUTHCTL_SERVER)")
+	fs.StringVar(&opts.token, "token", os.Getenv("AUTHCTL_TOKEN"), "admin bearer token (env AUTHCTL_TOKEN)")
+	fs.BoolVar(&opts.direct, "direct", false, "use the configured database instead of the admin API")
+	fs.StringVar(&opts.output, "output", outputTable, "output format: table or json")
+	fs.DurationVar(&opts.timeout, "timeout", 30*time.Second, "request timeout")
+	fs.BoolVar(&opts.verbose, "verbose", false, "log storage activity to stderr")
+	fs.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if opts.output != outputTable && opts.output != outputJSON {
+		return fmt.Errorf("unknown output format %q", opts.output)
+	}
+
+	// Direct mode shares the server's logging; keep it to warnings unless asked
+	if !opts.verbose {
+		zerolog.SetGlobalLevel(zerolog.WarnLevel)
+	}
+
+	rest := fs.Args()
+	if len(rest) < 2 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+	cmd, ok := commands[rest[0]+" "+rest[1]]
+	if !ok {
+		fs.Usage()
+		return fmt.Errorf("unknown command %q", strings.Join(rest[:2], " "))
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, opts.timeout)
+	defer cancel()
+
+	env := &env{opts: opts, out: newPrinter(stdout, opts.output), stdin: stdin, stderr: stderr}
+	defer env.close()
+	return cmd(ctx, env, rest[2:])
+}
+
+// env carries what a command needs: the global options, an output printer and a lazily opened backend
+type env struct {
+	opts    options
+	out     *printer
+	stdin   io.Reader
+	stderr  io.Writer
+	backend backend
+}
+
+// Backend returns the storage or HTTP backend selected by the global flags
+func (e *env) Backend() (backend, error) {
+	if e.backend != nil {
+		return e.backend, nil
+	}
+
+	if e.opts.direct {
+		if err := auth.ReadConfiguration(); err != nil {
+			return nil, fmt.Errorf("failed to load configuration: %w", err)
+		}
+		store, err := auth.OpenStore()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open database: %w", err)
+		}
+		e.backend = &directBackend{store: store, actor: localActor()}
+		return e.backend, nil
+	}
+
+	if e.opts.server == "" {
+		return nil, errors.New("either --server or --direct is required")
+	}
+	e.backend = newHTTPBackend(e.opts.server, e.opts.token)
+	return e.backend, nil
+}
+
+// close releases the backend, if one was opened
+func (e *env) close() {
+	if e.backend != nil {
+		e.backend.Close()
+	}
+}
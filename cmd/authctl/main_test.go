@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"auth-server/auth"
+)
+
+// runCLI runs authctl with args and returns stdout, stderr and the error
+func runCLI(t *testing.T, stdin string, args ...string) (string, string, error) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	err := run(context.Background(), args, strings.NewReader(stdin), &stdout, &stderr)
+	return stdout.String(), stderr.String(), err
+}
+
+func TestParseArgs_Interspersed(t *testing.T) {
+	var stderr bytes.Buffer
+	e := &env{stderr: &stderr}
+	fs := newFlagSet(e, "client update", "")
+	name := fs.String("name", "", "")
+	ttl := fs.Int("ttl", 0, "")
+
+	positional, err := parseArgs(fs, []string{"--name", "Billing", "billing", "--ttl", "60"})
+	if err != nil {
+		t.Fatalf("parseArgs failed: %v", err)
+	}
+	if len(positional) != 1 || positional[0] != "billing" || *name != "Billing" || *ttl != 60 {
+		t.Errorf("Unexpected parse: positional=%v name=%q ttl=%d", positional, *name, *ttl)
+	}
+}
+
+func TestClientCommands_HTTPBackend(t *testing.T) {
+	var updates []auth.ClientUpdate
+	client := auth.ClientRecord{ClientID: "billing", Name: "Billing", AccessTokenTTL: 600, AllowedScopes: []string{"a"}, Active: true}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer admin-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "unauthorized", "error_description": "admin token required"})
+			return
+		}
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/admin/clients":
+			var created auth.ClientRecord
+			json.NewDecoder(r.Body).Decode(&created)
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(createdClient{ClientRecord: created, ClientSecret: "s3cret"})
+		case r.Method == http.MethodGet && r.URL.Path == "/admin/clients":
+			if r.URL.Query().Get("active") != "true" {
+				t.Errorf("Expected active filter, got %q", r.URL.RawQuery)
+			}
+			json.NewEncoder(w).Encode(auth.ClientPage{Clients: []auth.ClientRecord{client}, Total: 1, Limit: 50})
+		case r.Method == http.MethodGet && r.URL.Path == "/admin/clients/billing":
+			json.NewEncoder(w).Encode(client)
+		case r.Method == http.MethodPatch && r.URL.Path == "/admin/clients/billing":
+			var update auth.ClientUpdate
+			json.NewDecoder(r.Body).Decode(&update)
+			updates = append(updates, update)
+			if update.AllowedScopes != nil {
+				client.AllowedScopes = *update.AllowedScopes
+			}
+			json.NewEncoder(w).Encode(client)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	global := []string{"--server", server.URL, "--token", "admin-token"}
+
+	stdout, stderr, err := runCLI(t, "", append(global, "--output", "json", "client", "create", "--id", "billing", "--scope", "a", "--scope", "b")...)
+	if err != nil {
+		t.Fatalf("client create failed: %v", err)
+	}
+	var created createdClient
+	if err := json.Unmarshal([]byte(stdout), &created); err != nil || created.ClientSecret != "s3cret" || len(created.AllowedScopes) != 2 {
+		t.Errorf("Unexpected create output %q: %v", stdout, err)
+	}
+	if !strings.Contains(stderr, "cannot be shown again") {
+		t.Errorf("Expected one-time secret warning, got %q", stderr)
+	}
+
+	stdout, _, err = runCLI(t, "", append(global, "client", "list", "--active", "true")...)
+	if err != nil {
+		t.Fatalf("client list failed: %v", err)
+	}
+	if !strings.HasPrefix(stdout, "CLIENT ID") || !strings.Contains(stdout, "billing") {
+		t.Errorf("Unexpected table output:\n%s", stdout)
+	}
+
+	if _, _, err := runCLI(t, "", append(global, "scope", "add", "billing", "b", "a")...); err != nil {
+		t.Fatalf("scope add failed: %v", err)
+	}
+	if len(updates) != 1 || updates[0].AllowedScopes == nil || strings.Join(*updates[0].AllowedScopes, ",") != "a,b" {
+		t.Errorf("Expected scopes [a b] to be written once, got %+v", updates)
+	}
+
+	_, _, err = runCLI(t, "", "--server", server.URL, "client", "show", "billing")
+	if apiErr, ok := err.(*serverError); !ok || apiErr.StatusCode != http.StatusUnauthorized || apiErr.Code != "unauthorized" {
+		t.Errorf("Expected unauthorized server error, got %v", err)
+	}
+}
+
+func TestSecretCommands_HTTPBackend(t *testing.T) {
+	var expireBody map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/admin/clients/billing/secrets":
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(createdSecret{
+				ClientSecretInfo: auth.ClientSecretInfo{ID: 7, ClientID: "billing", Label: "2026-q4"},
+				ClientSecret:     "n3w",
+			})
+		case r.Method == http.MethodPost && r.URL.Path == "/admin/clients/billing/secrets/6/expire":
+			json.NewDecoder(r.Body).Decode(&expireBody)
+			json.NewEncoder(w).Encode(auth.ClientSecretInfo{ID: 6, ClientID: "billing"})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	global := []string{"--server", server.URL, "--token", "admin-token"}
+
+	stdout, stderr, err := runCLI(t, "", append(global, "secret", "add", "billing", "--label", "2026-q4")...)
+	if err != nil {
+		t.Fatalf("secret add failed: %v", err)
+	}
+	if !strings.Contains(stdout, "n3w") || !strings.Contains(stderr, "cannot be shown again") {
+		t.Errorf("Expected the new secret to be printed once, got %q / %q", stdout, stderr)
+	}
+
+	if _, _, err := runCLI(t, "", append(global, "secret", "expire", "billing", "6", "--grace", "1h")...); err != nil {
+		t.Fatalf("secret expire failed: %v", err)
+	}
+	if expireBody["grace_seconds"] != float64(3600) {
+		t.Errorf("Expected a one hour grace period, got %v", expireBody)
+	}
+
+	if _, _, err := runCLI(t, "", append(global, "secret", "promote", "billing", "latest")...); err == nil {
+		t.Error("Expected a non-numeric secret ID to be rejected")
+	}
+}
+
+func TestTokenDecode(t *testing.T) {
+	encode := func(v any) string {
+		data, _ := json.Marshal(v)
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	token := encode(map[string]any{"alg": "ES256", "kid": "k1"}) + "." +
+		encode(map[string]any{"client_id": "billing", "scope": []string{"a", "b"}, "exp": 1700000000}) + ".sig"
+
+	stdout, _, err := runCLI(t, "Bearer "+token+"\n", "--output", "json", "token", "decode", "-")
+	if err != nil {
+		t.Fatalf("token decode failed: %v", err)
+	}
+	var decoded decodedToken
+	if err := json.Unmarshal([]byte(stdout), &decoded); err != nil {
+		t.Fatalf("Invalid JSON output: %v", err)
+	}
+	if decoded.Header["kid"] != "k1" || decoded.Claims["client_id"] != "billing" {
+		t.Errorf("Unexpected decode: %+v", decoded)
+	}
+
+	stdout, _, err = runCLI(t, "", "token", "decode", token)
+	if err != nil || !strings.Contains(stdout, "a, b") {
+		t.Errorf("Expected scopes in table output, got %q: %v", stdout, err)
+	}
+
+	if _, _, err := runCLI(t, "", "token", "decode", "not-a-token"); err == nil {
+		t.Error("Expected malformed token to be rejected")
+	}
+}
+
+func TestBackendModeErrors(t *testing.T) {
+	if _, _, err := runCLI(t, "", "client", "list"); err == nil || !strings.Contains(err.Error(), "--server or --direct") {
+		t.Errorf("Expected missing backend error, got %v", err)
+	}
+	if _, _, err := runCLI(t, "", "--server", "http://127.0.0.1:1", "migrate", "up"); err != errDirectOnly {
+		t.Errorf("Expected migrations to require --direct, got %v", err)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"auth-server/auth"
+)
+
+// Output formats
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+// printer writes command results as indented JSON or as an aligned table
+type printer struct {
+	w      io.Writer
+	format string
+}
+
+func newPrinter(w io.Writer, format string) *printer {
+	return &printer{w: w, format: format}
+}
+
+// Print writes v as JSON, or headers and rows as a table
+func (p *printer) Print(v any, headers []string, rows [][]string) error {
+	if p.format == outputJSON {
+		encoder := json.NewEncoder(p.w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(v)
+	}
+
+	tw := tabwriter.NewWriter(p.w, 0, 0, 2, ' ', 0)
+	if len(headers) > 0 {
+		fmt.Fprintln(tw, strings.Join(headers, "\t"))
+	}
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+// Fields writes v as JSON, or label/value pairs as a two-column table
+func (p *printer) Fields(v any, fields [][2]string) error {
+	rows := make([][]string, 0, len(fields))
+	for _, field := range fields {
+		rows = append(rows, []string{field[0] + ":", field[1]})
+	}
+	return p.Print(v, nil, rows)
+}
+
+// formatTime renders a timestamp for tables, leaving zero times blank
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Local().Format(time.RFC3339)
+}
+
+// formatOptionalTime renders an optional timestamp, showing "-" when absent
+func formatOptionalTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return formatTime(*t)
+}
+
+// formatList joins values for a table cell
+func formatList(values []string) string {
+	if len(values) == 0 {
+		return "-"
+	}
+	return strings.Join(values, ", ")
+}
+
+// formatLimit renders a rate limit as requests per second and burst
+func formatLimit(limit *auth.RateLimit) string {
+	if limit == nil || !limit.Enabled() {
+		return "-"
+	}
+	text := strconv.FormatFloat(limit.Rate, 'f', -1, 64) + "/s"
+	if limit.Burst > 0 {
+		text += ", burst " + strconv.Itoa(limit.Burst)
+	}
+	return text
+}
+
+// formatOptional renders an optional string, showing "-" when empty
+func formatOptional(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
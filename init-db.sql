@@ -4,13 +4,37 @@
 -- Create CLIENTS table
 CREATE TABLE clients (
     client_id VARCHAR2(100) PRIMARY KEY,
-    client_secret VARCHAR2(255) NOT NULL,
     client_name VARCHAR2(255),
     access_token_ttl NUMBER(10) DEFAULT 3600,
     allowed_scopes CLOB,
     created_at TIMESTAMP DEFAULT SYSTIMESTAMP,
     updated_at TIMESTAMP DEFAULT SYSTIMESTAMP,
-    active NUMBER(1) DEFAULT 1
+    active NUMBER(1) DEFAULT 1,
+    deleted_at TIMESTAMP,
+    valid_from TIMESTAMP,
+    valid_until TIMESTAMP,
+    token_rate NUMBER,
+    token_burst NUMBER(10),
+    validate_rate NUMBER,
+    validate_burst NUMBER(10),
+    allowed_cidrs VARCHAR2(2000),
+    cidrs_on_use NUMBER(1) DEFAULT 0,
+    network_claim NUMBER(1) DEFAULT 0
+);
+
+-- Create CLIENT_SECRETS table
+-- A client may hold several secrets while rotating; exactly one is primary
+-- secret_hash is an argon2id or bcrypt hash, or a legacy plaintext secret rehashed on first use
+CREATE TABLE client_secrets (
+    id NUMBER GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    client_id VARCHAR2(100) NOT NULL,
+    secret_hash VARCHAR2(255) NOT NULL,
+    label VARCHAR2(100),
+    is_primary NUMBER(1) DEFAULT 0 NOT NULL,
+    created_at TIMESTAMP DEFAULT SYSTIMESTAMP,
+    expires_at TIMESTAMP,
+    last_used_at TIMESTAMP,
+    CONSTRAINT fk_client_secrets_client FOREIGN KEY (client_id) REFERENCES clients(client_id)
 );
 
 -- Create TOKENS table
@@ -34,6 +58,71 @@ CREATE TABLE revoked_tokens (
     CONSTRAINT fk_revoked_tokens_client FOREIGN KEY (client_id) REFERENCES clients(client_id)
 );
 
+-- Create TOKEN_DENYLIST table (stateless token mode)
+-- Holds revoked JTIs only until the token would have expired anyway
+CREATE TABLE token_denylist (
+    token_id VARCHAR2(255) PRIMARY KEY,
+    client_id VARCHAR2(100) NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP DEFAULT SYSTIMESTAMP
+);
+
+-- Create AUTH_EVENTS table (cross-instance change feed)
+-- Every replica polls for rows newer than the last one it has applied
+CREATE TABLE auth_events (
+    id NUMBER GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    event_type VARCHAR2(50) NOT NULL,
+    origin VARCHAR2(255) NOT NULL,
+    client_id VARCHAR2(100),
+    token_id VARCHAR2(255),
+    key_id VARCHAR2(100),
+    expires_at TIMESTAMP,
+    revoked_before TIMESTAMP,
+    created_at TIMESTAMP DEFAULT SYSTIMESTAMP,
+    subject VARCHAR2(255)
+);
+
+-- Create CLIENT_REVOCATIONS table (client-wide revocation watermarks)
+-- Tokens issued to the client at or before revoked_before are rejected
+CREATE TABLE client_revocations (
+    client_id VARCHAR2(100) PRIMARY KEY,
+    revoked_before TIMESTAMP NOT NULL,
+    CONSTRAINT fk_client_revocations_client FOREIGN KEY (client_id) REFERENCES clients(client_id)
+);
+
+-- Create SIGNING_KEYS table (ES256 key ring)
+-- The newest key with no retired_at signs tokens; retired keys verify until the grace period ends
+CREATE TABLE signing_keys (
+    kid VARCHAR2(64) PRIMARY KEY,
+    algorithm VARCHAR2(16) NOT NULL,
+    private_key CLOB NOT NULL,
+    created_at TIMESTAMP DEFAULT SYSTIMESTAMP,
+    retired_at TIMESTAMP
+);
+
+-- Create CLIENT_AUDIT table (admin changes to clients)
+-- Written in the same transaction as the change it records
+CREATE TABLE client_audit (
+    id NUMBER GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    client_id VARCHAR2(100) NOT NULL,
+    action VARCHAR2(50) NOT NULL,
+    actor VARCHAR2(255) NOT NULL,
+    request_id VARCHAR2(100),
+    remote_addr VARCHAR2(100),
+    changes CLOB,
+    created_at TIMESTAMP DEFAULT SYSTIMESTAMP
+);
+
+-- Create RATE_LIMIT_HITS table
+-- Instances exchange their rate limit hits here when rate_limit.mode is "db"
+CREATE TABLE rate_limit_hits (
+    id NUMBER GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    limit_key VARCHAR2(200) NOT NULL,
+    instance_id VARCHAR2(255) NOT NULL,
+    hits NUMBER(10) NOT NULL,
+    created_at TIMESTAMP DEFAULT SYSTIMESTAMP
+);
+
 -- Create ENDPOINTS table
 CREATE TABLE endpoints (
     id NUMBER GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
@@ -47,6 +136,66 @@ CREATE TABLE endpoints (
     CONSTRAINT fk_endpoints_client FOREIGN KEY (client_id) REFERENCES clients(client_id)
 );
 
+-- Create AUDIT_LOG table (hash-chained security audit entries)
+CREATE TABLE audit_log (
+    seq NUMBER(19) PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    action VARCHAR2(50) NOT NULL,
+    outcome VARCHAR2(20) NOT NULL,
+    actor VARCHAR2(255) NOT NULL,
+    client_id VARCHAR2(100),
+    token_id VARCHAR2(255),
+    remote_addr VARCHAR2(100),
+    request_id VARCHAR2(100),
+    detail VARCHAR2(4000),
+    prev_hash VARCHAR2(64) NOT NULL,
+    hash VARCHAR2(64) NOT NULL
+);
+
+-- Create AUDIT_HEAD table (end of the audit_log chain, locked while entries are added)
+CREATE TABLE audit_head (
+    id NUMBER(1) PRIMARY KEY,
+    seq NUMBER(19) NOT NULL,
+    hash VARCHAR2(64) NOT NULL
+);
+
+INSERT INTO audit_head (id, seq, hash) VALUES (1, 0, '0000000000000000000000000000000000000000000000000000000000000000');
+
+-- Create WEBHOOK_SUBSCRIPTIONS table (endpoints notified of changes)
+CREATE TABLE webhook_subscriptions (
+    id NUMBER GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    url VARCHAR2(2000) NOT NULL,
+    secret VARCHAR2(255) NOT NULL,
+    event_types VARCHAR2(2000),
+    client_id VARCHAR2(100),
+    created_at TIMESTAMP DEFAULT SYSTIMESTAMP
+);
+
+-- Create WEBHOOK_OUTBOX table (events written in the same transaction as their change)
+CREATE TABLE webhook_outbox (
+    id NUMBER GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    event_id VARCHAR2(36) NOT NULL,
+    event_type VARCHAR2(50) NOT NULL,
+    subject VARCHAR2(255),
+    payload CLOB NOT NULL,
+    created_at TIMESTAMP DEFAULT SYSTIMESTAMP,
+    dispatched_at TIMESTAMP
+);
+
+-- Create WEBHOOK_DELIVERIES table (one per event and matching subscription, with retry state)
+CREATE TABLE webhook_deliveries (
+    id NUMBER GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    outbox_id NUMBER NOT NULL,
+    subscription_id NUMBER NOT NULL,
+    status VARCHAR2(20) NOT NULL,
+    attempts NUMBER(5) DEFAULT 0,
+    next_attempt_at TIMESTAMP,
+    last_status NUMBER(3),
+    last_error VARCHAR2(1000),
+    created_at TIMESTAMP DEFAULT SYSTIMESTAMP,
+    delivered_at TIMESTAMP
+);
+
 -- Create indexes for performance
 CREATE INDEX idx_tokens_client_id ON tokens(client_id);
 CREATE INDEX idx_tokens_expires_at ON tokens(expires_at);
@@ -54,37 +203,88 @@ CREATE INDEX idx_tokens_revoked ON tokens(revoked);
 CREATE INDEX idx_revoked_tokens_token_id ON revoked_tokens(token_id);
 CREATE INDEX idx_revoked_tokens_client_id ON revoked_tokens(client_id);
 CREATE INDEX idx_endpoints_client_id ON endpoints(client_id);
+CREATE INDEX idx_token_denylist_expires_at ON token_denylist(expires_at);
+CREATE INDEX idx_auth_events_created_at ON auth_events(created_at);
+CREATE INDEX idx_client_audit_client_id ON client_audit(client_id);
+CREATE INDEX idx_client_secrets_client_id ON client_secrets(client_id);
+CREATE INDEX idx_client_secrets_expires_at ON client_secrets(expires_at);
+CREATE INDEX idx_rate_limit_hits_created_at ON rate_limit_hits(created_at);
+CREATE INDEX idx_audit_log_created_at ON audit_log(created_at);
+CREATE INDEX idx_audit_log_client_id ON audit_log(client_id, created_at);
+CREATE INDEX idx_webhook_outbox_dispatched ON webhook_outbox(dispatched_at);
+CREATE INDEX idx_webhook_deliveries_due ON webhook_deliveries(status, next_attempt_at);
+CREATE INDEX idx_webhook_deliveries_sub ON webhook_deliveries(subscription_id);
+CREATE INDEX idx_webhook_deliveries_outbox ON webhook_deliveries(outbox_id);
+
+-- Create SCHEMA_MIGRATIONS table (authctl migrate)
+-- This script creates the schema as of the latest migration listed below
+CREATE TABLE schema_migrations (
+    version NUMBER(10) PRIMARY KEY,
+    name VARCHAR2(255) NOT NULL,
+    applied_at TIMESTAMP DEFAULT SYSTIMESTAMP
+);
+
+INSERT INTO schema_migrations (version, name) VALUES (1, 'baseline');
+INSERT INTO schema_migrations (version, name) VALUES (2, 'token denylist');
+INSERT INTO schema_migrations (version, name) VALUES (3, 'change feed');
+INSERT INTO schema_migrations (version, name) VALUES (4, 'client revocations');
+INSERT INTO schema_migrations (version, name) VALUES (5, 'signing keys');
+INSERT INTO schema_migrations (version, name) VALUES (6, 'client soft delete and audit');
+INSERT INTO schema_migrations (version, name) VALUES (7, 'client secret rotation');
+INSERT INTO schema_migrations (version, name) VALUES (8, 'client validity windows');
+INSERT INTO schema_migrations (version, name) VALUES (9, 'rate limits');
+INSERT INTO schema_migrations (version, name) VALUES (10, 'lockout events');
+INSERT INTO schema_migrations (version, name) VALUES (11, 'client networks');
+INSERT INTO schema_migrations (version, name) VALUES (12, 'audit log');
+INSERT INTO schema_migrations (version, name) VALUES (13, 'webhooks');
 
 -- Insert sample test data
-INSERT INTO clients (client_id, client_secret, client_name, access_token_ttl, allowed_scopes) 
+INSERT INTO clients (client_id, client_name, access_token_ttl, allowed_scopes) 
 VALUES (
     'test-client-1',
-    'secret-key-12345',
     'Test Client 1',
     3600,
     '["http://localhost:3000/api/users", "http://localhost:3000/api/posts"]'
 );
 
-INSERT INTO clients (client_id, client_secret, client_name, access_token_ttl, allowed_scopes) 
+INSERT INTO clients (client_id, client_name, access_token_ttl, allowed_scopes) 
 VALUES (
     'test-client-2',
-    'secret-key-67890',
     'Test Client 2',
     7200,
     '["http://localhost:3000/api/admin", "http://localhost:3000/api/reports"]'
 );
 
-INSERT INTO clients (client_id, client_secret, client_name, access_token_ttl, allowed_scopes) 
+INSERT INTO clients (client_id, client_name, access_token_ttl, allowed_scopes) 
 VALUES (
     'mobile-app',
-    'mobile-secret-key',
     'Mobile Application',
     1800,
     '["http://localhost:3000/api/auth", "http://localhost:3000/api/profile"]'
 );
 
+-- Sample secrets are plaintext; each is rehashed on the client's first successful login
+INSERT INTO client_secrets (client_id, secret_hash, label, is_primary)
+VALUES ('test-client-1', 'secret-key-12345', 'initial', 1);
+
+INSERT INTO client_secrets (client_id, secret_hash, label, is_primary)
+VALUES ('test-client-2', 'secret-key-67890', 'initial', 1);
+
+INSERT INTO client_secrets (client_id, secret_hash, label, is_primary)
+VALUES ('mobile-app', 'mobile-secret-key', 'initial', 1);
+
+-- Endpoint rules for test-client-1: users may be read and created but not deleted
+INSERT INTO endpoints (client_id, scope, method, endpoint_url, description)
+VALUES ('test-client-1', 'http://localhost:3000/api/users', 'GET', 'http://localhost:3000/api/users', 'List users');
+
+INSERT INTO endpoints (client_id, scope, method, endpoint_url, description)
+VALUES ('test-client-1', 'http://localhost:3000/api/users', 'POST', 'http://localhost:3000/api/users', 'Create user');
+
+INSERT INTO endpoints (client_id, scope, method, endpoint_url, description)
+VALUES ('test-client-1', 'http://localhost:3000/api/posts', '*', 'http://localhost:3000/api/posts', 'Any method on posts');
+
 -- Commit changes
 COMMIT;
 
 -- Display table information
-SELECT table_name FROM user_tables WHERE table_name IN ('CLIENTS', 'TOKENS', 'REVOKED_TOKENS', 'ENDPOINTS');
+SELECT table_name FROM user_tables WHERE table_name IN ('CLIENTS', 'TOKENS', 'REVOKED_TOKENS', 'TOKEN_DENYLIST', 'AUTH_EVENTS', 'CLIENT_REVOCATIONS', 'SIGNING_KEYS', 'ENDPOINTS', 'CLIENT_AUDIT', 'CLIENT_SECRETS', 'SCHEMA_MIGRATIONS');
@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// GinClaimsKey is the gin context key holding verified Claims
+const GinClaimsKey = "auth.claims"
+
+// Gin returns the verifier as gin middleware
+// Claims are available from GinClaims(c) and from ClaimsFromContext(c.Request.Context())
+func (v *Verifier) Gin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, err := v.check(c.Request)
+		if err != nil {
+			v.config.ErrorHandler(c.Writer, c.Request, err)
+			c.Abort()
+			return
+		}
+
+		c.Set(GinClaimsKey, claims)
+		c.Request = c.Request.WithContext(WithClaims(c.Request.Context(), claims))
+		c.Next()
+	}
+}
+
+// GinClaims returns the claims stored by the gin middleware
+func GinClaims(c *gin.Context) (*Claims, bool) {
+	value, exists := c.Get(GinClaimsKey)
+	if !exists {
+		return nil, false
+	}
+	claims, ok := value.(*Claims)
+	return claims, ok
+}
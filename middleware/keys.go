@@ -0,0 +1,187 @@
+package middleware
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/sync/singleflight"
+)
+
+// KeySource supplies the key a token's signature is checked against
+type KeySource interface {
+	Key(ctx context.Context, token *jwt.Token) (any, error)
+}
+
+// KeyFunc adapts a function to KeySource
+type KeyFunc func(ctx context.Context, token *jwt.Token) (any, error)
+
+// Key calls f
+func (f KeyFunc) Key(ctx context.Context, token *jwt.Token) (any, error) {
+	return f(ctx, token)
+}
+
+// HMACKey verifies HS256 tokens with the server's shared secret
+// Only use it for services trusted with the secret: anyone holding it can mint tokens
+func HMACKey(secret []byte) KeySource {
+	return KeyFunc(func(_ context.Context, token *jwt.Token) (any, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return secret, nil
+	})
+}
+
+const (
+	defaultJWKSTTL         = 5 * time.Minute
+	jwksMinRefreshInterval = 10 * time.Second
+)
+
+// JWKS verifies ES256 tokens with public keys fetched from the auth server's /jwks endpoint
+// Keys are cached and refetched after TTL, or early when a token names an unknown key,
+// at most once per jwksMinRefreshInterval
+type JWKS struct {
+	url        string
+	httpClient *http.Client
+	ttl        time.Duration
+
+	mu          sync.RWMutex
+	keys        map[string]*ecdsa.PublicKey
+	fetchedAt   time.Time
+	attemptedAt time.Time
+
+	group singleflight.Group
+}
+
+// NewJWKS creates a key source for the JWKS document at url; httpClient may be nil
+func NewJWKS(url string, httpClient *http.Client) *JWKS {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &JWKS{
+		url:        url,
+		httpClient: httpClient,
+		ttl:        defaultJWKSTTL,
+		keys:       make(map[string]*ecdsa.PublicKey),
+	}
+}
+
+// Key returns the public key named by the token's kid header
+func (j *JWKS) Key(ctx context.Context, token *jwt.Token) (any, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodECDSA); !ok {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		return nil, errors.New("token has no kid header")
+	}
+
+	key, fresh, canRefresh := j.lookup(kid)
+	if key != nil && (fresh || !canRefresh) {
+		// A stale key is served between refetches, which are throttled like unknown keys
+		return key, nil
+	}
+	if key == nil && !canRefresh {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	if err := j.Refresh(ctx); err != nil {
+		// A stale key is still better than failing every request while the server is unreachable
+		if key != nil {
+			return key, nil
+		}
+		return nil, err
+	}
+
+	if key, _, _ = j.lookup(kid); key == nil {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	return key, nil
+}
+
+// lookup returns a cached key, whether the cache is within its TTL, and whether a refetch is allowed now
+func (j *JWKS) lookup(kid string) (*ecdsa.PublicKey, bool, bool) {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	now := time.Now()
+	return j.keys[kid], now.Sub(j.fetchedAt) < j.ttl, now.Sub(j.attemptedAt) >= jwksMinRefreshInterval
+}
+
+// Refresh fetches the JWKS document, sharing the request with concurrent callers
+func (j *JWKS) Refresh(ctx context.Context) error {
+	_, err, _ := j.group.Do("refresh", func() (any, error) {
+		j.mu.Lock()
+		j.attemptedAt = time.Now()
+		j.mu.Unlock()
+
+		keys, err := j.fetch(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		j.mu.Lock()
+		j.keys = keys
+		j.fetchedAt = time.Now()
+		j.mu.Unlock()
+		return nil, nil
+	})
+	return err
+}
+
+// fetch downloads and parses the JWKS document
+func (j *JWKS) fetch(ctx context.Context) (map[string]*ecdsa.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, j.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := j.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching JWKS: status %d", resp.StatusCode)
+	}
+
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Crv string `json:"crv"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+			Kid string `json:"kid"`
+		} `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, fmt.Errorf("decoding JWKS: %w", err)
+	}
+
+	keys := make(map[string]*ecdsa.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		// Only the server's own key type; anything else is ignored rather than failing the whole set
+		if jwk.Kty != "EC" || jwk.Crv != "P-256" || jwk.Kid == "" {
+			continue
+		}
+		x, errX := base64.RawURLEncoding.DecodeString(jwk.X)
+		y, errY := base64.RawURLEncoding.DecodeString(jwk.Y)
+		if errX != nil || errY != nil || len(x) != 32 || len(y) != 32 {
+			continue
+		}
+		key, err := ecdsa.ParseUncompressedPublicKey(elliptic.P256(), append(append([]byte{4}, x...), y...))
+		if err != nil {
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+	return keys, nil
+}
@@ -0,0 +1,238 @@
+// Package middleware verifies auth server tokens inside resource servers.
+//
+// A Verifier checks a bearer token's signature against the auth server's key
+// ring, its exp, nbf, iss and aud claims, and optionally the scopes it grants,
+// without a /validate round trip per request. Verified Claims are placed in the
+// request context:
+//
+//	v, err := middleware.New(middleware.Config{
+//		Keys:          middleware.NewJWKS("http://auth-server:8080/auth-server/v1/oauth/jwks", nil),
+//		Audience:      "orders-api",
+//		MatchResource: true,
+//	})
+//	http.Handle("/api/", v.Handler(apiHandler))
+//
+//	func apiHandler(w http.ResponseWriter, r *http.Request) {
+//		claims, _ := middleware.ClaimsFromContext(r.Context())
+//		...
+//	}
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"auth-server/scope"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// DefaultIssuer is the iss claim the auth server puts in its tokens
+const DefaultIssuer = "auth-server"
+
+// Claims are the auth server's token claims
+type Claims struct {
+	ClientID string   `json:"client_id"`
+	TokenID  string   `json:"token_id"`
+	Scope    []string `json:"scope"`
+	jwt.RegisteredClaims
+}
+
+// Verification failures; Handler maps ErrInsufficientScope to 403 and the others to 401
+var (
+	ErrMissingToken      = errors.New("missing bearer token")
+	ErrInvalidToken      = errors.New("invalid or expired token")
+	ErrRevokedToken      = errors.New("token has been revoked")
+	ErrInsufficientScope = errors.New("token does not grant access to this resource")
+)
+
+// Config configures a Verifier
+type Config struct {
+	// Keys verifies token signatures, usually NewJWKS pointed at the auth server
+	Keys KeySource
+	// Issuer is the required iss claim; defaults to DefaultIssuer
+	Issuer string
+	// Audience, when set, must appear in the token's aud claim
+	Audience string
+	// Leeway tolerates clock skew when checking exp and nbf
+	Leeway time.Duration
+
+	// RequiredScopes must all be granted to the token
+	RequiredScopes []string
+	// MatchResource requires one of the token's scopes, read as a URL or path template,
+	// to match the request URL, as the auth server's /validate does
+	MatchResource bool
+	// IgnoreQuery drops query strings when matching resources
+	IgnoreQuery bool
+	// ResourceURL builds the URL matched against token scopes; defaults to the request's own URL
+	ResourceURL func(r *http.Request) string
+
+	// Revocations, when set, rejects tokens revoked since they were issued
+	Revocations *RevocationSubscriber
+
+	// ErrorHandler writes verification failures; defaults to a JSON error in the auth server's format
+	ErrorHandler func(w http.ResponseWriter, r *http.Request, err error)
+}
+
+// Verifier checks bearer tokens; it is safe for concurrent use
+type Verifier struct {
+	config Config
+	parser *jwt.Parser
+}
+
+// New creates a verifier from config
+func New(config Config) (*Verifier, error) {
+	if config.Keys == nil {
+		return nil, errors.New("middleware: Keys is required")
+	}
+	if config.Issuer == "" {
+		config.Issuer = DefaultIssuer
+	}
+	if config.ResourceURL == nil {
+		config.ResourceURL = requestURL
+	}
+	if config.ErrorHandler == nil {
+		config.ErrorHandler = writeError
+	}
+
+	options := []jwt.ParserOption{
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuer(config.Issuer),
+		jwt.WithLeeway(config.Leeway),
+		jwt.WithValidMethods([]string{jwt.SigningMethodES256.Alg(), jwt.SigningMethodHS256.Alg()}),
+	}
+	if config.Audience != "" {
+		options = append(options, jwt.WithAudience(config.Audience))
+	}
+
+	return &Verifier{
+		config: config,
+		parser: jwt.NewParser(options...),
+	}, nil
+}
+
+// Verify checks a raw token's signature, registered claims and revocation status
+func (v *Verifier) Verify(ctx context.Context, tokenString string) (*Claims, error) {
+	claims := &Claims{}
+	_, err := v.parser.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (any, error) {
+		return v.config.Keys.Key(ctx, token)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+
+	if v.config.Revocations != nil && v.config.Revocations.Revoked(claims) {
+		return nil, ErrRevokedToken
+	}
+	return claims, nil
+}
+
+// Authorize checks claims against the configured scope rules for r
+func (v *Verifier) Authorize(r *http.Request, claims *Claims) error {
+	for _, required := range v.config.RequiredScopes {
+		if !slices.Contains(claims.Scope, required) {
+			return fmt.Errorf("%w: missing scope %q", ErrInsufficientScope, required)
+		}
+	}
+
+	if v.config.MatchResource {
+		matcher, err := scope.Compile(claims.Scope, scope.Options{IgnoreQuery: v.config.IgnoreQuery})
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrInsufficientScope, err)
+		}
+		if !matcher.Matches(v.config.ResourceURL(r)) {
+			return ErrInsufficientScope
+		}
+	}
+	return nil
+}
+
+// Handler verifies and authorizes each request before calling next with the claims in its context
+func (v *Verifier) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, err := v.check(r)
+		if err != nil {
+			v.config.ErrorHandler(w, r, err)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(WithClaims(r.Context(), claims)))
+	})
+}
+
+// check runs the full verification for a request
+func (v *Verifier) check(r *http.Request) (*Claims, error) {
+	tokenString, ok := bearerToken(r)
+	if !ok {
+		return nil, ErrMissingToken
+	}
+	claims, err := v.Verify(r.Context(), tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if err := v.Authorize(r, claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+type claimsContextKey struct{}
+
+// WithClaims returns a context carrying claims
+func WithClaims(ctx context.Context, claims *Claims) context.Context {
+	return context.WithValue(ctx, claimsContextKey{}, claims)
+}
+
+// ClaimsFromContext returns the claims placed in ctx by the middleware
+func ClaimsFromContext(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value(claimsContextKey{}).(*Claims)
+	return claims, ok
+}
+
+// bearerToken extracts the token from the Authorization header
+func bearerToken(r *http.Request) (string, bool) {
+	authHeader := r.Header.Get("Authorization")
+	tokenString, found := strings.CutPrefix(authHeader, "Bearer ")
+	return tokenString, found && tokenString != ""
+}
+
+// requestURL reconstructs the URL the client called, honouring X-Forwarded-Proto from a fronting proxy
+func requestURL(r *http.Request) string {
+	proto := r.Header.Get("X-Forwarded-Proto")
+	if proto == "" {
+		proto = "http"
+		if r.TLS != nil {
+			proto = "https"
+		}
+	}
+	return proto + "://" + r.Host + r.URL.RequestURI()
+}
+
+// statusFor maps a verification error to its HTTP status and OAuth error code
+func statusFor(err error) (int, string) {
+	if errors.Is(err, ErrInsufficientScope) {
+		return http.StatusForbidden, "insufficient_scope"
+	}
+	return http.StatusUnauthorized, "invalid_token"
+}
+
+// writeError responds like the auth server, with a Bearer challenge per RFC 6750
+func writeError(w http.ResponseWriter, _ *http.Request, err error) {
+	status, code := statusFor(err)
+	if errors.Is(err, ErrMissingToken) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+	} else {
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf("Bearer error=%q", code))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error":             code,
+		"error_description": err.Error(),
+	})
+}
@@ -0,0 +1,339 @@
+package middleware
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// testKeyServer publishes a JWKS document for keys that can be added during a test
+type testKeyServer struct {
+	*httptest.Server
+	mu   sync.Mutex
+	keys map[string]*ecdsa.PrivateKey
+}
+
+func newTestKeyServer(t *testing.T) *testKeyServer {
+	t.Helper()
+
+	ks := &testKeyServer{keys: make(map[string]*ecdsa.PrivateKey)}
+	ks.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ks.mu.Lock()
+		defer ks.mu.Unlock()
+
+		var set struct {
+			Keys []map[string]string `json:"keys"`
+		}
+		for kid, key := range ks.keys {
+			point, _ := key.PublicKey.Bytes()
+			set.Keys = append(set.Keys, map[string]string{
+				"kty": "EC", "crv": "P-256", "kid": kid, "alg": "ES256", "use": "sig",
+				"x": base64.RawURLEncoding.EncodeToString(point[1:33]),
+				"y": base64.RawURLEncoding.EncodeToString(point[33:]),
+			})
+		}
+		json.NewEncoder(w).Encode(set)
+	}))
+	t.Cleanup(ks.Close)
+	return ks
+}
+
+func (ks *testKeyServer) addKey(t *testing.T, kid string) *ecdsa.PrivateKey {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	ks.mu.Lock()
+	ks.keys[kid] = key
+	ks.mu.Unlock()
+	return key
+}
+
+func signTestToken(t *testing.T, key *ecdsa.PrivateKey, kid string, claims Claims) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("Failed to sign token: %v", err)
+	}
+	return signed
+}
+
+func testClaims(scopes ...string) Claims {
+	now := time.Now()
+	return Claims{
+		ClientID: "client-1",
+		TokenID:  "jti-1",
+		Scope:    scopes,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    DefaultIssuer,
+			Audience:  jwt.ClaimStrings{"orders-api"},
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(2 * time.Minute)),
+		},
+	}
+}
+
+func serve(t *testing.T, handler http.Handler, path, token string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest("GET", "http://api.example.com"+path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+	return recorder
+}
+
+var echoClientID = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	claims, ok := ClaimsFromContext(r.Context())
+	if !ok {
+		http.Error(w, "no claims", http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprint(w, claims.ClientID)
+})
+
+func TestVerifier_Handler(t *testing.T) {
+	ks := newTestKeyServer(t)
+	key := ks.addKey(t, "key-1")
+
+	v, err := New(Config{Keys: NewJWKS(ks.URL, nil), Audience: "orders-api"})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	handler := v.Handler(echoClientID)
+
+	expired := testClaims()
+	expired.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+	notYet := testClaims()
+	notYet.NotBefore = jwt.NewNumericDate(time.Now().Add(time.Hour))
+	otherAudience := testClaims()
+	otherAudience.Audience = jwt.ClaimStrings{"billing-api"}
+
+	tests := []struct {
+		name       string
+		token      string
+		wantStatus int
+	}{
+		{"valid", signTestToken(t, key, "key-1", testClaims()), http.StatusOK},
+		{"missing token", "", http.StatusUnauthorized},
+		{"garbage", "not-a-jwt", http.StatusUnauthorized},
+		{"expired", signTestToken(t, key, "key-1", expired), http.StatusUnauthorized},
+		{"not yet valid", signTestToken(t, key, "key-1", notYet), http.StatusUnauthorized},
+		{"wrong audience", signTestToken(t, key, "key-1", otherAudience), http.StatusUnauthorized},
+		{"unknown key", signTestToken(t, key, "key-2", testClaims()), http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := serve(t, handler, "/api/orders", tt.token)
+			if recorder.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.wantStatus, recorder.Code, recorder.Body.String())
+			}
+			if tt.wantStatus == http.StatusOK && recorder.Body.String() != "client-1" {
+				t.Errorf("Expected claims in context, got %q", recorder.Body.String())
+			}
+			if tt.wantStatus == http.StatusUnauthorized && recorder.Header().Get("WWW-Authenticate") == "" {
+				t.Error("Expected WWW-Authenticate challenge")
+			}
+		})
+	}
+}
+
+func TestJWKS_RefreshesOnUnknownKey(t *testing.T) {
+	ks := newTestKeyServer(t)
+	key1 := ks.addKey(t, "key-1")
+
+	v, _ := New(Config{Keys: NewJWKS(ks.URL, nil)})
+	handler := v.Handler(echoClientID)
+
+	if recorder := serve(t, handler, "/", signTestToken(t, key1, "key-1", testClaims())); recorder.Code != http.StatusOK {
+		t.Fatalf("Expected 200 for first key, got %d", recorder.Code)
+	}
+
+	// A rotated key is picked up without waiting for the cache TTL
+	key2 := ks.addKey(t, "key-2")
+	v.config.Keys.(*JWKS).mu.Lock()
+	v.config.Keys.(*JWKS).attemptedAt = time.Time{}
+	v.config.Keys.(*JWKS).mu.Unlock()
+
+	if recorder := serve(t, handler, "/", signTestToken(t, key2, "key-2", testClaims())); recorder.Code != http.StatusOK {
+		t.Errorf("Expected 200 for rotated key, got %d", recorder.Code)
+	}
+}
+
+func TestJWKS_ThrottlesStaleRefresh(t *testing.T) {
+	var fetches atomic.Int32
+	unreachable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer unreachable.Close()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	jwks := NewJWKS(unreachable.URL, nil)
+	jwks.keys["key-1"] = &key.PublicKey
+	jwks.fetchedAt = time.Now().Add(-time.Hour)
+
+	v, _ := New(Config{Keys: jwks})
+	handler := v.Handler(echoClientID)
+
+	// The stale key keeps verifying, and only the first request waits for a refetch
+	for range 5 {
+		if recorder := serve(t, handler, "/", signTestToken(t, key, "key-1", testClaims())); recorder.Code != http.StatusOK {
+			t.Fatalf("Expected 200 with a stale key, got %d", recorder.Code)
+		}
+	}
+	if got := fetches.Load(); got != 1 {
+		t.Errorf("Expected 1 refetch within the refresh interval, got %d", got)
+	}
+}
+
+func TestVerifier_ScopeRules(t *testing.T) {
+	ks := newTestKeyServer(t)
+	key := ks.addKey(t, "key-1")
+	token := signTestToken(t, key, "key-1", testClaims("orders:read", "/api/orders/{id}", "http://api.example.com/api/reports/**"))
+
+	t.Run("required scopes", func(t *testing.T) {
+		v, _ := New(Config{Keys: NewJWKS(ks.URL, nil), RequiredScopes: []string{"orders:read"}})
+		if recorder := serve(t, v.Handler(echoClientID), "/anything", token); recorder.Code != http.StatusOK {
+			t.Errorf("Expected 200, got %d", recorder.Code)
+		}
+
+		v, _ = New(Config{Keys: NewJWKS(ks.URL, nil), RequiredScopes: []string{"orders:write"}})
+		if recorder := serve(t, v.Handler(echoClientID), "/anything", token); recorder.Code != http.StatusForbidden {
+			t.Errorf("Expected 403 for missing scope, got %d", recorder.Code)
+		}
+	})
+
+	t.Run("path templates", func(t *testing.T) {
+		v, _ := New(Config{Keys: NewJWKS(ks.URL, nil), MatchResource: true})
+		handler := v.Handler(echoClientID)
+
+		for path, want := range map[string]int{
+			"/api/orders/42":          http.StatusOK,
+			"/api/reports/2024/q1":    http.StatusOK,
+			"/api/orders/42/items":    http.StatusForbidden,
+			"/api/orders/../admin/1":  http.StatusForbidden,
+			"/api/customers/7":        http.StatusForbidden,
+			"/api/orders/42?expand=1": http.StatusForbidden,
+		} {
+			if recorder := serve(t, handler, path, token); recorder.Code != want {
+				t.Errorf("%s: expected %d, got %d", path, want, recorder.Code)
+			}
+		}
+	})
+}
+
+func TestHMACKey(t *testing.T) {
+	secret := []byte("test-secret")
+	signed, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, testClaims()).SignedString(secret)
+
+	v, _ := New(Config{Keys: HMACKey(secret)})
+	if _, err := v.Verify(context.Background(), signed); err != nil {
+		t.Errorf("Expected HS256 token to verify, got %v", err)
+	}
+
+	v, _ = New(Config{Keys: HMACKey([]byte("other-secret"))})
+	if _, err := v.Verify(context.Background(), signed); err == nil {
+		t.Error("Expected verification with the wrong secret to fail")
+	}
+}
+
+func TestVerifier_Gin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	ks := newTestKeyServer(t)
+	key := ks.addKey(t, "key-1")
+	v, _ := New(Config{Keys: NewJWKS(ks.URL, nil)})
+
+	router := gin.New()
+	router.Use(v.Gin())
+	router.GET("/api/orders", func(c *gin.Context) {
+		claims, ok := GinClaims(c)
+		if !ok {
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+		c.String(http.StatusOK, claims.TokenID)
+	})
+
+	if recorder := serve(t, router, "/api/orders", ""); recorder.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without token, got %d", recorder.Code)
+	}
+
+	recorder := serve(t, router, "/api/orders", signTestToken(t, key, "key-1", testClaims()))
+	if recorder.Code != http.StatusOK || recorder.Body.String() != "jti-1" {
+		t.Errorf("Expected 200 with claims, got %d %q", recorder.Code, recorder.Body.String())
+	}
+}
+
+func TestRevocationSubscriber(t *testing.T) {
+	revokedBefore := time.Now().Add(time.Second)
+	stream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Last-Event-ID") != "0" {
+			// Reconnects just hold the stream open
+			<-r.Context().Done()
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, ": keep-alive\n\n")
+		fmt.Fprintf(w, "id: 1\nevent: revocation\ndata: {\"id\":1,\"kind\":\"token\",\"client_id\":\"client-2\",\"token_id\":\"jti-revoked\",\"expires_at\":%q}\n\n",
+			time.Now().Add(time.Minute).Format(time.RFC3339Nano))
+		fmt.Fprintf(w, "id: 2\nevent: revocation\ndata: {\"id\":2,\"kind\":\"client\",\"client_id\":\"client-3\",\"revoked_before\":%q}\n\n",
+			revokedBefore.Format(time.RFC3339Nano))
+	}))
+	defer stream.Close()
+
+	subscriber := NewRevocationSubscriber(stream.URL, stream.Client())
+	subscriber.Start(context.Background())
+	defer subscriber.Close()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for subscriber.LastEventID() < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if subscriber.LastEventID() != 2 {
+		t.Fatalf("Expected both events to be applied, last event ID %d", subscriber.LastEventID())
+	}
+
+	issued := func(clientID, tokenID string, at time.Time) *Claims {
+		return &Claims{ClientID: clientID, TokenID: tokenID, RegisteredClaims: jwt.RegisteredClaims{IssuedAt: jwt.NewNumericDate(at)}}
+	}
+
+	if !subscriber.Revoked(issued("client-2", "jti-revoked", time.Now())) {
+		t.Error("Expected revoked token to be rejected")
+	}
+	if subscriber.Revoked(issued("client-2", "jti-other", time.Now())) {
+		t.Error("Expected other token of the same client to pass")
+	}
+	if !subscriber.Revoked(issued("client-3", "jti-old", time.Now().Add(-time.Minute))) {
+		t.Error("Expected token issued before the client watermark to be rejected")
+	}
+	if subscriber.Revoked(issued("client-3", "jti-new", revokedBefore.Add(2*time.Second))) {
+		t.Error("Expected token issued after the client watermark to pass")
+	}
+}
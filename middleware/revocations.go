@@ -0,0 +1,227 @@
+package middleware
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	minReconnectDelay = time.Second
+	maxReconnectDelay = 30 * time.Second
+	purgeInterval     = time.Minute
+)
+
+// revocationNotice mirrors the auth server's revocation feed entries
+type revocationNotice struct {
+	ID            int64      `json:"id"`
+	Kind          string     `json:"kind"`
+	ClientID      string     `json:"client_id"`
+	TokenID       string     `json:"token_id,omitempty"`
+	ExpiresAt     *time.Time `json:"expires_at,omitempty"`
+	RevokedBefore *time.Time `json:"revoked_before,omitempty"`
+}
+
+// RevocationSubscriber follows the auth server's revocation stream and remembers what was revoked
+// The stream requires a token with the revocations:read scope, so give it an authenticated client:
+//
+//	ts := authClient.TokenSource()
+//	subscriber := middleware.NewRevocationSubscriber(
+//		"http://auth-server:8080/auth-server/v1/oauth/revocations/stream",
+//		&http.Client{Transport: &client.Transport{Source: ts}},
+//	)
+//	subscriber.Start(ctx)
+type RevocationSubscriber struct {
+	streamURL  string
+	httpClient *http.Client
+
+	mu          sync.RWMutex
+	tokens      map[string]time.Time // token ID -> token expiry
+	clients     map[string]time.Time // client ID -> revoked-before watermark
+	lastEventID int64
+
+	// OnError is called when the stream fails; the subscriber reconnects on its own
+	OnError func(err error)
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewRevocationSubscriber creates a subscriber for the stream at streamURL
+// httpClient must authenticate its requests and must not set an overall Timeout
+func NewRevocationSubscriber(streamURL string, httpClient *http.Client) *RevocationSubscriber {
+	return &RevocationSubscriber{
+		streamURL:  streamURL,
+		httpClient: httpClient,
+		tokens:     make(map[string]time.Time),
+		clients:    make(map[string]time.Time),
+	}
+}
+
+// Start follows the stream in the background until ctx ends or Close is called
+func (s *RevocationSubscriber) Start(ctx context.Context) {
+	ctx, s.cancel = context.WithCancel(ctx)
+	s.wg.Add(2)
+	go s.run(ctx)
+	go s.purgeLoop(ctx)
+}
+
+// Close stops following the stream
+func (s *RevocationSubscriber) Close() {
+	if s.cancel == nil {
+		return
+	}
+	s.cancel()
+	s.wg.Wait()
+}
+
+// Revoked reports whether claims belong to a revoked token
+func (s *RevocationSubscriber) Revoked(claims *Claims) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, revoked := s.tokens[claims.TokenID]; revoked {
+		return true
+	}
+	revokedBefore, exists := s.clients[claims.ClientID]
+	if !exists || claims.IssuedAt == nil {
+		return false
+	}
+	// iat has second precision, matching the server's watermark check
+	return !claims.IssuedAt.Time.After(revokedBefore.Truncate(time.Second))
+}
+
+// LastEventID returns the position of the last applied revocation
+func (s *RevocationSubscriber) LastEventID() int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.lastEventID
+}
+
+// run reconnects with backoff, resuming from the last applied event
+func (s *RevocationSubscriber) run(ctx context.Context) {
+	defer s.wg.Done()
+
+	delay := minReconnectDelay
+	for {
+		applied, err := s.follow(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil && s.OnError != nil {
+			s.OnError(err)
+		}
+		if applied {
+			delay = minReconnectDelay
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, maxReconnectDelay)
+	}
+}
+
+// purgeLoop periodically forgets revoked tokens that have expired anyway
+func (s *RevocationSubscriber) purgeLoop(ctx context.Context) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(purgeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.purgeExpired()
+		}
+	}
+}
+
+// follow reads the stream until it ends, reporting whether any event was applied
+func (s *RevocationSubscriber) follow(ctx context.Context) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.streamURL, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Last-Event-ID", strconv.FormatInt(s.LastEventID(), 10))
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("revocation stream: status %d", resp.StatusCode)
+	}
+
+	applied := false
+	var data strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			// Blank line ends an event
+			if data.Len() > 0 {
+				var notice revocationNotice
+				if err := json.Unmarshal([]byte(data.String()), &notice); err != nil {
+					return applied, fmt.Errorf("revocation stream: %w", err)
+				}
+				s.apply(notice)
+				applied = true
+				data.Reset()
+			}
+		case strings.HasPrefix(line, "data:"):
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+		// id: duplicates the notice ID, event: is always "revocation", and ":" lines are keep-alives
+	}
+	return applied, scanner.Err()
+}
+
+// apply records a revocation notice
+func (s *RevocationSubscriber) apply(notice revocationNotice) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case notice.TokenID != "":
+		expiresAt := time.Now().Add(24 * time.Hour)
+		if notice.ExpiresAt != nil {
+			expiresAt = *notice.ExpiresAt
+		}
+		s.tokens[notice.TokenID] = expiresAt
+	case notice.RevokedBefore != nil:
+		if current, exists := s.clients[notice.ClientID]; !exists || notice.RevokedBefore.After(current) {
+			s.clients[notice.ClientID] = *notice.RevokedBefore
+		}
+	}
+	if notice.ID > s.lastEventID {
+		s.lastEventID = notice.ID
+	}
+}
+
+// purgeExpired drops revoked tokens past their expiry
+func (s *RevocationSubscriber) purgeExpired() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for tokenID, expiresAt := range s.tokens {
+		if now.After(expiresAt) {
+			delete(s.tokens, tokenID)
+		}
+	}
+}
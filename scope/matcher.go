@@ -0,0 +1,239 @@
+// Package scope matches request URLs against scope patterns.
+//
+// A pattern is either a full URL ("http://localhost:3000/api/users/{id}") or a
+// path that matches on any host ("/api/users/{id}"). Path segments may be:
+//
+//   - a literal, compared exactly
+//   - "{name}" or "*", matching exactly one segment
+//   - "**" as the final segment, matching zero or more remaining segments
+//
+// Hosts are normalized (lowercased, default ports dropped) and paths are cleaned
+// before matching, so "/api/users/../admin" cannot match "/api/users/**".
+// Scopes that are neither URLs nor paths (such as "revocations:read") are not
+// resource patterns and are skipped at compile time.
+package scope
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// Options control how patterns and request URLs are compared
+type Options struct {
+	// IgnoreQuery drops the query string from both patterns and request URLs
+	// When false, a pattern with a query only matches that exact query and a pattern without one only matches URLs without one
+	IgnoreQuery bool
+}
+
+// Matcher is an immutable set of compiled patterns, safe for concurrent use
+type Matcher struct {
+	options Options
+	// hosts maps "scheme://host" to its path trie; "" holds host-less patterns
+	hosts map[string]*node
+	size  int
+}
+
+type node struct {
+	literals map[string]*node
+	param    *node
+	leaves   []leaf // patterns ending at this node
+	catchAll []leaf // patterns ending with "**" below this node
+}
+
+type leaf struct {
+	pattern string
+	query   string
+}
+
+// Compile builds a matcher from patterns, failing on the first malformed pattern
+func Compile(patterns []string, options Options) (*Matcher, error) {
+	m := &Matcher{
+		options: options,
+		hosts:   make(map[string]*node),
+	}
+	for _, pattern := range patterns {
+		if !IsResourcePattern(pattern) {
+			continue
+		}
+		if err := m.add(pattern); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// MustCompile is like Compile but panics on error, for patterns known at build time
+func MustCompile(patterns []string, options Options) *Matcher {
+	m, err := Compile(patterns, options)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// Len returns the number of compiled patterns
+func (m *Matcher) Len() int {
+	return m.size
+}
+
+// Matches reports whether any pattern matches rawURL
+func (m *Matcher) Matches(rawURL string) bool {
+	return len(m.Match(rawURL)) > 0
+}
+
+// Match returns every pattern that matches rawURL, as originally written
+func (m *Matcher) Match(rawURL string) []string {
+	hostKey, segments, query, err := m.split(rawURL)
+	if err != nil {
+		return nil
+	}
+
+	var matched []string
+	collect := func(leaves []leaf) {
+		for _, l := range leaves {
+			if m.options.IgnoreQuery || l.query == query {
+				matched = append(matched, l.pattern)
+			}
+		}
+	}
+
+	if root, exists := m.hosts[hostKey]; exists && hostKey != "" {
+		walk(root, segments, collect)
+	}
+	if root, exists := m.hosts[""]; exists {
+		walk(root, segments, collect)
+	}
+	return matched
+}
+
+// walk visits every trie path matching segments, reporting the leaves reached
+func walk(n *node, segments []string, collect func([]leaf)) {
+	collect(n.catchAll)
+	if len(segments) == 0 {
+		collect(n.leaves)
+		return
+	}
+
+	if child, exists := n.literals[segments[0]]; exists {
+		walk(child, segments[1:], collect)
+	}
+	if n.param != nil {
+		walk(n.param, segments[1:], collect)
+	}
+}
+
+// add compiles a single pattern into the trie
+func (m *Matcher) add(pattern string) error {
+	hostKey, segments, query, err := m.split(pattern)
+	if err != nil {
+		return fmt.Errorf("invalid scope pattern %q: %w", pattern, err)
+	}
+
+	root, exists := m.hosts[hostKey]
+	if !exists {
+		root = newNode()
+		m.hosts[hostKey] = root
+	}
+
+	n := root
+	for i, segment := range segments {
+		switch {
+		case segment == "**":
+			if i != len(segments)-1 {
+				return fmt.Errorf("invalid scope pattern %q: ** must be the last segment", pattern)
+			}
+			n.catchAll = append(n.catchAll, leaf{pattern: pattern, query: query})
+			m.size++
+			return nil
+		case segment == "*" || isParam(segment):
+			if n.param == nil {
+				n.param = newNode()
+			}
+			n = n.param
+		case strings.ContainsAny(segment, "{}*"):
+			return fmt.Errorf("invalid scope pattern %q: segment %q mixes literal and wildcard", pattern, segment)
+		default:
+			child, exists := n.literals[segment]
+			if !exists {
+				child = newNode()
+				n.literals[segment] = child
+			}
+			n = child
+		}
+	}
+
+	n.leaves = append(n.leaves, leaf{pattern: pattern, query: query})
+	m.size++
+	return nil
+}
+
+// split normalizes a URL or pattern into its host key, cleaned path segments and query
+func (m *Matcher) split(raw string) (string, []string, string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", nil, "", fmt.Errorf("empty URL")
+	}
+
+	// Keep template braces out of the URL parser's way
+	u, err := url.Parse(strings.NewReplacer("{", "%7B", "}", "%7D").Replace(raw))
+	if err != nil {
+		return "", nil, "", err
+	}
+
+	hostKey := ""
+	if u.Host != "" {
+		hostKey = NormalizeHost(u.Scheme, u.Host)
+	} else if u.Scheme != "" {
+		return "", nil, "", fmt.Errorf("URL has a scheme but no host")
+	}
+
+	p := u.Path
+	if p == "" {
+		p = "/"
+	}
+	p = path.Clean("/" + p)
+
+	var segments []string
+	for _, segment := range strings.Split(p, "/") {
+		if segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+
+	query := ""
+	if !m.options.IgnoreQuery {
+		query = u.RawQuery
+	}
+	return hostKey, segments, query, nil
+}
+
+// IsResourcePattern reports whether a scope names a resource (a URL or an absolute path)
+func IsResourcePattern(scope string) bool {
+	scope = strings.TrimSpace(scope)
+	return strings.HasPrefix(scope, "/") || strings.Contains(scope, "://")
+}
+
+// NormalizeHost lowercases scheme and host and drops the scheme's default port
+func NormalizeHost(scheme, host string) string {
+	scheme = strings.ToLower(scheme)
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+
+	switch {
+	case scheme == "http" && strings.HasSuffix(host, ":80"):
+		host = strings.TrimSuffix(host, ":80")
+	case scheme == "https" && strings.HasSuffix(host, ":443"):
+		host = strings.TrimSuffix(host, ":443")
+	}
+	return scheme + "://" + host
+}
+
+func isParam(segment string) bool {
+	return len(segment) > 2 && strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") &&
+		!strings.ContainsAny(segment[1:len(segment)-1], "{}/")
+}
+
+func newNode() *node {
+	return &node{literals: make(map[string]*node)}
+}
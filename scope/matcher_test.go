@@ -0,0 +1,120 @@
+package scope
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestMatcher_Match(t *testing.T) {
+	m := MustCompile([]string{
+		"http://localhost:3000/api/users",
+		"http://localhost:3000/api/users/{id}",
+		"http://localhost:3000/api/reports/**",
+		"/health",
+		"https://API.example.com:443/v1/*/items",
+	}, Options{})
+
+	tests := []struct {
+		name    string
+		url     string
+		matches bool
+	}{
+		{"exact", "http://localhost:3000/api/users", true},
+		{"trailing slash", "http://localhost:3000/api/users/", true},
+		{"template", "http://localhost:3000/api/users/42", true},
+		{"template too deep", "http://localhost:3000/api/users/42/posts", false},
+		{"prefix wildcard root", "http://localhost:3000/api/reports", true},
+		{"prefix wildcard deep", "http://localhost:3000/api/reports/2024/q1", true},
+		{"host-less pattern", "http://anything.internal:8080/health", true},
+		{"host normalization", "https://api.example.com/v1/store/items", true},
+		{"wrong host", "http://evil.example.com:3000/api/users", false},
+		{"wrong scheme", "https://localhost:3000/api/users", false},
+		{"dot segments cleaned", "http://localhost:3000/api/reports/../users/1/../../admin", false},
+		{"query not ignored", "http://localhost:3000/api/users?page=2", false},
+		{"garbage", "::not a url", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := m.Matches(test.url); got != test.matches {
+				t.Errorf("Matches(%q) = %v, want %v", test.url, got, test.matches)
+			}
+		})
+	}
+}
+
+func TestMatcher_IgnoreQuery(t *testing.T) {
+	m := MustCompile([]string{"http://localhost:3000/api/users/{id}"}, Options{IgnoreQuery: true})
+
+	if !m.Matches("http://localhost:3000/api/users/42?fields=name") {
+		t.Errorf("Expected query string to be ignored")
+	}
+}
+
+func TestMatcher_QueryInPattern(t *testing.T) {
+	m := MustCompile([]string{"http://localhost:3000/api/search?type=user"}, Options{})
+
+	if !m.Matches("http://localhost:3000/api/search?type=user") {
+		t.Errorf("Expected exact query to match")
+	}
+	if m.Matches("http://localhost:3000/api/search?type=admin") {
+		t.Errorf("Expected different query not to match")
+	}
+}
+
+func TestMatcher_ReturnsAllMatchingPatterns(t *testing.T) {
+	m := MustCompile([]string{"/api/users/{id}", "/api/**", "/api/users/me"}, Options{})
+
+	got := m.Match("http://localhost/api/users/me")
+	slices.Sort(got)
+	want := []string{"/api/**", "/api/users/me", "/api/users/{id}"}
+	if !slices.Equal(got, want) {
+		t.Errorf("Match() = %v, want %v", got, want)
+	}
+}
+
+func TestCompile_SkipsNonResourceScopes(t *testing.T) {
+	m, err := Compile([]string{"revocations:read", "read", "/api/users"}, Options{})
+	if err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+	if m.Len() != 1 {
+		t.Errorf("Expected 1 compiled pattern, got %d", m.Len())
+	}
+}
+
+func TestCompile_InvalidPatterns(t *testing.T) {
+	for _, pattern := range []string{"/api/**/users", "/api/user{id}", "/api/{id"} {
+		if _, err := Compile([]string{pattern}, Options{}); err == nil {
+			t.Errorf("Expected error for pattern %q", pattern)
+		}
+	}
+}
+
+func TestNormalizeHost(t *testing.T) {
+	tests := map[string][2]string{
+		"http://example.com":      {"HTTP", "Example.COM:80"},
+		"https://example.com":     {"https", "example.com:443"},
+		"http://example.com:8080": {"http", "example.com:8080"},
+		"https://example.com:80":  {"https", "example.com:80"},
+	}
+	for want, in := range tests {
+		if got := NormalizeHost(in[0], in[1]); got != want {
+			t.Errorf("NormalizeHost(%q, %q) = %q, want %q", in[0], in[1], got, want)
+		}
+	}
+}
+
+func BenchmarkMatcher_HundredsOfPatterns(b *testing.B) {
+	patterns := make([]string, 0, 500)
+	for i := 0; i < 500; i++ {
+		patterns = append(patterns, "http://localhost:3000/api/resource"+string(rune('a'+i%26))+"/"+string(rune('a'+i/26))+"/{id}")
+	}
+	m := MustCompile(patterns, Options{})
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m.Matches("http://localhost:3000/api/resourcez/s/42")
+	}
+}